@@ -0,0 +1,124 @@
+package async
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type JobStatus string
+
+const (
+	JobPending   JobStatus = "pending"
+	JobRunning   JobStatus = "running"
+	JobSucceeded JobStatus = "succeeded"
+	JobFailed    JobStatus = "failed"
+)
+
+// Job ids are the Seldon-Puid of the request so they are restricted to characters which are safe in
+// URLs and file names.
+var jobIdPattern = regexp.MustCompile(`^[A-Za-z0-9_-][A-Za-z0-9._-]{0,127}$`)
+
+// Result is the response of the graph to an asynchronous request.
+type Result struct {
+	StatusCode  int    `json:"statusCode"`
+	ContentType string `json:"contentType"`
+	Body        []byte `json:"body"`
+	// The error returned by the graph, if any
+	Error string `json:"error,omitempty"`
+}
+
+// Job is an asynchronous request to the graph.
+type Job struct {
+	Id            string    `json:"id"`
+	Status        JobStatus `json:"status"`
+	CallbackUrl   string    `json:"callbackUrl,omitempty"`
+	CallbackError string    `json:"callbackError,omitempty"`
+	Created       time.Time `json:"created"`
+	Updated       time.Time `json:"updated"`
+	Result        *Result   `json:"result,omitempty"`
+}
+
+// Done returns true once the job has a result.
+func (j *Job) Done() bool {
+	return j.Status == JobSucceeded || j.Status == JobFailed
+}
+
+func (j *Job) copy() *Job {
+	c := *j
+	if j.Result != nil {
+		r := *j.Result
+		c.Result = &r
+	}
+	return &c
+}
+
+type JobNotFoundError struct {
+	Id string
+}
+
+func (e *JobNotFoundError) Error() string {
+	return fmt.Sprintf("Job %s not found", e.Id)
+}
+
+func (e *JobNotFoundError) GRPCStatus() *status.Status {
+	return status.New(codes.NotFound, e.Error())
+}
+
+type InvalidJobIdError struct {
+	Id string
+}
+
+func (e *InvalidJobIdError) Error() string {
+	return fmt.Sprintf("Invalid job id %q: must be at most 128 letters, digits, '.', '_' or '-'", e.Id)
+}
+
+func (e *InvalidJobIdError) GRPCStatus() *status.Status {
+	return status.New(codes.InvalidArgument, e.Error())
+}
+
+type InvalidCallbackUrlError struct {
+	Url    string
+	Reason string
+}
+
+func (e *InvalidCallbackUrlError) Error() string {
+	return fmt.Sprintf("Invalid callback URL %s: %s", e.Url, e.Reason)
+}
+
+func (e *InvalidCallbackUrlError) GRPCStatus() *status.Status {
+	return status.New(codes.InvalidArgument, e.Error())
+}
+
+type QueueFullError struct {
+	Size int
+}
+
+func (e *QueueFullError) Error() string {
+	return fmt.Sprintf("Asynchronous request queue is full (%d requests)", e.Size)
+}
+
+func (e *QueueFullError) GRPCStatus() *status.Status {
+	return status.New(codes.Unavailable, e.Error())
+}
+
+type StoppedError struct{}
+
+func (e *StoppedError) Error() string {
+	return "Asynchronous requests are no longer accepted as the executor is shutting down"
+}
+
+func (e *StoppedError) GRPCStatus() *status.Status {
+	return status.New(codes.Unavailable, e.Error())
+}
+
+// ValidateJobId checks a job id can be used in URLs and as a file name.
+func ValidateJobId(id string) error {
+	if !jobIdPattern.MatchString(id) {
+		return &InvalidJobIdError{Id: id}
+	}
+	return nil
+}
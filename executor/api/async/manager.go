@@ -0,0 +1,391 @@
+package async
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/seldonio/seldon-core/executor/api/payload"
+)
+
+// Header with the status of the job sent with results to callback URLs and with job status responses.
+const StatusHeader = "Seldon-Async-Status"
+
+// How often expired results are removed from the store.
+var expireInterval = time.Minute
+
+// Time to wait before retrying to send a result to a callback URL, doubled after each attempt.
+var callbackBackoff = time.Second
+
+// Options configure how asynchronous requests are run and how long their results are kept.
+type Options struct {
+	// Number of jobs run concurrently
+	Workers int
+	// Number of jobs which can wait to be run
+	QueueSize int
+	// Longest time a job can run for, or 0 for no limit
+	JobTimeout time.Duration
+	// How long finished jobs are kept
+	ResultTTL time.Duration
+	// Longest time a status request can wait for a job to finish
+	MaxWait time.Duration
+	// Number of times to retry sending a result to a callback URL
+	CallbackRetries int
+	// Timeout of each attempt to send a result to a callback URL
+	CallbackTimeout time.Duration
+	// URLs results can be sent to. A callback URL must have the scheme and host of one of them and
+	// a path starting with its path. Callbacks are rejected if there are none.
+	CallbackUrls []string
+}
+
+var DefaultOptions = Options{
+	Workers:         4,
+	QueueSize:       100,
+	ResultTTL:       time.Hour,
+	MaxWait:         time.Minute,
+	CallbackRetries: 3,
+	CallbackTimeout: 30 * time.Second,
+}
+
+func (o Options) Validate() error {
+	if o.Workers < 1 {
+		return fmt.Errorf("Asynchronous workers must be at least 1 but is %d", o.Workers)
+	}
+	if o.QueueSize < 1 {
+		return fmt.Errorf("Asynchronous queue size must be at least 1 but is %d", o.QueueSize)
+	}
+	if o.ResultTTL <= 0 {
+		return fmt.Errorf("Asynchronous result TTL must be positive but is %s", o.ResultTTL)
+	}
+	if o.JobTimeout < 0 || o.MaxWait < 0 || o.CallbackTimeout < 0 || o.CallbackRetries < 0 {
+		return fmt.Errorf("Asynchronous timeouts and callback retries can't be negative")
+	}
+	for _, allowed := range o.CallbackUrls {
+		if _, err := parseCallbackUrl(allowed); err != nil {
+			return fmt.Errorf("Invalid allowed asynchronous callback URL %s: %v", allowed, err)
+		}
+	}
+	return nil
+}
+
+func parseCallbackUrl(rawUrl string) (*url.URL, error) {
+	u, err := url.Parse(rawUrl)
+	if err != nil {
+		return nil, err
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("must be an absolute http or https URL")
+	}
+	// Dot segments could take the path outside of an allowed path once resolved
+	for _, segment := range strings.Split(u.Path, "/") {
+		if segment == "." || segment == ".." {
+			return nil, fmt.Errorf("must not contain . or .. path segments")
+		}
+	}
+	return u, nil
+}
+
+// checkCallbackUrl returns an error unless results can be sent to the URL, so clients can't make
+// the executor post to any service it can reach.
+func (o Options) checkCallbackUrl(callbackUrl string) error {
+	u, err := parseCallbackUrl(callbackUrl)
+	if err != nil {
+		return &InvalidCallbackUrlError{Url: callbackUrl, Reason: err.Error()}
+	}
+	if len(o.CallbackUrls) == 0 {
+		return &InvalidCallbackUrlError{Url: callbackUrl, Reason: "callbacks are not enabled"}
+	}
+	for _, allowed := range o.CallbackUrls {
+		a, _ := parseCallbackUrl(allowed)
+		if u.Scheme == a.Scheme && strings.EqualFold(u.Host, a.Host) && strings.HasPrefix(u.Path, a.Path) {
+			return nil
+		}
+	}
+	return &InvalidCallbackUrlError{Url: callbackUrl, Reason: "not an allowed callback URL"}
+}
+
+// Task runs the graph for a job. Its context is cancelled if the job times out or the executor stops.
+type Task func(ctx context.Context) *Result
+
+type queuedJob struct {
+	job  *Job
+	task Task
+}
+
+// Manager queues asynchronous requests, runs them on a pool of workers and keeps their results in a
+// store until they are fetched or sent to a callback URL.
+type Manager struct {
+	Log     logr.Logger
+	store   ResultStore
+	options Options
+	client  http.Client
+	queue   chan queuedJob
+	ctx     context.Context
+	cancel  context.CancelFunc
+	workers sync.WaitGroup
+	mu      sync.Mutex
+	stopped bool
+	// Jobs submitted to this manager which have not finished. Each channel is closed when its job
+	// finishes to release requests waiting for the result.
+	active map[string]chan struct{}
+	done   chan struct{}
+}
+
+func NewManager(store ResultStore, opts Options, log logr.Logger) (*Manager, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Manager{
+		Log:     log,
+		store:   store,
+		options: opts,
+		client: http.Client{
+			Timeout: opts.CallbackTimeout,
+			// Redirects could lead to URLs which are not allowed
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		queue:  make(chan queuedJob, opts.QueueSize),
+		ctx:    ctx,
+		cancel: cancel,
+		active: make(map[string]chan struct{}),
+		done:   make(chan struct{}),
+	}, nil
+}
+
+// Start starts the workers and the removal of expired results.
+func (m *Manager) Start() {
+	for i := 0; i < m.options.Workers; i++ {
+		m.workers.Add(1)
+		go m.work()
+	}
+	go m.expire()
+}
+
+// Submit queues a job to run the task. If a job with the id already exists it is returned and the
+// task is not run again, so clients can safely retry submitting a request.
+func (m *Manager) Submit(id string, callbackUrl string, task Task) (*Job, error) {
+	if err := ValidateJobId(id); err != nil {
+		return nil, err
+	}
+	if callbackUrl != "" {
+		if err := m.options.checkCallbackUrl(callbackUrl); err != nil {
+			return nil, err
+		}
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.stopped {
+		return nil, &StoppedError{}
+	}
+	job, err := m.store.Get(id)
+	if err == nil {
+		return job, nil
+	} else if _, ok := err.(*JobNotFoundError); !ok {
+		return nil, err
+	}
+	// Only Submit adds to the queue and it holds the lock so the send below can't block
+	if len(m.queue) >= cap(m.queue) {
+		return nil, &QueueFullError{Size: cap(m.queue)}
+	}
+	now := time.Now()
+	job = &Job{Id: id, Status: JobPending, CallbackUrl: callbackUrl, Created: now, Updated: now}
+	if err := m.store.Put(job); err != nil {
+		return nil, err
+	}
+	m.active[id] = make(chan struct{})
+	m.queue <- queuedJob{job: job.copy(), task: task}
+	return job, nil
+}
+
+// Get returns a job. Jobs left unfinished by an executor which stopped, which is only possible with a
+// persistent store, are reported as failed.
+func (m *Manager) Get(id string) (*Job, error) {
+	if err := ValidateJobId(id); err != nil {
+		return nil, err
+	}
+	// Hold the lock so a job can't be submitted or finish between the checks below
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	job, err := m.store.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := m.active[id]; !ok && !job.Done() {
+		job.Status = JobFailed
+		job.Updated = time.Now()
+		job.Result = &Result{StatusCode: http.StatusInternalServerError, Error: "Job was interrupted by an executor restart"}
+		if err := m.store.Put(job); err != nil {
+			m.Log.Error(err, "Failed to store interrupted job", "id", id)
+		}
+	}
+	return job, nil
+}
+
+// Wait returns a job once it has finished, the wait time or the manager's maximum wait time has
+// passed, the context is done or the manager stops.
+func (m *Manager) Wait(ctx context.Context, id string, wait time.Duration) (*Job, error) {
+	if wait > m.options.MaxWait {
+		wait = m.options.MaxWait
+	}
+	m.mu.Lock()
+	finished, ok := m.active[id]
+	m.mu.Unlock()
+	if ok && wait > 0 {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		select {
+		case <-finished:
+		case <-timer.C:
+		case <-ctx.Done():
+		// Don't hold up the shutdown of the server
+		case <-m.done:
+		}
+	}
+	return m.Get(id)
+}
+
+// Stop stops accepting jobs and waits for queued and running jobs to finish until the context is
+// done, when they are cancelled.
+func (m *Manager) Stop(ctx context.Context) error {
+	m.mu.Lock()
+	if !m.stopped {
+		m.stopped = true
+		close(m.queue)
+		close(m.done)
+	}
+	m.mu.Unlock()
+
+	finished := make(chan struct{})
+	go func() {
+		m.workers.Wait()
+		close(finished)
+	}()
+	defer m.cancel()
+	select {
+	case <-finished:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (m *Manager) work() {
+	defer m.workers.Done()
+	for q := range m.queue {
+		m.run(q)
+	}
+}
+
+func (m *Manager) run(q queuedJob) {
+	job := q.job
+	job.Status = JobRunning
+	job.Updated = time.Now()
+	if err := m.store.Put(job); err != nil {
+		m.Log.Error(err, "Failed to store job", "id", job.Id)
+	}
+
+	ctx := m.ctx
+	if m.options.JobTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, m.options.JobTimeout)
+		defer cancel()
+	}
+	job.Result = q.task(ctx)
+	if job.Result.Error != "" || job.Result.StatusCode >= http.StatusBadRequest {
+		job.Status = JobFailed
+	} else {
+		job.Status = JobSucceeded
+	}
+	job.Updated = time.Now()
+	if err := m.store.Put(job); err != nil {
+		m.Log.Error(err, "Failed to store job result", "id", job.Id)
+	}
+
+	m.mu.Lock()
+	close(m.active[job.Id])
+	delete(m.active, job.Id)
+	m.mu.Unlock()
+
+	if job.CallbackUrl != "" {
+		if err := m.sendCallback(job); err != nil {
+			m.Log.Error(err, "Failed to send result to callback URL", "id", job.Id, "url", job.CallbackUrl)
+			job.CallbackError = err.Error()
+			if err := m.store.Put(job); err != nil {
+				m.Log.Error(err, "Failed to store job", "id", job.Id)
+			}
+		}
+	}
+}
+
+// sendCallback posts the result of a job to its callback URL, retrying with backoff on failure.
+func (m *Manager) sendCallback(job *Job) error {
+	var err error
+	backoff := callbackBackoff
+	for attempt := 0; attempt <= m.options.CallbackRetries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-time.After(backoff):
+			case <-m.ctx.Done():
+				return err
+			}
+			backoff *= 2
+		}
+		if err = m.postCallback(job); err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+func (m *Manager) postCallback(job *Job) error {
+	req, err := http.NewRequest(http.MethodPost, job.CallbackUrl, bytes.NewReader(job.Result.Body))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(m.ctx)
+	req.Header.Set("Content-Type", job.Result.ContentType)
+	req.Header.Set(payload.SeldonPUIDHeader, job.Id)
+	req.Header.Set(StatusHeader, string(job.Status))
+	res, err := m.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	// Read the body so the connection can be reused
+	_, _ = io.Copy(ioutil.Discard, res.Body)
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return fmt.Errorf("Callback to %s failed with status code %d", job.CallbackUrl, res.StatusCode)
+	}
+	return nil
+}
+
+// expire periodically removes results older than the TTL until the manager stops.
+func (m *Manager) expire() {
+	ticker := time.NewTicker(expireInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			expired, err := m.store.Expire(time.Now().Add(-m.options.ResultTTL))
+			if err != nil {
+				m.Log.Error(err, "Failed to remove expired asynchronous results")
+			}
+			if expired > 0 {
+				m.Log.V(1).Info("Removed expired asynchronous results", "count", expired)
+			}
+		case <-m.done:
+			return
+		}
+	}
+}
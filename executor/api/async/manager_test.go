@@ -0,0 +1,247 @@
+package async
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	"github.com/seldonio/seldon-core/executor/api/payload"
+	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
+)
+
+func newTestManager(g *GomegaWithT, store ResultStore, opts Options) *Manager {
+	m, err := NewManager(store, opts, logf.Log.WithName("test"))
+	g.Expect(err).To(BeNil())
+	m.Start()
+	return m
+}
+
+func resultTask(body string) Task {
+	return func(ctx context.Context) *Result {
+		return &Result{StatusCode: http.StatusOK, ContentType: "application/json", Body: []byte(body)}
+	}
+}
+
+// blockingTask returns a task which runs until release is closed.
+func blockingTask(release chan struct{}) Task {
+	return func(ctx context.Context) *Result {
+		select {
+		case <-release:
+			return &Result{StatusCode: http.StatusOK, Body: []byte(`{}`)}
+		case <-ctx.Done():
+			return &Result{StatusCode: http.StatusInternalServerError, Error: ctx.Err().Error()}
+		}
+	}
+}
+
+func TestSubmitAndWait(t *testing.T) {
+	g := NewGomegaWithT(t)
+	m := newTestManager(g, NewMemoryResultStore(), DefaultOptions)
+	defer m.Stop(context.Background())
+
+	release := make(chan struct{})
+	job, err := m.Submit("job-1", "", blockingTask(release))
+	g.Expect(err).To(BeNil())
+	g.Expect(job.Status).To(Equal(JobPending))
+
+	// Submitting again returns the existing job without running the new task
+	job, err = m.Submit("job-1", "", resultTask(`{"again":true}`))
+	g.Expect(err).To(BeNil())
+	g.Expect(job.Done()).To(BeFalse())
+
+	job, err = m.Wait(context.Background(), "job-1", 10*time.Millisecond)
+	g.Expect(err).To(BeNil())
+	g.Expect(job.Done()).To(BeFalse())
+
+	close(release)
+	job, err = m.Wait(context.Background(), "job-1", 10*time.Second)
+	g.Expect(err).To(BeNil())
+	g.Expect(job.Status).To(Equal(JobSucceeded))
+	g.Expect(job.Result.Body).To(Equal([]byte(`{}`)))
+
+	_, err = m.Get("job-2")
+	g.Expect(err).To(BeAssignableToTypeOf(&JobNotFoundError{}))
+	_, err = m.Submit("job/1", "", resultTask(`{}`))
+	g.Expect(err).To(BeAssignableToTypeOf(&InvalidJobIdError{}))
+}
+
+func TestSubmitFailedJob(t *testing.T) {
+	g := NewGomegaWithT(t)
+	m := newTestManager(g, NewMemoryResultStore(), DefaultOptions)
+	defer m.Stop(context.Background())
+
+	_, err := m.Submit("job-1", "", func(ctx context.Context) *Result {
+		return &Result{StatusCode: http.StatusBadRequest, Body: []byte(`{}`), Error: "bad request"}
+	})
+	g.Expect(err).To(BeNil())
+	job, err := m.Wait(context.Background(), "job-1", 10*time.Second)
+	g.Expect(err).To(BeNil())
+	g.Expect(job.Status).To(Equal(JobFailed))
+	g.Expect(job.Result.Error).To(Equal("bad request"))
+}
+
+func TestQueueFull(t *testing.T) {
+	g := NewGomegaWithT(t)
+	opts := DefaultOptions
+	opts.Workers = 1
+	opts.QueueSize = 1
+	m := newTestManager(g, NewMemoryResultStore(), opts)
+	release := make(chan struct{})
+	defer m.Stop(context.Background())
+	defer close(release)
+
+	_, err := m.Submit("job-1", "", blockingTask(release))
+	g.Expect(err).To(BeNil())
+	// Wait for the worker to take the first job so the second is queued
+	g.Eventually(func() JobStatus {
+		job, _ := m.Get("job-1")
+		return job.Status
+	}).Should(Equal(JobRunning))
+	_, err = m.Submit("job-2", "", blockingTask(release))
+	g.Expect(err).To(BeNil())
+	_, err = m.Submit("job-3", "", blockingTask(release))
+	g.Expect(err).To(BeAssignableToTypeOf(&QueueFullError{}))
+}
+
+func TestJobTimeout(t *testing.T) {
+	g := NewGomegaWithT(t)
+	opts := DefaultOptions
+	opts.JobTimeout = 10 * time.Millisecond
+	m := newTestManager(g, NewMemoryResultStore(), opts)
+	defer m.Stop(context.Background())
+
+	_, err := m.Submit("job-1", "", blockingTask(make(chan struct{})))
+	g.Expect(err).To(BeNil())
+	job, err := m.Wait(context.Background(), "job-1", 10*time.Second)
+	g.Expect(err).To(BeNil())
+	g.Expect(job.Status).To(Equal(JobFailed))
+	g.Expect(job.Result.Error).To(Equal(context.DeadlineExceeded.Error()))
+}
+
+func TestCallback(t *testing.T) {
+	g := NewGomegaWithT(t)
+	callbackBackoff = time.Millisecond
+
+	type callback struct {
+		puid   string
+		status string
+		body   string
+	}
+	callbacks := make(chan callback, 10)
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		// Fail the first attempt so it is retried
+		if attempts == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		callbacks <- callback{puid: r.Header.Get(payload.SeldonPUIDHeader), status: r.Header.Get(StatusHeader), body: string(body)}
+	}))
+	defer server.Close()
+
+	opts := DefaultOptions
+	opts.Workers = 1
+	opts.CallbackUrls = []string{server.URL, "http://localhost:0/callback"}
+	m := newTestManager(g, NewMemoryResultStore(), opts)
+	defer m.Stop(context.Background())
+
+	_, err := m.Submit("job-1", server.URL+"/results", resultTask(`{"data":1}`))
+	g.Expect(err).To(BeNil())
+	var cb callback
+	g.Eventually(callbacks, 5*time.Second).Should(Receive(&cb))
+	g.Expect(cb).To(Equal(callback{puid: "job-1", status: string(JobSucceeded), body: `{"data":1}`}))
+	g.Expect(attempts).To(Equal(2))
+
+	// The error is kept if the result can't be sent
+	_, err = m.Submit("job-2", "http://localhost:0/callback", resultTask(`{}`))
+	g.Expect(err).To(BeNil())
+	g.Eventually(func() string {
+		job, _ := m.Get("job-2")
+		return job.CallbackError
+	}, 10*time.Second).ShouldNot(BeEmpty())
+}
+
+func TestCallbackUrls(t *testing.T) {
+	g := NewGomegaWithT(t)
+	m := newTestManager(g, NewMemoryResultStore(), DefaultOptions)
+	defer m.Stop(context.Background())
+
+	// Callbacks are disabled by default
+	_, err := m.Submit("job-1", "http://results.example.com/", resultTask(`{}`))
+	g.Expect(err).To(BeAssignableToTypeOf(&InvalidCallbackUrlError{}))
+
+	opts := DefaultOptions
+	opts.CallbackUrls = []string{"https://results.example.com/seldon/"}
+	m = newTestManager(g, NewMemoryResultStore(), opts)
+	defer m.Stop(context.Background())
+
+	for _, callbackUrl := range []string{
+		"/seldon/results",
+		"http://results.example.com/seldon/results",
+		"https://results.example.com/other",
+		"https://results.example.com.evil.com/seldon/results",
+		"https://results.example.com@internal/seldon/results",
+		"https://results.example.com/seldon/../admin",
+		"https://results.example.com/seldon/%2e%2e/admin",
+	} {
+		_, err = m.Submit("job-1", callbackUrl, resultTask(`{}`))
+		g.Expect(err).To(BeAssignableToTypeOf(&InvalidCallbackUrlError{}), callbackUrl)
+	}
+	g.Expect(opts.checkCallbackUrl("https://RESULTS.example.com/seldon/results?id=1")).To(BeNil())
+
+	opts.CallbackUrls = []string{"results.example.com"}
+	_, err = NewManager(NewMemoryResultStore(), opts, logf.Log.WithName("test"))
+	g.Expect(err).ToNot(BeNil())
+}
+
+func TestInterruptedJob(t *testing.T) {
+	g := NewGomegaWithT(t)
+	dir, err := ioutil.TempDir("", "async")
+	g.Expect(err).To(BeNil())
+	defer os.RemoveAll(dir)
+	store, err := NewFileResultStore(dir)
+	g.Expect(err).To(BeNil())
+
+	// A job left running by a previous executor
+	g.Expect(store.Put(&Job{Id: "job-1", Status: JobRunning})).To(BeNil())
+	m := newTestManager(g, store, DefaultOptions)
+	defer m.Stop(context.Background())
+
+	job, err := m.Wait(context.Background(), "job-1", time.Second)
+	g.Expect(err).To(BeNil())
+	g.Expect(job.Status).To(Equal(JobFailed))
+	job, err = store.Get("job-1")
+	g.Expect(err).To(BeNil())
+	g.Expect(job.Status).To(Equal(JobFailed))
+}
+
+func TestStop(t *testing.T) {
+	g := NewGomegaWithT(t)
+	opts := DefaultOptions
+	opts.Workers = 1
+	m := newTestManager(g, NewMemoryResultStore(), opts)
+
+	_, err := m.Submit("job-1", "", blockingTask(make(chan struct{})))
+	g.Expect(err).To(BeNil())
+	_, err = m.Submit("job-2", "", resultTask(`{}`))
+	g.Expect(err).To(BeNil())
+
+	// Jobs still running at the deadline are cancelled
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	g.Expect(m.Stop(ctx)).To(Equal(context.DeadlineExceeded))
+	g.Eventually(func() JobStatus {
+		job, _ := m.Get("job-1")
+		return job.Status
+	}).Should(Equal(JobFailed))
+
+	_, err = m.Submit("job-3", "", resultTask(`{}`))
+	g.Expect(err).To(BeAssignableToTypeOf(&StoppedError{}))
+}
@@ -0,0 +1,149 @@
+package async
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ResultStore keeps asynchronous jobs and their results until they expire.
+type ResultStore interface {
+	// Put creates or replaces a job.
+	Put(job *Job) error
+	// Get returns a job or a JobNotFoundError.
+	Get(id string) (*Job, error)
+	// Expire deletes finished jobs last updated before the given time and returns how many were deleted.
+	Expire(before time.Time) (int, error)
+}
+
+// MemoryResultStore keeps jobs in memory so they are lost on restart.
+type MemoryResultStore struct {
+	mu   sync.Mutex
+	jobs map[string]*Job
+}
+
+func NewMemoryResultStore() *MemoryResultStore {
+	return &MemoryResultStore{jobs: make(map[string]*Job)}
+}
+
+func (m *MemoryResultStore) Put(job *Job) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.jobs[job.Id] = job.copy()
+	return nil
+}
+
+func (m *MemoryResultStore) Get(id string) (*Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	job, ok := m.jobs[id]
+	if !ok {
+		return nil, &JobNotFoundError{Id: id}
+	}
+	return job.copy(), nil
+}
+
+func (m *MemoryResultStore) Expire(before time.Time) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	expired := 0
+	for id, job := range m.jobs {
+		if job.Done() && job.Updated.Before(before) {
+			delete(m.jobs, id)
+			expired++
+		}
+	}
+	return expired, nil
+}
+
+// FileResultStore writes each job to a JSON file in a directory so results survive executor
+// restarts and can be kept on a volume rather than in memory.
+type FileResultStore struct {
+	dir string
+}
+
+const jobFileSuffix = ".json"
+
+func NewFileResultStore(dir string) (*FileResultStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &FileResultStore{dir: dir}, nil
+}
+
+func (f *FileResultStore) path(id string) (string, error) {
+	if err := ValidateJobId(id); err != nil {
+		return "", err
+	}
+	return filepath.Join(f.dir, id+jobFileSuffix), nil
+}
+
+func (f *FileResultStore) Put(job *Job) error {
+	path, err := f.path(job.Id)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	// Write to a temporary file and rename so readers never see a partial job
+	tmp, err := ioutil.TempFile(f.dir, job.Id+".tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (f *FileResultStore) Get(id string) (*Job, error) {
+	path, err := f.path(id)
+	if err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, &JobNotFoundError{Id: id}
+	} else if err != nil {
+		return nil, err
+	}
+	job := &Job{}
+	if err := json.Unmarshal(data, job); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+func (f *FileResultStore) Expire(before time.Time) (int, error) {
+	files, err := ioutil.ReadDir(f.dir)
+	if err != nil {
+		return 0, err
+	}
+	expired := 0
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), jobFileSuffix) {
+			continue
+		}
+		job, err := f.Get(strings.TrimSuffix(file.Name(), jobFileSuffix))
+		if err != nil || !job.Done() || !job.Updated.Before(before) {
+			continue
+		}
+		if err := os.Remove(filepath.Join(f.dir, file.Name())); err != nil && !os.IsNotExist(err) {
+			return expired, err
+		}
+		expired++
+	}
+	return expired, nil
+}
@@ -0,0 +1,64 @@
+package async
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+)
+
+func testResultStore(g *GomegaWithT, store ResultStore) {
+	_, err := store.Get("job-1")
+	g.Expect(err).To(BeAssignableToTypeOf(&JobNotFoundError{}))
+
+	old := time.Now().Add(-time.Hour)
+	g.Expect(store.Put(&Job{Id: "job-1", Status: JobPending, Created: old, Updated: old})).To(BeNil())
+	g.Expect(store.Put(&Job{Id: "job-2", Status: JobSucceeded, Created: old, Updated: old, Result: &Result{StatusCode: 200, Body: []byte(`{}`)}})).To(BeNil())
+	g.Expect(store.Put(&Job{Id: "job-3", Status: JobFailed, Created: old, Updated: time.Now()})).To(BeNil())
+
+	job, err := store.Get("job-2")
+	g.Expect(err).To(BeNil())
+	g.Expect(job.Status).To(Equal(JobSucceeded))
+	g.Expect(job.Result.Body).To(Equal([]byte(`{}`)))
+
+	// Only finished jobs updated before the time are removed
+	expired, err := store.Expire(time.Now().Add(-time.Minute))
+	g.Expect(err).To(BeNil())
+	g.Expect(expired).To(Equal(1))
+	_, err = store.Get("job-2")
+	g.Expect(err).To(BeAssignableToTypeOf(&JobNotFoundError{}))
+	_, err = store.Get("job-1")
+	g.Expect(err).To(BeNil())
+	_, err = store.Get("job-3")
+	g.Expect(err).To(BeNil())
+}
+
+func TestMemoryResultStore(t *testing.T) {
+	g := NewGomegaWithT(t)
+	testResultStore(g, NewMemoryResultStore())
+}
+
+func TestFileResultStore(t *testing.T) {
+	g := NewGomegaWithT(t)
+	dir, err := ioutil.TempDir("", "async")
+	g.Expect(err).To(BeNil())
+	defer os.RemoveAll(dir)
+
+	store, err := NewFileResultStore(dir)
+	g.Expect(err).To(BeNil())
+	testResultStore(g, store)
+
+	// Ids can't escape the directory
+	_, err = store.Get("../job-1")
+	g.Expect(err).To(BeAssignableToTypeOf(&InvalidJobIdError{}))
+	g.Expect(store.Put(&Job{Id: ".."})).To(BeAssignableToTypeOf(&InvalidJobIdError{}))
+
+	// Jobs are kept by a new store
+	store, err = NewFileResultStore(dir)
+	g.Expect(err).To(BeNil())
+	job, err := store.Get("job-1")
+	g.Expect(err).To(BeNil())
+	g.Expect(job.Status).To(Equal(JobPending))
+}
@@ -0,0 +1,100 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/seldonio/seldon-core/executor/api/payload"
+)
+
+// Backend stores cached responses. Implementations must be safe for concurrent use.
+type Backend interface {
+	// Get returns the response cached for the key or nil if there is none.
+	Get(key string) (payload.SeldonPayload, error)
+	// Set caches a response for the key for the given time.
+	Set(key string, value payload.SeldonPayload, ttl time.Duration) error
+}
+
+type memoryEntry struct {
+	key     string
+	value   payload.SeldonPayload
+	expires time.Time
+}
+
+// MemoryBackend is an in-memory cache which evicts the least recently used responses once full.
+type MemoryBackend struct {
+	mu         sync.Mutex
+	maxEntries int
+	entries    map[string]*list.Element
+	lru        *list.List
+	now        func() time.Time
+}
+
+// NewMemoryBackend creates an in-memory cache. A maxEntries of zero means no limit.
+func NewMemoryBackend(maxEntries int) *MemoryBackend {
+	return &MemoryBackend{
+		maxEntries: maxEntries,
+		entries:    make(map[string]*list.Element),
+		lru:        list.New(),
+		now:        time.Now,
+	}
+}
+
+func (m *MemoryBackend) Get(key string) (payload.SeldonPayload, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	el, ok := m.entries[key]
+	if !ok {
+		return nil, nil
+	}
+	entry := el.Value.(*memoryEntry)
+	if m.now().After(entry.expires) {
+		m.remove(el)
+		return nil, nil
+	}
+	m.lru.MoveToFront(el)
+	return copyPayload(entry.value), nil
+}
+
+func (m *MemoryBackend) Set(key string, value payload.SeldonPayload, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	entry := &memoryEntry{key: key, value: copyPayload(value), expires: m.now().Add(ttl)}
+	if el, ok := m.entries[key]; ok {
+		el.Value = entry
+		m.lru.MoveToFront(el)
+		return nil
+	}
+	m.entries[key] = m.lru.PushFront(entry)
+	if m.maxEntries > 0 && m.lru.Len() > m.maxEntries {
+		m.remove(m.lru.Back())
+	}
+	return nil
+}
+
+// Len returns the number of cached responses including expired ones not yet removed.
+func (m *MemoryBackend) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.lru.Len()
+}
+
+// remove deletes an entry. Callers hold the lock.
+func (m *MemoryBackend) remove(el *list.Element) {
+	m.lru.Remove(el)
+	delete(m.entries, el.Value.(*memoryEntry).key)
+}
+
+// copyPayload copies a response so callers adding meta data don't change the cached response.
+func copyPayload(msg payload.SeldonPayload) payload.SeldonPayload {
+	switch v := msg.(type) {
+	case *payload.ProtoPayload:
+		return &payload.ProtoPayload{Msg: proto.Clone(v.Msg)}
+	case *payload.BytesPayload:
+		return &payload.BytesPayload{Msg: append([]byte{}, v.Msg...), ContentType: v.ContentType}
+	default:
+		return msg
+	}
+}
@@ -0,0 +1,48 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	"github.com/seldonio/seldon-core/executor/api/payload"
+)
+
+func TestMemoryBackendEvictsLeastRecentlyUsed(t *testing.T) {
+	g := NewGomegaWithT(t)
+	backend := NewMemoryBackend(2)
+	for _, key := range []string{"a", "b"} {
+		g.Expect(backend.Set(key, &payload.BytesPayload{Msg: []byte(key)}, time.Minute)).To(BeNil())
+	}
+	res, err := backend.Get("a")
+	g.Expect(err).To(BeNil())
+	g.Expect(res).ToNot(BeNil())
+
+	g.Expect(backend.Set("c", &payload.BytesPayload{Msg: []byte("c")}, time.Minute)).To(BeNil())
+	g.Expect(backend.Len()).To(Equal(2))
+	res, _ = backend.Get("b")
+	g.Expect(res).To(BeNil())
+	res, _ = backend.Get("a")
+	g.Expect(string(res.GetPayload().([]byte))).To(Equal("a"))
+}
+
+func TestMemoryBackendExpires(t *testing.T) {
+	g := NewGomegaWithT(t)
+	now := time.Now()
+	backend := NewMemoryBackend(0)
+	backend.now = func() time.Time { return now }
+	g.Expect(backend.Set("a", &payload.BytesPayload{Msg: []byte("a")}, time.Second)).To(BeNil())
+
+	res, _ := backend.Get("a")
+	g.Expect(res).ToNot(BeNil())
+
+	// Changing a returned response doesn't change the cached one
+	res.(*payload.BytesPayload).Msg[0] = 'b'
+	res, _ = backend.Get("a")
+	g.Expect(string(res.GetPayload().([]byte))).To(Equal("a"))
+
+	now = now.Add(2 * time.Second)
+	res, _ = backend.Get("a")
+	g.Expect(res).To(BeNil())
+	g.Expect(backend.Len()).To(Equal(0))
+}
@@ -0,0 +1,148 @@
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/golang/protobuf/proto"
+	"github.com/seldonio/seldon-core/executor/api/client"
+	"github.com/seldonio/seldon-core/executor/api/metric"
+	"github.com/seldonio/seldon-core/executor/api/payload"
+	"github.com/seldonio/seldon-core/executor/api/util"
+	v1 "github.com/seldonio/seldon-core/operator/apis/machinelearning.seldon.io/v1"
+	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
+)
+
+// BackendFactory creates the backend for a model with a cache policy.
+type BackendFactory func(policy *v1.ResponseCachePolicy) Backend
+
+// NewMemoryBackendFactory creates in-memory backends sized by each policy.
+func NewMemoryBackendFactory(policy *v1.ResponseCachePolicy) Backend {
+	return NewMemoryBackend(int(policy.MaxEntries))
+}
+
+type modelCache struct {
+	policy  *v1.ResponseCachePolicy
+	backend Backend
+}
+
+// CachingClient returns cached responses for predictions to models with a cache policy.
+// All other calls go to the wrapped client.
+type CachingClient struct {
+	client.SeldonApiClient
+	Log     logr.Logger
+	caches  map[string]*modelCache
+	metrics *metric.CacheMetrics
+}
+
+// NewCachingClient wraps a client with the response caches of the models in the graph.
+// The client is returned unchanged if no model has a cache policy.
+func NewCachingClient(c client.SeldonApiClient, predictor *v1.PredictorSpec, deploymentName string, factory BackendFactory) client.SeldonApiClient {
+	caches := make(map[string]*modelCache)
+	for _, pu := range v1.GetPredictiveUnitList(&predictor.Graph) {
+		if pu.Cache != nil {
+			caches[pu.Name] = &modelCache{policy: pu.Cache, backend: factory(pu.Cache)}
+		}
+	}
+	if len(caches) == 0 {
+		return c
+	}
+	return &CachingClient{
+		SeldonApiClient: c,
+		Log:             logf.Log.WithName("CachingClient"),
+		caches:          caches,
+		metrics:         metric.NewCacheMetrics(predictor, deploymentName),
+	}
+}
+
+func (c *CachingClient) Predict(ctx context.Context, modelName string, host string, port int32, msg payload.SeldonPayload, meta map[string][]string) (payload.SeldonPayload, error) {
+	mc, ok := c.caches[modelName]
+	if !ok {
+		return c.SeldonApiClient.Predict(ctx, modelName, host, port, msg, meta)
+	}
+	key, err := cacheKey(modelName, msg, mc.policy.Headers, meta)
+	if err != nil {
+		c.Log.Error(err, "Failed to create cache key", "model", modelName)
+		return c.SeldonApiClient.Predict(ctx, modelName, host, port, msg, meta)
+	}
+	// Backend errors are treated as misses so an unavailable cache doesn't fail predictions
+	cached, err := mc.backend.Get(key)
+	if err != nil {
+		c.Log.Error(err, "Failed to read from cache", "model", modelName)
+	}
+	if cached != nil {
+		c.metrics.Record(modelName, metric.CacheHit)
+		return cached, nil
+	}
+	c.metrics.Record(modelName, metric.CacheMiss)
+	res, err := c.SeldonApiClient.Predict(ctx, modelName, host, port, msg, meta)
+	if err != nil {
+		return res, err
+	}
+	// Custom metrics are only recorded for the call that produced them, not for every cache hit
+	_, cached, err = util.ExtractMetricsFromSeldonPayload(copyPayload(res))
+	if err != nil {
+		c.Log.Error(err, "Failed to remove custom metrics from cached response", "model", modelName)
+		return res, nil
+	}
+	if err := mc.backend.Set(key, cached, time.Duration(mc.policy.TtlSeconds)*time.Second); err != nil {
+		c.Log.Error(err, "Failed to write to cache", "model", modelName)
+	}
+	return res, nil
+}
+
+// cacheKey hashes the model name, the canonical form of the payload and the selected headers.
+func cacheKey(modelName string, msg payload.SeldonPayload, headers []string, meta map[string][]string) (string, error) {
+	data, err := canonicalPayload(msg)
+	if err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	h.Write([]byte(modelName))
+	h.Write([]byte{0})
+	h.Write([]byte(msg.GetContentType()))
+	h.Write([]byte{0})
+	h.Write(data)
+	for _, header := range headers {
+		h.Write([]byte{0})
+		h.Write([]byte(strings.ToLower(header)))
+		for k, values := range meta {
+			if strings.EqualFold(k, header) {
+				for _, v := range values {
+					h.Write([]byte{0})
+					h.Write([]byte(v))
+				}
+			}
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// canonicalPayload returns bytes which are equal for equivalent payloads,
+// e.g. JSON with a different key order or whitespace.
+func canonicalPayload(msg payload.SeldonPayload) ([]byte, error) {
+	switch v := msg.GetPayload().(type) {
+	case proto.Message:
+		buf := proto.NewBuffer(nil)
+		buf.SetDeterministic(true)
+		if err := buf.Marshal(v); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	case []byte:
+		var data interface{}
+		dec := json.NewDecoder(strings.NewReader(string(v)))
+		dec.UseNumber()
+		if err := dec.Decode(&data); err != nil || dec.More() {
+			return v, nil
+		}
+		return json.Marshal(data)
+	default:
+		return msg.GetBytes()
+	}
+}
@@ -0,0 +1,112 @@
+package cache
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/seldonio/seldon-core/executor/api/metric"
+	"github.com/seldonio/seldon-core/executor/api/payload"
+	"github.com/seldonio/seldon-core/executor/api/test"
+	v1 "github.com/seldonio/seldon-core/operator/apis/machinelearning.seldon.io/v1"
+)
+
+type countingTestClient struct {
+	test.SeldonMessageTestClient
+	calls int
+}
+
+func (c *countingTestClient) Predict(ctx context.Context, modelName string, host string, port int32, msg payload.SeldonPayload, meta map[string][]string) (payload.SeldonPayload, error) {
+	c.calls++
+	return msg, nil
+}
+
+func createCachePredictor() *v1.PredictorSpec {
+	return &v1.PredictorSpec{
+		Name: "p",
+		Graph: v1.PredictiveUnit{
+			Name: "transformer",
+			Children: []v1.PredictiveUnit{
+				{
+					Name: "model",
+					Cache: &v1.ResponseCachePolicy{
+						TtlSeconds: 60,
+						MaxEntries: 10,
+						Headers:    []string{"X-User"},
+					},
+				},
+			},
+		},
+	}
+}
+
+func cacheRequests(g *GomegaWithT, result string) float64 {
+	mfs, err := prometheus.DefaultGatherer.Gather()
+	g.Expect(err).Should(BeNil())
+	for _, mf := range mfs {
+		if mf.GetName() == metric.CacheRequestsMetricName {
+			for _, m := range mf.Metric {
+				for _, label := range m.Label {
+					if label.GetName() == metric.CacheResultMetric && label.GetValue() == result {
+						return m.GetCounter().GetValue()
+					}
+				}
+			}
+		}
+	}
+	return 0
+}
+
+func TestCachingClientPredict(t *testing.T) {
+	g := NewGomegaWithT(t)
+	inner := &countingTestClient{}
+	c := NewCachingClient(inner, createCachePredictor(), "dep", NewMemoryBackendFactory)
+	ctx := context.Background()
+	meta := map[string][]string{"x-user": {"alice"}}
+
+	_, err := c.Predict(ctx, "model", "host", 9000, &payload.BytesPayload{Msg: []byte(`{"data":{"ndarray":[1,2]},"meta":{}}`), ContentType: "application/json"}, meta)
+	g.Expect(err).To(BeNil())
+	// Equivalent JSON is served from the cache
+	res, err := c.Predict(ctx, "model", "host", 9000, &payload.BytesPayload{Msg: []byte(`{"meta":{}, "data":{"ndarray":[1,2]}}`), ContentType: "application/json"}, meta)
+	g.Expect(err).To(BeNil())
+	g.Expect(string(res.GetPayload().([]byte))).To(Equal(`{"data":{"ndarray":[1,2]},"meta":{}}`))
+	g.Expect(inner.calls).To(Equal(1))
+	g.Expect(cacheRequests(g, metric.CacheHit)).To(Equal(1.0))
+	g.Expect(cacheRequests(g, metric.CacheMiss)).To(Equal(1.0))
+
+	// Selected headers are part of the key
+	_, err = c.Predict(ctx, "model", "host", 9000, &payload.BytesPayload{Msg: []byte(`{"data":{"ndarray":[1,2]},"meta":{}}`), ContentType: "application/json"}, map[string][]string{"x-user": {"bob"}})
+	g.Expect(err).To(BeNil())
+	g.Expect(inner.calls).To(Equal(2))
+
+	// Models without a cache policy are not cached
+	for i := 0; i < 2; i++ {
+		_, err = c.Predict(ctx, "transformer", "host", 9000, &payload.BytesPayload{Msg: []byte(`{}`), ContentType: "application/json"}, meta)
+		g.Expect(err).To(BeNil())
+	}
+	g.Expect(inner.calls).To(Equal(4))
+}
+
+func TestCachingClientCustomMetrics(t *testing.T) {
+	g := NewGomegaWithT(t)
+	inner := &countingTestClient{}
+	c := NewCachingClient(inner, createCachePredictor(), "dep", NewMemoryBackendFactory)
+	msg := `{"data":{"ndarray":[1]},"meta":{"metrics":[{"key":"mycounter","type":"COUNTER","value":1}]}}`
+
+	// The custom metrics are returned by the call to the model but not by cache hits
+	res, err := c.Predict(context.Background(), "model", "host", 9000, &payload.BytesPayload{Msg: []byte(msg), ContentType: "application/json"}, nil)
+	g.Expect(err).To(BeNil())
+	g.Expect(string(res.GetPayload().([]byte))).To(Equal(msg))
+	res, err = c.Predict(context.Background(), "model", "host", 9000, &payload.BytesPayload{Msg: []byte(msg), ContentType: "application/json"}, nil)
+	g.Expect(err).To(BeNil())
+	g.Expect(inner.calls).To(Equal(1))
+	g.Expect(string(res.GetPayload().([]byte))).To(Equal(`{"data":{"ndarray":[1]},"meta":{}}`))
+}
+
+func TestCachingClientNotUsedWithoutPolicy(t *testing.T) {
+	g := NewGomegaWithT(t)
+	inner := &countingTestClient{}
+	predictor := &v1.PredictorSpec{Name: "p", Graph: v1.PredictiveUnit{Name: "model"}}
+	g.Expect(NewCachingClient(inner, predictor, "dep", NewMemoryBackendFactory)).To(BeIdenticalTo(inner))
+}
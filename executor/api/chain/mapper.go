@@ -0,0 +1,238 @@
+package chain
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/seldonio/seldon-core/executor/api/payload"
+	v1 "github.com/seldonio/seldon-core/operator/apis/machinelearning.seldon.io/v1"
+)
+
+// InputMappingParameter is the node parameter mapping output tensor names of the previous node
+// to input tensor names of the node, e.g. "probabilities:input_1,labels:input_2".
+const InputMappingParameter = "input_mapping"
+
+// Tensor describes a v2 protocol tensor passed from one node to the next.
+type Tensor struct {
+	Name     string
+	Datatype string
+	Shape    []int64
+}
+
+// Mapper renames the outputs of a node to the inputs of the next node and checks they are compatible.
+type Mapper struct {
+	// Mapping from output names to input names given in the node parameters
+	Mapping map[string]string
+	// Inputs expected by the next node discovered from its metadata
+	Inputs []Tensor
+}
+
+// NewMapper creates a mapper from the parameters and metadata of the node receiving the tensors.
+func NewMapper(node *v1.PredictiveUnit, metadata *payload.ModelMetadata) (*Mapper, error) {
+	m := &Mapper{}
+	for _, param := range node.Parameters {
+		if param.Name != InputMappingParameter {
+			continue
+		}
+		m.Mapping = make(map[string]string)
+		for _, pair := range strings.Split(param.Value, ",") {
+			parts := strings.Split(strings.TrimSpace(pair), ":")
+			if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+				return nil, fmt.Errorf("Invalid %s parameter for %s: %q is not output:input", InputMappingParameter, node.Name, pair)
+			}
+			m.Mapping[parts[0]] = parts[1]
+		}
+	}
+	if metadata != nil {
+		m.Inputs = metadataTensors(metadata.Inputs)
+	}
+	return m, nil
+}
+
+// Map returns the input name for each output. Outputs named in the mapping are renamed. If none of the
+// remaining outputs match an expected input and there are as many outputs as inputs they are renamed by position.
+func (m *Mapper) Map(outputs []Tensor) ([]string, error) {
+	names := make([]string, len(outputs))
+	for i, output := range outputs {
+		names[i] = output.Name
+	}
+	if m == nil {
+		return names, nil
+	}
+	mapped := make([]bool, len(outputs))
+	for i, output := range outputs {
+		if input, ok := m.Mapping[output.Name]; ok {
+			names[i] = input
+			mapped[i] = true
+		}
+	}
+	if len(m.Inputs) > 0 && len(m.Inputs) == len(outputs) {
+		matched := false
+		for i := range outputs {
+			if !mapped[i] && m.input(names[i]) != nil {
+				matched = true
+			}
+		}
+		if !matched {
+			for i := range outputs {
+				if !mapped[i] {
+					names[i] = m.Inputs[i].Name
+				}
+			}
+		}
+	}
+	for i, output := range outputs {
+		if input := m.input(names[i]); input != nil {
+			if err := checkCompatible(output, input); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return names, nil
+}
+
+func (m *Mapper) input(name string) *Tensor {
+	for i := range m.Inputs {
+		if m.Inputs[i].Name == name {
+			return &m.Inputs[i]
+		}
+	}
+	return nil
+}
+
+// checkCompatible checks the datatype and shape of an output against an input. Negative input dimensions match any size.
+func checkCompatible(output Tensor, input *Tensor) error {
+	if input.Datatype != "" && output.Datatype != "" && input.Datatype != output.Datatype {
+		return fmt.Errorf("Output %s has datatype %s but input %s expects %s", output.Name, output.Datatype, input.Name, input.Datatype)
+	}
+	if len(input.Shape) == 0 || len(output.Shape) == 0 {
+		return nil
+	}
+	if len(input.Shape) != len(output.Shape) {
+		return fmt.Errorf("Output %s has shape %v but input %s expects %v", output.Name, output.Shape, input.Name, input.Shape)
+	}
+	for i, d := range input.Shape {
+		if d >= 0 && d != output.Shape[i] {
+			return fmt.Errorf("Output %s has shape %v but input %s expects %v", output.Name, output.Shape, input.Name, input.Shape)
+		}
+	}
+	return nil
+}
+
+// metadataTensors reads tensor metadata decoded from v2 JSON. Shapes may be numbers or,
+// when converted from gRPC, strings.
+func metadataTensors(v interface{}) []Tensor {
+	list, ok := v.([]interface{})
+	if !ok {
+		return nil
+	}
+	var tensors []Tensor
+	for _, item := range list {
+		fields, ok := item.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		t := Tensor{}
+		t.Name, _ = fields["name"].(string)
+		t.Datatype, _ = fields["datatype"].(string)
+		shape, _ := fields["shape"].([]interface{})
+		for _, d := range shape {
+			switch n := d.(type) {
+			case float64:
+				t.Shape = append(t.Shape, int64(n))
+			case string:
+				i, err := strconv.ParseInt(n, 10, 64)
+				if err != nil {
+					return nil
+				}
+				t.Shape = append(t.Shape, i)
+			}
+		}
+		tensors = append(tensors, t)
+	}
+	return tensors
+}
+
+// MetadataFunc returns the metadata of a model.
+type MetadataFunc func(ctx context.Context, modelName string, host string, port int32) (payload.ModelMetadata, error)
+
+// How long to wait before fetching metadata again for a mapper created without it.
+var mapperRetryInterval = time.Minute
+
+// Mappers creates and caches the mapper for each node of a graph.
+type Mappers struct {
+	predictor     *v1.PredictorSpec
+	grpc          bool
+	metadata      MetadataFunc
+	log           logr.Logger
+	retryInterval time.Duration
+	mappers       sync.Map
+}
+
+type cachedMapper struct {
+	mapper *Mapper
+	// Set if the mapper has all the metadata it can have
+	complete bool
+	created  time.Time
+}
+
+// NewMappers creates the mappers of a graph whose metadata is fetched over gRPC if grpc is set and
+// otherwise over REST.
+func NewMappers(predictor *v1.PredictorSpec, grpc bool, metadata MetadataFunc, log logr.Logger) *Mappers {
+	return &Mappers{predictor: predictor, grpc: grpc, metadata: metadata, log: log, retryInterval: mapperRetryInterval}
+}
+
+// port returns the port of the endpoint for the transport metadata is fetched with.
+func (m *Mappers) port(endpoint *v1.Endpoint) int32 {
+	port := endpoint.HttpPort
+	if m.grpc {
+		port = endpoint.GrpcPort
+	}
+	if port > 0 {
+		return port
+	}
+	return endpoint.ServicePort
+}
+
+// Get returns the mapper for the node receiving the tensors. Models which don't provide metadata
+// are only mapped by their parameters until their metadata is fetched again after the retry
+// interval. A nil mapper leaves names unchanged.
+func (m *Mappers) Get(ctx context.Context, modelName string) (*Mapper, error) {
+	if m == nil || m.predictor == nil {
+		return nil, nil
+	}
+	if v, ok := m.mappers.Load(modelName); ok {
+		cached := v.(*cachedMapper)
+		if cached.complete || time.Since(cached.created) < m.retryInterval {
+			return cached.mapper, nil
+		}
+	}
+	node := v1.GetPredictiveUnit(&m.predictor.Graph, modelName)
+	if node == nil {
+		return nil, nil
+	}
+	var metadata *payload.ModelMetadata
+	if node.Endpoint != nil && m.metadata != nil {
+		md, err := m.metadata(ctx, modelName, node.Endpoint.ServiceHost, m.port(node.Endpoint))
+		if err != nil {
+			m.log.V(1).Info("No metadata to map tensors", "model", modelName, "error", err.Error())
+		} else {
+			metadata = &md
+		}
+	}
+	mapper, err := NewMapper(node, metadata)
+	if err != nil {
+		return nil, err
+	}
+	// Metadata failing because the request was cancelled is tried again on the next request
+	if metadata != nil || ctx.Err() == nil {
+		complete := metadata != nil || node.Endpoint == nil || m.metadata == nil
+		m.mappers.Store(modelName, &cachedMapper{mapper: mapper, complete: complete, created: time.Now()})
+	}
+	return mapper, nil
+}
@@ -0,0 +1,91 @@
+package chain
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/seldonio/seldon-core/executor/api/payload"
+	v1 "github.com/seldonio/seldon-core/operator/apis/machinelearning.seldon.io/v1"
+	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
+)
+
+func createMetadata(inputs ...interface{}) *payload.ModelMetadata {
+	return &payload.ModelMetadata{Name: "model", Inputs: inputs}
+}
+
+func TestMapperParameters(t *testing.T) {
+	g := NewGomegaWithT(t)
+	node := &v1.PredictiveUnit{
+		Name:       "model",
+		Parameters: []v1.Parameter{{Name: InputMappingParameter, Value: "probs:input_1, labels:input_2", Type: v1.STRING}},
+	}
+	mapper, err := NewMapper(node, nil)
+	g.Expect(err).To(BeNil())
+	names, err := mapper.Map([]Tensor{{Name: "labels"}, {Name: "probs"}, {Name: "other"}})
+	g.Expect(err).To(BeNil())
+	g.Expect(names).To(Equal([]string{"input_2", "input_1", "other"}))
+
+	node.Parameters[0].Value = "probs"
+	_, err = NewMapper(node, nil)
+	g.Expect(err).ToNot(BeNil())
+}
+
+func TestMapperMetadata(t *testing.T) {
+	g := NewGomegaWithT(t)
+	metadata := createMetadata(
+		map[string]interface{}{"name": "a", "datatype": "FP32", "shape": []interface{}{-1.0, 2.0}},
+		map[string]interface{}{"name": "b", "datatype": "INT64", "shape": []interface{}{"-1"}},
+	)
+	mapper, err := NewMapper(&v1.PredictiveUnit{Name: "model"}, metadata)
+	g.Expect(err).To(BeNil())
+
+	// Unknown names are mapped by position
+	names, err := mapper.Map([]Tensor{{Name: "x", Datatype: "FP32", Shape: []int64{3, 2}}, {Name: "y", Datatype: "INT64", Shape: []int64{3}}})
+	g.Expect(err).To(BeNil())
+	g.Expect(names).To(Equal([]string{"a", "b"}))
+
+	_, err = mapper.Map([]Tensor{{Name: "a", Datatype: "FP64", Shape: []int64{3, 2}}, {Name: "b", Datatype: "INT64", Shape: []int64{3}}})
+	g.Expect(err).ToNot(BeNil())
+
+	_, err = mapper.Map([]Tensor{{Name: "a", Datatype: "FP32", Shape: []int64{3, 3}}, {Name: "b", Datatype: "INT64", Shape: []int64{3}}})
+	g.Expect(err).ToNot(BeNil())
+}
+
+func TestMappersCache(t *testing.T) {
+	g := NewGomegaWithT(t)
+	model := v1.MODEL
+	predictor := &v1.PredictorSpec{
+		Name: "p",
+		Graph: v1.PredictiveUnit{
+			Name:     "model",
+			Type:     &model,
+			Endpoint: &v1.Endpoint{ServiceHost: "foo", ServicePort: 9000, HttpPort: 9000, GrpcPort: 9500},
+		},
+	}
+	calls := 0
+	mappers := NewMappers(predictor, true, func(ctx context.Context, modelName string, host string, port int32) (payload.ModelMetadata, error) {
+		calls++
+		// Metadata is fetched from the port of the client's transport
+		g.Expect(port).To(Equal(int32(9500)))
+		return payload.ModelMetadata{}, errors.New("no metadata")
+	}, logf.Log)
+
+	for i := 0; i < 2; i++ {
+		mapper, err := mappers.Get(context.Background(), "model")
+		g.Expect(err).To(BeNil())
+		g.Expect(mapper).ToNot(BeNil())
+	}
+	g.Expect(calls).To(Equal(1))
+
+	// Metadata is fetched again after the retry interval
+	mappers.retryInterval = 0
+	_, err := mappers.Get(context.Background(), "model")
+	g.Expect(err).To(BeNil())
+	g.Expect(calls).To(Equal(2))
+
+	mapper, err := mappers.Get(context.Background(), "unknown")
+	g.Expect(err).To(BeNil())
+	g.Expect(mapper).To(BeNil())
+}
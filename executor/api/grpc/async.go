@@ -0,0 +1,177 @@
+package grpc
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+	"github.com/seldonio/seldon-core/executor/api/async"
+	"github.com/seldonio/seldon-core/executor/api/payload"
+	"github.com/seldonio/seldon-core/executor/api/tracing"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Metadata of asynchronous prediction requests.
+const (
+	// Set to true to queue the request and return its job id, which is its Seldon-Puid
+	AsyncMetadata = "seldon-async"
+	// The job id of an asynchronous request to return the result of instead of running the request
+	AsyncJobMetadata = "seldon-async-job"
+	// How long to wait for the job to finish, e.g. 30s
+	AsyncWaitMetadata = "seldon-async-wait"
+	// A URL the result is posted to
+	AsyncCallbackMetadata = "seldon-callback-url"
+)
+
+// The status of the job is returned in the response header metadata
+var asyncStatusMetadata = strings.ToLower(async.StatusHeader)
+
+const asyncSpanName = "predictions"
+
+func firstMetadata(md metadata.MD, key string) string {
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// AsyncRequest returns whether a request should be queued and the id of the job whose result is
+// requested instead, if any.
+func AsyncRequest(md metadata.MD) (bool, string) {
+	submit, _ := strconv.ParseBool(firstMetadata(md, AsyncMetadata))
+	return submit, firstMetadata(md, AsyncJobMetadata)
+}
+
+// SubmitAsync queues a request to be run with the metadata of the call. The status of the job is
+// sent in the response header metadata.
+func SubmitAsync(ctx context.Context, jobs *async.Manager, md metadata.MD, run func(ctx context.Context, md metadata.MD) (proto.Message, error)) (*async.Job, error) {
+	if jobs == nil {
+		return nil, status.Error(codes.Unimplemented, "Asynchronous requests are not enabled")
+	}
+	// Keep the trace context of this call so the job is part of the same trace
+	jobMd := md.Copy()
+	tracing.Inject(ctx, tracing.MapCarrier(jobMd))
+	job, err := jobs.Submit(firstMetadata(md, payload.SeldonPUIDHeader), firstMetadata(md, AsyncCallbackMetadata), func(ctx context.Context) *async.Result {
+		ctx, span := tracing.StartServerSpan(ctx, tracing.MapCarrier(jobMd), asyncSpanName)
+		res, err := run(ctx, jobMd)
+		tracing.EndSpan(span, err)
+		return AsyncResult(res, err)
+	})
+	if err != nil {
+		return nil, err
+	}
+	_ = grpc.SetHeader(ctx, metadata.Pairs(asyncStatusMetadata, string(job.Status), payload.SeldonPUIDHeader, job.Id))
+	return job, nil
+}
+
+// AsyncResponse waits for a job for up to the time given in the metadata and unmarshals its result
+// into res if it has finished. The status of the job is sent in the response header metadata and an
+// error is returned if it failed.
+func AsyncResponse(ctx context.Context, jobs *async.Manager, md metadata.MD, id string, res proto.Message) (*async.Job, error) {
+	if jobs == nil {
+		return nil, status.Error(codes.Unimplemented, "Asynchronous requests are not enabled")
+	}
+	var wait time.Duration
+	if w := firstMetadata(md, AsyncWaitMetadata); w != "" {
+		var err error
+		if wait, err = time.ParseDuration(w); err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "Invalid %s %s: %v", AsyncWaitMetadata, w, err)
+		}
+	}
+	job, err := jobs.Wait(ctx, id, wait)
+	if err != nil {
+		return nil, err
+	}
+	_ = grpc.SetHeader(ctx, metadata.Pairs(asyncStatusMetadata, string(job.Status), payload.SeldonPUIDHeader, job.Id))
+	switch job.Status {
+	case async.JobFailed:
+		return nil, status.Error(codeFromHTTPStatus(job.Result.StatusCode), job.Result.Error)
+	case async.JobSucceeded:
+		if err := jsonpb.UnmarshalString(string(job.Result.Body), res); err != nil {
+			return nil, status.Errorf(codes.Internal, "Failed to read result of job %s: %v", id, err)
+		}
+	}
+	return job, nil
+}
+
+// AsyncResult stores the response of a gRPC request as JSON so it can also be fetched over REST or
+// sent to a callback URL. The response is stored with the error if it has one.
+func AsyncResult(res proto.Message, err error) *async.Result {
+	result := &async.Result{StatusCode: http.StatusOK, ContentType: "application/json"}
+	if err != nil {
+		st := status.Convert(err)
+		result.StatusCode = httpStatusFromCode(st.Code())
+		result.Error = st.Message()
+		if res == nil {
+			result.Body, _ = json.Marshal(map[string]string{"error": st.Message()})
+			return result
+		}
+	}
+	body, merr := (&jsonpb.Marshaler{}).MarshalToString(res)
+	if merr != nil {
+		return &async.Result{StatusCode: http.StatusInternalServerError, Error: merr.Error()}
+	}
+	result.Body = []byte(body)
+	return result
+}
+
+// httpStatusFromCode returns the HTTP status a failed gRPC job is stored with.
+func httpStatusFromCode(code codes.Code) int {
+	switch code {
+	case codes.InvalidArgument, codes.FailedPrecondition, codes.OutOfRange:
+		return http.StatusBadRequest
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists, codes.Aborted:
+		return http.StatusConflict
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// codeFromHTTPStatus returns the gRPC code of a failed job from the HTTP status it was stored with,
+// whether the job was submitted over REST or gRPC.
+func codeFromHTTPStatus(statusCode int) codes.Code {
+	switch statusCode {
+	case http.StatusBadRequest:
+		return codes.InvalidArgument
+	case http.StatusUnauthorized:
+		return codes.Unauthenticated
+	case http.StatusForbidden:
+		return codes.PermissionDenied
+	case http.StatusNotFound:
+		return codes.NotFound
+	case http.StatusConflict:
+		return codes.Aborted
+	case http.StatusTooManyRequests:
+		return codes.ResourceExhausted
+	case http.StatusNotImplemented:
+		return codes.Unimplemented
+	case http.StatusServiceUnavailable:
+		return codes.Unavailable
+	case http.StatusGatewayTimeout:
+		return codes.DeadlineExceeded
+	default:
+		return codes.Internal
+	}
+}
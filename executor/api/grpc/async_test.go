@@ -0,0 +1,44 @@
+package grpc
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/seldonio/seldon-core/executor/api/async"
+	"github.com/seldonio/seldon-core/executor/proto/tensorflow/serving"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
+)
+
+func TestAsyncResponseFailedJob(t *testing.T) {
+	g := NewGomegaWithT(t)
+	jobs, err := async.NewManager(async.NewMemoryResultStore(), async.DefaultOptions, logf.Log.WithName("test"))
+	g.Expect(err).To(BeNil())
+	jobs.Start()
+	defer jobs.Stop(context.Background())
+
+	// The code of a failed gRPC job is kept
+	_, err = jobs.Submit("job-1", "", func(ctx context.Context) *async.Result {
+		return AsyncResult(nil, status.Error(codes.InvalidArgument, "bad input"))
+	})
+	g.Expect(err).To(BeNil())
+	md := metadata.Pairs(AsyncWaitMetadata, "10s")
+	_, err = AsyncResponse(context.Background(), jobs, md, "job-1", &serving.PredictResponse{})
+	g.Expect(status.Code(err)).To(Equal(codes.InvalidArgument))
+	g.Expect(status.Convert(err).Message()).To(Equal("bad input"))
+
+	// Jobs submitted over REST are returned with the code of their HTTP status
+	_, err = jobs.Submit("job-2", "", func(ctx context.Context) *async.Result {
+		return &async.Result{StatusCode: http.StatusServiceUnavailable, Error: "unavailable"}
+	})
+	g.Expect(err).To(BeNil())
+	_, err = AsyncResponse(context.Background(), jobs, md, "job-2", &serving.PredictResponse{})
+	g.Expect(status.Code(err)).To(Equal(codes.Unavailable))
+
+	g.Expect(AsyncResult(nil, status.Error(codes.NotFound, "no model")).StatusCode).To(Equal(http.StatusNotFound))
+	g.Expect(codeFromHTTPStatus(http.StatusTeapot)).To(Equal(codes.Internal))
+}
@@ -4,9 +4,8 @@ import (
 	"context"
 	"github.com/go-logr/logr"
 	grpc_middleware "github.com/grpc-ecosystem/go-grpc-middleware"
-	grpc_opentracing "github.com/grpc-ecosystem/go-grpc-middleware/tracing/opentracing"
-	"github.com/opentracing/opentracing-go"
 	"github.com/seldonio/seldon-core/executor/api/metric"
+	"github.com/seldonio/seldon-core/executor/api/tracing"
 	"github.com/seldonio/seldon-core/executor/k8s"
 	v1 "github.com/seldonio/seldon-core/operator/apis/machinelearning.seldon.io/v1"
 	"google.golang.org/grpc"
@@ -25,9 +24,9 @@ func AddMetadataToOutgoingGrpcContext(ctx context.Context, meta map[string][]str
 }
 
 func AddClientInterceptors(predictor *v1.PredictorSpec, deploymentName, modelName string, annotations map[string]string, log logr.Logger) grpc.DialOption {
-	interceptors := []grpc.UnaryClientInterceptor{metric.NewClientMetrics(predictor, deploymentName, modelName).UnaryClientInterceptor()}
-	if opentracing.IsGlobalTracerRegistered() {
-		interceptors = append(interceptors, grpc_opentracing.UnaryClientInterceptor())
+	interceptors := []grpc.UnaryClientInterceptor{
+		metric.NewClientMetrics(predictor, deploymentName, modelName).UnaryClientInterceptor(),
+		tracing.UnaryClientInterceptor(),
 	}
 	if annotations != nil {
 		val := annotations[k8s.ANNOTATION_GRPC_TIMEOUT]
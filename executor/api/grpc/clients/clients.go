@@ -0,0 +1,27 @@
+package clients
+
+import (
+	"fmt"
+
+	"github.com/seldonio/seldon-core/executor/api"
+	"github.com/seldonio/seldon-core/executor/api/client"
+	"github.com/seldonio/seldon-core/executor/api/grpc/kfserving"
+	"github.com/seldonio/seldon-core/executor/api/grpc/seldon"
+	"github.com/seldonio/seldon-core/executor/api/grpc/tensorflow"
+	v1 "github.com/seldonio/seldon-core/operator/apis/machinelearning.seldon.io/v1"
+)
+
+// NewGrpcClient creates the gRPC client for a protocol. It lives in its own package as the clients
+// of each protocol import the grpc package.
+func NewGrpcClient(protocol string, deploymentName string, predictor *v1.PredictorSpec, annotations map[string]string) (client.SeldonApiClient, error) {
+	switch protocol {
+	case api.ProtocolSeldon:
+		return seldon.NewSeldonGrpcClient(predictor, deploymentName, annotations), nil
+	case api.ProtocolTensorflow:
+		return tensorflow.NewTensorflowGrpcClient(predictor, deploymentName, annotations), nil
+	case api.ProtocolKFServing:
+		return kfserving.NewKFServingGrpcClient(predictor, deploymentName, annotations), nil
+	default:
+		return nil, fmt.Errorf("Unknown protocol %s", protocol)
+	}
+}
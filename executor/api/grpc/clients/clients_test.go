@@ -0,0 +1,32 @@
+package clients
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/seldonio/seldon-core/executor/api"
+	"github.com/seldonio/seldon-core/executor/api/grpc/kfserving"
+	"github.com/seldonio/seldon-core/executor/api/grpc/seldon"
+	"github.com/seldonio/seldon-core/executor/api/grpc/tensorflow"
+	v1 "github.com/seldonio/seldon-core/operator/apis/machinelearning.seldon.io/v1"
+)
+
+func TestNewGrpcClient(t *testing.T) {
+	g := NewGomegaWithT(t)
+	predictor := &v1.PredictorSpec{Name: "p"}
+
+	c, err := NewGrpcClient(api.ProtocolSeldon, "dep", predictor, nil)
+	g.Expect(err).To(BeNil())
+	g.Expect(c).To(BeAssignableToTypeOf(&seldon.SeldonMessageGrpcClient{}))
+
+	c, err = NewGrpcClient(api.ProtocolTensorflow, "dep", predictor, nil)
+	g.Expect(err).To(BeNil())
+	g.Expect(c).To(BeAssignableToTypeOf(&tensorflow.TensorflowGrpcClient{}))
+
+	c, err = NewGrpcClient(api.ProtocolKFServing, "dep", predictor, nil)
+	g.Expect(err).To(BeNil())
+	g.Expect(c).To(BeAssignableToTypeOf(&kfserving.KFServingGrpcClient{}))
+
+	_, err = NewGrpcClient("unknown", "dep", predictor, nil)
+	g.Expect(err).ToNot(BeNil())
+}
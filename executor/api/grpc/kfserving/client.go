@@ -2,20 +2,36 @@ package kfserving
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"github.com/go-logr/logr"
-	"github.com/pkg/errors"
+	"github.com/golang/protobuf/jsonpb"
+	protobuf "github.com/golang/protobuf/proto"
+	"github.com/seldonio/seldon-core/executor/api/chain"
 	"github.com/seldonio/seldon-core/executor/api/client"
 	grpc2 "github.com/seldonio/seldon-core/executor/api/grpc"
 	"github.com/seldonio/seldon-core/executor/api/grpc/kfserving/inference"
 	"github.com/seldonio/seldon-core/executor/api/payload"
 	v1 "github.com/seldonio/seldon-core/operator/apis/machinelearning.seldon.io/v1"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 	"io"
 	"math"
 	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
 )
 
+const (
+	// MethodParameter is the request parameter telling a v2 model which graph method is being called
+	MethodParameter = "seldon_method"
+	// ChildParameter is the input parameter giving the index of the child an aggregated input came from
+	ChildParameter = "seldon_child"
+
+	MethodRoute     = "route"
+	MethodAggregate = "aggregate"
+	MethodFeedback  = "feedback"
+)
+
 type KFServingGrpcClient struct {
 	Log            logr.Logger
 	callOptions    []grpc.CallOption
@@ -23,14 +39,32 @@ type KFServingGrpcClient struct {
 	Predictor      *v1.PredictorSpec
 	DeploymentName string
 	annotations    map[string]string
+	mappers        *chain.Mappers
 }
 
 func (s *KFServingGrpcClient) IsGrpc() bool {
 	return true
 }
 
+// ModelMetadata decodes the metadata of a model to the same form as the JSON metadata of the v2 REST protocol.
 func (s *KFServingGrpcClient) ModelMetadata(ctx context.Context, modelName string, host string, port int32, msg payload.SeldonPayload, meta map[string][]string) (payload.ModelMetadata, error) {
-	panic("implement me")
+	if msg == nil {
+		msg = &payload.ProtoPayload{Msg: &inference.ModelMetadataRequest{Name: modelName}}
+	}
+	resPayload, err := s.Metadata(ctx, modelName, host, port, msg, meta)
+	if err != nil {
+		return payload.ModelMetadata{}, err
+	}
+	ma := jsonpb.Marshaler{OrigName: true}
+	data, err := ma.MarshalToString(resPayload.GetPayload().(protobuf.Message))
+	if err != nil {
+		return payload.ModelMetadata{}, err
+	}
+	var modelMetadata payload.ModelMetadata
+	if err := json.Unmarshal([]byte(data), &modelMetadata); err != nil {
+		return payload.ModelMetadata{}, err
+	}
+	return modelMetadata, nil
 }
 
 func NewKFServingGrpcClient(predictor *v1.PredictorSpec, deploymentName string, annotations map[string]string) client.SeldonApiClient {
@@ -46,6 +80,9 @@ func NewKFServingGrpcClient(predictor *v1.PredictorSpec, deploymentName string,
 		DeploymentName: deploymentName,
 		annotations:    annotations,
 	}
+	smgc.mappers = chain.NewMappers(predictor, true, func(ctx context.Context, modelName string, host string, port int32) (payload.ModelMetadata, error) {
+		return smgc.ModelMetadata(ctx, modelName, host, port, nil, nil)
+	}, smgc.Log)
 	return &smgc
 }
 
@@ -79,7 +116,7 @@ func (s *KFServingGrpcClient) Predict(ctx context.Context, modelName string, hos
 	case *inference.ModelInferRequest:
 		resp, err = grpcClient.ModelInfer(ctx, v, s.callOptions...)
 	default:
-		return nil, errors.Errorf("Invalid type %v", v)
+		return nil, status.Errorf(codes.InvalidArgument, "Invalid type %T", v)
 	}
 	if err != nil {
 		return nil, err
@@ -89,50 +126,153 @@ func (s *KFServingGrpcClient) Predict(ctx context.Context, modelName string, hos
 }
 
 func (s *KFServingGrpcClient) TransformInput(ctx context.Context, modelName string, host string, port int32, msg payload.SeldonPayload, meta map[string][]string) (payload.SeldonPayload, error) {
-	panic("implement me")
+	return s.Predict(ctx, modelName, host, port, msg, meta)
 }
 
+// Route calls ModelInfer with the route method parameter. The first element of the first output is the chosen child.
 func (s *KFServingGrpcClient) Route(ctx context.Context, modelName string, host string, port int32, msg payload.SeldonPayload, meta map[string][]string) (int, error) {
-	panic("implement me")
+	req, err := s.toInferRequest(ctx, modelName, msg)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := s.infer(ctx, modelName, host, port, setMethod(req, MethodRoute), meta)
+	if err != nil {
+		return 0, err
+	}
+	return routeFromResponse(resp)
 }
 
+// Combine calls ModelInfer with the aggregate method parameter. The outputs of all children are sent as inputs,
+// each with a parameter giving the index of the child it came from.
 func (s *KFServingGrpcClient) Combine(ctx context.Context, modelName string, host string, port int32, msgs []payload.SeldonPayload, meta map[string][]string) (payload.SeldonPayload, error) {
-	panic("implement me")
+	req := &inference.ModelInferRequest{ModelName: modelName}
+	for i, msg := range msgs {
+		childReq, err := s.toInferRequest(ctx, modelName, msg)
+		if err != nil {
+			return nil, err
+		}
+		for _, input := range childReq.Inputs {
+			input = protobuf.Clone(input).(*inference.ModelInferRequest_InferInputTensor)
+			if input.Parameters == nil {
+				input.Parameters = make(map[string]*inference.InferParameter)
+			}
+			input.Parameters[ChildParameter] = &inference.InferParameter{ParameterChoice: &inference.InferParameter_Int64Param{Int64Param: int64(i)}}
+			req.Inputs = append(req.Inputs, input)
+		}
+	}
+	resp, err := s.infer(ctx, modelName, host, port, setMethod(req, MethodAggregate), meta)
+	if err != nil {
+		return nil, err
+	}
+	return &payload.ProtoPayload{Msg: resp}, nil
 }
 
 func (s *KFServingGrpcClient) TransformOutput(ctx context.Context, modelName string, host string, port int32, msg payload.SeldonPayload, meta map[string][]string) (payload.SeldonPayload, error) {
-	panic("implement me")
+	return s.Predict(ctx, modelName, host, port, msg, meta)
 }
 
+// Feedback calls ModelInfer with the feedback method parameter as the v2 protocol has no feedback call.
 func (s *KFServingGrpcClient) Feedback(ctx context.Context, modelName string, host string, port int32, msg payload.SeldonPayload, meta map[string][]string) (payload.SeldonPayload, error) {
-	panic("implement me")
+	req, ok := msg.GetPayload().(*inference.ModelInferRequest)
+	if !ok {
+		return nil, status.Errorf(codes.InvalidArgument, "Invalid feedback type %T", msg.GetPayload())
+	}
+	resp, err := s.infer(ctx, modelName, host, port, setMethod(req, MethodFeedback), meta)
+	if err != nil {
+		return nil, err
+	}
+	return &payload.ProtoPayload{Msg: resp}, nil
 }
 
+func (s *KFServingGrpcClient) infer(ctx context.Context, modelName string, host string, port int32, req *inference.ModelInferRequest, meta map[string][]string) (*inference.ModelInferResponse, error) {
+	conn, err := s.getConnection(host, port, modelName)
+	if err != nil {
+		return nil, status.Errorf(codes.Unavailable, "Failed to connect to %s: %v", modelName, err)
+	}
+	grpcClient := inference.NewGRPCInferenceServiceClient(conn)
+	return grpcClient.ModelInfer(grpc2.AddMetadataToOutgoingGrpcContext(ctx, meta), req, s.callOptions...)
+}
+
+// toInferRequest turns the output of a previous node into a request.
+func (s *KFServingGrpcClient) toInferRequest(ctx context.Context, modelName string, msg payload.SeldonPayload) (*inference.ModelInferRequest, error) {
+	chained, err := s.Chain(ctx, modelName, msg)
+	if err != nil {
+		return nil, err
+	}
+	return chained.GetPayload().(*inference.ModelInferRequest), nil
+}
+
+// setMethod returns a copy of the request with the method parameter set.
+func setMethod(req *inference.ModelInferRequest, method string) *inference.ModelInferRequest {
+	req = protobuf.Clone(req).(*inference.ModelInferRequest)
+	if req.Parameters == nil {
+		req.Parameters = make(map[string]*inference.InferParameter)
+	}
+	req.Parameters[MethodParameter] = &inference.InferParameter{ParameterChoice: &inference.InferParameter_StringParam{StringParam: method}}
+	return req
+}
+
+func routeFromResponse(resp *inference.ModelInferResponse) (int, error) {
+	if len(resp.Outputs) == 0 {
+		return 0, status.Errorf(codes.Internal, "Router response has no outputs")
+	}
+	c := resp.Outputs[0].GetContents()
+	switch {
+	case len(c.GetIntContents()) > 0:
+		return int(c.IntContents[0]), nil
+	case len(c.GetInt64Contents()) > 0:
+		return int(c.Int64Contents[0]), nil
+	case len(c.GetUintContents()) > 0:
+		return int(c.UintContents[0]), nil
+	case len(c.GetUint64Contents()) > 0:
+		return int(c.Uint64Contents[0]), nil
+	case len(c.GetFp32Contents()) > 0:
+		return int(c.Fp32Contents[0]), nil
+	case len(c.GetFp64Contents()) > 0:
+		return int(c.Fp64Contents[0]), nil
+	}
+	return 0, status.Errorf(codes.Internal, "Router response has no numeric route")
+}
+
+// Chain turns the response of a model into a request for the next model, renaming the outputs to
+// the inputs of the next model.
 func (s *KFServingGrpcClient) Chain(ctx context.Context, modelName string, msg payload.SeldonPayload) (payload.SeldonPayload, error) {
 	switch v := msg.GetPayload().(type) {
 	case *inference.ModelInferRequest:
-		s.Log.Info("Identity chain")
 		return msg, nil
 	case *inference.ModelInferResponse:
-		s.Log.Info("Chain!")
-		inputTensors := make([]*inference.ModelInferRequest_InferInputTensor, len(v.Outputs))
-		for _, oTensor := range v.Outputs {
-			inputTensor := &inference.ModelInferRequest_InferInputTensor{
-				Name:       oTensor.Name,
+		mapper, err := s.mappers.Get(ctx, modelName)
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		outputs := make([]chain.Tensor, len(v.Outputs))
+		for i, oTensor := range v.Outputs {
+			outputs[i] = chain.Tensor{Name: oTensor.Name, Datatype: oTensor.Datatype, Shape: oTensor.Shape}
+		}
+		names, err := mapper.Map(outputs)
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		inputTensors := make([]*inference.ModelInferRequest_InferInputTensor, 0, len(v.Outputs))
+		for i, oTensor := range v.Outputs {
+			inputTensors = append(inputTensors, &inference.ModelInferRequest_InferInputTensor{
+				Name:       names[i],
 				Datatype:   oTensor.Datatype,
 				Shape:      oTensor.Shape,
 				Parameters: oTensor.Parameters,
 				Contents:   oTensor.Contents,
-			}
-			inputTensors = append(inputTensors, inputTensor)
+			})
 		}
 		pr := inference.ModelInferRequest{
-			Inputs: inputTensors,
+			ModelName: modelName,
+			Id:        v.Id,
+			Inputs:    inputTensors,
+			// Raw contents are in the same order as the tensors
+			RawInputContents: v.RawOutputContents,
 		}
-		msg2 := payload.ProtoPayload{Msg: &pr}
-		return &msg2, nil
+		return &payload.ProtoPayload{Msg: &pr}, nil
 	default:
-		return nil, errors.Errorf("Invalid type %v", v)
+		return nil, status.Errorf(codes.InvalidArgument, "Invalid type %T", v)
 	}
 }
 
@@ -148,7 +288,7 @@ func (s *KFServingGrpcClient) Status(ctx context.Context, modelName string, host
 	case *inference.ModelReadyRequest:
 		resp, err = grpcClient.ModelReady(ctx, v, s.callOptions...)
 	default:
-		return nil, errors.Errorf("Invalid type %v", v)
+		return nil, status.Errorf(codes.InvalidArgument, "Invalid type %T", v)
 	}
 	if err != nil {
 		return nil, err
@@ -169,7 +309,7 @@ func (s *KFServingGrpcClient) Metadata(ctx context.Context, modelName string, ho
 	case *inference.ModelMetadataRequest:
 		resp, err = grpcClient.ModelMetadata(ctx, v, s.callOptions...)
 	default:
-		return nil, errors.Errorf("Invalid type %v", v)
+		return nil, status.Errorf(codes.InvalidArgument, "Invalid type %T", v)
 	}
 	if err != nil {
 		return nil, err
@@ -178,14 +318,30 @@ func (s *KFServingGrpcClient) Metadata(ctx context.Context, modelName string, ho
 	return &resPayload, nil
 }
 
+// Unmarshall decodes a JSON or protobuf encoded ModelInferRequest.
 func (s *KFServingGrpcClient) Unmarshall(msg []byte, contentType string) (payload.SeldonPayload, error) {
-	panic("implement me")
+	req := &inference.ModelInferRequest{}
+	if contentType == grpc2.ProtobufContentType {
+		if err := protobuf.Unmarshal(msg, req); err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "Failed to decode request: %v", err)
+		}
+	} else if err := jsonpb.UnmarshalString(string(msg), req); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "Failed to decode request: %v", err)
+	}
+	return &payload.ProtoPayload{Msg: req}, nil
 }
 
+// Marshall writes a message as JSON using the field names of the v2 protocol.
 func (s *KFServingGrpcClient) Marshall(out io.Writer, msg payload.SeldonPayload) error {
-	panic("implement me")
+	pm, ok := msg.GetPayload().(protobuf.Message)
+	if !ok {
+		return status.Errorf(codes.Internal, "Invalid type %T", msg.GetPayload())
+	}
+	ma := jsonpb.Marshaler{OrigName: true}
+	return ma.Marshal(out, pm)
 }
 
+// CreateErrorPayload uses the error message of the streaming response as the v2 protocol has no unary error message.
 func (s *KFServingGrpcClient) CreateErrorPayload(err error) payload.SeldonPayload {
-	panic("implement me")
+	return &payload.ProtoPayload{Msg: &inference.ModelStreamInferResponse{ErrorMessage: err.Error()}}
 }
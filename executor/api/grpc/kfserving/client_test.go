@@ -0,0 +1,163 @@
+package kfserving
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/seldonio/seldon-core/executor/api/grpc/kfserving/inference"
+	"github.com/seldonio/seldon-core/executor/api/payload"
+	v1 "github.com/seldonio/seldon-core/operator/apis/machinelearning.seldon.io/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type testInferenceServer struct {
+	inference.UnimplementedGRPCInferenceServiceServer
+	requests chan *inference.ModelInferRequest
+}
+
+// ModelInfer returns a route of 1 for routers and echoes the inputs as outputs for all other calls.
+func (s *testInferenceServer) ModelInfer(ctx context.Context, req *inference.ModelInferRequest) (*inference.ModelInferResponse, error) {
+	s.requests <- req
+	if req.Parameters[MethodParameter].GetStringParam() == MethodRoute {
+		return &inference.ModelInferResponse{
+			Outputs: []*inference.ModelInferResponse_InferOutputTensor{
+				{Name: "route", Datatype: "INT64", Shape: []int64{1}, Contents: &inference.InferTensorContents{Int64Contents: []int64{1}}},
+			},
+		}, nil
+	}
+	resp := &inference.ModelInferResponse{ModelName: req.ModelName}
+	for _, input := range req.Inputs {
+		resp.Outputs = append(resp.Outputs, &inference.ModelInferResponse_InferOutputTensor{
+			Name:     input.Name,
+			Datatype: input.Datatype,
+			Shape:    input.Shape,
+			Contents: input.Contents,
+		})
+	}
+	return resp, nil
+}
+
+func createTestInferenceServer(g *GomegaWithT) (*testInferenceServer, string, int32, func()) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	g.Expect(err).To(BeNil())
+	srv := &testInferenceServer{requests: make(chan *inference.ModelInferRequest, 10)}
+	grpcServer := grpc.NewServer()
+	inference.RegisterGRPCInferenceServiceServer(grpcServer, srv)
+	go grpcServer.Serve(lis)
+	addr := lis.Addr().(*net.TCPAddr)
+	return srv, "127.0.0.1", int32(addr.Port), grpcServer.Stop
+}
+
+func createInferRequest(values ...float32) payload.SeldonPayload {
+	return &payload.ProtoPayload{Msg: &inference.ModelInferRequest{
+		ModelName: "model",
+		Inputs: []*inference.ModelInferRequest_InferInputTensor{
+			{Name: "input", Datatype: "FP32", Shape: []int64{int64(len(values))}, Contents: &inference.InferTensorContents{Fp32Contents: values}},
+		},
+	}}
+}
+
+func TestClientRoute(t *testing.T) {
+	g := NewGomegaWithT(t)
+	srv, host, port, stop := createTestInferenceServer(g)
+	defer stop()
+
+	c := NewKFServingGrpcClient(&v1.PredictorSpec{Name: "p"}, "dep", nil)
+	route, err := c.Route(context.Background(), "router", host, port, createInferRequest(1, 2), nil)
+	g.Expect(err).To(BeNil())
+	g.Expect(route).To(Equal(1))
+	req := <-srv.requests
+	g.Expect(req.Parameters[MethodParameter].GetStringParam()).To(Equal(MethodRoute))
+}
+
+func TestClientCombine(t *testing.T) {
+	g := NewGomegaWithT(t)
+	srv, host, port, stop := createTestInferenceServer(g)
+	defer stop()
+
+	c := NewKFServingGrpcClient(&v1.PredictorSpec{Name: "p"}, "dep", nil)
+	msgs := []payload.SeldonPayload{createInferRequest(1, 2), createInferRequest(3, 4)}
+	res, err := c.Combine(context.Background(), "combiner", host, port, msgs, nil)
+	g.Expect(err).To(BeNil())
+	g.Expect(len(res.GetPayload().(*inference.ModelInferResponse).Outputs)).To(Equal(2))
+
+	req := <-srv.requests
+	g.Expect(req.Parameters[MethodParameter].GetStringParam()).To(Equal(MethodAggregate))
+	g.Expect(req.Inputs[1].Parameters[ChildParameter].GetInt64Param()).To(Equal(int64(1)))
+	g.Expect(req.Inputs[1].Contents.Fp32Contents).To(Equal([]float32{3, 4}))
+	// The children's messages are not changed
+	g.Expect(msgs[1].GetPayload().(*inference.ModelInferRequest).Inputs[0].Parameters).To(BeNil())
+}
+
+func TestClientTransformInput(t *testing.T) {
+	g := NewGomegaWithT(t)
+	srv, host, port, stop := createTestInferenceServer(g)
+	defer stop()
+
+	c := NewKFServingGrpcClient(&v1.PredictorSpec{Name: "p"}, "dep", nil)
+	res, err := c.TransformInput(context.Background(), "transformer", host, port, createInferRequest(1, 2), nil)
+	g.Expect(err).To(BeNil())
+	g.Expect(res.GetPayload().(*inference.ModelInferResponse).Outputs[0].Contents.Fp32Contents).To(Equal([]float32{1, 2}))
+	req := <-srv.requests
+	g.Expect(req.Parameters).To(BeNil())
+}
+
+func TestClientInvalidPayload(t *testing.T) {
+	g := NewGomegaWithT(t)
+	c := NewKFServingGrpcClient(&v1.PredictorSpec{Name: "p"}, "dep", nil)
+	_, err := c.Feedback(context.Background(), "model", "127.0.0.1", 9000, &payload.BytesPayload{Msg: []byte("{}")}, nil)
+	g.Expect(status.Code(err)).To(Equal(codes.InvalidArgument))
+}
+
+func TestClientMarshalling(t *testing.T) {
+	g := NewGomegaWithT(t)
+	c := NewKFServingGrpcClient(&v1.PredictorSpec{Name: "p"}, "dep", nil)
+	msg, err := c.Unmarshall([]byte(`{"model_name":"model","inputs":[{"name":"input","datatype":"FP32","shape":["1"],"contents":{"fp32_contents":[1]}}]}`), "application/json")
+	g.Expect(err).To(BeNil())
+	g.Expect(msg.GetPayload().(*inference.ModelInferRequest).Inputs[0].Contents.Fp32Contents).To(Equal([]float32{1}))
+
+	var buf bytes.Buffer
+	g.Expect(c.Marshall(&buf, msg)).To(BeNil())
+	g.Expect(buf.String()).To(ContainSubstring(`"model_name":"model"`))
+
+	_, err = c.Unmarshall([]byte(`not json`), "application/json")
+	g.Expect(status.Code(err)).To(Equal(codes.InvalidArgument))
+
+	errPayload := c.CreateErrorPayload(status.Error(codes.Internal, "failed"))
+	g.Expect(errPayload.GetPayload().(*inference.ModelStreamInferResponse).ErrorMessage).To(ContainSubstring("failed"))
+}
+
+func TestClientChain(t *testing.T) {
+	g := NewGomegaWithT(t)
+	predictor := &v1.PredictorSpec{
+		Name: "p",
+		Graph: v1.PredictiveUnit{
+			Name:       "second",
+			Parameters: []v1.Parameter{{Name: "input_mapping", Value: "predict:input", Type: v1.STRING}},
+		},
+	}
+	c := NewKFServingGrpcClient(predictor, "dep", nil)
+	resp := &inference.ModelInferResponse{
+		ModelName: "first",
+		Id:        "1",
+		Outputs: []*inference.ModelInferResponse_InferOutputTensor{
+			{Name: "predict", Datatype: "FP32", Shape: []int64{2}},
+			{Name: "extra", Datatype: "BYTES", Shape: []int64{1}},
+		},
+		RawOutputContents: [][]byte{{0, 0, 128, 63, 0, 0, 0, 64}, []byte("a")},
+	}
+	res, err := c.Chain(context.Background(), "second", &payload.ProtoPayload{Msg: resp})
+	g.Expect(err).To(BeNil())
+	req := res.GetPayload().(*inference.ModelInferRequest)
+	g.Expect(req.ModelName).To(Equal("second"))
+	g.Expect(req.Id).To(Equal("1"))
+	g.Expect(len(req.Inputs)).To(Equal(2))
+	g.Expect(req.Inputs[0].Name).To(Equal("input"))
+	g.Expect(req.Inputs[1].Name).To(Equal("extra"))
+	g.Expect(req.RawInputContents).To(Equal(resp.RawOutputContents))
+}
@@ -3,12 +3,17 @@ package kfserving
 import (
 	"context"
 	"github.com/go-logr/logr"
+	proto2 "github.com/golang/protobuf/proto"
+	guuid "github.com/google/uuid"
+	"github.com/seldonio/seldon-core/executor/api"
+	"github.com/seldonio/seldon-core/executor/api/async"
 	"github.com/seldonio/seldon-core/executor/api/client"
 	"github.com/seldonio/seldon-core/executor/api/grpc"
 	"github.com/seldonio/seldon-core/executor/api/grpc/kfserving/inference"
 	"github.com/seldonio/seldon-core/executor/api/payload"
 	"github.com/seldonio/seldon-core/executor/predictor"
 	v1 "github.com/seldonio/seldon-core/operator/apis/machinelearning.seldon.io/v1"
+	"google.golang.org/grpc/metadata"
 	"net/url"
 	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
 )
@@ -19,6 +24,8 @@ type GrpcKFServingServer struct {
 	Log       logr.Logger
 	ServerUrl *url.URL
 	Namespace string
+	// Runs asynchronous predictions. Asynchronous requests are rejected if it is not set.
+	Jobs *async.Manager
 }
 
 func NewGrpcKFServingServer(predictor *v1.PredictorSpec, client client.SeldonApiClient, serverUrl *url.URL, namespace string) *GrpcKFServingServer {
@@ -69,9 +76,56 @@ func (g GrpcKFServingServer) ModelMetadata(ctx context.Context, request *inferen
 
 func (g GrpcKFServingServer) ModelInfer(ctx context.Context, request *inference.ModelInferRequest) (*inference.ModelInferResponse, error) {
 	md := grpc.CollectMetadata(ctx)
+	if submit, jobId := grpc.AsyncRequest(md); jobId != "" {
+		return g.asyncResponse(ctx, md, jobId, request)
+	} else if submit {
+		return g.submitAsync(ctx, md, request)
+	}
+	return g.infer(ctx, md, request)
+}
+
+// submitAsync validates a request and queues it to run on the graph. The job id is returned as the
+// id of the response.
+func (g GrpcKFServingServer) submitAsync(ctx context.Context, md metadata.MD, request *inference.ModelInferRequest) (*inference.ModelInferResponse, error) {
+	seldonPredictorProcess := predictor.NewPredictorProcess(ctx, g.Client, logf.Log.WithName("infer"), g.ServerUrl, g.Namespace, md)
+	if err := seldonPredictorProcess.ValidateRequest(&g.predictor.Graph, api.ProtocolKFServing, &payload.ProtoPayload{Msg: request}); err != nil {
+		return nil, err
+	}
+	job, err := grpc.SubmitAsync(ctx, g.Jobs, md, func(ctx context.Context, md metadata.MD) (proto2.Message, error) {
+		res, err := g.infer(ctx, md, request)
+		if err != nil {
+			// An untyped nil so only the error is stored
+			return nil, err
+		}
+		return res, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &inference.ModelInferResponse{ModelName: request.GetModelName(), Id: job.Id}, nil
+}
+
+// asyncResponse returns the result of an asynchronous request if it has finished and a response
+// with just the job id otherwise.
+func (g GrpcKFServingServer) asyncResponse(ctx context.Context, md metadata.MD, jobId string, request *inference.ModelInferRequest) (*inference.ModelInferResponse, error) {
+	res := &inference.ModelInferResponse{}
+	job, err := grpc.AsyncResponse(ctx, g.Jobs, md, jobId, res)
+	if err != nil {
+		return nil, err
+	}
+	if !job.Done() {
+		return &inference.ModelInferResponse{ModelName: request.GetModelName(), Id: job.Id}, nil
+	}
+	return res, nil
+}
+
+func (g GrpcKFServingServer) infer(ctx context.Context, md metadata.MD, request *inference.ModelInferRequest) (*inference.ModelInferResponse, error) {
 	ctx = context.WithValue(ctx, payload.SeldonPUIDHeader, md.Get(payload.SeldonPUIDHeader)[0])
 	seldonPredictorProcess := predictor.NewPredictorProcess(ctx, g.Client, logf.Log.WithName("infer"), g.ServerUrl, g.Namespace, md)
 	reqPayload := payload.ProtoPayload{Msg: request}
+	if err := seldonPredictorProcess.ValidateRequest(&g.predictor.Graph, api.ProtocolKFServing, &reqPayload); err != nil {
+		return nil, err
+	}
 	resPayload, err := seldonPredictorProcess.Predict(&g.predictor.Graph, &reqPayload)
 	if err != nil {
 		return nil, err
@@ -79,8 +133,27 @@ func (g GrpcKFServingServer) ModelInfer(ctx context.Context, request *inference.
 	return resPayload.GetPayload().(*inference.ModelInferResponse), nil
 }
 
+// ModelStreamInfer runs each request of the stream through the graph as a separate prediction.
+// Failed predictions are returned with an error message so the stream stays open.
 func (g GrpcKFServingServer) ModelStreamInfer(server inference.GRPCInferenceService_ModelStreamInferServer) error {
-	panic("Not Implemented")
+	ctx := server.Context()
+	streamMd := grpc.CollectMetadata(ctx)
+	return grpc.ServeStream(ctx, grpc.StreamConcurrency,
+		func() (interface{}, error) {
+			return server.Recv()
+		},
+		func(req interface{}) interface{} {
+			md := streamMd.Copy()
+			md.Set(payload.SeldonPUIDHeader, guuid.New().String())
+			res, err := g.infer(ctx, md, req.(*inference.ModelInferRequest))
+			if err != nil {
+				return &inference.ModelStreamInferResponse{ErrorMessage: err.Error()}
+			}
+			return &inference.ModelStreamInferResponse{InferResponse: res}
+		},
+		func(res interface{}) error {
+			return server.Send(res.(*inference.ModelStreamInferResponse))
+		})
 }
 
 func (g GrpcKFServingServer) ModelConfig(ctx context.Context, request *inference.ModelConfigRequest) (*inference.ModelConfigResponse, error) {
@@ -1001,93 +1001,93 @@ func init() {
 }
 
 var fileDescriptor_430b55197713f541 = []byte{
-	// 1363 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xcc, 0x58, 0x6d, 0x6f, 0x1b, 0xc5,
-	0x13, 0xf7, 0xf9, 0xe1, 0x1c, 0x8f, 0x93, 0xd6, 0xdd, 0x3e, 0xf9, 0x7f, 0xff, 0x22, 0xa2, 0x13,
-	0x2a, 0x41, 0xa2, 0xbe, 0xe2, 0x96, 0x12, 0x2a, 0x54, 0x29, 0x49, 0x9d, 0xa4, 0x52, 0xd3, 0x44,
-	0x97, 0x04, 0xa9, 0x48, 0x15, 0xac, 0xed, 0xb5, 0x73, 0xc4, 0xbe, 0x3d, 0x76, 0xf7, 0x1a, 0xf9,
-	0x35, 0xe2, 0x05, 0xdf, 0x80, 0xcf, 0xc0, 0x1b, 0xbe, 0x06, 0x12, 0x02, 0xc4, 0x57, 0x80, 0x2f,
-	0x82, 0xf6, 0xe1, 0x2e, 0x67, 0xf7, 0xea, 0xb4, 0x24, 0x42, 0xbc, 0xca, 0xee, 0xcc, 0xef, 0x37,
-	0x3b, 0x33, 0x3b, 0x33, 0xeb, 0x0b, 0x34, 0x22, 0x46, 0xfa, 0x41, 0x4f, 0x04, 0x34, 0x6c, 0x45,
-	0x8c, 0x0a, 0x8a, 0x96, 0x38, 0x19, 0xf5, 0x93, 0x1d, 0x77, 0xfe, 0x37, 0xa4, 0x74, 0x38, 0x22,
-	0x9e, 0xda, 0x76, 0xe3, 0x81, 0x87, 0xc3, 0x89, 0xd6, 0x39, 0xb7, 0x66, 0x55, 0x5c, 0xb0, 0xb8,
-	0x27, 0x8c, 0xf6, 0xff, 0xb3, 0x5a, 0x32, 0x8e, 0x44, 0x42, 0xbd, 0x2d, 0x48, 0xc8, 0x29, 0x1b,
-	0x8c, 0xe8, 0x89, 0xd7, 0xa3, 0x8c, 0x78, 0x03, 0x86, 0xc7, 0xe4, 0x84, 0xb2, 0x63, 0x4f, 0x6b,
-	0x34, 0xce, 0xfd, 0xb5, 0x08, 0x4b, 0xfb, 0xca, 0x9f, 0x1d, 0xc2, 0x39, 0x1e, 0x12, 0x74, 0x07,
-	0x6c, 0x2e, 0xb0, 0x88, 0x79, 0xd3, 0x5a, 0xb6, 0x56, 0xea, 0xed, 0xeb, 0xad, 0x29, 0x7f, 0x5b,
-	0xfb, 0x4a, 0xe9, 0x1b, 0x10, 0x7a, 0x1f, 0xca, 0x63, 0x22, 0x70, 0xb3, 0xa8, 0xc0, 0x57, 0x67,
-	0xc0, 0x3b, 0x44, 0x60, 0x5f, 0x01, 0xd0, 0x5d, 0x28, 0xf7, 0xb1, 0xc0, 0xcd, 0x92, 0x02, 0x3a,
-	0x33, 0xc0, 0xc7, 0x64, 0x80, 0xe3, 0x91, 0x78, 0x8c, 0x05, 0xde, 0x2e, 0xf8, 0x0a, 0x89, 0x1c,
-	0xa8, 0x76, 0x83, 0x50, 0x8a, 0x9a, 0xe5, 0x65, 0x6b, 0x65, 0x71, 0xbb, 0xe0, 0x27, 0x02, 0xa9,
-	0xe3, 0x82, 0x29, 0x5d, 0x65, 0xd9, 0x5a, 0xa9, 0x49, 0x9d, 0x11, 0xa0, 0xfb, 0xb0, 0xf0, 0x35,
-	0xa7, 0x9a, 0x68, 0xab, 0xd3, 0x6e, 0xb4, 0x74, 0xae, 0x5a, 0x49, 0xae, 0x5a, 0x9f, 0xe3, 0x51,
-	0x4c, 0xb6, 0x0b, 0x7e, 0x8a, 0x44, 0x0f, 0x00, 0x7a, 0x31, 0x17, 0x74, 0xac, 0x78, 0x55, 0xc5,
-	0xbb, 0xf6, 0x0a, 0x6f, 0x2d, 0x9c, 0x6c, 0x17, 0xfc, 0x0c, 0x72, 0x7d, 0x11, 0x40, 0x7a, 0xfb,
-	0x25, 0x0d, 0x09, 0x1d, 0xb8, 0x7f, 0x58, 0x50, 0xcf, 0xc4, 0x82, 0xae, 0x41, 0x25, 0xc4, 0x63,
-	0x22, 0x93, 0x59, 0x5a, 0xa9, 0xf9, 0x7a, 0x83, 0x3c, 0xb0, 0xf5, 0x2d, 0x98, 0xb4, 0xcd, 0xe6,
-	0xf8, 0x40, 0x29, 0xb7, 0x0b, 0xbe, 0x81, 0xa1, 0x07, 0x50, 0x0d, 0xfb, 0x98, 0x31, 0x3c, 0x49,
-	0xf3, 0x37, 0xeb, 0xd9, 0xd3, 0x80, 0x8b, 0x24, 0xaa, 0x04, 0x8c, 0x3e, 0x86, 0x05, 0x31, 0x30,
-	0x47, 0x95, 0x15, 0xf1, 0x66, 0xeb, 0xb4, 0x32, 0xcc, 0x39, 0x7b, 0xd2, 0x84, 0xcc, 0x45, 0x02,
-	0x9d, 0x89, 0xe9, 0x11, 0xd8, 0x1a, 0x88, 0x9a, 0x50, 0xe1, 0x47, 0x38, 0x22, 0x2a, 0x9a, 0xca,
-	0x7a, 0xb1, 0x61, 0xf9, 0x5a, 0x80, 0x1c, 0xb0, 0x5f, 0xca, 0xc3, 0x79, 0xb3, 0xb8, 0x5c, 0x5a,
-	0xb1, 0x94, 0xca, 0x48, 0xdc, 0xdf, 0x4b, 0x50, 0x96, 0x85, 0x80, 0x10, 0x94, 0xa3, 0x38, 0xe8,
-	0xab, 0xc2, 0xaa, 0xf9, 0x6a, 0x8d, 0x3e, 0x82, 0xb2, 0xc0, 0x43, 0x4d, 0xab, 0xb7, 0xdf, 0xc9,
-	0xa9, 0x9f, 0xd6, 0x01, 0x1e, 0xf2, 0x4e, 0x28, 0xd8, 0xc4, 0x57, 0x50, 0xf4, 0x10, 0xaa, 0x8c,
-	0xc6, 0x22, 0x08, 0x87, 0xcd, 0x92, 0x62, 0x2d, 0xe7, 0xb1, 0x7c, 0x0d, 0xd1, 0xc4, 0x84, 0x80,
-	0x36, 0xa1, 0xce, 0xc8, 0x37, 0x31, 0xe1, 0x62, 0x0f, 0x8b, 0xa3, 0x66, 0x59, 0xf1, 0xdf, 0xcb,
-	0xe5, 0x9f, 0xc2, 0xb4, 0x8d, 0x2c, 0x11, 0x79, 0x50, 0x1d, 0x13, 0xc1, 0x82, 0x1e, 0x6f, 0x56,
-	0x94, 0x8d, 0xeb, 0xaf, 0xda, 0x60, 0x41, 0xcf, 0x4f, 0x50, 0xce, 0x2e, 0xd4, 0xd2, 0x38, 0x50,
-	0x03, 0x4a, 0xc7, 0x64, 0x62, 0xf2, 0x20, 0x97, 0xe8, 0x43, 0xa8, 0xa8, 0x6c, 0x99, 0x82, 0x78,
-	0x4d, 0xc1, 0xfa, 0x1a, 0xf4, 0xb0, 0xb8, 0x6a, 0x39, 0x0f, 0x61, 0x31, 0x1b, 0x62, 0x8e, 0xcd,
-	0x6b, 0x59, 0x9b, 0x95, 0x2c, 0xf7, 0x11, 0x34, 0x66, 0xc3, 0x3b, 0x8b, 0x5f, 0xcb, 0xf0, 0xdd,
-	0xef, 0x8a, 0x60, 0xeb, 0x00, 0x73, 0x68, 0xf7, 0xa1, 0x2c, 0x26, 0x91, 0x66, 0x5d, 0xca, 0xbb,
-	0x1b, 0x16, 0xf4, 0xcc, 0x9f, 0x83, 0x49, 0x44, 0x7c, 0x85, 0x3e, 0x3d, 0x4c, 0xd6, 0x77, 0xd1,
-	0x1c, 0x86, 0xee, 0x99, 0xea, 0xd0, 0xf7, 0xf4, 0x6e, 0xbe, 0xad, 0x99, 0xfa, 0x70, 0x3e, 0x99,
-	0x9f, 0xea, 0xd7, 0x87, 0xe5, 0x01, 0x9c, 0xfa, 0x85, 0xea, 0x50, 0xdd, 0xd8, 0x3d, 0x7c, 0x76,
-	0xd0, 0xf1, 0x1b, 0x05, 0x54, 0x83, 0xca, 0xd6, 0xda, 0xe1, 0x56, 0xa7, 0x61, 0xc9, 0xe5, 0xc1,
-	0x93, 0x9d, 0x8e, 0xdf, 0x28, 0xba, 0xcf, 0xe1, 0xca, 0xd4, 0xf0, 0x94, 0x3d, 0x88, 0x1e, 0xc3,
-	0x25, 0x9e, 0x15, 0xea, 0xde, 0xaf, 0xb7, 0x6f, 0xcd, 0x0e, 0xd2, 0x2c, 0xc8, 0x9f, 0xe1, 0xb8,
-	0x3f, 0x5a, 0x60, 0xeb, 0x51, 0x2b, 0xdb, 0xa6, 0x47, 0xfb, 0x44, 0xc5, 0x50, 0xf1, 0xd5, 0x5a,
-	0xca, 0x82, 0x70, 0x40, 0x4d, 0x0c, 0x6a, 0x8d, 0x6e, 0x80, 0xcd, 0x08, 0xe6, 0x34, 0x54, 0x39,
-	0xac, 0xf9, 0x66, 0x87, 0x56, 0xd3, 0x89, 0x5e, 0xce, 0xbd, 0x12, 0x7d, 0x8c, 0xf9, 0xb3, 0x39,
-	0xc2, 0xc3, 0x64, 0xb8, 0xbb, 0xb7, 0x01, 0x4e, 0xa5, 0x32, 0x21, 0xfb, 0x87, 0x1b, 0x1b, 0x9d,
-	0xfd, 0xfd, 0x46, 0x41, 0x6e, 0x36, 0xd7, 0x9e, 0x3c, 0x3d, 0xf4, 0x3b, 0x0d, 0xcb, 0xfd, 0xd9,
-	0x82, 0x85, 0x4d, 0x42, 0xfa, 0x5d, 0xdc, 0x3b, 0x96, 0xb3, 0xca, 0x74, 0x8a, 0x79, 0x41, 0xe6,
-	0x07, 0x9e, 0x80, 0xd1, 0x2a, 0x2c, 0x30, 0xc2, 0x23, 0x1a, 0xf2, 0xa4, 0x0b, 0xe6, 0x13, 0x53,
-	0xb4, 0x0e, 0xfc, 0x04, 0xb3, 0xbe, 0x29, 0x1e, 0xb3, 0x43, 0x6d, 0xa8, 0x08, 0x16, 0xab, 0x36,
-	0x3f, 0xdb, 0x9c, 0x86, 0xba, 0xdf, 0x5a, 0x70, 0xd9, 0xf4, 0x86, 0x9f, 0xd8, 0xff, 0xd7, 0x23,
-	0x72, 0xef, 0x82, 0x63, 0x54, 0xb4, 0x4f, 0x46, 0x72, 0x1e, 0xc9, 0x79, 0x6c, 0xfc, 0x92, 0x97,
-	0x2f, 0xdf, 0x91, 0x64, 0x8e, 0xca, 0xb5, 0xfb, 0x93, 0x05, 0xd7, 0xa7, 0xac, 0x25, 0x24, 0xb4,
-	0x0c, 0xf5, 0xb1, 0x16, 0xa9, 0xb6, 0xd4, 0xa4, 0xac, 0x08, 0xb5, 0xc0, 0xe6, 0xbd, 0x23, 0x32,
-	0xc6, 0x67, 0x4c, 0x1f, 0x83, 0x4a, 0xcf, 0x2f, 0x9d, 0x9e, 0x8f, 0x1c, 0x58, 0x90, 0xa7, 0xa9,
-	0x23, 0xca, 0x4a, 0x9e, 0xee, 0x65, 0xc7, 0xe9, 0x67, 0x43, 0x8e, 0xca, 0x92, 0x79, 0x32, 0xdc,
-	0x3f, 0x8b, 0x70, 0x35, 0x27, 0xc8, 0xbc, 0xe8, 0xa4, 0xf5, 0x97, 0x84, 0xf1, 0x80, 0x86, 0xfa,
-	0xa5, 0xa8, 0xf9, 0xe9, 0x5e, 0xea, 0xa2, 0x11, 0x16, 0x03, 0xca, 0xc6, 0xc6, 0xa3, 0x74, 0x8f,
-	0x3e, 0x03, 0x3b, 0x08, 0xa3, 0x58, 0xf0, 0xd7, 0x4c, 0xfa, 0xdc, 0x8c, 0xf9, 0x86, 0x83, 0x1e,
-	0x41, 0x95, 0xc6, 0x42, 0xd1, 0x2b, 0x6f, 0x41, 0x4f, 0x48, 0x68, 0x13, 0x6c, 0xfd, 0x43, 0xa1,
-	0x69, 0x2b, 0x7a, 0x2b, 0x9f, 0x9e, 0x8d, 0xbe, 0xb5, 0xa1, 0x08, 0x7a, 0x9c, 0x19, 0xb6, 0xf3,
-	0x29, 0xd4, 0x33, 0xe2, 0xb7, 0x1a, 0x69, 0xbf, 0xa5, 0x49, 0xde, 0x62, 0x38, 0x3a, 0x9a, 0x9b,
-	0xe4, 0x4d, 0xb0, 0xc7, 0xd2, 0x97, 0xe4, 0x31, 0xce, 0x77, 0x77, 0xca, 0x4e, 0x4b, 0x39, 0x6f,
-	0xa6, 0xaf, 0x61, 0x67, 0x92, 0x5e, 0x3a, 0x5f, 0xd2, 0xcb, 0xff, 0x20, 0xe9, 0xce, 0x0b, 0xa8,
-	0x67, 0x9c, 0xca, 0x49, 0xd6, 0xea, 0xf4, 0x53, 0xeb, 0x9e, 0x7d, 0x29, 0x99, 0x84, 0xb6, 0xbf,
-	0x2f, 0x41, 0x75, 0x8b, 0x84, 0x44, 0xbe, 0x7d, 0xcf, 0xe0, 0xd2, 0x01, 0xc3, 0x21, 0x97, 0xa5,
-	0xf6, 0x44, 0x7a, 0x8f, 0xe6, 0xf6, 0xb7, 0x33, 0x57, 0xeb, 0x16, 0xd0, 0x2e, 0x5c, 0x4e, 0xed,
-	0xed, 0xaa, 0x70, 0xce, 0x69, 0xb0, 0x03, 0x15, 0xf9, 0x1b, 0x81, 0x9c, 0xd3, 0xcc, 0x0e, 0xd4,
-	0xd6, 0x86, 0x43, 0x46, 0x86, 0x58, 0x10, 0xb4, 0x3c, 0x0f, 0x2c, 0x1f, 0xc0, 0x33, 0xcd, 0x6d,
-	0xc1, 0xe2, 0x3e, 0x09, 0xfb, 0xe9, 0x83, 0x71, 0x73, 0x06, 0x9f, 0x28, 0xce, 0x32, 0xd4, 0xfe,
-	0xcb, 0x82, 0x8a, 0xba, 0x28, 0xb4, 0x05, 0xd5, 0x3d, 0xfd, 0x9d, 0x75, 0xce, 0x50, 0x2f, 0xca,
-	0x37, 0xb4, 0x0d, 0x0b, 0x69, 0xb3, 0xbd, 0x3a, 0x4f, 0x3b, 0xf2, 0x53, 0xcd, 0x79, 0x83, 0xd2,
-	0x73, 0x0b, 0xed, 0x1f, 0x2c, 0xb0, 0xd5, 0x2d, 0xb2, 0x8b, 0xba, 0xcf, 0x0b, 0xbb, 0x80, 0x17,
-	0x50, 0x4f, 0x0b, 0x96, 0xb0, 0x8b, 0xee, 0x87, 0x76, 0x1f, 0xae, 0xe8, 0x36, 0xc8, 0x1e, 0x72,
-	0xd1, 0x4d, 0xd2, 0x7e, 0x0e, 0x0b, 0x1b, 0x74, 0xdc, 0x0d, 0x42, 0xc2, 0x2e, 0xb8, 0xd2, 0xdb,
-	0xbf, 0x14, 0xc1, 0xd6, 0xb2, 0xff, 0x60, 0x85, 0x7e, 0x05, 0x4b, 0xd3, 0x0f, 0xef, 0x07, 0x6f,
-	0x30, 0x09, 0xf5, 0x2f, 0x90, 0x37, 0xab, 0x5c, 0xb4, 0x03, 0x4b, 0xd3, 0xaf, 0xce, 0xdb, 0x35,
-	0xc2, 0x14, 0xd7, 0x2d, 0xac, 0x1f, 0x41, 0x23, 0xa0, 0xd3, 0xc8, 0xf5, 0xc6, 0x5e, 0xfa, 0xef,
-	0x15, 0xf5, 0x19, 0xcb, 0xbf, 0x58, 0x1f, 0x06, 0xe2, 0x28, 0xee, 0xb6, 0x7a, 0x74, 0xec, 0x69,
-	0x6c, 0x40, 0xcd, 0xe2, 0x8e, 0xfa, 0x4f, 0x48, 0x10, 0xf6, 0xe2, 0x2e, 0x96, 0x1f, 0x4e, 0xde,
-	0x09, 0xc3, 0x51, 0x44, 0x18, 0xf7, 0x78, 0x3b, 0xf0, 0x86, 0xd4, 0x8b, 0x8e, 0x87, 0x1e, 0x8e,
-	0x82, 0xae, 0xad, 0xac, 0xdf, 0xfb, 0x3b, 0x00, 0x00, 0xff, 0xff, 0x78, 0xc7, 0x70, 0x40, 0xbd,
-	0x11, 0x00, 0x00,
+	// 1374 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xcc, 0x58, 0xdd, 0x6e, 0xdb, 0xc6,
+	0x12, 0x16, 0xf5, 0x43, 0x49, 0x23, 0x3b, 0x51, 0x36, 0x7f, 0x3a, 0x3c, 0x39, 0x38, 0x02, 0x51,
+	0xa4, 0x2e, 0xd0, 0x88, 0xa9, 0x92, 0xa6, 0x6e, 0x50, 0x04, 0xb0, 0x1d, 0xd9, 0x0e, 0x10, 0xc7,
+	0x2e, 0x65, 0x17, 0x48, 0x81, 0xa0, 0x5d, 0x49, 0x2b, 0x99, 0xb5, 0x44, 0xb2, 0xbb, 0xcb, 0x18,
+	0xba, 0x2e, 0x7a, 0xd1, 0x37, 0xe8, 0x55, 0x1f, 0xa0, 0x37, 0x7d, 0x8d, 0xde, 0xb4, 0x45, 0x5f,
+	0xa1, 0x7d, 0x91, 0x62, 0x7f, 0x48, 0x53, 0x0a, 0x23, 0x27, 0xb5, 0x50, 0xf4, 0xca, 0xbb, 0x33,
+	0xdf, 0x37, 0x3b, 0x33, 0x3b, 0x33, 0x2b, 0x1a, 0xea, 0x21, 0x25, 0x03, 0xaf, 0xcf, 0xbd, 0xc0,
+	0x6f, 0x85, 0x34, 0xe0, 0x01, 0x5a, 0x65, 0x64, 0x3c, 0x88, 0x77, 0xcc, 0xfa, 0xcf, 0x28, 0x08,
+	0x46, 0x63, 0xe2, 0xc8, 0x6d, 0x2f, 0x1a, 0x3a, 0xd8, 0x9f, 0x2a, 0x9d, 0x75, 0x6b, 0x5e, 0xc5,
+	0x38, 0x8d, 0xfa, 0x5c, 0x6b, 0xff, 0x3b, 0xaf, 0x25, 0x93, 0x90, 0xc7, 0xd4, 0xdb, 0x9c, 0xf8,
+	0x2c, 0xa0, 0xc3, 0x71, 0x70, 0xea, 0xf4, 0x03, 0x4a, 0x9c, 0x21, 0xc5, 0x13, 0x72, 0x1a, 0xd0,
+	0x13, 0x47, 0x69, 0x14, 0xce, 0xfe, 0x25, 0x0f, 0xab, 0x5d, 0xe9, 0xcf, 0x1e, 0x61, 0x0c, 0x8f,
+	0x08, 0xba, 0x03, 0x26, 0xe3, 0x98, 0x47, 0xac, 0x61, 0x34, 0x8d, 0xb5, 0x5a, 0xfb, 0x7a, 0x6b,
+	0xc6, 0xdf, 0x56, 0x57, 0x2a, 0x5d, 0x0d, 0x42, 0xef, 0x42, 0x71, 0x42, 0x38, 0x6e, 0xe4, 0x25,
+	0xf8, 0xea, 0x1c, 0x78, 0x8f, 0x70, 0xec, 0x4a, 0x00, 0xba, 0x0b, 0xc5, 0x01, 0xe6, 0xb8, 0x51,
+	0x90, 0x40, 0x6b, 0x0e, 0xf8, 0x98, 0x0c, 0x71, 0x34, 0xe6, 0x8f, 0x31, 0xc7, 0xbb, 0x39, 0x57,
+	0x22, 0x91, 0x05, 0xe5, 0x9e, 0xe7, 0x0b, 0x51, 0xa3, 0xd8, 0x34, 0xd6, 0x56, 0x76, 0x73, 0x6e,
+	0x2c, 0x10, 0x3a, 0xc6, 0xa9, 0xd4, 0x95, 0x9a, 0xc6, 0x5a, 0x55, 0xe8, 0xb4, 0x00, 0xdd, 0x87,
+	0xca, 0x57, 0x2c, 0x50, 0x44, 0x53, 0x9e, 0x76, 0xa3, 0xa5, 0x72, 0xd5, 0x8a, 0x73, 0xd5, 0xfa,
+	0x0c, 0x8f, 0x23, 0xb2, 0x9b, 0x73, 0x13, 0x24, 0x7a, 0x00, 0xd0, 0x8f, 0x18, 0x0f, 0x26, 0x92,
+	0x57, 0x96, 0xbc, 0x6b, 0xaf, 0xf0, 0x36, 0xfc, 0xe9, 0x6e, 0xce, 0x4d, 0x21, 0x37, 0x57, 0x00,
+	0x84, 0xb7, 0x5f, 0x04, 0x3e, 0x09, 0x86, 0xf6, 0xef, 0x06, 0xd4, 0x52, 0xb1, 0xa0, 0x6b, 0x50,
+	0xf2, 0xf1, 0x84, 0x88, 0x64, 0x16, 0xd6, 0xaa, 0xae, 0xda, 0x20, 0x07, 0x4c, 0x75, 0x0b, 0x8d,
+	0x7c, 0x66, 0x8e, 0x0f, 0xa5, 0x72, 0x37, 0xe7, 0x6a, 0x18, 0x7a, 0x00, 0x65, 0x7f, 0x80, 0x29,
+	0xc5, 0xd3, 0x24, 0x7f, 0xf3, 0x9e, 0x3d, 0xf5, 0x18, 0x8f, 0xa3, 0x8a, 0xc1, 0xe8, 0x43, 0xa8,
+	0xf0, 0xa1, 0x3e, 0xaa, 0x28, 0x89, 0x37, 0x5b, 0x67, 0x95, 0xa1, 0xcf, 0x39, 0x10, 0x26, 0x44,
+	0x2e, 0x62, 0xe8, 0x5c, 0x4c, 0x8f, 0xc0, 0x54, 0x40, 0xd4, 0x80, 0x12, 0x3b, 0xc6, 0x21, 0x91,
+	0xd1, 0x94, 0x36, 0xf3, 0x75, 0xc3, 0x55, 0x02, 0x64, 0x81, 0xf9, 0x52, 0x1c, 0xce, 0x1a, 0xf9,
+	0x66, 0x61, 0xcd, 0x90, 0x2a, 0x2d, 0xb1, 0x7f, 0x2b, 0x40, 0x51, 0x14, 0x02, 0x42, 0x50, 0x0c,
+	0x23, 0x6f, 0x20, 0x0b, 0xab, 0xea, 0xca, 0x35, 0xfa, 0x00, 0x8a, 0x1c, 0x8f, 0x14, 0xad, 0xd6,
+	0xfe, 0x5f, 0x46, 0xfd, 0xb4, 0x0e, 0xf1, 0x88, 0x75, 0x7c, 0x4e, 0xa7, 0xae, 0x84, 0xa2, 0x87,
+	0x50, 0xa6, 0x41, 0xc4, 0x3d, 0x7f, 0xd4, 0x28, 0x48, 0x56, 0x33, 0x8b, 0xe5, 0x2a, 0x88, 0x22,
+	0xc6, 0x04, 0xb4, 0x0d, 0x35, 0x4a, 0xbe, 0x8e, 0x08, 0xe3, 0x07, 0x98, 0x1f, 0x37, 0x8a, 0x92,
+	0xff, 0x4e, 0x26, 0xff, 0x0c, 0xa6, 0x6c, 0xa4, 0x89, 0xc8, 0x81, 0xf2, 0x84, 0x70, 0xea, 0xf5,
+	0x59, 0xa3, 0xd4, 0x2c, 0x64, 0x5c, 0xe1, 0x9e, 0xd4, 0xba, 0x31, 0xca, 0xda, 0x87, 0x6a, 0x12,
+	0x07, 0xaa, 0x43, 0xe1, 0x84, 0x4c, 0x75, 0x1e, 0xc4, 0x12, 0xbd, 0x0f, 0x25, 0x99, 0xad, 0x46,
+	0x7e, 0x51, 0xc1, 0xba, 0x0a, 0xf4, 0x30, 0xbf, 0x6e, 0x58, 0x0f, 0x61, 0x25, 0x1d, 0x62, 0x86,
+	0xcd, 0x6b, 0x69, 0x9b, 0xa5, 0x34, 0xf7, 0x11, 0xd4, 0xe7, 0xc3, 0x3b, 0x8f, 0x5f, 0x4d, 0xf1,
+	0xed, 0x6f, 0xf3, 0x60, 0xaa, 0x00, 0x33, 0x68, 0xf7, 0xa1, 0xc8, 0xa7, 0xa1, 0x62, 0x5d, 0xca,
+	0xba, 0x1b, 0xea, 0xf5, 0xf5, 0x9f, 0xc3, 0x69, 0x48, 0x5c, 0x89, 0x3e, 0x3b, 0x4c, 0xd4, 0x77,
+	0x5e, 0x1f, 0x86, 0xee, 0xe9, 0xea, 0x50, 0xf7, 0xf4, 0xff, 0x6c, 0x5b, 0x73, 0xf5, 0x61, 0x7d,
+	0xb4, 0x38, 0xd5, 0xaf, 0x0f, 0xcb, 0x01, 0x38, 0xf3, 0x0b, 0xd5, 0xa0, 0xbc, 0xb5, 0x7f, 0xf4,
+	0xec, 0xb0, 0xe3, 0xd6, 0x73, 0xa8, 0x0a, 0xa5, 0x9d, 0x8d, 0xa3, 0x9d, 0x4e, 0xdd, 0x10, 0xcb,
+	0xc3, 0x27, 0x7b, 0x1d, 0xb7, 0x9e, 0xb7, 0x9f, 0xc3, 0x95, 0x99, 0xe1, 0x29, 0x7a, 0x10, 0x3d,
+	0x86, 0x4b, 0x2c, 0x2d, 0x54, 0xbd, 0x5f, 0x6b, 0xdf, 0x9a, 0x1f, 0xa4, 0x69, 0x90, 0x3b, 0xc7,
+	0xb1, 0x7f, 0x34, 0xc0, 0x54, 0xa3, 0x56, 0xb4, 0x4d, 0x3f, 0x18, 0x10, 0x19, 0x43, 0xc9, 0x95,
+	0x6b, 0x21, 0xf3, 0xfc, 0x61, 0xa0, 0x63, 0x90, 0x6b, 0x74, 0x03, 0x4c, 0x4a, 0x30, 0x0b, 0x7c,
+	0x99, 0xc3, 0xaa, 0xab, 0x77, 0x68, 0x3d, 0x99, 0xe8, 0xc5, 0xcc, 0x2b, 0x51, 0xc7, 0xe8, 0x3f,
+	0xdb, 0x63, 0x3c, 0x8a, 0x87, 0xbb, 0x7d, 0x1b, 0xe0, 0x4c, 0x2a, 0x12, 0xd2, 0x3d, 0xda, 0xda,
+	0xea, 0x74, 0xbb, 0xf5, 0x9c, 0xd8, 0x6c, 0x6f, 0x3c, 0x79, 0x7a, 0xe4, 0x76, 0xea, 0x86, 0xfd,
+	0xb3, 0x01, 0x95, 0x6d, 0x42, 0x06, 0x3d, 0xdc, 0x3f, 0x11, 0xb3, 0x4a, 0x77, 0x8a, 0x7e, 0x41,
+	0x16, 0x07, 0x1e, 0x83, 0xd1, 0x3a, 0x54, 0x28, 0x61, 0x61, 0xe0, 0xb3, 0xb8, 0x0b, 0x16, 0x13,
+	0x13, 0xb4, 0x0a, 0xfc, 0x14, 0xd3, 0x81, 0x2e, 0x1e, 0xbd, 0x43, 0x6d, 0x28, 0x71, 0x1a, 0xc9,
+	0x36, 0x3f, 0xdf, 0x9c, 0x82, 0xda, 0xdf, 0x18, 0x70, 0x59, 0xf7, 0x86, 0x1b, 0xdb, 0xff, 0xc7,
+	0x23, 0xb2, 0xef, 0x82, 0xa5, 0x55, 0xc1, 0x80, 0x8c, 0xc5, 0x3c, 0x12, 0xf3, 0x58, 0xfb, 0x25,
+	0x2e, 0x5f, 0xbc, 0x23, 0xf1, 0x1c, 0x15, 0x6b, 0xfb, 0x27, 0x03, 0xae, 0xcf, 0x58, 0x8b, 0x49,
+	0xa8, 0x09, 0xb5, 0x89, 0x12, 0xc9, 0xb6, 0x54, 0xa4, 0xb4, 0x08, 0xb5, 0xc0, 0x64, 0xfd, 0x63,
+	0x32, 0xc1, 0xe7, 0x4c, 0x1f, 0x8d, 0x4a, 0xce, 0x2f, 0x9c, 0x9d, 0x8f, 0x2c, 0xa8, 0x88, 0xd3,
+	0xe4, 0x11, 0x45, 0x29, 0x4f, 0xf6, 0xa2, 0xe3, 0xd4, 0xb3, 0x21, 0x46, 0x65, 0x41, 0x3f, 0x19,
+	0xf6, 0x1f, 0x79, 0xb8, 0x9a, 0x11, 0x64, 0x56, 0x74, 0xc2, 0xfa, 0x4b, 0x42, 0x99, 0x17, 0xf8,
+	0xea, 0xa5, 0xa8, 0xba, 0xc9, 0x5e, 0xe8, 0xc2, 0x31, 0xe6, 0xc3, 0x80, 0x4e, 0xb4, 0x47, 0xc9,
+	0x1e, 0x7d, 0x02, 0xa6, 0xe7, 0x87, 0x11, 0x67, 0xaf, 0x99, 0xf4, 0x99, 0x19, 0x73, 0x35, 0x07,
+	0x3d, 0x82, 0x72, 0x10, 0x71, 0x49, 0x2f, 0xbd, 0x05, 0x3d, 0x26, 0xa1, 0x6d, 0x30, 0xd5, 0x0f,
+	0x85, 0x86, 0x29, 0xe9, 0xad, 0x6c, 0x7a, 0x3a, 0xfa, 0xd6, 0x96, 0x24, 0xa8, 0x71, 0xa6, 0xd9,
+	0xd6, 0xc7, 0x50, 0x4b, 0x89, 0xdf, 0x6a, 0xa4, 0xfd, 0x9a, 0x24, 0x79, 0x87, 0xe2, 0xf0, 0x78,
+	0x61, 0x92, 0xb7, 0xc1, 0x9c, 0x08, 0x5f, 0xe2, 0xc7, 0x38, 0xdb, 0xdd, 0x19, 0x3b, 0x2d, 0xe9,
+	0xbc, 0x9e, 0xbe, 0x9a, 0x9d, 0x4a, 0x7a, 0xe1, 0x62, 0x49, 0x2f, 0xfe, 0x8d, 0xa4, 0x5b, 0x2f,
+	0xa0, 0x96, 0x72, 0x2a, 0x23, 0x59, 0xeb, 0xb3, 0x4f, 0xad, 0x7d, 0xfe, 0xa5, 0xa4, 0x12, 0xda,
+	0xfe, 0xae, 0x00, 0xe5, 0x1d, 0xe2, 0x13, 0xf1, 0xf6, 0x3d, 0x83, 0x4b, 0x87, 0x14, 0xfb, 0x4c,
+	0x94, 0xda, 0x13, 0xe1, 0x3d, 0x5a, 0xd8, 0xdf, 0xd6, 0x42, 0xad, 0x9d, 0x43, 0xfb, 0x70, 0x39,
+	0xb1, 0xb7, 0x1f, 0xf1, 0x8b, 0x1b, 0xec, 0x40, 0x49, 0xfc, 0x46, 0x20, 0x17, 0x34, 0xb3, 0x07,
+	0xd5, 0x8d, 0xd1, 0x88, 0x92, 0x11, 0xe6, 0x04, 0x35, 0x17, 0x81, 0xc5, 0x03, 0x78, 0xae, 0xb9,
+	0x1d, 0x58, 0xe9, 0x12, 0x7f, 0x90, 0x3c, 0x18, 0x37, 0xe7, 0xf0, 0xb1, 0xe2, 0x3c, 0x43, 0xed,
+	0x3f, 0x0d, 0x28, 0xc9, 0x8b, 0x42, 0x3b, 0x50, 0x3e, 0x50, 0xdf, 0x59, 0x17, 0x0c, 0x75, 0x59,
+	0xbe, 0xa1, 0x5d, 0xa8, 0x24, 0xcd, 0xf6, 0xea, 0x3c, 0xed, 0x88, 0x4f, 0x35, 0xeb, 0x0d, 0x4a,
+	0xcf, 0xce, 0xb5, 0xbf, 0x37, 0xc0, 0x94, 0xb7, 0x48, 0x97, 0x75, 0x9f, 0x4b, 0xbb, 0x80, 0x17,
+	0x50, 0x4b, 0x0a, 0x96, 0xd0, 0x65, 0xf7, 0x43, 0x7b, 0x00, 0x57, 0x54, 0x1b, 0xa4, 0x0f, 0x59,
+	0x76, 0x93, 0xb4, 0x9f, 0x43, 0x65, 0x2b, 0x98, 0xf4, 0x3c, 0x9f, 0xd0, 0x25, 0x57, 0x7a, 0xfb,
+	0x87, 0x02, 0x98, 0x4a, 0xf6, 0x2f, 0xac, 0xd0, 0x2f, 0x61, 0x75, 0xf6, 0xe1, 0x7d, 0xef, 0x0d,
+	0x26, 0xa1, 0xfa, 0x05, 0xf2, 0x66, 0x95, 0x8b, 0xf6, 0x60, 0x75, 0xf6, 0xd5, 0x79, 0xbb, 0x46,
+	0x98, 0xe1, 0xda, 0x39, 0xf4, 0x29, 0xac, 0x76, 0x39, 0x25, 0x78, 0xb2, 0x94, 0x44, 0xae, 0x19,
+	0x77, 0x8d, 0xcd, 0x63, 0xa8, 0x7b, 0xc1, 0x2c, 0x6e, 0xb3, 0x7e, 0x90, 0xfc, 0xc7, 0x46, 0x7e,
+	0x19, 0xb3, 0xcf, 0x37, 0x47, 0x1e, 0x3f, 0x8e, 0x7a, 0xad, 0x7e, 0x30, 0x71, 0x14, 0xd6, 0x0b,
+	0xf4, 0xe2, 0x8e, 0xfc, 0xe7, 0x8a, 0xe7, 0xf7, 0xa3, 0x1e, 0x16, 0xdf, 0x62, 0xce, 0x29, 0xc5,
+	0x61, 0x48, 0x28, 0x73, 0x58, 0xdb, 0x73, 0x46, 0x81, 0x13, 0x9e, 0x8c, 0x1c, 0x1c, 0x7a, 0x3d,
+	0x53, 0x5a, 0xbf, 0xf7, 0xd7, 0x00, 0x25, 0xdd, 0xaa, 0xf7, 0x10, 0x12, 0x00, 0x00,
 }
 
 // Reference imports to suppress errors if they are not otherwise used.
@@ -1790,6 +1790,7 @@ type SeldonClient interface {
 	SendFeedback(ctx context.Context, in *Feedback, opts ...grpc.CallOption) (*SeldonMessage, error)
 	ModelMetadata(ctx context.Context, in *SeldonModelMetadataRequest, opts ...grpc.CallOption) (*SeldonModelMetadata, error)
 	GraphMetadata(ctx context.Context, in *empty.Empty, opts ...grpc.CallOption) (*SeldonGraphMetadata, error)
+	StreamPredict(ctx context.Context, opts ...grpc.CallOption) (Seldon_StreamPredictClient, error)
 }
 
 type seldonClient struct {
@@ -1836,12 +1837,44 @@ func (c *seldonClient) GraphMetadata(ctx context.Context, in *empty.Empty, opts
 	return out, nil
 }
 
+func (c *seldonClient) StreamPredict(ctx context.Context, opts ...grpc.CallOption) (Seldon_StreamPredictClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_Seldon_serviceDesc.Streams[0], "/seldon.protos.Seldon/StreamPredict", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &seldonStreamPredictClient{stream}
+	return x, nil
+}
+
+type Seldon_StreamPredictClient interface {
+	Send(*SeldonMessage) error
+	Recv() (*SeldonMessage, error)
+	grpc.ClientStream
+}
+
+type seldonStreamPredictClient struct {
+	grpc.ClientStream
+}
+
+func (x *seldonStreamPredictClient) Send(m *SeldonMessage) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *seldonStreamPredictClient) Recv() (*SeldonMessage, error) {
+	m := new(SeldonMessage)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 // SeldonServer is the server API for Seldon service.
 type SeldonServer interface {
 	Predict(context.Context, *SeldonMessage) (*SeldonMessage, error)
 	SendFeedback(context.Context, *Feedback) (*SeldonMessage, error)
 	ModelMetadata(context.Context, *SeldonModelMetadataRequest) (*SeldonModelMetadata, error)
 	GraphMetadata(context.Context, *empty.Empty) (*SeldonGraphMetadata, error)
+	StreamPredict(Seldon_StreamPredictServer) error
 }
 
 // UnimplementedSeldonServer can be embedded to have forward compatible implementations.
@@ -1861,6 +1894,10 @@ func (*UnimplementedSeldonServer) GraphMetadata(ctx context.Context, req *empty.
 	return nil, status.Errorf(codes.Unimplemented, "method GraphMetadata not implemented")
 }
 
+func (*UnimplementedSeldonServer) StreamPredict(srv Seldon_StreamPredictServer) error {
+	return status.Errorf(codes.Unimplemented, "method StreamPredict not implemented")
+}
+
 func RegisterSeldonServer(s *grpc.Server, srv SeldonServer) {
 	s.RegisterService(&_Seldon_serviceDesc, srv)
 }
@@ -1937,6 +1974,32 @@ func _Seldon_GraphMetadata_Handler(srv interface{}, ctx context.Context, dec fun
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Seldon_StreamPredict_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(SeldonServer).StreamPredict(&seldonStreamPredictServer{stream})
+}
+
+type Seldon_StreamPredictServer interface {
+	Send(*SeldonMessage) error
+	Recv() (*SeldonMessage, error)
+	grpc.ServerStream
+}
+
+type seldonStreamPredictServer struct {
+	grpc.ServerStream
+}
+
+func (x *seldonStreamPredictServer) Send(m *SeldonMessage) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *seldonStreamPredictServer) Recv() (*SeldonMessage, error) {
+	m := new(SeldonMessage)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 var _Seldon_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "seldon.protos.Seldon",
 	HandlerType: (*SeldonServer)(nil),
@@ -1958,6 +2021,13 @@ var _Seldon_serviceDesc = grpc.ServiceDesc{
 			Handler:    _Seldon_GraphMetadata_Handler,
 		},
 	},
-	Streams:  []grpc.StreamDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamPredict",
+			Handler:       _Seldon_StreamPredict_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
 	Metadata: "prediction.proto",
 }
@@ -3,15 +3,23 @@ package seldon
 import (
 	"context"
 	"github.com/go-logr/logr"
+	proto2 "github.com/golang/protobuf/proto"
 	empty "github.com/golang/protobuf/ptypes/empty"
+	guuid "github.com/google/uuid"
+	"github.com/seldonio/seldon-core/executor/api"
+	"github.com/seldonio/seldon-core/executor/api/async"
 	"github.com/seldonio/seldon-core/executor/api/client"
 	"github.com/seldonio/seldon-core/executor/api/grpc"
 	"github.com/seldonio/seldon-core/executor/api/grpc/seldon/proto"
 	"github.com/seldonio/seldon-core/executor/api/payload"
 	"github.com/seldonio/seldon-core/executor/predictor"
 	v1 "github.com/seldonio/seldon-core/operator/apis/machinelearning.seldon.io/v1"
+	grpc2 "google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"net/http"
 	"net/url"
 	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
+	"strconv"
 )
 
 type GrpcSeldonServer struct {
@@ -20,23 +28,82 @@ type GrpcSeldonServer struct {
 	Log       logr.Logger
 	ServerUrl *url.URL
 	Namespace string
+	// Runs asynchronous predictions. Asynchronous requests are rejected if it is not set.
+	Jobs *async.Manager
+
+	metadataCache *predictor.GraphMetadataCache
 }
 
-func NewGrpcSeldonServer(predictor *v1.PredictorSpec, client client.SeldonApiClient, serverUrl *url.URL, namespace string) *GrpcSeldonServer {
+func NewGrpcSeldonServer(predictorSpec *v1.PredictorSpec, client client.SeldonApiClient, serverUrl *url.URL, namespace string) *GrpcSeldonServer {
 	return &GrpcSeldonServer{
 		Client:    client,
-		predictor: predictor,
+		predictor: predictorSpec,
 		Log:       logf.Log.WithName("SeldonGrpcApi"),
 		ServerUrl: serverUrl,
 		Namespace: namespace,
+
+		metadataCache: predictor.NewGraphMetadataCache(client, predictorSpec, serverUrl, namespace, predictor.NewReadyChecker(predictorSpec, api.ProtocolSeldon, nil, client)),
 	}
 }
 
 func (g GrpcSeldonServer) Predict(ctx context.Context, req *proto.SeldonMessage) (*proto.SeldonMessage, error) {
 	md := grpc.CollectMetadata(ctx)
+	if submit, jobId := grpc.AsyncRequest(md); jobId != "" {
+		return g.asyncResponse(ctx, md, jobId)
+	} else if submit {
+		return g.submitAsync(ctx, md, req)
+	}
+	return g.predict(ctx, md, req)
+}
+
+// submitAsync validates a request and queues it to run on the graph. The job id is returned in the
+// puid of the response.
+func (g GrpcSeldonServer) submitAsync(ctx context.Context, md metadata.MD, req *proto.SeldonMessage) (*proto.SeldonMessage, error) {
+	seldonPredictorProcess := predictor.NewPredictorProcess(ctx, g.Client, logf.Log.WithName("SeldonMessageRestClient"), g.ServerUrl, g.Namespace, md)
+	if err := seldonPredictorProcess.ValidateRequest(&g.predictor.Graph, api.ProtocolSeldon, &payload.ProtoPayload{Msg: req}); err != nil {
+		return nil, err
+	}
+	job, err := grpc.SubmitAsync(ctx, g.Jobs, md, func(ctx context.Context, md metadata.MD) (proto2.Message, error) {
+		res, err := g.predict(ctx, md, req)
+		if err != nil && res == nil {
+			res = &proto.SeldonMessage{Status: &proto.Status{Code: http.StatusInternalServerError, Info: err.Error(), Status: proto.Status_FAILURE}}
+		}
+		return res, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return asyncStatusMessage(job), nil
+}
+
+// asyncResponse returns the result of an asynchronous request if it has finished and its status
+// otherwise.
+func (g GrpcSeldonServer) asyncResponse(ctx context.Context, md metadata.MD, jobId string) (*proto.SeldonMessage, error) {
+	res := &proto.SeldonMessage{}
+	job, err := grpc.AsyncResponse(ctx, g.Jobs, md, jobId, res)
+	if err != nil {
+		return nil, err
+	}
+	if !job.Done() {
+		return asyncStatusMessage(job), nil
+	}
+	return res, nil
+}
+
+func asyncStatusMessage(job *async.Job) *proto.SeldonMessage {
+	return &proto.SeldonMessage{
+		Meta:   &proto.Meta{Puid: job.Id},
+		Status: &proto.Status{Code: http.StatusAccepted, Info: string(job.Status), Status: proto.Status_SUCCESS},
+	}
+}
+
+func (g GrpcSeldonServer) predict(ctx context.Context, md metadata.MD, req *proto.SeldonMessage) (*proto.SeldonMessage, error) {
 	ctx = context.WithValue(ctx, payload.SeldonPUIDHeader, md.Get(payload.SeldonPUIDHeader)[0])
 	seldonPredictorProcess := predictor.NewPredictorProcess(ctx, g.Client, logf.Log.WithName("SeldonMessageRestClient"), g.ServerUrl, g.Namespace, md)
 	reqPayload := payload.ProtoPayload{Msg: req}
+	if err := seldonPredictorProcess.ValidateRequest(&g.predictor.Graph, api.ProtocolSeldon, &reqPayload); err != nil {
+		return nil, err
+	}
 	resPayload, err := seldonPredictorProcess.Predict(&g.predictor.Graph, &reqPayload)
 	if err != nil {
 		g.Log.Error(err, "Failed to call predict")
@@ -45,6 +112,34 @@ func (g GrpcSeldonServer) Predict(ctx context.Context, req *proto.SeldonMessage)
 	return payloadToMessage(resPayload), nil
 }
 
+// StreamPredict runs each message of the stream through the graph as a separate prediction.
+// Failed predictions are returned as messages with a failure status so the stream stays open.
+func (g GrpcSeldonServer) StreamPredict(stream proto.Seldon_StreamPredictServer) error {
+	ctx := stream.Context()
+	streamMd := grpc.CollectMetadata(ctx)
+	return grpc.ServeStream(ctx, grpc.StreamConcurrency,
+		func() (interface{}, error) {
+			return stream.Recv()
+		},
+		func(req interface{}) interface{} {
+			sm := req.(*proto.SeldonMessage)
+			md := streamMd.Copy()
+			if puid := sm.GetMeta().GetPuid(); puid != "" {
+				md.Set(payload.SeldonPUIDHeader, puid)
+			} else {
+				md.Set(payload.SeldonPUIDHeader, guuid.New().String())
+			}
+			res, err := g.predict(ctx, md, sm)
+			if err != nil {
+				return &proto.SeldonMessage{Status: &proto.Status{Code: http.StatusInternalServerError, Info: err.Error(), Status: proto.Status_FAILURE}}
+			}
+			return res
+		},
+		func(res interface{}) error {
+			return stream.Send(res.(*proto.SeldonMessage))
+		})
+}
+
 func (g GrpcSeldonServer) SendFeedback(ctx context.Context, req *proto.Feedback) (*proto.SeldonMessage, error) {
 	seldonPredictorProcess := predictor.NewPredictorProcess(ctx, g.Client, logf.Log.WithName("SeldonMessageRestClient"), g.ServerUrl, g.Namespace, grpc.CollectMetadata(ctx))
 	reqPayload := payload.ProtoPayload{Msg: req}
@@ -69,10 +164,12 @@ func (g GrpcSeldonServer) GraphMetadata(ctx context.Context, req *empty.Empty) (
 
 	seldonPredictorProcess := predictor.NewPredictorProcess(ctx, g.Client, logf.Log.WithName("SeldonMessageRestClient"), g.ServerUrl, g.Namespace, grpc.CollectMetadata(ctx))
 
-	graphMetadata, err := seldonPredictorProcess.GraphMetadata(g.predictor)
+	graphMetadata, age, err := g.metadataCache.GraphMetadata(&seldonPredictorProcess)
 	if err != nil {
 		return nil, err
 	}
+	// The age of cached metadata is returned in a header as for REST
+	_ = grpc2.SetHeader(ctx, metadata.Pairs("age", strconv.Itoa(int(age.Seconds()))))
 
 	output := &proto.SeldonGraphMetadata{
 		Name:    graphMetadata.Name,
@@ -2,14 +2,25 @@ package seldon
 
 import (
 	"context"
+	"fmt"
 	"github.com/golang/protobuf/jsonpb"
 	empty "github.com/golang/protobuf/ptypes/empty"
 	. "github.com/onsi/gomega"
+	"github.com/seldonio/seldon-core/executor/api/async"
+	seldongrpc "github.com/seldonio/seldon-core/executor/api/grpc"
 	"github.com/seldonio/seldon-core/executor/api/grpc/seldon/proto"
 	"github.com/seldonio/seldon-core/executor/api/payload"
 	"github.com/seldonio/seldon-core/executor/api/test"
 	v1 "github.com/seldonio/seldon-core/operator/apis/machinelearning.seldon.io/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"io"
+	"net"
+	"net/http"
 	"net/url"
+	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
 	"testing"
 )
 
@@ -179,3 +190,93 @@ func TestGraphMetadata(t *testing.T) {
 	}
 
 }
+
+func TestStreamPredict(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	model := v1.MODEL
+	p := v1.PredictorSpec{
+		Name: "p",
+		Graph: v1.PredictiveUnit{
+			Type: &model,
+			Endpoint: &v1.Endpoint{
+				ServiceHost: "foo",
+				ServicePort: 9000,
+				Type:        v1.REST,
+			},
+		},
+	}
+	url, _ := url.Parse("http://localhost")
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	g.Expect(err).To(BeNil())
+	grpcServer := grpc.NewServer()
+	proto.RegisterSeldonServer(grpcServer, NewGrpcSeldonServer(&p, &test.SeldonMessageTestClient{}, url, "default"))
+	go grpcServer.Serve(lis)
+	defer grpcServer.Stop()
+
+	conn, err := grpc.Dial(lis.Addr().String(), grpc.WithInsecure())
+	g.Expect(err).To(BeNil())
+	defer conn.Close()
+	stream, err := proto.NewSeldonClient(conn).StreamPredict(context.Background())
+	g.Expect(err).To(BeNil())
+
+	for i := 0; i < 10; i++ {
+		var sm proto.SeldonMessage
+		err := jsonpb.UnmarshalString(fmt.Sprintf(`{"data":{"ndarray":[[%d]]}}`, i), &sm)
+		g.Expect(err).Should(BeNil())
+		g.Expect(stream.Send(&sm)).To(BeNil())
+	}
+	g.Expect(stream.CloseSend()).To(BeNil())
+
+	for i := 0; i < 10; i++ {
+		res, err := stream.Recv()
+		g.Expect(err).To(BeNil())
+		g.Expect(res.GetData().GetNdarray().Values[0].GetListValue().Values[0].GetNumberValue()).Should(Equal(float64(i)))
+	}
+	_, err = stream.Recv()
+	g.Expect(err).To(Equal(io.EOF))
+}
+
+func TestAsyncPredict(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	model := v1.MODEL
+	p := v1.PredictorSpec{
+		Name: "p",
+		Graph: v1.PredictiveUnit{
+			Type: &model,
+			Endpoint: &v1.Endpoint{
+				ServiceHost: "foo",
+				ServicePort: 9000,
+				Type:        v1.REST,
+			},
+		},
+	}
+	url, _ := url.Parse("http://localhost")
+	server := NewGrpcSeldonServer(&p, &test.SeldonMessageTestClient{}, url, "default")
+	jobs, err := async.NewManager(async.NewMemoryResultStore(), async.DefaultOptions, logf.Log.WithName("test"))
+	g.Expect(err).Should(BeNil())
+	jobs.Start()
+	defer jobs.Stop(context.Background())
+	server.Jobs = jobs
+
+	var sm proto.SeldonMessage
+	err = jsonpb.UnmarshalString(`{"data":{"ndarray":[[1.1,2.0]]}}`, &sm)
+	g.Expect(err).Should(BeNil())
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(seldongrpc.AsyncMetadata, "true", payload.SeldonPUIDHeader, "job-1"))
+	res, err := server.Predict(ctx, &sm)
+	g.Expect(err).To(BeNil())
+	g.Expect(res.GetMeta().GetPuid()).To(Equal("job-1"))
+	g.Expect(res.GetStatus().GetCode()).To(Equal(int32(http.StatusAccepted)))
+
+	ctx = metadata.NewIncomingContext(context.Background(), metadata.Pairs(seldongrpc.AsyncJobMetadata, "job-1", seldongrpc.AsyncWaitMetadata, "10s"))
+	res, err = server.Predict(ctx, &proto.SeldonMessage{})
+	g.Expect(err).To(BeNil())
+	g.Expect(res.GetData().GetNdarray().Values[0].GetListValue().Values[0].GetNumberValue()).Should(Equal(1.1))
+
+	// Without a manager asynchronous requests are rejected
+	server.Jobs = nil
+	_, err = server.Predict(ctx, &proto.SeldonMessage{})
+	g.Expect(status.Code(err)).To(Equal(codes.Unimplemented))
+}
@@ -8,10 +8,9 @@ import (
 	"github.com/go-logr/logr"
 	guuid "github.com/google/uuid"
 	grpc_middleware "github.com/grpc-ecosystem/go-grpc-middleware"
-	grpc_opentracing "github.com/grpc-ecosystem/go-grpc-middleware/tracing/opentracing"
-	"github.com/opentracing/opentracing-go"
 	"github.com/seldonio/seldon-core/executor/api/metric"
 	"github.com/seldonio/seldon-core/executor/api/payload"
+	"github.com/seldonio/seldon-core/executor/api/tracing"
 	"github.com/seldonio/seldon-core/executor/k8s"
 	v1 "github.com/seldonio/seldon-core/operator/apis/machinelearning.seldon.io/v1"
 	"google.golang.org/grpc"
@@ -54,12 +53,18 @@ func CreateGrpcServer(spec *v1.PredictorSpec, deploymentName string, annotations
 		grpc.MaxSendMsgSize(maxMsgSize),
 	}
 
-	interceptors := []grpc.UnaryServerInterceptor{metric.NewServerMetrics(spec, deploymentName).UnaryServerInterceptor()}
-	if opentracing.IsGlobalTracerRegistered() {
-		interceptors = append(interceptors, grpc_opentracing.UnaryServerInterceptor())
+	interceptors := []grpc.UnaryServerInterceptor{
+		metric.NewServerMetrics(spec, deploymentName).UnaryServerInterceptor(),
+		tracing.UnaryServerInterceptor(),
 	}
 	opts = append(opts, grpc.UnaryInterceptor(grpc_middleware.ChainUnaryServer(interceptors...)))
 
+	streamInterceptors := []grpc.StreamServerInterceptor{
+		metric.NewServerMetrics(spec, deploymentName).StreamServerInterceptor(),
+		tracing.StreamServerInterceptor(),
+	}
+	opts = append(opts, grpc.StreamInterceptor(grpc_middleware.ChainStreamServer(streamInterceptors...)))
+
 	grpcServer := grpc.NewServer(opts...)
 	return grpcServer, nil
 }
@@ -0,0 +1,53 @@
+package grpc
+
+import (
+	"context"
+	"io"
+)
+
+// StreamConcurrency is the number of messages from a single stream that are processed at the same time.
+var StreamConcurrency = 32
+
+// ServeStream receives messages until the client closes the stream, handles up to concurrency of
+// them at the same time and sends the responses in the order the requests were received.
+func ServeStream(ctx context.Context, concurrency int, recv func() (interface{}, error), handle func(interface{}) interface{}, send func(interface{}) error) error {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	// Each in flight request has a channel for its response queued in arrival order
+	responses := make(chan chan interface{}, concurrency)
+	recvErr := make(chan error, 1)
+	go func() {
+		defer close(responses)
+		for {
+			req, err := recv()
+			if err != nil {
+				if err != io.EOF {
+					recvErr <- err
+				}
+				return
+			}
+			res := make(chan interface{}, 1)
+			select {
+			case responses <- res:
+			case <-ctx.Done():
+				return
+			}
+			go func() {
+				res <- handle(req)
+			}()
+		}
+	}()
+
+	for res := range responses {
+		if err := send(<-res); err != nil {
+			return err
+		}
+	}
+	select {
+	case err := <-recvErr:
+		return err
+	default:
+		return nil
+	}
+}
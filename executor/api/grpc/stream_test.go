@@ -0,0 +1,59 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestServeStreamKeepsOrder(t *testing.T) {
+	g := NewGomegaWithT(t)
+	reqs := []int{5, 1, 3, 0, 2}
+	next := 0
+	var sent []int
+	err := ServeStream(context.Background(), 3,
+		func() (interface{}, error) {
+			if next == len(reqs) {
+				return nil, io.EOF
+			}
+			next++
+			return reqs[next-1], nil
+		},
+		func(req interface{}) interface{} {
+			// Later requests finish first
+			time.Sleep(time.Duration(req.(int)) * time.Millisecond)
+			return req
+		},
+		func(res interface{}) error {
+			sent = append(sent, res.(int))
+			return nil
+		})
+	g.Expect(err).To(BeNil())
+	g.Expect(sent).To(Equal(reqs))
+}
+
+func TestServeStreamReturnsReceiveError(t *testing.T) {
+	g := NewGomegaWithT(t)
+	recvErr := errors.New("broken")
+	calls := 0
+	var sent []interface{}
+	err := ServeStream(context.Background(), 2,
+		func() (interface{}, error) {
+			calls++
+			if calls > 1 {
+				return nil, recvErr
+			}
+			return "a", nil
+		},
+		func(req interface{}) interface{} { return req },
+		func(res interface{}) error {
+			sent = append(sent, res)
+			return nil
+		})
+	g.Expect(err).To(Equal(recvErr))
+	g.Expect(sent).To(Equal([]interface{}{"a"}))
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/go-logr/logr"
 	"github.com/golang/protobuf/proto"
 	"github.com/pkg/errors"
+	"github.com/seldonio/seldon-core/executor/api"
 	"github.com/seldonio/seldon-core/executor/api/client"
 	"github.com/seldonio/seldon-core/executor/api/grpc"
 	"github.com/seldonio/seldon-core/executor/api/payload"
@@ -39,6 +40,12 @@ func (g *GrpcTensorflowServer) execute(ctx context.Context, req proto.Message, m
 	ctx = context.WithValue(ctx, payload.SeldonPUIDHeader, md.Get(payload.SeldonPUIDHeader)[0])
 	seldonPredictorProcess := predictor.NewPredictorProcess(ctx, g.Client, logf.Log.WithName(method), g.ServerUrl, g.Namespace, md)
 	reqPayload := payload.ProtoPayload{Msg: req}
+	// Only predict requests carry tensors that can be validated
+	if _, ok := req.(*serving.PredictRequest); ok {
+		if err := seldonPredictorProcess.ValidateRequest(&g.predictor.Graph, api.ProtocolTensorflow, &reqPayload); err != nil {
+			return nil, err
+		}
+	}
 	return seldonPredictorProcess.Predict(&g.predictor.Graph, &reqPayload)
 }
 
@@ -74,7 +74,7 @@ func getPuidFromMeta(meta map[string][]string) (string, error) {
 	}
 }
 
-func (kc *KafkaClient) kafkaRPC(msg payload.SeldonPayload, meta map[string][]string, modelName string, method string) (payload.SeldonPayload, error) {
+func (kc *KafkaClient) kafkaRPC(ctx context.Context, msg payload.SeldonPayload, meta map[string][]string, modelName string, method string) (payload.SeldonPayload, error) {
 	bytes, err := msg.GetBytes()
 	if err != nil {
 		kc.Log.Error(err, "Failed to get bytes from request")
@@ -85,22 +85,22 @@ func (kc *KafkaClient) kafkaRPC(msg payload.SeldonPayload, meta map[string][]str
 		return nil, err
 	}
 	if kafkaRPC, ok := kc.topicHandlers[modelName]; ok {
-		return kafkaRPC.call(bytes, puid, method)
+		return kafkaRPC.call(ctx, bytes, puid, method)
 	} else {
 		return nil, fmt.Errorf("Failed to find topic handler for model name %s", modelName)
 	}
 }
 
 func (kc *KafkaClient) Predict(ctx context.Context, modelName string, host string, port int32, msg payload.SeldonPayload, meta map[string][]string) (payload.SeldonPayload, error) {
-	return kc.kafkaRPC(msg, meta, modelName, client.SeldonPredictPath)
+	return kc.kafkaRPC(ctx, msg, meta, modelName, client.SeldonPredictPath)
 }
 
 func (kc *KafkaClient) TransformInput(ctx context.Context, modelName string, host string, port int32, msg payload.SeldonPayload, meta map[string][]string) (payload.SeldonPayload, error) {
-	return kc.kafkaRPC(msg, meta, modelName, client.SeldonTransformInputPath)
+	return kc.kafkaRPC(ctx, msg, meta, modelName, client.SeldonTransformInputPath)
 }
 
 func (kc *KafkaClient) Route(ctx context.Context, modelName string, host string, port int32, msg payload.SeldonPayload, meta map[string][]string) (int, error) {
-	res, err := kc.kafkaRPC(msg, meta, modelName, client.SeldonRoutePath)
+	res, err := kc.kafkaRPC(ctx, msg, meta, modelName, client.SeldonRoutePath)
 	if err != nil {
 		return 0, err
 	} else {
@@ -113,15 +113,15 @@ func (kc *KafkaClient) Combine(ctx context.Context, modelName string, host strin
 	if err != nil {
 		return nil, err
 	}
-	return kc.kafkaRPC(req, meta, modelName, client.SeldonCombinePath)
+	return kc.kafkaRPC(ctx, req, meta, modelName, client.SeldonCombinePath)
 }
 
 func (kc *KafkaClient) TransformOutput(ctx context.Context, modelName string, host string, port int32, msg payload.SeldonPayload, meta map[string][]string) (payload.SeldonPayload, error) {
-	return kc.kafkaRPC(msg, meta, modelName, client.SeldonTransformOutputPath)
+	return kc.kafkaRPC(ctx, msg, meta, modelName, client.SeldonTransformOutputPath)
 }
 
 func (kc *KafkaClient) Feedback(ctx context.Context, modelName string, host string, port int32, msg payload.SeldonPayload, meta map[string][]string) (payload.SeldonPayload, error) {
-	return kc.kafkaRPC(msg, meta, modelName, client.SeldonFeedbackPath)
+	return kc.kafkaRPC(ctx, msg, meta, modelName, client.SeldonFeedbackPath)
 }
 
 func (kc *KafkaClient) Chain(ctx context.Context, modelName string, msg payload.SeldonPayload) (payload.SeldonPayload, error) {
@@ -1,6 +1,7 @@
 package kafka
 
 import (
+	"context"
 	"fmt"
 	"github.com/cloudevents/sdk-go/pkg/bindings/http"
 	"github.com/confluentinc/confluent-kafka-go/kafka"
@@ -8,18 +9,19 @@ import (
 	proto2 "github.com/golang/protobuf/proto"
 	guuid "github.com/google/uuid"
 	"github.com/seldonio/seldon-core/executor/api"
+	"github.com/seldonio/seldon-core/executor/api/cache"
 	"github.com/seldonio/seldon-core/executor/api/client"
-	"github.com/seldonio/seldon-core/executor/api/grpc/seldon"
-	"github.com/seldonio/seldon-core/executor/api/grpc/tensorflow"
+	"github.com/seldonio/seldon-core/executor/api/grpc/clients"
+	"github.com/seldonio/seldon-core/executor/api/lifecycle"
 	"github.com/seldonio/seldon-core/executor/api/payload"
 	"github.com/seldonio/seldon-core/executor/api/rest"
+	"github.com/seldonio/seldon-core/executor/api/tracing"
+	"github.com/seldonio/seldon-core/executor/api/translate"
 	"github.com/seldonio/seldon-core/executor/predictor"
 	v1 "github.com/seldonio/seldon-core/operator/apis/machinelearning.seldon.io/v1"
 	"net/url"
-	"os"
-	"os/signal"
 	"reflect"
-	"syscall"
+	"sync"
 	"time"
 )
 
@@ -36,6 +38,9 @@ const (
 	ENV_KAFKA_WORKERS      = "KAFKA_WORKERS"
 )
 
+// How long to wait on shutdown for produced responses to be delivered.
+var producerFlushTimeout = 10 * time.Second
+
 type SeldonKafkaServer struct {
 	Client         client.SeldonApiClient
 	Producer       *kafka.Producer
@@ -49,32 +54,57 @@ type SeldonKafkaServer struct {
 	ServerUrl      *url.URL
 	Workers        int
 	Log            logr.Logger
+	ReadyChecker   *predictor.ReadyChecker
+	// Deadline of requests without a Seldon-Request-Timeout header. None is set if it is zero.
+	RequestTimeout time.Duration
+	// Closed once Serve has finished its jobs and closed the consumer and producer
+	stopped chan struct{}
 }
 
-func NewKafkaServer(fullGraph bool, workers int, deploymentName, namespace, protocol, transport string, annotations map[string]string, serverUrl *url.URL, predictor *v1.PredictorSpec, broker, topicIn, topicOut string, log logr.Logger) (*SeldonKafkaServer, error) {
+func NewKafkaServer(fullGraph bool, workers int, deploymentName, namespace, protocol, transport string, annotations map[string]string, serverUrl *url.URL, predictorSpec *v1.PredictorSpec, broker, topicIn, topicOut string, log logr.Logger) (*SeldonKafkaServer, error) {
 	var apiClient client.SeldonApiClient
 	var err error
+	// Clients used to check the graph is ready. Nodes are only checked with a TCP connection for
+	// the full graph as it is called through the executor.
+	var restClient, grpcClient client.SeldonApiClient
 	if fullGraph {
 		log.Info("Starting full graph kafka server")
-		apiClient = NewKafkaClient(serverUrl.Hostname(), deploymentName, namespace, protocol, transport, predictor, broker, log)
+		apiClient = NewKafkaClient(serverUrl.Hostname(), deploymentName, namespace, protocol, transport, predictorSpec, broker, log)
 	} else {
+		var newClient translate.ClientFactory
 		switch transport {
 		case api.TransportRest:
 			log.Info("Start http kafka graph")
-			apiClient, err = rest.NewJSONRestClient(protocol, deploymentName, predictor, annotations)
-			if err != nil {
-				return nil, err
+			newClient = func(protocol string) (client.SeldonApiClient, error) {
+				return rest.NewJSONRestClient(protocol, deploymentName, predictorSpec, annotations)
 			}
 		case api.TransportGrpc:
 			log.Info("Start grpc kafka graph")
-			if protocol == "seldon" {
-				apiClient = seldon.NewSeldonGrpcClient(predictor, deploymentName, annotations)
-			} else {
-				apiClient = tensorflow.NewTensorflowGrpcClient(predictor, deploymentName, annotations)
+			newClient = func(protocol string) (client.SeldonApiClient, error) {
+				return clients.NewGrpcClient(protocol, deploymentName, predictorSpec, annotations)
 			}
 		default:
 			return nil, fmt.Errorf("Unknown transport %s", transport)
 		}
+		apiClient, err = newClient(protocol)
+		if err != nil {
+			return nil, err
+		}
+		apiClient, err = translate.NewTranslatingClient(apiClient, protocol, predictorSpec, newClient)
+		if err != nil {
+			return nil, err
+		}
+		apiClient = cache.NewCachingClient(apiClient, predictorSpec, deploymentName, cache.NewMemoryBackendFactory)
+		if transport == api.TransportRest {
+			restClient = apiClient
+		} else {
+			grpcClient = apiClient
+		}
+	}
+
+	requestTimeout, err := rest.GetRequestTimeoutFromAnnotations(annotations)
+	if err != nil {
+		return nil, err
 	}
 
 	// Create Producer
@@ -91,13 +121,16 @@ func NewKafkaServer(fullGraph bool, workers int, deploymentName, namespace, prot
 		DeploymentName: deploymentName,
 		Namespace:      namespace,
 		Transport:      transport,
-		Predictor:      predictor,
+		Predictor:      predictorSpec,
 		Broker:         broker,
 		TopicIn:        topicIn,
 		TopicOut:       topicOut,
 		ServerUrl:      serverUrl,
+		ReadyChecker:   predictor.NewReadyChecker(predictorSpec, protocol, restClient, grpcClient),
 		Workers:        workers,
+		RequestTimeout: requestTimeout,
 		Log:            log.WithName("KafkaServer"),
+		stopped:        make(chan struct{}),
 	}, nil
 }
 
@@ -105,6 +138,17 @@ func (ks *SeldonKafkaServer) getGroupName() string {
 	return ks.Predictor.Name + "." + ks.DeploymentName + "." + ks.Namespace
 }
 
+// traceHeaders returns Kafka headers carrying the trace context of ctx.
+func traceHeaders(ctx context.Context) []kafka.Header {
+	carrier := tracing.MapCarrier{}
+	tracing.Inject(ctx, carrier)
+	headers := make([]kafka.Header, 0, len(carrier))
+	for key, values := range carrier {
+		headers = append(headers, kafka.Header{Key: key, Value: []byte(values[0])})
+	}
+	return headers
+}
+
 func collectHeaders(headers []kafka.Header) map[string][]string {
 	sheaders := make(map[string][]string)
 	foundPuid := false
@@ -152,21 +196,32 @@ func (ks *SeldonKafkaServer) Serve() error {
 	}
 
 	run := true
-	sigchan := make(chan os.Signal, 1)
-	signal.Notify(sigchan, syscall.SIGINT, syscall.SIGTERM)
+	defer close(ks.stopped)
+	// Shutdown waits for the queued jobs to finish and their responses to be delivered
+	lifecycle.OnStop("kafka", func(ctx context.Context) error {
+		select {
+		case <-ks.stopped:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
 
-	// create a cancel channel
-	cancelChan := make(chan struct{})
 	// make a channel with a capacity of the number of workers
 	jobChan := make(chan *KafkaJob, ks.Workers)
+	var workers sync.WaitGroup
 	for i := 0; i < ks.Workers; i++ {
-		go ks.worker(jobChan, cancelChan)
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			ks.worker(jobChan)
+		}()
 	}
 
 	//wait for graph to be ready
 	ready := false
-	for ready == false {
-		err := predictor.Ready(&ks.Predictor.Graph)
+	for ready == false && !lifecycle.IsStopping() {
+		err := ks.ReadyChecker.Ready(context.Background())
 		ready = err == nil
 		if !ready {
 			ks.Log.Info("Waiting for graph to be ready")
@@ -177,8 +232,8 @@ func (ks *SeldonKafkaServer) Serve() error {
 	cnt := 0
 	for run == true {
 		select {
-		case sig := <-sigchan:
-			ks.Log.Info("Terminating", "signal", sig)
+		case <-lifecycle.Stopping():
+			ks.Log.Info("Terminating")
 			run = false
 		default:
 			ev := c.Poll(100)
@@ -231,7 +286,8 @@ func (ks *SeldonKafkaServer) Serve() error {
 					headers:    headers,
 					reqPayload: reqPayload,
 				}
-				// enqueue a job
+				// enqueue a job which shutdown waits for
+				lifecycle.Begin()
 				jobChan <- &job
 
 			case kafka.Error:
@@ -251,8 +307,17 @@ func (ks *SeldonKafkaServer) Serve() error {
 	}
 
 	ks.Log.Info("Final Processed", "messages", cnt)
+	// Workers finish the queued jobs before stopping
+	close(jobChan)
+	workers.Wait()
 	ks.Log.Info("Closing consumer")
-	close(cancelChan)
-	c.Close()
+	if err := c.Close(); err != nil {
+		ks.Log.Error(err, "Failed to close consumer")
+	}
+	ks.Log.Info("Closing producer")
+	if remaining := ks.Producer.Flush(int(producerFlushTimeout / time.Millisecond)); remaining > 0 {
+		ks.Log.Info("Responses not delivered before closing producer", "messages", remaining)
+	}
+	ks.Producer.Close()
 	return nil
 }
@@ -1,16 +1,15 @@
 package kafka
 
 import (
+	"context"
 	"fmt"
 	"github.com/cloudevents/sdk-go/pkg/bindings/http"
 	"github.com/confluentinc/confluent-kafka-go/kafka"
 	"github.com/go-logr/logr"
+	"github.com/seldonio/seldon-core/executor/api/lifecycle"
 	"github.com/seldonio/seldon-core/executor/api/payload"
 	"github.com/seldonio/seldon-core/executor/api/rest"
-	"os"
-	"os/signal"
 	"sync"
-	"syscall"
 )
 
 const (
@@ -93,13 +92,12 @@ func (tp *KafkaRPC) start() {
 
 		tp.Log.Info("Created", "consumer", c.String(), "topic", tp.TopicReceive)
 		run := true
-		sigchan := make(chan os.Signal, 1)
-		signal.Notify(sigchan, syscall.SIGINT, syscall.SIGTERM)
 
+		// Responses are received until in-flight requests have finished on shutdown
 		for run == true {
 			select {
-			case sig := <-sigchan:
-				tp.Log.Info("Terminating", "signal", sig)
+			case <-lifecycle.Done():
+				tp.Log.Info("Terminating")
 				run = false
 			default:
 				ev := c.Poll(100)
@@ -159,9 +157,15 @@ func (tp *KafkaRPC) start() {
 	}()
 }
 
-func (tp *KafkaRPC) call(msg []byte, puid string, method string) (payload.SeldonPayload, error) {
+func (tp *KafkaRPC) removeReceiver(puid string) {
+	tp.Lock.Lock()
+	delete(tp.Receivers, puid)
+	tp.Lock.Unlock()
+}
+
+func (tp *KafkaRPC) call(ctx context.Context, msg []byte, puid string, method string) (payload.SeldonPayload, error) {
 	//add to receivers
-	c := make(chan payload.SeldonPayload)
+	c := make(chan payload.SeldonPayload, 1)
 	tp.Lock.Lock()
 	tp.Receivers[puid] = c
 	tp.Lock.Unlock()
@@ -169,23 +173,24 @@ func (tp *KafkaRPC) call(msg []byte, puid string, method string) (payload.Seldon
 	err := tp.Producer.Produce(&kafka.Message{
 		TopicPartition: kafka.TopicPartition{Topic: &tp.TopicSend, Partition: kafka.PartitionAny},
 		Value:          msg,
-		Headers: []kafka.Header{
+		Headers: append([]kafka.Header{
 			{Key: payload.SeldonPUIDHeader, Value: []byte(puid)},
 			{Key: KeyTopicResponse, Value: []byte(tp.TopicReceive)},
 			{Key: KeyMethod, Value: []byte(method)},
-		}}, nil)
+		}, traceHeaders(ctx)...)}, nil)
 	if err != nil {
 		tp.Log.Error(err, "Failed to produce request", "topic", tp.TopicSend)
 		return nil, err
 	}
 	//wait for response
 
-	sigchan := make(chan os.Signal, 1)
-	signal.Notify(sigchan, syscall.SIGINT, syscall.SIGTERM)
 	select {
-	case sig := <-sigchan:
-		tp.Log.Info("Terminating", "signal", sig)
+	case <-lifecycle.Done():
+		tp.Log.Info("Terminating")
 		return nil, fmt.Errorf("Terminated")
+	case <-ctx.Done():
+		tp.removeReceiver(puid)
+		return nil, ctx.Err()
 	case res := <-c:
 		return res, nil
 	}
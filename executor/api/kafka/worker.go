@@ -3,9 +3,9 @@ package kafka
 import (
 	"context"
 	"github.com/confluentinc/confluent-kafka-go/kafka"
-	"github.com/opentracing/opentracing-go"
-	"github.com/opentracing/opentracing-go/ext"
+	"github.com/seldonio/seldon-core/executor/api/lifecycle"
 	"github.com/seldonio/seldon-core/executor/api/payload"
+	"github.com/seldonio/seldon-core/executor/api/tracing"
 	"github.com/seldonio/seldon-core/executor/predictor"
 	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
 )
@@ -15,15 +15,10 @@ type KafkaJob struct {
 	reqPayload payload.SeldonPayload
 }
 
-func (ks *SeldonKafkaServer) worker(jobChan <-chan *KafkaJob, cancelChan <-chan struct{}) {
-	for {
-		select {
-		case <-cancelChan:
-			return
-
-		case job := <-jobChan:
-			ks.processKafkaRequest(job)
-		}
+func (ks *SeldonKafkaServer) worker(jobChan <-chan *KafkaJob) {
+	for job := range jobChan {
+		ks.processKafkaRequest(job)
+		lifecycle.End()
 	}
 }
 
@@ -32,12 +27,23 @@ func (ks *SeldonKafkaServer) processKafkaRequest(job *KafkaJob) {
 	// Add Seldon Puid to Context
 	ctx = context.WithValue(ctx, payload.SeldonPUIDHeader, job.headers[payload.SeldonPUIDHeader][0])
 
-	// Apply tracing if active
-	if opentracing.IsGlobalTracerRegistered() {
-		tracer := opentracing.GlobalTracer()
-		serverSpan := tracer.StartSpan("kafkaServer", ext.RPCServerOption(nil))
-		ctx = opentracing.ContextWithSpan(ctx, serverSpan)
-		defer serverSpan.Finish()
+	ctx, serverSpan := tracing.StartServerSpan(ctx, tracing.MapCarrier(job.headers), "kafkaServer")
+	defer serverSpan.End()
+
+	var timeoutHeader string
+	if val, ok := job.headers[payload.SeldonRequestTimeoutHeader]; ok && len(val) == 1 {
+		timeoutHeader = val[0]
+	}
+	timeout, err := predictor.RequestTimeout(timeoutHeader, ks.RequestTimeout)
+	if err != nil {
+		ks.Log.Error(err, "Invalid request timeout")
+		tracing.SetError(serverSpan, err)
+		return
+	}
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
 	}
 
 	seldonPredictorProcess := predictor.NewPredictorProcess(ctx, ks.Client, logf.Log.WithName("KafkaClient"), ks.ServerUrl, ks.Namespace, job.headers)
@@ -45,6 +51,7 @@ func (ks *SeldonKafkaServer) processKafkaRequest(job *KafkaJob) {
 	resPayload, err := seldonPredictorProcess.Predict(&ks.Predictor.Graph, job.reqPayload)
 	if err != nil {
 		ks.Log.Error(err, "Failed prediction")
+		tracing.SetError(serverSpan, err)
 		return
 	}
 	resBytes, err := resPayload.GetBytes()
@@ -53,7 +60,7 @@ func (ks *SeldonKafkaServer) processKafkaRequest(job *KafkaJob) {
 		return
 	}
 
-	kafkaHeaders := make([]kafka.Header, 0)
+	kafkaHeaders := traceHeaders(ctx)
 	// Could in the future add the proto message name. At present seems we need to know the class to cast to so would need to do
 	// an exhaustive check, e.g. check its a tensorflow_serving.predict_pb2.PredictResponse, etc
 	//if ks.Transport == api.TransportGrpc {
@@ -0,0 +1,163 @@
+package lifecycle
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-logr/logr"
+	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
+)
+
+// How often in-flight work is checked while draining.
+var drainPollInterval = 50 * time.Millisecond
+
+type stopHook struct {
+	name string
+	fn   func(ctx context.Context) error
+}
+
+// Lifecycle coordinates the graceful shutdown of the servers of the executor. Shutdown happens in
+// phases: the executor is marked as not ready, the servers stop taking new work, in-flight work is
+// allowed to finish and then components which must outlive all requests are told to finish.
+type Lifecycle struct {
+	Log      logr.Logger
+	stopping chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+	doneOnce sync.Once
+	inFlight int64
+	mu       sync.Mutex
+	hooks    []stopHook
+}
+
+func New() *Lifecycle {
+	return &Lifecycle{
+		Log:      logf.Log.WithName("Lifecycle"),
+		stopping: make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// Stopping is closed when shutdown starts and servers should stop taking new work.
+func (l *Lifecycle) Stopping() <-chan struct{} {
+	return l.stopping
+}
+
+// IsStopping returns true once shutdown has started.
+func (l *Lifecycle) IsStopping() bool {
+	select {
+	case <-l.stopping:
+		return true
+	default:
+		return false
+	}
+}
+
+// Done is closed once in-flight work has finished or the shutdown deadline has passed.
+func (l *Lifecycle) Done() <-chan struct{} {
+	return l.done
+}
+
+// Begin records the start of work which shutdown must wait for. It must be followed by End.
+func (l *Lifecycle) Begin() {
+	atomic.AddInt64(&l.inFlight, 1)
+}
+
+// End records the end of work started with Begin.
+func (l *Lifecycle) End() {
+	atomic.AddInt64(&l.inFlight, -1)
+}
+
+// InFlight returns the amount of work started with Begin which has not ended.
+func (l *Lifecycle) InFlight() int64 {
+	return atomic.LoadInt64(&l.inFlight)
+}
+
+// OnStop registers a function which stops a server taking new work. Functions are called
+// concurrently on shutdown and should return once the server's in-flight requests have finished or
+// the context is done.
+func (l *Lifecycle) OnStop(name string, fn func(ctx context.Context) error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.hooks = append(l.hooks, stopHook{name: name, fn: fn})
+}
+
+// Shutdown marks the executor as stopping and waits for delay so load balancers can see it is no
+// longer ready. The servers are then stopped and in-flight work drained until the context is done.
+func (l *Lifecycle) Shutdown(ctx context.Context, delay time.Duration) {
+	defer l.doneOnce.Do(func() { close(l.done) })
+	l.stopOnce.Do(func() { close(l.stopping) })
+	l.Log.Info("Shutting down", "delay", delay)
+
+	select {
+	case <-time.After(delay):
+	case <-ctx.Done():
+	}
+
+	l.mu.Lock()
+	hooks := l.hooks
+	l.mu.Unlock()
+	var wg sync.WaitGroup
+	for _, hook := range hooks {
+		wg.Add(1)
+		go func(hook stopHook) {
+			defer wg.Done()
+			if err := hook.fn(ctx); err != nil {
+				l.Log.Error(err, "Failed to stop gracefully", "server", hook.name)
+			} else {
+				l.Log.Info("Stopped", "server", hook.name)
+			}
+		}(hook)
+	}
+	wg.Wait()
+
+	if err := l.drain(ctx); err != nil {
+		l.Log.Error(err, "Gave up waiting for in-flight work", "inflight", l.InFlight())
+	}
+}
+
+func (l *Lifecycle) drain(ctx context.Context) error {
+	ticker := time.NewTicker(drainPollInterval)
+	defer ticker.Stop()
+	for l.InFlight() > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+	return nil
+}
+
+// The lifecycle of the executor process.
+var defaultLifecycle = New()
+
+func Stopping() <-chan struct{} {
+	return defaultLifecycle.Stopping()
+}
+
+func IsStopping() bool {
+	return defaultLifecycle.IsStopping()
+}
+
+func Done() <-chan struct{} {
+	return defaultLifecycle.Done()
+}
+
+func Begin() {
+	defaultLifecycle.Begin()
+}
+
+func End() {
+	defaultLifecycle.End()
+}
+
+func OnStop(name string, fn func(ctx context.Context) error) {
+	defaultLifecycle.OnStop(name, fn)
+}
+
+func Shutdown(ctx context.Context, delay time.Duration) {
+	defaultLifecycle.Shutdown(ctx, delay)
+}
@@ -0,0 +1,62 @@
+package lifecycle
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestShutdownOrder(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	l := New()
+	var mu sync.Mutex
+	var events []string
+	record := func(event string) {
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, event)
+	}
+
+	l.Begin()
+	l.OnStop("server", func(ctx context.Context) error {
+		g.Expect(l.IsStopping()).To(BeTrue())
+		record("stop")
+		go func() {
+			time.Sleep(100 * time.Millisecond)
+			record("end")
+			l.End()
+		}()
+		return nil
+	})
+
+	g.Expect(l.IsStopping()).To(BeFalse())
+	l.Shutdown(context.Background(), 10*time.Millisecond)
+	record("done")
+
+	g.Expect(events).To(Equal([]string{"stop", "end", "done"}))
+	g.Expect(l.InFlight()).To(Equal(int64(0)))
+	select {
+	case <-l.Done():
+	default:
+		t.Fatal("Done not closed after shutdown")
+	}
+}
+
+func TestShutdownDeadline(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	l := New()
+	l.Begin()
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	l.Shutdown(ctx, time.Hour)
+	g.Expect(time.Since(start) < time.Second).To(BeTrue())
+	g.Expect(l.InFlight()).To(Equal(int64(1)))
+	g.Expect(l.IsStopping()).To(BeTrue())
+}
@@ -0,0 +1,42 @@
+package metric
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	v1 "github.com/seldonio/seldon-core/operator/apis/machinelearning.seldon.io/v1"
+)
+
+const (
+	CacheHit  = "hit"
+	CacheMiss = "miss"
+)
+
+type CacheMetrics struct {
+	CacheRequestsCounter *prometheus.CounterVec
+	Predictor            *v1.PredictorSpec
+	DeploymentName       string
+}
+
+func NewCacheMetrics(spec *v1.PredictorSpec, deploymentName string) *CacheMetrics {
+	counter := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: CacheRequestsMetricName,
+			Help: "A counter of response cache lookups for client calls from executor",
+		},
+		[]string{DeploymentNameMetric, PredictorNameMetric, PredictorVersionMetric, ModelNameMetric, CacheResultMetric},
+	)
+	err := prometheus.Register(counter)
+	if err != nil {
+		if e, ok := err.(prometheus.AlreadyRegisteredError); ok {
+			counter = e.ExistingCollector.(*prometheus.CounterVec)
+		}
+	}
+	return &CacheMetrics{
+		CacheRequestsCounter: counter,
+		Predictor:            spec,
+		DeploymentName:       deploymentName,
+	}
+}
+
+func (m *CacheMetrics) Record(modelName string, result string) {
+	m.CacheRequestsCounter.WithLabelValues(m.DeploymentName, m.Predictor.Name, m.Predictor.Annotations["version"], modelName, result).Inc()
+}
@@ -2,7 +2,6 @@ package metric
 
 import (
 	"context"
-	"strings"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
@@ -44,16 +43,7 @@ func NewClientMetrics(spec *v1.PredictorSpec, deploymentName string, modelName s
 
 		}
 	}
-	container := v1.GetContainerForPredictiveUnit(spec, modelName)
-	imageName := ""
-	imageVersion := ""
-	if container != nil {
-		imageParts := strings.Split(container.Image, ":")
-		imageName = imageParts[0]
-		if len(imageParts) == 2 {
-			imageVersion = imageParts[1]
-		}
-	}
+	imageName, imageVersion := imageNameVersion(spec, modelName)
 
 	return &ClientMetrics{
 		ClientHandledHistogram: histogram,
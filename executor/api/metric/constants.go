@@ -1,5 +1,7 @@
 package metric
 
+import "github.com/prometheus/client_golang/prometheus"
+
 const (
 	CodeMetric             = "code"    // 2xx, 5xx etc
 	HTTPMethodMetric       = "method"  // Http Method (Post, Get etc)
@@ -10,16 +12,42 @@ const (
 	ModelNameMetric        = "model_name"
 	ModelImageMetric       = "model_image"
 	ModelVersionMetric     = "model_version"
+	CacheResultMetric      = "result" // hit or miss
+	LoggerReasonMetric     = "reason" // queue_full or send_failed
+	ErrorClassMetric       = "error_class"
+	RouteChildMetric       = "child" // child chosen by a router
+
+	LoggerDeadLetterResultMetric = "result" // written or replayed
 
 	ServerRequestsMetricName = "seldon_api_executor_server_requests_seconds"
 	ClientRequestsMetricName = "seldon_api_executor_client_requests_seconds"
+	CacheRequestsMetricName  = "seldon_api_executor_client_cache_requests_total"
+
+	NodeRequestsMetricName      = "seldon_api_executor_node_requests_total"
+	NodeErrorsMetricName        = "seldon_api_executor_node_errors_total"
+	NodeInFlightMetricName      = "seldon_api_executor_node_requests_in_flight"
+	NodeRequestBytesMetricName  = "seldon_api_executor_node_request_bytes"
+	NodeResponseBytesMetricName = "seldon_api_executor_node_response_bytes"
+	RoutingDecisionsMetricName  = "seldon_api_executor_routing_decisions_total"
+
+	LoggerQueueDepthMetricName  = "seldon_api_executor_logger_queue_depth"
+	LoggerQueueSizeMetricName   = "seldon_api_executor_logger_queue_size"
+	LoggerInFlightMetricName    = "seldon_api_executor_logger_in_flight"
+	LoggerDroppedMetricName     = "seldon_api_executor_logger_dropped_total"
+	LoggerFailuresMetricName    = "seldon_api_executor_logger_send_failures_total"
+	LoggerDeadLettersMetricName = "seldon_api_executor_logger_dead_letters_total"
 
 	PredictionHttpServiceName = "predictions"
 	StatusHttpServiceName     = "status"
 	MetadataHttpServiceName   = "metadata"
 	FeedbackHttpServiceName   = "feedback"
+
+	AsyncPredictionHttpServiceName = "predictions-async"
+	AsyncStatusHttpServiceName     = "predictions-async-status"
 )
 
 var (
 	DefBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.075, 0.1, 0.25, 0.5, 0.75, 1, 2.5, 5, 7.5, 10}
+	// 256B to 64MB
+	ByteBuckets = prometheus.ExponentialBuckets(256, 4, 10)
 )
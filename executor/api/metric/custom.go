@@ -0,0 +1,99 @@
+package metric
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/seldonio/seldon-core/executor/api/grpc/seldon/proto"
+)
+
+// customCollector is the collector of a custom metric, or nil if it could not be registered.
+type customCollector struct {
+	collector prometheus.Collector
+}
+
+// RecordCustom updates the custom metrics returned by a node in the meta of its response. Each metric
+// has the labels of the node metrics and its own tags. Counters are incremented, gauges are set and
+// timers, given in milliseconds, are observed in seconds. An error is returned the first time a
+// metric can't be registered, e.g. when its tags differ from those it was first returned with.
+func (m *NodeMetrics) RecordCustom(modelName string, metrics []*proto.Metric) error {
+	if m == nil {
+		return nil
+	}
+	var errs []string
+	for _, cm := range metrics {
+		tagKeys := make([]string, 0, len(cm.GetTags()))
+		for k := range cm.GetTags() {
+			tagKeys = append(tagKeys, k)
+		}
+		sort.Strings(tagKeys)
+		collector, err := m.customCollector(cm, tagKeys)
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		values := m.values(modelName)
+		for _, k := range tagKeys {
+			values = append(values, cm.GetTags()[k])
+		}
+		switch c := collector.(type) {
+		case *prometheus.CounterVec:
+			// Counters can't be decreased
+			if cm.GetValue() >= 0 {
+				c.WithLabelValues(values...).Add(float64(cm.GetValue()))
+			}
+		case *prometheus.GaugeVec:
+			c.WithLabelValues(values...).Set(float64(cm.GetValue()))
+		case *prometheus.HistogramVec:
+			c.WithLabelValues(values...).Observe(float64(cm.GetValue()) / 1000)
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("Failed to register custom metrics: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+func (m *NodeMetrics) customCollector(cm *proto.Metric, tagKeys []string) (prometheus.Collector, error) {
+	key := cm.GetType().String() + ":" + cm.GetKey() + ":" + strings.Join(tagKeys, ",")
+	if c, ok := m.custom.Load(key); ok {
+		return c.(customCollector).collector, nil
+	}
+	labels := append(append([]string{DeploymentNameMetric, PredictorNameMetric, ModelNameMetric}, m.Labels...), tagKeys...)
+	var collector prometheus.Collector
+	var err error
+	switch cm.GetType() {
+	case proto.Metric_COUNTER:
+		collector = prometheus.NewCounterVec(prometheus.CounterOpts{Name: cm.GetKey(), Help: "Custom counter returned by models"}, labels)
+	case proto.Metric_GAUGE:
+		collector = prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: cm.GetKey(), Help: "Custom gauge returned by models"}, labels)
+	case proto.Metric_TIMER:
+		collector = prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: cm.GetKey(), Help: "Custom timer returned by models", Buckets: DefBuckets}, labels)
+	default:
+		// Any number is accepted as a type when decoding JSON so it must be checked here
+		err = fmt.Errorf("unknown metric type %d", cm.GetType())
+	}
+	if collector != nil {
+		err = prometheus.Register(collector)
+	}
+	if e, ok := err.(prometheus.AlreadyRegisteredError); ok {
+		// Registered by another request, unless the name is used by a metric of a different type
+		if sameType(e.ExistingCollector, collector) {
+			collector, err = e.ExistingCollector, nil
+		}
+	}
+	if err != nil {
+		if c, loaded := m.custom.LoadOrStore(key, customCollector{}); loaded {
+			return c.(customCollector).collector, nil
+		}
+		return nil, fmt.Errorf("%s %s: %v", cm.GetType(), cm.GetKey(), err)
+	}
+	c, _ := m.custom.LoadOrStore(key, customCollector{collector: collector})
+	return c.(customCollector).collector, nil
+}
+
+func sameType(a prometheus.Collector, b prometheus.Collector) bool {
+	return fmt.Sprintf("%T", a) == fmt.Sprintf("%T", b)
+}
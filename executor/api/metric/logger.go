@@ -0,0 +1,129 @@
+package metric
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	LoggerDroppedQueueFull  = "queue_full"
+	LoggerDroppedSendFailed = "send_failed"
+
+	LoggerDeadLetterWritten  = "written"
+	LoggerDeadLetterReplayed = "replayed"
+)
+
+type LoggerMetrics struct {
+	QueueDepthGauge    *prometheus.GaugeVec
+	QueueSizeGauge     *prometheus.GaugeVec
+	InFlightGauge      *prometheus.GaugeVec
+	DroppedCounter     *prometheus.CounterVec
+	FailuresCounter    *prometheus.CounterVec
+	DeadLettersCounter *prometheus.CounterVec
+	DeploymentName     string
+	PredictorName      string
+}
+
+func registerCounter(counter *prometheus.CounterVec) *prometheus.CounterVec {
+	err := prometheus.Register(counter)
+	if err != nil {
+		if e, ok := err.(prometheus.AlreadyRegisteredError); ok {
+			counter = e.ExistingCollector.(*prometheus.CounterVec)
+		}
+	}
+	return counter
+}
+
+func NewLoggerMetrics(deploymentName string, predictorName string) *LoggerMetrics {
+	gauge := registerGauge(prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: LoggerQueueDepthMetricName,
+			Help: "The number of payload logs waiting to be sent",
+		},
+		[]string{DeploymentNameMetric, PredictorNameMetric},
+	))
+	queueSize := registerGauge(prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: LoggerQueueSizeMetricName,
+			Help: "The number of payload logs which can be queued",
+		},
+		[]string{DeploymentNameMetric, PredictorNameMetric},
+	))
+	inFlight := registerGauge(prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: LoggerInFlightMetricName,
+			Help: "The number of payload logs being sent by workers",
+		},
+		[]string{DeploymentNameMetric, PredictorNameMetric},
+	))
+	dropped := registerCounter(prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: LoggerDroppedMetricName,
+			Help: "A counter of payload logs which were dropped",
+		},
+		[]string{DeploymentNameMetric, PredictorNameMetric, LoggerReasonMetric},
+	))
+	failures := registerCounter(prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: LoggerFailuresMetricName,
+			Help: "A counter of failed attempts to send payload logs",
+		},
+		[]string{DeploymentNameMetric, PredictorNameMetric},
+	))
+	deadLetters := registerCounter(prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: LoggerDeadLettersMetricName,
+			Help: "A counter of payload logs written to and replayed from the dead letter file",
+		},
+		[]string{DeploymentNameMetric, PredictorNameMetric, LoggerDeadLetterResultMetric},
+	))
+	return &LoggerMetrics{
+		QueueDepthGauge:    gauge,
+		QueueSizeGauge:     queueSize,
+		InFlightGauge:      inFlight,
+		DroppedCounter:     dropped,
+		FailuresCounter:    failures,
+		DeadLettersCounter: deadLetters,
+		DeploymentName:     deploymentName,
+		PredictorName:      predictorName,
+	}
+}
+
+// The methods of LoggerMetrics do nothing if metrics were not created so payloads can be logged
+// before the logger is started.
+
+func (m *LoggerMetrics) QueueDepth(depth int) {
+	if m != nil {
+		m.QueueDepthGauge.WithLabelValues(m.DeploymentName, m.PredictorName).Set(float64(depth))
+	}
+}
+
+func (m *LoggerMetrics) QueueSize(size int) {
+	if m != nil {
+		m.QueueSizeGauge.WithLabelValues(m.DeploymentName, m.PredictorName).Set(float64(size))
+	}
+}
+
+// InFlight adds delta to the number of payload logs being sent.
+func (m *LoggerMetrics) InFlight(delta int) {
+	if m != nil {
+		m.InFlightGauge.WithLabelValues(m.DeploymentName, m.PredictorName).Add(float64(delta))
+	}
+}
+
+func (m *LoggerMetrics) Dropped(reason string) {
+	if m != nil {
+		m.DroppedCounter.WithLabelValues(m.DeploymentName, m.PredictorName, reason).Inc()
+	}
+}
+
+func (m *LoggerMetrics) Failure() {
+	if m != nil {
+		m.FailuresCounter.WithLabelValues(m.DeploymentName, m.PredictorName).Inc()
+	}
+}
+
+func (m *LoggerMetrics) DeadLetter(result string) {
+	if m != nil {
+		m.DeadLettersCounter.WithLabelValues(m.DeploymentName, m.PredictorName, result).Inc()
+	}
+}
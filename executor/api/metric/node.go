@@ -0,0 +1,228 @@
+package metric
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	v1 "github.com/seldonio/seldon-core/operator/apis/machinelearning.seldon.io/v1"
+)
+
+// Classes of errors returned by calls to nodes
+const (
+	ErrorClassTimeout     = "timeout"
+	ErrorClassCancelled   = "cancelled"
+	ErrorClassCircuitOpen = "circuit_open"
+	ErrorClassUnavailable = "unavailable"
+	ErrorClassClient      = "client_error"
+	ErrorClassServer      = "server_error"
+	ErrorClassOther       = "other"
+
+	// The router sent the request to all its children or returned it
+	RouteAllChildren = "all"
+	RouteNoChildren  = "none"
+)
+
+// Labels which can be added to the node metrics. The deployment, predictor and model names are
+// always added.
+var OptionalNodeLabels = []string{PredictorVersionMetric, ModelImageMetric, ModelVersionMetric}
+
+var DefaultNodeLabels = strings.Join(OptionalNodeLabels, ",")
+
+// ParseNodeLabels parses a comma separated list of optional labels of the node metrics.
+func ParseNodeLabels(s string) ([]string, error) {
+	var labels []string
+	for _, label := range strings.Split(s, ",") {
+		label = strings.TrimSpace(label)
+		if label == "" {
+			continue
+		}
+		found := false
+		for _, optional := range OptionalNodeLabels {
+			if label == optional {
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("Unknown node metric label %s, must be one of %s", label, DefaultNodeLabels)
+		}
+		labels = append(labels, label)
+	}
+	return labels, nil
+}
+
+// NodeMetrics records the calls to each node of the graph.
+type NodeMetrics struct {
+	RequestsCounter        *prometheus.CounterVec
+	ErrorsCounter          *prometheus.CounterVec
+	InFlightGauge          *prometheus.GaugeVec
+	RequestBytesHistogram  *prometheus.HistogramVec
+	ResponseBytesHistogram *prometheus.HistogramVec
+	RoutingCounter         *prometheus.CounterVec
+	Predictor              *v1.PredictorSpec
+	DeploymentName         string
+	Labels                 []string
+	nodeLabelValues        map[string][]string
+	// Collectors of the custom metrics returned by nodes
+	custom sync.Map
+}
+
+func registerGauge(gauge *prometheus.GaugeVec) *prometheus.GaugeVec {
+	err := prometheus.Register(gauge)
+	if err != nil {
+		if e, ok := err.(prometheus.AlreadyRegisteredError); ok {
+			gauge = e.ExistingCollector.(*prometheus.GaugeVec)
+		}
+	}
+	return gauge
+}
+
+func registerHistogram(histogram *prometheus.HistogramVec) *prometheus.HistogramVec {
+	err := prometheus.Register(histogram)
+	if err != nil {
+		if e, ok := err.(prometheus.AlreadyRegisteredError); ok {
+			histogram = e.ExistingCollector.(*prometheus.HistogramVec)
+		}
+	}
+	return histogram
+}
+
+// NewNodeMetrics creates the node metrics with the given optional labels.
+func NewNodeMetrics(spec *v1.PredictorSpec, deploymentName string, labels []string) *NodeMetrics {
+	names := append([]string{DeploymentNameMetric, PredictorNameMetric, ModelNameMetric}, labels...)
+	withLabel := func(label string) []string {
+		return append(append([]string{}, names...), label)
+	}
+	m := &NodeMetrics{
+		RequestsCounter: registerCounter(prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: NodeRequestsMetricName,
+				Help: "A counter of calls from executor to graph nodes",
+			},
+			withLabel(ServiceMetric),
+		)),
+		ErrorsCounter: registerCounter(prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: NodeErrorsMetricName,
+				Help: "A counter of failed calls from executor to graph nodes by class of error",
+			},
+			append(withLabel(ServiceMetric), ErrorClassMetric),
+		)),
+		InFlightGauge: registerGauge(prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: NodeInFlightMetricName,
+				Help: "The number of calls from executor to graph nodes in progress",
+			},
+			withLabel(ServiceMetric),
+		)),
+		RequestBytesHistogram: registerHistogram(prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    NodeRequestBytesMetricName,
+				Help:    "A histogram of the size of request payloads sent to graph nodes",
+				Buckets: ByteBuckets,
+			},
+			names,
+		)),
+		ResponseBytesHistogram: registerHistogram(prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    NodeResponseBytesMetricName,
+				Help:    "A histogram of the size of response payloads returned by graph nodes",
+				Buckets: ByteBuckets,
+			},
+			names,
+		)),
+		RoutingCounter: registerCounter(prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: RoutingDecisionsMetricName,
+				Help: "A counter of the children chosen by routers",
+			},
+			withLabel(RouteChildMetric),
+		)),
+		Predictor:       spec,
+		DeploymentName:  deploymentName,
+		Labels:          labels,
+		nodeLabelValues: map[string][]string{},
+	}
+	for _, node := range v1.GetPredictiveUnitList(&spec.Graph) {
+		m.nodeLabelValues[node.Name] = m.labelValues(node.Name)
+	}
+	return m
+}
+
+// imageNameVersion returns the image name and version of the container of a node.
+func imageNameVersion(spec *v1.PredictorSpec, modelName string) (string, string) {
+	container := v1.GetContainerForPredictiveUnit(spec, modelName)
+	if container == nil {
+		return "", ""
+	}
+	imageParts := strings.Split(container.Image, ":")
+	if len(imageParts) == 2 {
+		return imageParts[0], imageParts[1]
+	}
+	return imageParts[0], ""
+}
+
+func (m *NodeMetrics) labelValues(modelName string) []string {
+	values := []string{m.DeploymentName, m.Predictor.Name, modelName}
+	imageName, imageVersion := imageNameVersion(m.Predictor, modelName)
+	for _, label := range m.Labels {
+		switch label {
+		case PredictorVersionMetric:
+			values = append(values, m.Predictor.Annotations["version"])
+		case ModelImageMetric:
+			values = append(values, imageName)
+		case ModelVersionMetric:
+			values = append(values, imageVersion)
+		}
+	}
+	return values
+}
+
+// values returns the label values of a node followed by the extra values.
+func (m *NodeMetrics) values(modelName string, extra ...string) []string {
+	values, ok := m.nodeLabelValues[modelName]
+	if !ok {
+		values = m.labelValues(modelName)
+	}
+	return append(append([]string{}, values...), extra...)
+}
+
+// The methods of NodeMetrics do nothing if metrics were not created.
+
+// Started records the start of a call to a node.
+func (m *NodeMetrics) Started(modelName string, service string) {
+	if m != nil {
+		m.InFlightGauge.WithLabelValues(m.values(modelName, service)...).Inc()
+	}
+}
+
+// Finished records the end of a call to a node. The error class is empty if the call succeeded.
+func (m *NodeMetrics) Finished(modelName string, service string, errorClass string) {
+	if m != nil {
+		m.InFlightGauge.WithLabelValues(m.values(modelName, service)...).Dec()
+		m.RequestsCounter.WithLabelValues(m.values(modelName, service)...).Inc()
+		if errorClass != "" {
+			m.ErrorsCounter.WithLabelValues(m.values(modelName, service, errorClass)...).Inc()
+		}
+	}
+}
+
+func (m *NodeMetrics) RequestBytes(modelName string, size int) {
+	if m != nil {
+		m.RequestBytesHistogram.WithLabelValues(m.values(modelName)...).Observe(float64(size))
+	}
+}
+
+func (m *NodeMetrics) ResponseBytes(modelName string, size int) {
+	if m != nil {
+		m.ResponseBytesHistogram.WithLabelValues(m.values(modelName)...).Observe(float64(size))
+	}
+}
+
+// Routed records the child chosen by a router.
+func (m *NodeMetrics) Routed(modelName string, child string) {
+	if m != nil {
+		m.RoutingCounter.WithLabelValues(m.values(modelName, child)...).Inc()
+	}
+}
@@ -0,0 +1,101 @@
+package metric
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/seldonio/seldon-core/executor/api/grpc/seldon/proto"
+	v1 "github.com/seldonio/seldon-core/operator/apis/machinelearning.seldon.io/v1"
+	v12 "k8s.io/api/core/v1"
+)
+
+func TestParseNodeLabels(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	labels, err := ParseNodeLabels(DefaultNodeLabels)
+	g.Expect(err).To(BeNil())
+	g.Expect(labels).To(Equal(OptionalNodeLabels))
+
+	labels, err = ParseNodeLabels(" model_image ,")
+	g.Expect(err).To(BeNil())
+	g.Expect(labels).To(Equal([]string{ModelImageMetric}))
+
+	labels, err = ParseNodeLabels("")
+	g.Expect(err).To(BeNil())
+	g.Expect(labels).To(BeEmpty())
+
+	_, err = ParseNodeLabels("model_image,puid")
+	g.Expect(err).ToNot(BeNil())
+}
+
+func TestNodeMetricsLabels(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	predictor := &v1.PredictorSpec{
+		Name:  "p",
+		Graph: v1.PredictiveUnit{Name: "classifier"},
+		ComponentSpecs: []*v1.SeldonPodSpec{
+			{Spec: v12.PodSpec{Containers: []v12.Container{{Name: "classifier", Image: "image:1.2"}}}},
+		},
+	}
+	m := NewNodeMetrics(predictor, "dep", []string{ModelVersionMetric})
+
+	g.Expect(m.values("classifier", "/predict")).To(Equal([]string{"dep", "p", "classifier", "1.2", "/predict"}))
+	g.Expect(m.values("unknown")).To(Equal([]string{"dep", "p", "unknown", ""}))
+
+	m.Started("classifier", "/predict")
+	g.Expect(testutil.ToFloat64(m.InFlightGauge.WithLabelValues("dep", "p", "classifier", "1.2", "/predict"))).To(Equal(1.0))
+	m.Finished("classifier", "/predict", ErrorClassTimeout)
+	g.Expect(testutil.ToFloat64(m.InFlightGauge.WithLabelValues("dep", "p", "classifier", "1.2", "/predict"))).To(Equal(0.0))
+	g.Expect(testutil.ToFloat64(m.RequestsCounter.WithLabelValues("dep", "p", "classifier", "1.2", "/predict"))).To(Equal(1.0))
+	g.Expect(testutil.ToFloat64(m.ErrorsCounter.WithLabelValues("dep", "p", "classifier", "1.2", "/predict", ErrorClassTimeout))).To(Equal(1.0))
+
+	// Metrics are optional
+	var none *NodeMetrics
+	none.Started("classifier", "/predict")
+	none.Routed("classifier", RouteAllChildren)
+}
+
+func TestRecordCustom(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	m := NewNodeMetrics(&v1.PredictorSpec{Name: "p", Graph: v1.PredictiveUnit{Name: "classifier"}}, "dep", nil)
+
+	err := m.RecordCustom("classifier", []*proto.Metric{
+		{Key: "test_custom_counter", Type: proto.Metric_COUNTER, Value: 2, Tags: map[string]string{"user": "a"}},
+		{Key: "test_custom_counter", Type: proto.Metric_COUNTER, Value: 1, Tags: map[string]string{"user": "a"}},
+		{Key: "test_custom_gauge", Type: proto.Metric_GAUGE, Value: 5},
+		{Key: "test_custom_timer", Type: proto.Metric_TIMER, Value: 20},
+	})
+	g.Expect(err).To(BeNil())
+	g.Expect(testutil.ToFloat64(m.customCollectorOf(t, "COUNTER:test_custom_counter:user").(*prometheus.CounterVec).WithLabelValues("dep", "p", "classifier", "a"))).To(Equal(3.0))
+	g.Expect(testutil.ToFloat64(m.customCollectorOf(t, "GAUGE:test_custom_gauge:").(*prometheus.GaugeVec).WithLabelValues("dep", "p", "classifier"))).To(Equal(5.0))
+	g.Expect(testutil.CollectAndCount(m.customCollectorOf(t, "TIMER:test_custom_timer:"))).To(Equal(1))
+
+	// Tags must be the same each time a metric is returned
+	err = m.RecordCustom("classifier", []*proto.Metric{{Key: "test_custom_counter", Type: proto.Metric_COUNTER, Value: 1}})
+	g.Expect(err).ToNot(BeNil())
+	err = m.RecordCustom("classifier", []*proto.Metric{{Key: "test_custom_counter", Type: proto.Metric_COUNTER, Value: 1}})
+	g.Expect(err).To(BeNil())
+
+	// Metric names can't be used by metrics of another type
+	err = m.RecordCustom("classifier", []*proto.Metric{{Key: NodeRequestsMetricName, Type: proto.Metric_GAUGE, Value: 1}})
+	g.Expect(err).ToNot(BeNil())
+
+	// Unknown types are rejected rather than registered
+	err = m.RecordCustom("classifier", []*proto.Metric{{Key: "test_custom_unknown", Type: proto.Metric_MetricType(7), Value: 1}})
+	g.Expect(err).ToNot(BeNil())
+	g.Expect(err.Error()).To(ContainSubstring("unknown metric type 7"))
+	err = m.RecordCustom("classifier", []*proto.Metric{{Key: "test_custom_unknown", Type: proto.Metric_MetricType(7), Value: 1}})
+	g.Expect(err).To(BeNil())
+}
+
+func (m *NodeMetrics) customCollectorOf(t *testing.T, key string) prometheus.Collector {
+	c, ok := m.custom.Load(key)
+	if !ok {
+		t.Fatalf("No custom collector %s", key)
+	}
+	return c.(customCollector).collector
+}
@@ -56,3 +56,14 @@ func (m *ServerMetrics) UnaryServerInterceptor() func(ctx context.Context, req i
 		return resp, err
 	}
 }
+
+// StreamServerInterceptor is a gRPC server-side interceptor that provides Prometheus monitoring for Streaming RPCs.
+func (m *ServerMetrics) StreamServerInterceptor() func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		startTime := time.Now()
+		err := handler(srv, ss)
+		st, _ := status.FromError(err)
+		m.ServerHandledHistogram.WithLabelValues(m.DeploymentName, m.Predictor.Name, m.Predictor.Annotations["version"], info.FullMethod, "stream", st.Code().String()).Observe(time.Since(startTime).Seconds())
+		return err
+	}
+}
@@ -2,6 +2,8 @@ package payload
 
 const (
 	SeldonPUIDHeader = "Seldon-Puid"
+	// Deadline of a REST or Kafka request in milliseconds
+	SeldonRequestTimeoutHeader = "Seldon-Request-Timeout"
 )
 
 type MetaData struct {
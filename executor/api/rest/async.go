@@ -0,0 +1,204 @@
+package rest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"path"
+	"strconv"
+	"time"
+
+	http2 "github.com/cloudevents/sdk-go/pkg/bindings/http"
+	"github.com/gorilla/mux"
+	"github.com/seldonio/seldon-core/executor/api/async"
+	"github.com/seldonio/seldon-core/executor/api/payload"
+	"github.com/seldonio/seldon-core/executor/api/tracing"
+	"github.com/seldonio/seldon-core/executor/predictor"
+	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
+)
+
+const (
+	JobHttpPathVariable = "job"
+
+	// Header or query parameter with a URL the result of an asynchronous request is posted to
+	CallbackUrlHeader     = "Seldon-Callback-Url"
+	callbackUrlQueryParam = "callback"
+	// Query parameter with how long to wait for an asynchronous request to finish, as a duration
+	// such as 30s or a number of seconds
+	waitQueryParam = "wait"
+)
+
+// asyncJobStatus is the response to asynchronous requests which have not finished.
+type asyncJobStatus struct {
+	Id            string          `json:"id"`
+	Status        async.JobStatus `json:"status"`
+	Created       time.Time       `json:"created"`
+	Updated       time.Time       `json:"updated"`
+	CallbackError string          `json:"callbackError,omitempty"`
+}
+
+// asyncPredictions validates a prediction request and queues it to run on the graph. The job id is
+// the Seldon-Puid of the request.
+func (r *SeldonRestApi) asyncPredictions(w http.ResponseWriter, req *http.Request) {
+	r.Log.V(1).Info("Async predictions called")
+
+	puid := req.Header.Get(payload.SeldonPUIDHeader)
+	ctx := context.WithValue(req.Context(), payload.SeldonPUIDHeader, puid)
+
+	ctx, serverSpan := setupTracing(ctx, req, TracingAsyncName)
+	defer serverSpan.End()
+
+	callbackUrl := asyncCallbackUrl(req)
+	// The deadline applies to running the job rather than waiting in the queue
+	timeout, err := predictor.RequestTimeout(req.Header.Get(payload.SeldonRequestTimeoutHeader), r.RequestTimeout)
+	if err != nil {
+		r.respondWithError(w, nil, err)
+		return
+	}
+
+	bodyBytes, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		r.respondWithError(w, nil, err)
+		return
+	}
+	reqPayload, err := r.Client.Unmarshall(bodyBytes, req.Header.Get(http2.ContentType))
+	if err != nil {
+		r.respondWithError(w, nil, err)
+		return
+	}
+	graphNode, err := r.graphNode(req)
+	if err != nil {
+		r.respondWithError(w, nil, err)
+		return
+	}
+	seldonPredictorProcess := predictor.NewPredictorProcess(ctx, r.Client, logf.Log.WithName(LoggingRestClientName), r.ServerUrl, r.Namespace, req.Header)
+	if err := seldonPredictorProcess.ValidateRequest(graphNode, r.Protocol, reqPayload); err != nil {
+		r.respondWithError(w, nil, err)
+		return
+	}
+
+	// The request headers are kept for the job, with the trace context of this request so the
+	// job is part of the same trace
+	header := http.Header{}
+	for k, v := range req.Header {
+		header[k] = append([]string{}, v...)
+	}
+	tracing.Inject(ctx, tracing.HeaderCarrier(header))
+
+	job, err := r.Jobs.Submit(puid, callbackUrl, func(ctx context.Context) *async.Result {
+		ctx = context.WithValue(ctx, payload.SeldonPUIDHeader, puid)
+		if timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+		ctx, span := tracing.StartServerSpan(ctx, tracing.HeaderCarrier(header), TracingPredictionsName)
+		seldonPredictorProcess := predictor.NewPredictorProcess(ctx, r.Client, logf.Log.WithName(LoggingRestClientName), r.ServerUrl, r.Namespace, header)
+		resPayload, err := seldonPredictorProcess.Predict(graphNode, reqPayload)
+		tracing.EndSpan(span, err)
+		return r.asyncResult(resPayload, err)
+	})
+	if err != nil {
+		r.respondWithError(w, nil, err)
+		return
+	}
+	w.Header().Set("Location", path.Join(req.URL.Path, job.Id))
+	r.respondWithJob(w, http.StatusAccepted, job)
+}
+
+// asyncStatus returns the result of an asynchronous request once it has finished and its status
+// otherwise. The request can wait for the job to finish.
+func (r *SeldonRestApi) asyncStatus(w http.ResponseWriter, req *http.Request) {
+	ctx, serverSpan := setupTracing(req.Context(), req, TracingStatusName)
+	defer serverSpan.End()
+
+	wait, err := asyncWait(req)
+	if err != nil {
+		r.respondWithError(w, nil, err)
+		return
+	}
+	job, err := r.Jobs.Wait(ctx, mux.Vars(req)[JobHttpPathVariable], wait)
+	if err != nil {
+		r.respondWithError(w, nil, err)
+		return
+	}
+	w.Header().Set(payload.SeldonPUIDHeader, job.Id)
+	if !job.Done() {
+		r.respondWithJob(w, http.StatusAccepted, job)
+		return
+	}
+	w.Header().Set(async.StatusHeader, string(job.Status))
+	res := job.Result
+	// Jobs which were interrupted only have an error
+	if len(res.Body) == 0 {
+		r.respondWithError(w, nil, errors.New(res.Error))
+		return
+	}
+	w.Header().Set("Content-Type", res.ContentType)
+	w.WriteHeader(res.StatusCode)
+	if _, err := w.Write(res.Body); err != nil {
+		r.Log.Error(err, "Failed to write response")
+	}
+}
+
+func (r *SeldonRestApi) respondWithJob(w http.ResponseWriter, code int, job *async.Job) {
+	w.Header().Set(async.StatusHeader, string(job.Status))
+	w.Header().Set("Content-Type", ContentTypeJSON)
+	w.WriteHeader(code)
+	err := json.NewEncoder(w).Encode(asyncJobStatus{
+		Id:            job.Id,
+		Status:        job.Status,
+		Created:       job.Created,
+		Updated:       job.Updated,
+		CallbackError: job.CallbackError,
+	})
+	if err != nil {
+		r.Log.Error(err, "Failed to write response")
+	}
+}
+
+// asyncResult encodes the response of the graph, or the error it returned, as it would be returned
+// to a synchronous request.
+func (r *SeldonRestApi) asyncResult(res payload.SeldonPayload, err error) *async.Result {
+	result := &async.Result{StatusCode: http.StatusOK}
+	if err != nil {
+		result.StatusCode = errorStatusCode(err)
+		result.Error = err.Error()
+		if res == nil || res.GetPayload() == nil {
+			res = r.Client.CreateErrorPayload(err)
+		}
+	}
+	var buf bytes.Buffer
+	if err := r.Client.Marshall(&buf, res); err != nil {
+		return &async.Result{StatusCode: http.StatusInternalServerError, Error: err.Error()}
+	}
+	result.ContentType = res.GetContentType()
+	result.Body = buf.Bytes()
+	return result
+}
+
+// asyncCallbackUrl returns the callback URL of a request, which is checked by the job manager.
+func asyncCallbackUrl(req *http.Request) string {
+	callbackUrl := req.Header.Get(CallbackUrlHeader)
+	if callbackUrl == "" {
+		callbackUrl = req.URL.Query().Get(callbackUrlQueryParam)
+	}
+	return callbackUrl
+}
+
+func asyncWait(req *http.Request) (time.Duration, error) {
+	wait := req.URL.Query().Get(waitQueryParam)
+	if wait == "" {
+		return 0, nil
+	}
+	if d, err := time.ParseDuration(wait); err == nil && d >= 0 {
+		return d, nil
+	}
+	if secs, err := strconv.Atoi(wait); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second, nil
+	}
+	return 0, &badRequestError{msg: "Invalid wait " + wait + ": must be a duration such as 30s or a number of seconds"}
+}
@@ -0,0 +1,135 @@
+package rest
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/seldonio/seldon-core/executor/api"
+	"github.com/seldonio/seldon-core/executor/api/async"
+	"github.com/seldonio/seldon-core/executor/api/payload"
+	"github.com/seldonio/seldon-core/executor/api/test"
+	v1 "github.com/seldonio/seldon-core/operator/apis/machinelearning.seldon.io/v1"
+	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
+)
+
+func createAsyncRestApi(g *GomegaWithT, protocol string) (*SeldonRestApi, *async.Manager) {
+	model := v1.MODEL
+	p := v1.PredictorSpec{
+		Name: "p",
+		Graph: v1.PredictiveUnit{
+			Name: "mymodel",
+			Type: &model,
+			Endpoint: &v1.Endpoint{
+				ServiceHost: "foo",
+				ServicePort: 9000,
+				Type:        v1.REST,
+			},
+		},
+	}
+	jobs, err := async.NewManager(async.NewMemoryResultStore(), async.DefaultOptions, logf.Log.WithName("test"))
+	g.Expect(err).To(BeNil())
+	jobs.Start()
+
+	url, _ := url.Parse("http://localhost")
+	r := NewServerRestApi(&p, &test.SeldonMessageTestClient{}, false, url, "default", protocol, "test", "/metrics")
+	r.Jobs = jobs
+	r.Initialise()
+	return r, jobs
+}
+
+func TestAsyncPredictions(t *testing.T) {
+	g := NewGomegaWithT(t)
+	r, jobs := createAsyncRestApi(g, api.ProtocolSeldon)
+	defer jobs.Stop(context.Background())
+
+	var data = `{"data":{"ndarray":[1.1,2.0]}}`
+	req, _ := http.NewRequest("POST", "/api/v1.0/predictions/async", strings.NewReader(data))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(payload.SeldonPUIDHeader, "job-1")
+	res := httptest.NewRecorder()
+	r.Router.ServeHTTP(res, req)
+	g.Expect(res.Code).To(Equal(http.StatusAccepted))
+	g.Expect(res.Header().Get("Location")).To(Equal("/api/v1.0/predictions/async/job-1"))
+	g.Expect(res.Header().Get(async.StatusHeader)).To(Equal(string(async.JobPending)))
+	g.Expect(res.Body.String()).To(ContainSubstring(`"id":"job-1"`))
+
+	req, _ = http.NewRequest("GET", "/api/v1.0/predictions/async/job-1?wait=10s", nil)
+	res = httptest.NewRecorder()
+	r.Router.ServeHTTP(res, req)
+	g.Expect(res.Code).To(Equal(http.StatusOK))
+	g.Expect(res.Header().Get(async.StatusHeader)).To(Equal(string(async.JobSucceeded)))
+	g.Expect(res.Header().Get(payload.SeldonPUIDHeader)).To(Equal("job-1"))
+	g.Expect(res.Body.String()).To(ContainSubstring(`"ndarray":[1.1,2.0]`))
+}
+
+func TestAsyncPredictionsKFServing(t *testing.T) {
+	g := NewGomegaWithT(t)
+	r, jobs := createAsyncRestApi(g, api.ProtocolKFServing)
+	defer jobs.Stop(context.Background())
+
+	var data = `{"inputs":[{"name":"input","datatype":"FP32","shape":[1],"data":[1.0]}]}`
+	req, _ := http.NewRequest("POST", "/v2/models/mymodel/infer/async", strings.NewReader(data))
+	req.Header.Set(payload.SeldonPUIDHeader, "job-1")
+	res := httptest.NewRecorder()
+	r.Router.ServeHTTP(res, req)
+	g.Expect(res.Code).To(Equal(http.StatusAccepted))
+	g.Expect(res.Header().Get("Location")).To(Equal("/v2/models/mymodel/infer/async/job-1"))
+
+	req, _ = http.NewRequest("GET", "/v2/models/mymodel/infer/async/job-1?wait=10", nil)
+	res = httptest.NewRecorder()
+	r.Router.ServeHTTP(res, req)
+	g.Expect(res.Code).To(Equal(http.StatusOK))
+	g.Expect(res.Body.String()).To(Equal(data))
+}
+
+func TestAsyncBadRequests(t *testing.T) {
+	g := NewGomegaWithT(t)
+	r, jobs := createAsyncRestApi(g, api.ProtocolSeldon)
+	defer jobs.Stop(context.Background())
+
+	req, _ := http.NewRequest("GET", "/api/v1.0/predictions/async/unknown", nil)
+	res := httptest.NewRecorder()
+	r.Router.ServeHTTP(res, req)
+	g.Expect(res.Code).To(Equal(http.StatusNotFound))
+
+	req, _ = http.NewRequest("GET", "/api/v1.0/predictions/async/unknown?wait=soon", nil)
+	res = httptest.NewRecorder()
+	r.Router.ServeHTTP(res, req)
+	g.Expect(res.Code).To(Equal(http.StatusBadRequest))
+
+	req, _ = http.NewRequest("POST", "/api/v1.0/predictions/async?callback=/results", strings.NewReader(`{"data":{"ndarray":[1.1,2.0]}}`))
+	res = httptest.NewRecorder()
+	r.Router.ServeHTTP(res, req)
+	g.Expect(res.Code).To(Equal(http.StatusBadRequest))
+
+	// Callbacks are only sent to allowed URLs
+	req, _ = http.NewRequest("POST", "/api/v1.0/predictions/async", strings.NewReader(`{"data":{"ndarray":[1.1,2.0]}}`))
+	req.Header.Set(CallbackUrlHeader, "http://169.254.169.254/latest/meta-data")
+	res = httptest.NewRecorder()
+	r.Router.ServeHTTP(res, req)
+	g.Expect(res.Code).To(Equal(http.StatusBadRequest))
+
+	req, _ = http.NewRequest("POST", "/api/v1.0/predictions/async", strings.NewReader(`{"data":{"ndarray":[1.1,2.0]}}`))
+	req.Header.Set(payload.SeldonPUIDHeader, "a/b")
+	res = httptest.NewRecorder()
+	r.Router.ServeHTTP(res, req)
+	g.Expect(res.Code).To(Equal(http.StatusBadRequest))
+}
+
+func TestAsyncDisabled(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	url, _ := url.Parse("http://localhost")
+	r := NewServerRestApi(&v1.PredictorSpec{Name: "p"}, &test.SeldonMessageTestClient{}, false, url, "default", api.ProtocolSeldon, "test", "/metrics")
+	r.Initialise()
+
+	req, _ := http.NewRequest("GET", "/api/v1.0/predictions/async/job-1", nil)
+	res := httptest.NewRecorder()
+	r.Router.ServeHTTP(res, req)
+	g.Expect(res.Code).To(Equal(http.StatusNotFound))
+}
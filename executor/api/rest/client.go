@@ -7,18 +7,21 @@ import (
 	http2 "github.com/cloudevents/sdk-go/pkg/bindings/http"
 	"github.com/go-logr/logr"
 	"github.com/golang/protobuf/jsonpb"
-	"github.com/opentracing/opentracing-go"
 	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/seldonio/seldon-core/executor/api"
+	"github.com/seldonio/seldon-core/executor/api/chain"
 	"github.com/seldonio/seldon-core/executor/api/client"
 	"github.com/seldonio/seldon-core/executor/api/grpc/seldon/proto"
 	"github.com/seldonio/seldon-core/executor/api/metric"
 	"github.com/seldonio/seldon-core/executor/api/payload"
+	"github.com/seldonio/seldon-core/executor/api/tracing"
 	"github.com/seldonio/seldon-core/executor/api/util"
 	"github.com/seldonio/seldon-core/executor/k8s"
 	v1 "github.com/seldonio/seldon-core/operator/apis/machinelearning.seldon.io/v1"
+	"go.opentelemetry.io/otel/semconv"
+	"go.opentelemetry.io/otel/trace"
 	"io"
 	"io/ioutil"
 	"net"
@@ -43,6 +46,7 @@ type JSONRestClient struct {
 	DeploymentName string
 	predictor      *v1.PredictorSpec
 	metrics        *metric.ClientMetrics
+	mappers        *chain.Mappers
 }
 
 func (smc *JSONRestClient) IsGrpc() bool {
@@ -92,6 +96,13 @@ func getRestTimeoutFromAnnotations(annotations map[string]string) (int, error) {
 	}
 }
 
+// GetRequestTimeoutFromAnnotations returns the deadline of requests to the executor without a
+// Seldon-Request-Timeout header, which is the REST timeout of the deployment.
+func GetRequestTimeoutFromAnnotations(annotations map[string]string) (time.Duration, error) {
+	restTimeout, err := getRestTimeoutFromAnnotations(annotations)
+	return time.Duration(restTimeout) * time.Millisecond, err
+}
+
 func NewJSONRestClient(protocol string, deploymentName string, predictor *v1.PredictorSpec, annotations map[string]string, options ...BytesRestClientOption) (client.SeldonApiClient, error) {
 
 	httpClient := http.DefaultClient
@@ -114,10 +125,16 @@ func NewJSONRestClient(protocol string, deploymentName string, predictor *v1.Pre
 		deploymentName,
 		predictor,
 		metric.NewClientMetrics(predictor, deploymentName, ""),
+		nil,
 	}
 	for i := range options {
 		options[i](&client)
 	}
+	if protocol == api.ProtocolKFServing {
+		client.mappers = chain.NewMappers(predictor, false, func(ctx context.Context, modelName string, host string, port int32) (payload.ModelMetadata, error) {
+			return client.ModelMetadata(ctx, modelName, host, port, nil, nil)
+		}, client.Log)
+	}
 
 	return &client, nil
 }
@@ -172,31 +189,25 @@ func (smc *JSONRestClient) doHttp(ctx context.Context, modelName string, method
 		}
 	}
 
+	// Carry the request deadline and cancellation to the downstream call
+	req = req.WithContext(ctx)
+
 	// Add metadata passed in
 	smc.addHeaders(req, meta)
 
-	if opentracing.IsGlobalTracerRegistered() {
-		tracer := opentracing.GlobalTracer()
-
-		startSpanOptions := make([]opentracing.StartSpanOption, 0)
-		parentSpan := opentracing.SpanFromContext(ctx)
-		if parentSpan != nil {
-			startSpanOptions = append(startSpanOptions, opentracing.ChildOf(parentSpan.Context()))
-		}
-		clientSpan := opentracing.StartSpan(
-			method,
-			startSpanOptions...)
-		defer clientSpan.Finish()
-		tracer.Inject(clientSpan.Context(), opentracing.HTTPHeaders, opentracing.HTTPHeadersCarrier(req.Header))
-	}
+	ctx, clientSpan := tracing.Tracer().Start(ctx, method, trace.WithSpanKind(trace.SpanKindClient))
+	defer clientSpan.End()
+	tracing.Inject(ctx, tracing.HeaderCarrier(req.Header))
 
 	client := smc.httpClient
 	client.Transport = smc.getMetricsRoundTripper(modelName, method)
 
 	response, err := client.Do(req)
 	if err != nil {
+		tracing.SetError(clientSpan, err)
 		return nil, "", err
 	}
+	clientSpan.SetAttributes(semconv.HTTPStatusCodeKey.Int(response.StatusCode))
 
 	//Read response
 	b, err := ioutil.ReadAll(response.Body)
@@ -210,6 +221,7 @@ func (smc *JSONRestClient) doHttp(ctx context.Context, modelName string, method
 	if response.StatusCode != http.StatusOK {
 		smc.Log.Info("httpPost failed", "response code", response.StatusCode)
 		err = &httpStatusError{StatusCode: response.StatusCode, Url: url}
+		tracing.SetError(clientSpan, err)
 	}
 
 	return b, contentTypeResponse, err
@@ -305,7 +317,7 @@ func (smc *JSONRestClient) Chain(ctx context.Context, modelName string, msg payl
 	case api.ProtocolTensorflow: // Attempt to chain tensorflow payload
 		return ChainTensorflow(msg)
 	case api.ProtocolKFServing:
-		return ChainKFserving(msg)
+		return ChainKFserving(ctx, modelName, msg, smc.mappers)
 	}
 	return nil, errors.Errorf("Unknown protocol %s", smc.Protocol)
 }
@@ -4,6 +4,7 @@ const (
 	TracingPredictionsName = "predictions"
 	TracingStatusName      = "status"
 	TracingMetadataName    = "metadata"
+	TracingAsyncName       = "predictions-async"
 
 	LoggingRestClientName = "RestClient"
 )
@@ -14,6 +14,20 @@ func (e *httpStatusError) Error() string {
 	return fmt.Sprintf("Internal service call from executor failed calling %s status code %d", e.Url, e.StatusCode)
 }
 
+// HTTPStatus allows the class of the error to be found without depending on this package.
+func (e *httpStatusError) HTTPStatus() int {
+	return e.StatusCode
+}
+
 func invalidPayload(msg string) error {
 	return fmt.Errorf("invalid payload: %s", msg)
 }
+
+// badRequestError is returned for requests to the executor which are invalid.
+type badRequestError struct {
+	msg string
+}
+
+func (e *badRequestError) Error() string {
+	return e.msg
+}
@@ -1,28 +1,61 @@
 package rest
 
 import (
+	"context"
 	"encoding/json"
 	"github.com/pkg/errors"
+	"github.com/seldonio/seldon-core/executor/api/chain"
 	"github.com/seldonio/seldon-core/executor/api/payload"
 )
 
-func ChainKFserving(msg payload.SeldonPayload) (payload.SeldonPayload, error) {
-	var f interface{}
-	err := json.Unmarshal(msg.GetPayload().([]byte), &f)
+// ChainKFserving turns a v2 response into a request for the next model, renaming the outputs
+// to the inputs of the next model with its mapper.
+func ChainKFserving(ctx context.Context, modelName string, msg payload.SeldonPayload, mappers *chain.Mappers) (payload.SeldonPayload, error) {
+	var m map[string]json.RawMessage
+	err := json.Unmarshal(msg.GetPayload().([]byte), &m)
 	if err != nil {
 		return nil, err
 	}
-	m := f.(map[string]interface{})
 	if _, ok := m["inputs"]; ok {
 		return msg, nil
-	} else if _, ok := m["outputs"]; ok {
-		m["inputs"] = m["outputs"]
+	} else if rawOutputs, ok := m["outputs"]; ok {
+		var outputs []map[string]interface{}
+		if err := json.Unmarshal(rawOutputs, &outputs); err != nil {
+			return nil, errors.Wrap(err, "Failed to decode kfserving outputs")
+		}
+		tensors := make([]chain.Tensor, len(outputs))
+		for i, output := range outputs {
+			tensors[i].Name, _ = output["name"].(string)
+			tensors[i].Datatype, _ = output["datatype"].(string)
+			shape, _ := output["shape"].([]interface{})
+			for _, d := range shape {
+				if n, ok := d.(float64); ok {
+					tensors[i].Shape = append(tensors[i].Shape, int64(n))
+				}
+			}
+		}
+		mapper, err := mappers.Get(ctx, modelName)
+		if err != nil {
+			return nil, err
+		}
+		names, err := mapper.Map(tensors)
+		if err != nil {
+			return nil, err
+		}
+		for i, output := range outputs {
+			output["name"] = names[i]
+		}
+		inputs, err := json.Marshal(outputs)
+		if err != nil {
+			return nil, err
+		}
+		m["inputs"] = inputs
 		delete(m, "outputs")
 		b, err := json.Marshal(m)
 		if err != nil {
 			return nil, err
 		} else {
-			p := payload.BytesPayload{Msg: b}
+			p := payload.BytesPayload{Msg: b, ContentType: msg.GetContentType()}
 			return &p, nil
 		}
 	} else {
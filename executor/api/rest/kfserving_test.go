@@ -0,0 +1,34 @@
+package rest
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/seldonio/seldon-core/executor/api/chain"
+	"github.com/seldonio/seldon-core/executor/api/payload"
+	v1 "github.com/seldonio/seldon-core/operator/apis/machinelearning.seldon.io/v1"
+	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
+)
+
+func TestChainKFservingMapping(t *testing.T) {
+	g := NewGomegaWithT(t)
+	predictor := &v1.PredictorSpec{
+		Name: "p",
+		Graph: v1.PredictiveUnit{
+			Name:       "second",
+			Parameters: []v1.Parameter{{Name: chain.InputMappingParameter, Value: "predict:input", Type: v1.STRING}},
+		},
+	}
+	mappers := chain.NewMappers(predictor, false, nil, logf.Log)
+	msg := &payload.BytesPayload{Msg: []byte(`{"model_name":"first","outputs":[{"name":"predict","shape":[2],"datatype":"FP32","data":[1,2]}]}`), ContentType: ContentTypeJSON}
+	res, err := ChainKFserving(context.Background(), "second", msg, mappers)
+	g.Expect(err).To(BeNil())
+	g.Expect(string(res.GetPayload().([]byte))).To(Equal(`{"inputs":[{"data":[1,2],"datatype":"FP32","name":"input","shape":[2]}],"model_name":"first"}`))
+	g.Expect(res.GetContentType()).To(Equal(ContentTypeJSON))
+
+	// Requests are not changed
+	res, err = ChainKFserving(context.Background(), "second", &payload.BytesPayload{Msg: []byte(`{"inputs":[]}`)}, mappers)
+	g.Expect(err).To(BeNil())
+	g.Expect(string(res.GetPayload().([]byte))).To(Equal(`{"inputs":[]}`))
+}
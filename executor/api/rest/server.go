@@ -8,19 +8,22 @@ import (
 	"io/ioutil"
 	"net/http"
 	"net/url"
+	"strconv"
 
 	"github.com/go-logr/logr"
 	"github.com/gorilla/mux"
-	"github.com/opentracing/opentracing-go"
-	"github.com/opentracing/opentracing-go/ext"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/seldonio/seldon-core/executor/api"
+	"github.com/seldonio/seldon-core/executor/api/async"
 	"github.com/seldonio/seldon-core/executor/api/client"
+	"github.com/seldonio/seldon-core/executor/api/lifecycle"
 	"github.com/seldonio/seldon-core/executor/api/metric"
 	"github.com/seldonio/seldon-core/executor/api/payload"
+	"github.com/seldonio/seldon-core/executor/api/tracing"
 	"github.com/seldonio/seldon-core/executor/predictor"
 	v1 "github.com/seldonio/seldon-core/operator/apis/machinelearning.seldon.io/v1"
+	"go.opentelemetry.io/otel/trace"
 	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
 	"time"
 )
@@ -37,17 +40,24 @@ type SeldonRestApi struct {
 	DeploymentName string
 	metrics        *metric.ServerMetrics
 	prometheusPath string
+	metadataCache  *predictor.GraphMetadataCache
+	ReadyChecker   *predictor.ReadyChecker
+	// Runs asynchronous predictions. The asynchronous endpoints are only added if it is set.
+	Jobs *async.Manager
+	// Deadline of requests without a Seldon-Request-Timeout header. None is set if it is zero.
+	RequestTimeout time.Duration
 }
 
-func NewServerRestApi(predictor *v1.PredictorSpec, client client.SeldonApiClient, probesOnly bool, serverUrl *url.URL, namespace string, protocol string, deploymentName string, prometheusPath string) *SeldonRestApi {
+func NewServerRestApi(predictorSpec *v1.PredictorSpec, client client.SeldonApiClient, probesOnly bool, serverUrl *url.URL, namespace string, protocol string, deploymentName string, prometheusPath string) *SeldonRestApi {
 	var serverMetrics *metric.ServerMetrics
 	if !probesOnly {
-		serverMetrics = metric.NewServerMetrics(predictor, deploymentName)
+		serverMetrics = metric.NewServerMetrics(predictorSpec, deploymentName)
 	}
+	readyChecker := predictor.NewReadyChecker(predictorSpec, protocol, client, nil)
 	return &SeldonRestApi{
 		mux.NewRouter(),
 		client,
-		predictor,
+		predictorSpec,
 		logf.Log.WithName("SeldonRestApi"),
 		probesOnly,
 		serverUrl,
@@ -56,6 +66,10 @@ func NewServerRestApi(predictor *v1.PredictorSpec, client client.SeldonApiClient
 		deploymentName,
 		serverMetrics,
 		prometheusPath,
+		predictor.NewGraphMetadataCache(client, predictorSpec, serverUrl, namespace, readyChecker),
+		readyChecker,
+		nil,
+		0,
 	}
 }
 
@@ -86,11 +100,7 @@ func (r *SeldonRestApi) respondWithSuccess(w http.ResponseWriter, code int, payl
 
 func (r *SeldonRestApi) respondWithError(w http.ResponseWriter, payload payload.SeldonPayload, err error) {
 
-	if serr, ok := err.(*httpStatusError); ok {
-		w.WriteHeader(serr.StatusCode)
-	} else {
-		w.WriteHeader(http.StatusInternalServerError)
-	}
+	w.WriteHeader(errorStatusCode(err))
 
 	if payload != nil && payload.GetPayload() != nil {
 		w.Header().Set("Content-Type", payload.GetContentType())
@@ -108,6 +118,37 @@ func (r *SeldonRestApi) respondWithError(w http.ResponseWriter, payload payload.
 	}
 }
 
+// requestContext sets the deadline of a request so the graph is walked within it.
+func (r *SeldonRestApi) requestContext(ctx context.Context, req *http.Request) (context.Context, context.CancelFunc, error) {
+	timeout, err := predictor.RequestTimeout(req.Header.Get(payload.SeldonRequestTimeoutHeader), r.RequestTimeout)
+	if err != nil {
+		return ctx, func() {}, err
+	}
+	if timeout > 0 {
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		return ctx, cancel, nil
+	}
+	return ctx, func() {}, nil
+}
+
+// errorStatusCode returns the HTTP status code of the response for an error.
+func errorStatusCode(err error) int {
+	switch serr := err.(type) {
+	case *httpStatusError:
+		return serr.StatusCode
+	case *predictor.NodeTimeoutError:
+		return http.StatusGatewayTimeout
+	case *predictor.CircuitOpenError, *async.QueueFullError, *async.StoppedError:
+		return http.StatusServiceUnavailable
+	case *badRequestError, *predictor.RequestValidationError, *async.InvalidJobIdError, *async.InvalidCallbackUrlError:
+		return http.StatusBadRequest
+	case *async.JobNotFoundError:
+		return http.StatusNotFound
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
 func (r *SeldonRestApi) wrapMetrics(service string, baseHandler http.HandlerFunc) http.HandlerFunc {
 
 	handler := promhttp.InstrumentHandlerDuration(
@@ -122,6 +163,8 @@ func (r *SeldonRestApi) wrapMetrics(service string, baseHandler http.HandlerFunc
 }
 
 func (r *SeldonRestApi) Initialise() {
+	// Metadata is refreshed when the readiness reported by the probe changes
+	r.metadataCache.ReadyChecker = r.ReadyChecker
 	r.Router.HandleFunc("/ready", r.checkReady)
 	r.Router.HandleFunc("/live", r.alive)
 	r.Router.Handle(r.prometheusPath, promhttp.Handler())
@@ -147,6 +190,10 @@ func (r *SeldonRestApi) Initialise() {
 			api10 := r.Router.PathPrefix("/api/v1.0").Methods("OPTIONS", "POST").Subrouter()
 			api10.Handle("/predictions", r.wrapMetrics(metric.PredictionHttpServiceName, r.predictions))
 			api10.Handle("/feedback", r.wrapMetrics(metric.FeedbackHttpServiceName, r.feedback))
+			if r.Jobs != nil {
+				api10.Handle("/predictions/async", r.wrapMetrics(metric.AsyncPredictionHttpServiceName, r.asyncPredictions))
+				r.Router.NewRoute().Path("/api/v1.0/predictions/async/{"+JobHttpPathVariable+"}").Methods("GET", "OPTIONS").HandlerFunc(r.wrapMetrics(metric.AsyncStatusHttpServiceName, r.asyncStatus))
+			}
 			r.Router.NewRoute().Path("/api/v1.0/status/{"+ModelHttpPathVariable+"}").Methods("GET", "OPTIONS").HandlerFunc(r.wrapMetrics(metric.StatusHttpServiceName, r.status))
 			r.Router.NewRoute().Path("/api/v1.0/metadata").Methods("GET", "OPTIONS").HandlerFunc(r.wrapMetrics(metric.MetadataHttpServiceName, r.graphMetadata))
 			r.Router.NewRoute().Path("/api/v1.0/metadata/{"+ModelHttpPathVariable+"}").Methods("GET", "OPTIONS").HandlerFunc(r.wrapMetrics(metric.MetadataHttpServiceName, r.metadata))
@@ -164,6 +211,12 @@ func (r *SeldonRestApi) Initialise() {
 		case api.ProtocolKFServing:
 			r.Router.NewRoute().Path("/v2/models/{"+ModelHttpPathVariable+"}/infer").Methods("OPTIONS", "POST").HandlerFunc(r.wrapMetrics(metric.PredictionHttpServiceName, r.predictions))
 			r.Router.NewRoute().Path("/v2/models/infer").Methods("OPTIONS", "POST").HandlerFunc(r.wrapMetrics(metric.PredictionHttpServiceName, r.predictions)) // Nonstandard path - Seldon extension
+			if r.Jobs != nil {
+				r.Router.NewRoute().Path("/v2/models/{"+ModelHttpPathVariable+"}/infer/async").Methods("OPTIONS", "POST").HandlerFunc(r.wrapMetrics(metric.AsyncPredictionHttpServiceName, r.asyncPredictions))
+				r.Router.NewRoute().Path("/v2/models/infer/async").Methods("OPTIONS", "POST").HandlerFunc(r.wrapMetrics(metric.AsyncPredictionHttpServiceName, r.asyncPredictions))
+				r.Router.NewRoute().Path("/v2/models/{"+ModelHttpPathVariable+"}/infer/async/{"+JobHttpPathVariable+"}").Methods("GET", "OPTIONS").HandlerFunc(r.wrapMetrics(metric.AsyncStatusHttpServiceName, r.asyncStatus))
+				r.Router.NewRoute().Path("/v2/models/infer/async/{"+JobHttpPathVariable+"}").Methods("GET", "OPTIONS").HandlerFunc(r.wrapMetrics(metric.AsyncStatusHttpServiceName, r.asyncStatus))
+			}
 			r.Router.NewRoute().Path("/v2/models/{"+ModelHttpPathVariable+"}/ready").Methods("GET", "OPTIONS").HandlerFunc(r.wrapMetrics(metric.StatusHttpServiceName, r.status))
 			r.Router.NewRoute().Path("/v2/models/{"+ModelHttpPathVariable+"}").Methods("GET", "OPTIONS").HandlerFunc(r.wrapMetrics(metric.MetadataHttpServiceName, r.metadata))
 
@@ -172,10 +225,17 @@ func (r *SeldonRestApi) Initialise() {
 }
 
 func (r *SeldonRestApi) checkReady(w http.ResponseWriter, req *http.Request) {
-	err := predictor.Ready(&r.predictor.Graph)
+	// Report not ready once shutdown starts so no new requests are sent
+	if lifecycle.IsStopping() {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte("Executor is shutting down"))
+		return
+	}
+	err := r.ReadyChecker.Ready(req.Context())
 	if err != nil {
 		r.Log.Error(err, "Ready check failed")
 		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte(err.Error()))
 	} else {
 		w.WriteHeader(http.StatusOK)
 	}
@@ -185,23 +245,15 @@ func (r *SeldonRestApi) alive(w http.ResponseWriter, req *http.Request) {
 	w.WriteHeader(http.StatusOK)
 }
 
-func setupTracing(ctx context.Context, req *http.Request, spanName string) (context.Context, opentracing.Span) {
-	tracer := opentracing.GlobalTracer()
-	spanCtx, _ := tracer.Extract(opentracing.HTTPHeaders, opentracing.HTTPHeadersCarrier(req.Header))
-	serverSpan := tracer.StartSpan(spanName, ext.RPCServerOption(spanCtx))
-	ctx = opentracing.ContextWithSpan(ctx, serverSpan)
-	return ctx, serverSpan
+func setupTracing(ctx context.Context, req *http.Request, spanName string) (context.Context, trace.Span) {
+	return tracing.StartServerSpan(ctx, tracing.HeaderCarrier(req.Header), spanName)
 }
 
 func (r *SeldonRestApi) metadata(w http.ResponseWriter, req *http.Request) {
 	ctx := req.Context()
 
-	// Apply tracing if active
-	if opentracing.IsGlobalTracerRegistered() {
-		var serverSpan opentracing.Span
-		ctx, serverSpan = setupTracing(ctx, req, TracingMetadataName)
-		defer serverSpan.Finish()
-	}
+	ctx, serverSpan := setupTracing(ctx, req, TracingMetadataName)
+	defer serverSpan.End()
 
 	vars := mux.Vars(req)
 	modelName := vars[ModelHttpPathVariable]
@@ -218,12 +270,8 @@ func (r *SeldonRestApi) metadata(w http.ResponseWriter, req *http.Request) {
 func (r *SeldonRestApi) status(w http.ResponseWriter, req *http.Request) {
 	ctx := req.Context()
 
-	// Apply tracing if active
-	if opentracing.IsGlobalTracerRegistered() {
-		var serverSpan opentracing.Span
-		ctx, serverSpan = setupTracing(ctx, req, TracingStatusName)
-		defer serverSpan.Finish()
-	}
+	ctx, serverSpan := setupTracing(ctx, req, TracingStatusName)
+	defer serverSpan.End()
 
 	vars := mux.Vars(req)
 	modelName := vars[ModelHttpPathVariable]
@@ -241,11 +289,14 @@ func (r *SeldonRestApi) feedback(w http.ResponseWriter, req *http.Request) {
 	ctx := req.Context()
 	ctx = context.WithValue(ctx, payload.SeldonPUIDHeader, req.Header.Get(payload.SeldonPUIDHeader))
 
-	// Apply tracing if active
-	if opentracing.IsGlobalTracerRegistered() {
-		var serverSpan opentracing.Span
-		ctx, serverSpan = setupTracing(ctx, req, TracingStatusName)
-		defer serverSpan.Finish()
+	ctx, serverSpan := setupTracing(ctx, req, TracingStatusName)
+	defer serverSpan.End()
+
+	ctx, cancel, err := r.requestContext(ctx, req)
+	defer cancel()
+	if err != nil {
+		r.respondWithError(w, nil, err)
+		return
 	}
 
 	bodyBytes, err := ioutil.ReadAll(req.Body)
@@ -276,11 +327,14 @@ func (r *SeldonRestApi) predictions(w http.ResponseWriter, req *http.Request) {
 	// Add Seldon Puid to Context
 	ctx = context.WithValue(ctx, payload.SeldonPUIDHeader, req.Header.Get(payload.SeldonPUIDHeader))
 
-	// Apply tracing if active
-	if opentracing.IsGlobalTracerRegistered() {
-		var serverSpan opentracing.Span
-		ctx, serverSpan = setupTracing(ctx, req, TracingPredictionsName)
-		defer serverSpan.Finish()
+	ctx, serverSpan := setupTracing(ctx, req, TracingPredictionsName)
+	defer serverSpan.End()
+
+	ctx, cancel, err := r.requestContext(ctx, req)
+	defer cancel()
+	if err != nil {
+		r.respondWithError(w, nil, err)
+		return
 	}
 
 	bodyBytes, err := ioutil.ReadAll(req.Body)
@@ -297,20 +351,14 @@ func (r *SeldonRestApi) predictions(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	var graphNode *v1.PredictiveUnit
-	if r.Protocol == api.ProtocolTensorflow {
-		vars := mux.Vars(req)
-		modelName := vars[ModelHttpPathVariable]
-		if modelName != "" {
-			if graphNode = v1.GetPredictiveUnit(&r.predictor.Graph, modelName); graphNode == nil {
-				r.respondWithError(w, nil, fmt.Errorf("Failed to find model %s", modelName))
-				return
-			}
-		} else {
-			graphNode = &r.predictor.Graph
-		}
-	} else {
-		graphNode = &r.predictor.Graph
+	graphNode, err := r.graphNode(req)
+	if err != nil {
+		r.respondWithError(w, nil, err)
+		return
+	}
+	if err := seldonPredictorProcess.ValidateRequest(graphNode, r.Protocol, reqPayload); err != nil {
+		r.respondWithError(w, nil, err)
+		return
 	}
 	resPayload, err := seldonPredictorProcess.Predict(graphNode, reqPayload)
 	if err != nil {
@@ -320,26 +368,40 @@ func (r *SeldonRestApi) predictions(w http.ResponseWriter, req *http.Request) {
 	r.respondWithSuccess(w, http.StatusOK, resPayload)
 }
 
+// graphNode returns the node of the graph a prediction request is for. Tensorflow requests can
+// be for a single model of the graph.
+func (r *SeldonRestApi) graphNode(req *http.Request) (*v1.PredictiveUnit, error) {
+	if r.Protocol == api.ProtocolTensorflow {
+		vars := mux.Vars(req)
+		modelName := vars[ModelHttpPathVariable]
+		if modelName != "" {
+			graphNode := v1.GetPredictiveUnit(&r.predictor.Graph, modelName)
+			if graphNode == nil {
+				return nil, fmt.Errorf("Failed to find model %s", modelName)
+			}
+			return graphNode, nil
+		}
+	}
+	return &r.predictor.Graph, nil
+}
+
 func (r *SeldonRestApi) graphMetadata(w http.ResponseWriter, req *http.Request) {
 	r.Log.V(1).Info("Graph Metadata called.")
 
 	ctx := req.Context()
 
-	// Apply tracing if active
-	if opentracing.IsGlobalTracerRegistered() {
-		var serverSpan opentracing.Span
-		ctx, serverSpan = setupTracing(ctx, req, TracingMetadataName)
-		defer serverSpan.Finish()
-	}
+	ctx, serverSpan := setupTracing(ctx, req, TracingMetadataName)
+	defer serverSpan.End()
 
 	seldonPredictorProcess := predictor.NewPredictorProcess(ctx, r.Client, logf.Log.WithName(LoggingRestClientName), r.ServerUrl, r.Namespace, req.Header)
 
-	graphMetadata, err := seldonPredictorProcess.GraphMetadata(r.predictor)
+	graphMetadata, age, err := r.metadataCache.GraphMetadata(&seldonPredictorProcess)
 
 	if err != nil {
 		r.respondWithError(w, nil, err)
 		return
 	}
+	w.Header().Set("Age", strconv.Itoa(int(age.Seconds())))
 
 	msg, _ := json.Marshal(graphMetadata)
 	resPayload := payload.BytesPayload{Msg: msg, ContentType: ContentTypeJSON}
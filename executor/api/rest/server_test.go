@@ -8,6 +8,7 @@ import (
 	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	guuid "github.com/google/uuid"
 	. "github.com/onsi/gomega"
@@ -525,6 +526,7 @@ func TestSeldonGraphMetadata(t *testing.T) {
 	r.Router.ServeHTTP(res, req)
 	g.Expect(res.Code).To(Equal(200))
 	g.Expect(res.Body.String()).To(Equal(strings.Join(strings.Fields(test.TestGraphMeta), "")))
+	g.Expect(res.Header().Get("Age")).To(Equal("0"))
 }
 
 func TestTensorflowMetadata(t *testing.T) {
@@ -612,6 +614,64 @@ func TestPredictErrorWithServer(t *testing.T) {
 	g.Expect(string(b)).To(Equal(errorPredictResponse))
 }
 
+func TestRequestTimeout(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+		w.Write([]byte(`{"data":{"ndarray":[1.1,2.0]}}`))
+	})
+	server := httptest.NewServer(handler)
+	defer server.Close()
+	url, err := url.Parse(server.URL)
+	g.Expect(err).Should(BeNil())
+	urlParts := strings.Split(url.Host, ":")
+	port, err := strconv.Atoi(urlParts[1])
+	g.Expect(err).Should(BeNil())
+
+	model := v1.MODEL
+	p := v1.PredictorSpec{
+		Name: "p",
+		Graph: v1.PredictiveUnit{
+			Name: "slow",
+			Type: &model,
+			Endpoint: &v1.Endpoint{
+				ServiceHost: urlParts[0],
+				ServicePort: int32(port),
+				Type:        v1.REST,
+				HttpPort:    int32(port),
+			},
+		},
+	}
+	client, err := NewJSONRestClient(api.ProtocolSeldon, "dep", &p, nil)
+	g.Expect(err).Should(BeNil())
+	r := NewServerRestApi(&p, client, false, url, "default", api.ProtocolSeldon, "test", "/metrics")
+	r.RequestTimeout = 10 * time.Millisecond
+	r.Initialise()
+
+	predict := func(timeout string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", "/api/v0.1/predictions", strings.NewReader(`{"data":{"ndarray":[1.1,2.0]}}`))
+		req.Header = map[string][]string{"Content-Type": []string{"application/json"}}
+		if timeout != "" {
+			req.Header.Set(payload.SeldonRequestTimeoutHeader, timeout)
+		}
+		res := httptest.NewRecorder()
+		r.Router.ServeHTTP(res, req)
+		return res
+	}
+
+	// The default deadline applies to requests without a header
+	start := time.Now()
+	g.Expect(predict("").Code).To(Equal(http.StatusGatewayTimeout))
+	g.Expect(time.Since(start)).To(BeNumerically("<", 500*time.Millisecond))
+
+	g.Expect(predict("5000").Code).To(Equal(http.StatusOK))
+	g.Expect(predict("soon").Code).To(Equal(http.StatusBadRequest))
+}
+
 func TestTensorflowModel(t *testing.T) {
 	t.Logf("Started")
 	g := NewGomegaWithT(t)
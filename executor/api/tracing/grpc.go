@@ -0,0 +1,69 @@
+package tracing
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// MetadataCarrier carries trace context in gRPC metadata.
+type MetadataCarrier metadata.MD
+
+func (c MetadataCarrier) Get(key string) string {
+	if values := metadata.MD(c).Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+func (c MetadataCarrier) Set(key string, value string) {
+	metadata.MD(c).Set(key, value)
+}
+
+func incomingCarrier(ctx context.Context) MetadataCarrier {
+	md, _ := metadata.FromIncomingContext(ctx)
+	return MetadataCarrier(md.Copy())
+}
+
+func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, span := StartServerSpan(ctx, incomingCarrier(ctx), info.FullMethod)
+		resp, err := handler(ctx, req)
+		EndSpan(span, err)
+		return resp, err
+	}
+}
+
+type tracedServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *tracedServerStream) Context() context.Context {
+	return s.ctx
+}
+
+func StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, span := StartServerSpan(ss.Context(), incomingCarrier(ss.Context()), info.FullMethod)
+		err := handler(srv, &tracedServerStream{ServerStream: ss, ctx: ctx})
+		EndSpan(span, err)
+		return err
+	}
+}
+
+// UnaryClientInterceptor starts a span for each call and sends its trace context in the metadata.
+// Metadata copied from the incoming request carries the caller's trace context, which is replaced.
+func UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		ctx, span := Tracer().Start(ctx, method, trace.WithSpanKind(trace.SpanKindClient))
+		md, _ := metadata.FromOutgoingContext(ctx)
+		md = md.Copy()
+		Inject(ctx, MetadataCarrier(md))
+		err := invoker(metadata.NewOutgoingContext(ctx, md), method, req, reply, cc, opts...)
+		EndSpan(span, err)
+		return err
+	}
+}
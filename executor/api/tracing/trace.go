@@ -1,52 +1,231 @@
 package tracing
 
 import (
+	"context"
 	"io"
+	"net/http"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
-	"github.com/opentracing/opentracing-go"
-	jaegercfg "github.com/uber/jaeger-client-go/config"
-	"github.com/uber/jaeger-client-go/zipkin"
+	"go.opentelemetry.io/contrib/propagators/b3"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp"
+	"go.opentelemetry.io/otel/label"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/semconv"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/credentials"
+	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
 )
 
+const (
+	// Tracing is configured with the standard OpenTelemetry environment variables
+	ENV_SERVICE_NAME  = "OTEL_SERVICE_NAME"
+	ENV_OTLP_ENDPOINT = "OTEL_EXPORTER_OTLP_ENDPOINT"
+	ENV_OTLP_INSECURE = "OTEL_EXPORTER_OTLP_INSECURE"
+	ENV_SAMPLER_ARG   = "OTEL_TRACES_SAMPLER_ARG"
+	ENV_PROPAGATORS   = "OTEL_PROPAGATORS"
+	// Kept so deployments using B3 with the Jaeger client continue to propagate traces
+	ENV_JAEGER_TRACE_PROPAGATION_TYPE = "JAEGER_TRACE_PROPAGATION_TYPE"
+	// Settings of the Jaeger client which are no longer read
+	ENV_JAEGER_AGENT_HOST     = "JAEGER_AGENT_HOST"
+	ENV_JAEGER_AGENT_PORT     = "JAEGER_AGENT_PORT"
+	ENV_JAEGER_SAMPLER_PREFIX = "JAEGER_SAMPLER_"
+
+	DefaultServiceName = "executor"
+	DefaultPropagators = "tracecontext,baggage"
+
+	instrumentationName = "github.com/seldonio/seldon-core/executor"
+
+	// How long to wait for spans to be exported on shutdown
+	shutdownTimeout = 5 * time.Second
+)
+
+// Attributes of the spans of graph nodes
+const (
+	NodeNameKey   = label.Key("seldon.node.name")
+	NodeImageKey  = label.Key("seldon.node.image")
+	NodeStepKey   = label.Key("seldon.node.step")
+	RouteKey      = label.Key("seldon.route")
+	RouteChildKey = label.Key("seldon.route.child")
+	BatchSizeKey  = label.Key("seldon.batch.size")
+)
+
+type closerFunc func() error
+
+func (f closerFunc) Close() error {
+	return f()
+}
+
+// InitTracing sets up the global tracer provider and propagators. Spans are exported over OTLP
+// gRPC when an endpoint is set. Without an endpoint nothing is exported, but the trace context of
+// incoming requests is still passed on to the nodes of the graph.
 func InitTracing() (io.Closer, error) {
-	//Initialise tracing
-	cfg, err := jaegercfg.FromEnv()
+	propagator, err := newPropagator(os.Getenv(ENV_PROPAGATORS), os.Getenv(ENV_JAEGER_TRACE_PROPAGATION_TYPE))
 	if err != nil {
-		// parsing errors might happen here, such as when we get a string where we expect a number
 		return nil, err
 	}
+	otel.SetTextMapPropagator(propagator)
 
-	if cfg.ServiceName == "" {
-		cfg.ServiceName = "executor"
+	serviceName := os.Getenv(ENV_SERVICE_NAME)
+	if serviceName == "" {
+		serviceName = DefaultServiceName
+	}
+	opts := []sdktrace.TracerProviderOption{
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.ServiceNameKey.String(serviceName))),
 	}
 
-	propagation := os.Getenv("JAEGER_TRACE_PROPAGATION_TYPE")
-	closer, err := newTraceByPropagation(propagation, cfg)
-	if err != nil {
-		return nil, err
+	if endpoint := os.Getenv(ENV_OTLP_ENDPOINT); endpoint != "" {
+		ratio, err := samplerRatio(os.Getenv(ENV_SAMPLER_ARG))
+		if err != nil {
+			return nil, err
+		}
+		exporterOpts := []otlp.ExporterOption{otlp.WithAddress(endpoint)}
+		if insecure, _ := strconv.ParseBool(os.Getenv(ENV_OTLP_INSECURE)); insecure {
+			exporterOpts = append(exporterOpts, otlp.WithInsecure())
+		} else {
+			exporterOpts = append(exporterOpts, otlp.WithTLSCredentials(credentials.NewClientTLSFromCert(nil, "")))
+		}
+		// The exporter connects in the background so a missing collector doesn't stop the executor
+		exporter := otlp.NewUnstartedExporter(exporterOpts...)
+		if err := exporter.Start(context.Background()); err != nil {
+			return nil, err
+		}
+		opts = append(opts,
+			sdktrace.WithBatcher(exporter),
+			sdktrace.WithConfig(sdktrace.Config{DefaultSampler: sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio))}),
+		)
+	} else {
+		if vars := jaegerEnvVars(os.Environ()); len(vars) > 0 {
+			logf.Log.WithName("Tracing").Info("Warning: Jaeger settings are no longer used and no spans will be exported. Set "+ENV_OTLP_ENDPOINT+" to export spans over OTLP", "variables", vars)
+		}
+		opts = append(opts, sdktrace.WithConfig(sdktrace.Config{DefaultSampler: sdktrace.ParentBased(sdktrace.NeverSample())}))
+	}
+	provider := sdktrace.NewTracerProvider(opts...)
+	otel.SetTracerProvider(provider)
+
+	return closerFunc(func() error {
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		return provider.Shutdown(ctx)
+	}), nil
+}
+
+// jaegerEnvVars returns the names of the Jaeger client settings in the environment. Deployments
+// configured with them before tracing moved to OpenTelemetry export no spans.
+func jaegerEnvVars(environ []string) []string {
+	var vars []string
+	for _, kv := range environ {
+		name := strings.SplitN(kv, "=", 2)[0]
+		if name == ENV_JAEGER_AGENT_HOST || name == ENV_JAEGER_AGENT_PORT || strings.HasPrefix(name, ENV_JAEGER_SAMPLER_PREFIX) {
+			vars = append(vars, name)
+		}
+	}
+	return vars
+}
+
+func samplerRatio(arg string) (float64, error) {
+	if arg == "" {
+		return 1, nil
+	}
+	return strconv.ParseFloat(arg, 64)
+}
+
+func newPropagator(names string, jaegerPropagation string) (propagation.TextMapPropagator, error) {
+	if names == "" {
+		names = DefaultPropagators
+		if jaegerPropagation == "b3" {
+			names = "b3multi"
+		}
 	}
-	return closer, nil
+	var propagators []propagation.TextMapPropagator
+	for _, name := range strings.Split(names, ",") {
+		switch strings.TrimSpace(name) {
+		case "tracecontext":
+			propagators = append(propagators, propagation.TraceContext{})
+		case "baggage":
+			propagators = append(propagators, propagation.Baggage{})
+		case "b3":
+			propagators = append(propagators, b3.B3{InjectEncoding: b3.B3SingleHeader})
+		case "b3multi":
+			propagators = append(propagators, b3.B3{InjectEncoding: b3.B3MultipleHeader})
+		case "none":
+		default:
+			return nil, &UnknownPropagatorError{Name: name}
+		}
+	}
+	return propagation.NewCompositeTextMapPropagator(propagators...), nil
+}
+
+type UnknownPropagatorError struct {
+	Name string
+}
+
+func (e *UnknownPropagatorError) Error() string {
+	return "Unknown trace propagator " + e.Name
+}
+
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// HeaderCarrier carries trace context in HTTP headers.
+type HeaderCarrier http.Header
+
+func (c HeaderCarrier) Get(key string) string {
+	return http.Header(c).Get(key)
+}
+
+func (c HeaderCarrier) Set(key string, value string) {
+	http.Header(c).Set(key, value)
 }
 
-func newTraceByPropagation(propagation string, cfg *jaegercfg.Configuration) (closer io.Closer, err error) {
-	switch propagation {
-	case "b3":
-		return newB3Tracer(cfg)
+// MapCarrier carries trace context in headers whose keys are not canonicalized, such as Kafka headers.
+type MapCarrier map[string][]string
+
+func (c MapCarrier) Get(key string) string {
+	if values := c[key]; len(values) > 0 {
+		return values[0]
 	}
-	return newDefaultTracer(cfg)
+	return ""
+}
+
+func (c MapCarrier) Set(key string, value string) {
+	c[key] = []string{value}
 }
 
-func newB3Tracer(cfg *jaegercfg.Configuration) (io.Closer, error) {
-	zipkinPropagator := zipkin.NewZipkinB3HTTPHeaderPropagator()
-	closer, err := cfg.InitGlobalTracer(cfg.ServiceName,
-		jaegercfg.Injector(opentracing.HTTPHeaders, zipkinPropagator),
-		jaegercfg.Extractor(opentracing.HTTPHeaders, zipkinPropagator),
-		jaegercfg.ZipkinSharedRPCSpan(true))
+// Extract returns a context with the trace context of an incoming request.
+func Extract(ctx context.Context, carrier propagation.TextMapCarrier) context.Context {
+	return otel.GetTextMapPropagator().Extract(ctx, carrier)
+}
+
+// Inject adds the trace context to an outgoing request, replacing any trace context copied from the
+// incoming request.
+func Inject(ctx context.Context, carrier propagation.TextMapCarrier) {
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+}
 
-	return closer, err
+// StartServerSpan starts the span of a request received by the executor, continuing the trace of
+// the caller.
+func StartServerSpan(ctx context.Context, carrier propagation.TextMapCarrier, name string) (context.Context, trace.Span) {
+	return Tracer().Start(Extract(ctx, carrier), name, trace.WithSpanKind(trace.SpanKindServer))
 }
-func newDefaultTracer(cfg *jaegercfg.Configuration) (io.Closer, error) {
-	closer, err := cfg.InitGlobalTracer(cfg.ServiceName)
-	return closer, err
+
+// SetError marks the span as failed if there is an error.
+func SetError(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+}
+
+// EndSpan records the error of the operation, if any, and ends the span.
+func EndSpan(span trace.Span, err error) {
+	SetError(span, err)
+	span.End()
 }
@@ -1,44 +1,94 @@
 package tracing
 
 import (
-	"github.com/opentracing/opentracing-go"
-	"github.com/uber/jaeger-client-go"
+	"context"
+	"net/http"
 	"os"
 	"testing"
+
+	. "github.com/onsi/gomega"
+	"go.opentelemetry.io/otel/trace"
 )
 
-var (
-	nonRootNonSampledHeader = opentracing.TextMapCarrier{
-		"x-b3-traceid":      "1",
-		"x-b3-spanid":       "2",
-		"x-b3-parentspanid": "1",
-		"x-b3-sampled":      "0",
-	}
+const (
+	testTraceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	testTraceId     = "4bf92f3577b34da6a3ce929d0e0e4736"
 )
 
-func newSpanContext(traceID, spanID, parentID uint64, sampled bool, baggage map[string]string) jaeger.SpanContext {
-	return jaeger.NewSpanContext(
-		jaeger.TraceID{Low: traceID},
-		jaeger.SpanID(spanID),
-		jaeger.SpanID(parentID),
-		sampled,
-		baggage,
-	)
+func TestInitTracing(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	closer, err := InitTracing()
+	g.Expect(err).To(BeNil())
+	defer closer.Close()
+
+	ctx, span := StartServerSpan(context.Background(), HeaderCarrier(http.Header{"Traceparent": []string{testTraceparent}}), "test")
+	defer span.End()
+	g.Expect(span.SpanContext().TraceID.String()).To(Equal(testTraceId))
+	g.Expect(span.SpanContext().IsSampled()).To(BeTrue())
+
+	// The trace context sent on replaces the one received
+	headers := MapCarrier{"traceparent": []string{testTraceparent}}
+	Inject(ctx, headers)
+	g.Expect(headers["traceparent"]).To(HaveLen(1))
+	g.Expect(headers["traceparent"][0]).To(ContainSubstring(testTraceId))
+	g.Expect(headers["traceparent"][0]).ToNot(Equal(testTraceparent))
 }
 
-func TestInitTracing(t *testing.T) {
-	os.Setenv("JAEGER_TRACE_PROPAGATION_TYPE", "b3")
+func TestInitTracingB3(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	os.Setenv(ENV_JAEGER_TRACE_PROPAGATION_TYPE, "b3")
+	defer os.Unsetenv(ENV_JAEGER_TRACE_PROPAGATION_TYPE)
 	closer, err := InitTracing()
-	if err != nil {
-		t.Error("init trace err", err.Error())
-	}
+	g.Expect(err).To(BeNil())
 	defer closer.Close()
-	if !opentracing.IsGlobalTracerRegistered() {
-		t.Error("trace is not registered")
-	}
-	tracer := opentracing.GlobalTracer()
-	_, err = tracer.Extract(opentracing.HTTPHeaders, nonRootNonSampledHeader)
-	if err != nil {
-		t.Error("trace extract error", err.Error())
+
+	headers := http.Header{}
+	headers.Set("x-b3-traceid", testTraceId)
+	headers.Set("x-b3-spanid", "00f067aa0ba902b7")
+	headers.Set("x-b3-sampled", "0")
+	ctx := Extract(context.Background(), HeaderCarrier(headers))
+	sc := trace.RemoteSpanContextFromContext(ctx)
+	g.Expect(sc.TraceID.String()).To(Equal(testTraceId))
+	g.Expect(sc.IsSampled()).To(BeFalse())
+}
+
+func TestNewPropagator(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	p, err := newPropagator("", "")
+	g.Expect(err).To(BeNil())
+	g.Expect(p.Fields()).To(ContainElement("traceparent"))
+
+	p, err = newPropagator("", "b3")
+	g.Expect(err).To(BeNil())
+	g.Expect(p.Fields()).To(ContainElement("x-b3-traceid"))
+
+	p, err = newPropagator("b3, tracecontext", "")
+	g.Expect(err).To(BeNil())
+	g.Expect(p.Fields()).To(ContainElement("b3"))
+	g.Expect(p.Fields()).To(ContainElement("traceparent"))
+
+	p, err = newPropagator("none", "")
+	g.Expect(err).To(BeNil())
+	g.Expect(p.Fields()).To(BeEmpty())
+
+	_, err = newPropagator("xray", "")
+	g.Expect(err).To(BeAssignableToTypeOf(&UnknownPropagatorError{}))
+}
+
+func TestJaegerEnvVars(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	environ := []string{
+		"JAEGER_AGENT_HOST=10.0.0.1",
+		"JAEGER_AGENT_PORT=5775",
+		"JAEGER_SAMPLER_TYPE=const",
+		"JAEGER_SAMPLER_PARAM=1",
+		"JAEGER_TRACE_PROPAGATION_TYPE=b3",
+		"OTEL_SERVICE_NAME=executor",
 	}
+	g.Expect(jaegerEnvVars(environ)).To(Equal([]string{"JAEGER_AGENT_HOST", "JAEGER_AGENT_PORT", "JAEGER_SAMPLER_TYPE", "JAEGER_SAMPLER_PARAM"}))
+	g.Expect(jaegerEnvVars([]string{"OTEL_SERVICE_NAME=executor"})).To(BeEmpty())
 }
@@ -0,0 +1,168 @@
+package translate
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	"github.com/seldonio/seldon-core/executor/api/client"
+	"github.com/seldonio/seldon-core/executor/api/payload"
+	v1 "github.com/seldonio/seldon-core/operator/apis/machinelearning.seldon.io/v1"
+	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
+)
+
+// ClientFactory creates the client used to call units speaking a protocol.
+type ClientFactory func(protocol string) (client.SeldonApiClient, error)
+
+type unitClient struct {
+	protocol string
+	client   client.SeldonApiClient
+}
+
+// TranslatingClient calls units which declare a protocol different to the deployment protocol
+// with their own client, translating requests to the unit's protocol and responses back.
+// All other calls go to the wrapped client.
+type TranslatingClient struct {
+	client.SeldonApiClient
+	Log      logr.Logger
+	protocol string
+	units    map[string]*unitClient
+}
+
+// NewTranslatingClient wraps a client for the deployment protocol with clients for the units of
+// the graph speaking other protocols. The client is returned unchanged if there are none.
+func NewTranslatingClient(c client.SeldonApiClient, protocol string, predictor *v1.PredictorSpec, factory ClientFactory) (client.SeldonApiClient, error) {
+	clients := make(map[string]client.SeldonApiClient)
+	units := make(map[string]*unitClient)
+	for _, pu := range v1.GetPredictiveUnitList(&predictor.Graph) {
+		if pu.Protocol == nil || *pu.Protocol == "" || string(*pu.Protocol) == protocol {
+			continue
+		}
+		unitProtocol := string(*pu.Protocol)
+		if _, ok := clients[unitProtocol]; !ok {
+			uc, err := factory(unitProtocol)
+			if err != nil {
+				return nil, err
+			}
+			clients[unitProtocol] = uc
+		}
+		units[pu.Name] = &unitClient{protocol: unitProtocol, client: clients[unitProtocol]}
+	}
+	if len(units) == 0 {
+		return c, nil
+	}
+	return &TranslatingClient{
+		SeldonApiClient: c,
+		Log:             logf.Log.WithName("TranslatingClient"),
+		protocol:        protocol,
+		units:           units,
+	}, nil
+}
+
+type callFn func(c client.SeldonApiClient, msg payload.SeldonPayload) (payload.SeldonPayload, error)
+
+// call translates the request to the protocol of the unit and the response back.
+func (t *TranslatingClient) call(modelName string, msg payload.SeldonPayload, fn callFn) (payload.SeldonPayload, error) {
+	uc, ok := t.units[modelName]
+	if !ok {
+		return fn(t.SeldonApiClient, msg)
+	}
+	req, err := Translate(msg, t.protocol, uc.protocol, modelName, false)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to translate request for %s from %s to %s protocol: %v", modelName, t.protocol, uc.protocol, err)
+	}
+	res, err := fn(uc.client, req)
+	if err != nil {
+		return res, err
+	}
+	res, err = Translate(res, uc.protocol, t.protocol, modelName, true)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to translate response of %s from %s to %s protocol: %v", modelName, uc.protocol, t.protocol, err)
+	}
+	return res, nil
+}
+
+func (t *TranslatingClient) Predict(ctx context.Context, modelName string, host string, port int32, msg payload.SeldonPayload, meta map[string][]string) (payload.SeldonPayload, error) {
+	return t.call(modelName, msg, func(c client.SeldonApiClient, msg payload.SeldonPayload) (payload.SeldonPayload, error) {
+		return c.Predict(ctx, modelName, host, port, msg, meta)
+	})
+}
+
+func (t *TranslatingClient) TransformInput(ctx context.Context, modelName string, host string, port int32, msg payload.SeldonPayload, meta map[string][]string) (payload.SeldonPayload, error) {
+	return t.call(modelName, msg, func(c client.SeldonApiClient, msg payload.SeldonPayload) (payload.SeldonPayload, error) {
+		return c.TransformInput(ctx, modelName, host, port, msg, meta)
+	})
+}
+
+func (t *TranslatingClient) TransformOutput(ctx context.Context, modelName string, host string, port int32, msg payload.SeldonPayload, meta map[string][]string) (payload.SeldonPayload, error) {
+	return t.call(modelName, msg, func(c client.SeldonApiClient, msg payload.SeldonPayload) (payload.SeldonPayload, error) {
+		return c.TransformOutput(ctx, modelName, host, port, msg, meta)
+	})
+}
+
+func (t *TranslatingClient) Route(ctx context.Context, modelName string, host string, port int32, msg payload.SeldonPayload, meta map[string][]string) (int, error) {
+	uc, ok := t.units[modelName]
+	if !ok {
+		return t.SeldonApiClient.Route(ctx, modelName, host, port, msg, meta)
+	}
+	req, err := Translate(msg, t.protocol, uc.protocol, modelName, false)
+	if err != nil {
+		return 0, fmt.Errorf("Failed to translate request for %s from %s to %s protocol: %v", modelName, t.protocol, uc.protocol, err)
+	}
+	return uc.client.Route(ctx, modelName, host, port, req, meta)
+}
+
+func (t *TranslatingClient) Combine(ctx context.Context, modelName string, host string, port int32, msgs []payload.SeldonPayload, meta map[string][]string) (payload.SeldonPayload, error) {
+	uc, ok := t.units[modelName]
+	if !ok {
+		return t.SeldonApiClient.Combine(ctx, modelName, host, port, msgs, meta)
+	}
+	// The inputs of a combiner are the responses of its children
+	reqs := make([]payload.SeldonPayload, 0, len(msgs))
+	for _, msg := range msgs {
+		req, err := Translate(msg, t.protocol, uc.protocol, modelName, true)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to translate request for %s from %s to %s protocol: %v", modelName, t.protocol, uc.protocol, err)
+		}
+		reqs = append(reqs, req)
+	}
+	res, err := uc.client.Combine(ctx, modelName, host, port, reqs, meta)
+	if err != nil {
+		return res, err
+	}
+	res, err = Translate(res, uc.protocol, t.protocol, modelName, true)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to translate response of %s from %s to %s protocol: %v", modelName, uc.protocol, t.protocol, err)
+	}
+	return res, nil
+}
+
+// Feedback can't be translated as only the Seldon protocol defines it.
+func (t *TranslatingClient) Feedback(ctx context.Context, modelName string, host string, port int32, msg payload.SeldonPayload, meta map[string][]string) (payload.SeldonPayload, error) {
+	uc, ok := t.units[modelName]
+	if !ok {
+		return t.SeldonApiClient.Feedback(ctx, modelName, host, port, msg, meta)
+	}
+	return nil, fmt.Errorf("Feedback for %s can't be translated from %s to %s protocol", modelName, t.protocol, uc.protocol)
+}
+
+func (t *TranslatingClient) Status(ctx context.Context, modelName string, host string, port int32, msg payload.SeldonPayload, meta map[string][]string) (payload.SeldonPayload, error) {
+	return t.unitClient(modelName).Status(ctx, modelName, host, port, msg, meta)
+}
+
+func (t *TranslatingClient) Metadata(ctx context.Context, modelName string, host string, port int32, msg payload.SeldonPayload, meta map[string][]string) (payload.SeldonPayload, error) {
+	return t.unitClient(modelName).Metadata(ctx, modelName, host, port, msg, meta)
+}
+
+func (t *TranslatingClient) ModelMetadata(ctx context.Context, modelName string, host string, port int32, msg payload.SeldonPayload, meta map[string][]string) (payload.ModelMetadata, error) {
+	return t.unitClient(modelName).ModelMetadata(ctx, modelName, host, port, msg, meta)
+}
+
+// unitClient returns the client for the protocol of a unit. Status and metadata requests are not
+// translated and their responses are returned as the unit provides them.
+func (t *TranslatingClient) unitClient(modelName string) client.SeldonApiClient {
+	if uc, ok := t.units[modelName]; ok {
+		return uc.client
+	}
+	return t.SeldonApiClient
+}
@@ -0,0 +1,75 @@
+package translate
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/seldonio/seldon-core/executor/api"
+	"github.com/seldonio/seldon-core/executor/api/client"
+	"github.com/seldonio/seldon-core/executor/api/payload"
+	"github.com/seldonio/seldon-core/executor/api/test"
+	v1 "github.com/seldonio/seldon-core/operator/apis/machinelearning.seldon.io/v1"
+)
+
+// v2TestClient records the request and returns a fixed v2 response.
+type v2TestClient struct {
+	test.SeldonMessageTestClient
+	requests []string
+}
+
+func (c *v2TestClient) Predict(ctx context.Context, modelName string, host string, port int32, msg payload.SeldonPayload, meta map[string][]string) (payload.SeldonPayload, error) {
+	c.requests = append(c.requests, string(msg.GetPayload().([]byte)))
+	return createJsonPayload(`{"model_name":"model","outputs":[{"name":"predict","shape":[1,2],"datatype":"FP32","data":[0.25,0.75]}]}`), nil
+}
+
+func createMixedPredictor() *v1.PredictorSpec {
+	model := v1.MODEL
+	protocol := v1.ProtocolKfserving
+	return &v1.PredictorSpec{
+		Name: "p",
+		Graph: v1.PredictiveUnit{
+			Name: "transformer",
+			Children: []v1.PredictiveUnit{
+				{
+					Name:     "model",
+					Type:     &model,
+					Protocol: &protocol,
+				},
+			},
+		},
+	}
+}
+
+func TestTranslatingClient(t *testing.T) {
+	g := NewGomegaWithT(t)
+	unit := &v2TestClient{}
+	var protocols []string
+	c, err := NewTranslatingClient(&test.SeldonMessageTestClient{}, api.ProtocolSeldon, createMixedPredictor(), func(protocol string) (client.SeldonApiClient, error) {
+		protocols = append(protocols, protocol)
+		return unit, nil
+	})
+	g.Expect(err).To(BeNil())
+	g.Expect(protocols).To(Equal([]string{api.ProtocolKFServing}))
+
+	res, err := c.Predict(context.Background(), "model", "localhost", 9000, createJsonPayload(`{"data":{"ndarray":[[1,2]]}}`), nil)
+	g.Expect(err).To(BeNil())
+	g.Expect(unit.requests).To(Equal([]string{`{"inputs":[{"name":"input-0","shape":[1,2],"datatype":"FP64","data":[1,2]}]}`}))
+	g.Expect(string(res.GetPayload().([]byte))).To(Equal(`{"data":{"ndarray":[[0.25,0.75]]}}`))
+
+	_, err = c.Feedback(context.Background(), "model", "localhost", 9000, createJsonPayload(`{}`), nil)
+	g.Expect(err).ToNot(BeNil())
+
+	// Units speaking the deployment protocol use the wrapped client
+	res, err = c.Predict(context.Background(), "transformer", "localhost", 9000, createJsonPayload(`{"data":{"ndarray":[[1,2]]}}`), nil)
+	g.Expect(err).To(BeNil())
+	g.Expect(len(unit.requests)).To(Equal(1))
+}
+
+func TestTranslatingClientNotNeeded(t *testing.T) {
+	g := NewGomegaWithT(t)
+	inner := &test.SeldonMessageTestClient{}
+	c, err := NewTranslatingClient(inner, api.ProtocolKFServing, createMixedPredictor(), nil)
+	g.Expect(err).To(BeNil())
+	g.Expect(c).To(BeIdenticalTo(inner))
+}
@@ -0,0 +1,209 @@
+package translate
+
+import (
+	"fmt"
+
+	"github.com/seldonio/seldon-core/executor/api/grpc/kfserving/inference"
+)
+
+type v2JsonTensor struct {
+	Name     string      `json:"name"`
+	Shape    []int64     `json:"shape"`
+	Datatype string      `json:"datatype"`
+	Data     interface{} `json:"data"`
+}
+
+type v2JsonMessage struct {
+	ModelName string         `json:"model_name,omitempty"`
+	Id        string         `json:"id,omitempty"`
+	Inputs    []v2JsonTensor `json:"inputs,omitempty"`
+	Outputs   []v2JsonTensor `json:"outputs,omitempty"`
+}
+
+func fromV2Json(body *v2JsonMessage, response bool) (*Message, error) {
+	tensors := body.Inputs
+	if response {
+		tensors = body.Outputs
+	}
+	m := &Message{Id: body.Id}
+	for _, jt := range tensors {
+		t := Tensor{Name: jt.Name, Datatype: jt.Datatype, Shape: jt.Shape}
+		if err := t.flatValues(jt.Data); err != nil {
+			return nil, err
+		}
+		if err := t.checkShape(); err != nil {
+			return nil, err
+		}
+		m.Tensors = append(m.Tensors, t)
+	}
+	return m, nil
+}
+
+func toV2Json(m *Message, modelName string, response bool) (*v2JsonMessage, error) {
+	body := &v2JsonMessage{Id: m.Id}
+	tensors := make([]v2JsonTensor, 0, len(m.Tensors))
+	for _, t := range namedTensors(m.Tensors, response) {
+		if err := t.checkShape(); err != nil {
+			return nil, err
+		}
+		tensors = append(tensors, v2JsonTensor{Name: t.Name, Shape: t.Shape, Datatype: t.Datatype, Data: t.flat()})
+	}
+	if response {
+		body.ModelName = modelName
+		body.Outputs = tensors
+	} else {
+		body.Inputs = tensors
+	}
+	return body, nil
+}
+
+func fromV2Contents(t *Tensor, contents *inference.InferTensorContents) {
+	switch t.Datatype {
+	case DatatypeBool:
+		for _, v := range contents.GetBoolContents() {
+			t.Values = append(t.Values, boolToFloat(v))
+		}
+	case DatatypeInt8, DatatypeInt16, DatatypeInt32:
+		for _, v := range contents.GetIntContents() {
+			t.Values = append(t.Values, float64(v))
+		}
+	case DatatypeInt64:
+		for _, v := range contents.GetInt64Contents() {
+			t.Values = append(t.Values, float64(v))
+		}
+	case DatatypeUint8, DatatypeUint16, DatatypeUint32:
+		for _, v := range contents.GetUintContents() {
+			t.Values = append(t.Values, float64(v))
+		}
+	case DatatypeUint64:
+		for _, v := range contents.GetUint64Contents() {
+			t.Values = append(t.Values, float64(v))
+		}
+	case DatatypeFp32:
+		for _, v := range contents.GetFp32Contents() {
+			t.Values = append(t.Values, float64(v))
+		}
+	case DatatypeFp64:
+		t.Values = append(t.Values, contents.GetFp64Contents()...)
+	case DatatypeBytes:
+		for _, v := range contents.GetByteContents() {
+			t.Strings = append(t.Strings, string(v))
+		}
+	}
+}
+
+func toV2Contents(t Tensor) (*inference.InferTensorContents, error) {
+	contents := &inference.InferTensorContents{}
+	switch t.Datatype {
+	case DatatypeBool:
+		for _, v := range t.Values {
+			contents.BoolContents = append(contents.BoolContents, v != 0)
+		}
+	case DatatypeInt8, DatatypeInt16, DatatypeInt32:
+		for _, v := range t.Values {
+			contents.IntContents = append(contents.IntContents, int32(v))
+		}
+	case DatatypeInt64:
+		for _, v := range t.Values {
+			contents.Int64Contents = append(contents.Int64Contents, int64(v))
+		}
+	case DatatypeUint8, DatatypeUint16, DatatypeUint32:
+		for _, v := range t.Values {
+			contents.UintContents = append(contents.UintContents, uint32(v))
+		}
+	case DatatypeUint64:
+		for _, v := range t.Values {
+			contents.Uint64Contents = append(contents.Uint64Contents, uint64(v))
+		}
+	case DatatypeFp32:
+		for _, v := range t.Values {
+			contents.Fp32Contents = append(contents.Fp32Contents, float32(v))
+		}
+	case DatatypeFp64:
+		contents.Fp64Contents = append(contents.Fp64Contents, t.Values...)
+	case DatatypeBytes:
+		for _, v := range t.Strings {
+			contents.ByteContents = append(contents.ByteContents, []byte(v))
+		}
+	default:
+		return nil, fmt.Errorf("Tensor %q has unsupported datatype %s", t.Name, t.Datatype)
+	}
+	return contents, nil
+}
+
+// fromV2Tensor reads a tensor from its typed contents or from raw contents if there are any.
+func fromV2Tensor(name string, datatype string, shape []int64, contents *inference.InferTensorContents, raw [][]byte, i int) (Tensor, error) {
+	t := Tensor{Name: name, Datatype: datatype, Shape: shape}
+	if i < len(raw) {
+		if err := t.setRaw(raw[i]); err != nil {
+			return t, err
+		}
+	} else {
+		fromV2Contents(&t, contents)
+	}
+	return t, t.checkShape()
+}
+
+func fromV2Request(req *inference.ModelInferRequest) (*Message, error) {
+	m := &Message{Id: req.GetId()}
+	for i, input := range req.GetInputs() {
+		t, err := fromV2Tensor(input.GetName(), input.GetDatatype(), input.GetShape(), input.GetContents(), req.GetRawInputContents(), i)
+		if err != nil {
+			return nil, err
+		}
+		m.Tensors = append(m.Tensors, t)
+	}
+	return m, nil
+}
+
+func fromV2Response(res *inference.ModelInferResponse) (*Message, error) {
+	m := &Message{Id: res.GetId()}
+	for i, output := range res.GetOutputs() {
+		t, err := fromV2Tensor(output.GetName(), output.GetDatatype(), output.GetShape(), output.GetContents(), res.GetRawOutputContents(), i)
+		if err != nil {
+			return nil, err
+		}
+		m.Tensors = append(m.Tensors, t)
+	}
+	return m, nil
+}
+
+func toV2Request(m *Message, modelName string) (*inference.ModelInferRequest, error) {
+	req := &inference.ModelInferRequest{ModelName: modelName, Id: m.Id}
+	for _, t := range namedTensors(m.Tensors, false) {
+		if err := t.checkShape(); err != nil {
+			return nil, err
+		}
+		contents, err := toV2Contents(t)
+		if err != nil {
+			return nil, err
+		}
+		req.Inputs = append(req.Inputs, &inference.ModelInferRequest_InferInputTensor{
+			Name:     t.Name,
+			Datatype: t.Datatype,
+			Shape:    t.Shape,
+			Contents: contents,
+		})
+	}
+	return req, nil
+}
+
+func toV2Response(m *Message, modelName string) (*inference.ModelInferResponse, error) {
+	res := &inference.ModelInferResponse{ModelName: modelName, Id: m.Id}
+	for _, t := range namedTensors(m.Tensors, true) {
+		if err := t.checkShape(); err != nil {
+			return nil, err
+		}
+		contents, err := toV2Contents(t)
+		if err != nil {
+			return nil, err
+		}
+		res.Outputs = append(res.Outputs, &inference.ModelInferResponse_InferOutputTensor{
+			Name:     t.Name,
+			Datatype: t.Datatype,
+			Shape:    t.Shape,
+			Contents: contents,
+		})
+	}
+	return res, nil
+}
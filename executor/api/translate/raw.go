@@ -0,0 +1,76 @@
+package translate
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+var datatypeSizes = map[string]int{
+	DatatypeBool:   1,
+	DatatypeInt8:   1,
+	DatatypeInt16:  2,
+	DatatypeInt32:  4,
+	DatatypeInt64:  8,
+	DatatypeUint8:  1,
+	DatatypeUint16: 2,
+	DatatypeUint32: 4,
+	DatatypeUint64: 8,
+	DatatypeFp32:   4,
+	DatatypeFp64:   8,
+}
+
+// setRaw sets the values of a tensor from little endian raw contents. BYTES elements are
+// prefixed by their length as a 4 byte integer.
+func (t *Tensor) setRaw(raw []byte) error {
+	if t.Datatype == DatatypeBytes {
+		for len(raw) > 0 {
+			if len(raw) < 4 {
+				return fmt.Errorf("Tensor %q has truncated raw contents", t.Name)
+			}
+			n := int(binary.LittleEndian.Uint32(raw))
+			if len(raw) < 4+n {
+				return fmt.Errorf("Tensor %q has truncated raw contents", t.Name)
+			}
+			t.Strings = append(t.Strings, string(raw[4:4+n]))
+			raw = raw[4+n:]
+		}
+		return nil
+	}
+	size, ok := datatypeSizes[t.Datatype]
+	if !ok {
+		return fmt.Errorf("Tensor %q has unsupported datatype %s", t.Name, t.Datatype)
+	}
+	if len(raw)%size != 0 {
+		return fmt.Errorf("Tensor %q has %d bytes of raw contents which is not a multiple of %d", t.Name, len(raw), size)
+	}
+	t.Values = make([]float64, 0, len(raw)/size)
+	for i := 0; i < len(raw); i += size {
+		b := raw[i : i+size]
+		var v float64
+		switch t.Datatype {
+		case DatatypeBool, DatatypeUint8:
+			v = float64(b[0])
+		case DatatypeInt8:
+			v = float64(int8(b[0]))
+		case DatatypeInt16:
+			v = float64(int16(binary.LittleEndian.Uint16(b)))
+		case DatatypeUint16:
+			v = float64(binary.LittleEndian.Uint16(b))
+		case DatatypeInt32:
+			v = float64(int32(binary.LittleEndian.Uint32(b)))
+		case DatatypeUint32:
+			v = float64(binary.LittleEndian.Uint32(b))
+		case DatatypeInt64:
+			v = float64(int64(binary.LittleEndian.Uint64(b)))
+		case DatatypeUint64:
+			v = float64(binary.LittleEndian.Uint64(b))
+		case DatatypeFp32:
+			v = float64(math.Float32frombits(binary.LittleEndian.Uint32(b)))
+		case DatatypeFp64:
+			v = math.Float64frombits(binary.LittleEndian.Uint64(b))
+		}
+		t.Values = append(t.Values, v)
+	}
+	return nil
+}
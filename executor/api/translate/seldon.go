@@ -0,0 +1,115 @@
+package translate
+
+import (
+	"fmt"
+
+	_struct "github.com/golang/protobuf/ptypes/struct"
+	"github.com/seldonio/seldon-core/executor/api/grpc/seldon/proto"
+)
+
+// fromSeldon reads the tensor held in the data of a SeldonMessage.
+func fromSeldon(sm *proto.SeldonMessage) (*Message, error) {
+	m := &Message{Id: sm.GetMeta().GetPuid()}
+	data := sm.GetData()
+	if data == nil {
+		return nil, fmt.Errorf("Only SeldonMessage data can be translated to another protocol")
+	}
+	switch v := data.GetDataOneof().(type) {
+	case *proto.DefaultData_Tensor:
+		t := Tensor{Datatype: DatatypeFp64, Values: v.Tensor.GetValues()}
+		for _, d := range v.Tensor.GetShape() {
+			t.Shape = append(t.Shape, int64(d))
+		}
+		m.Tensors = append(m.Tensors, t)
+	case *proto.DefaultData_Ndarray:
+		t, err := fromNested("", fromListValue(v.Ndarray))
+		if err != nil {
+			return nil, err
+		}
+		m.Tensors = append(m.Tensors, t)
+	case *proto.DefaultData_Tftensor:
+		t, err := fromTFTensor("", v.Tftensor)
+		if err != nil {
+			return nil, err
+		}
+		m.Tensors = append(m.Tensors, t)
+	default:
+		return nil, fmt.Errorf("Unsupported SeldonMessage data %T", v)
+	}
+	m.Tensors[0].Names = data.GetNames()
+	return m, nil
+}
+
+// toSeldon creates a SeldonMessage with the single tensor of a message as an ndarray.
+func toSeldon(m *Message) (*proto.SeldonMessage, error) {
+	if len(m.Tensors) != 1 {
+		return nil, fmt.Errorf("A SeldonMessage holds a single tensor but there are %d", len(m.Tensors))
+	}
+	t := m.Tensors[0]
+	if err := t.checkShape(); err != nil {
+		return nil, err
+	}
+	// Scalars are sent as a list with one element
+	v := t.toNested()
+	nested, ok := v.([]interface{})
+	if !ok {
+		nested = []interface{}{v}
+	}
+	sm := &proto.SeldonMessage{
+		DataOneof: &proto.SeldonMessage_Data{
+			Data: &proto.DefaultData{
+				DataOneof: &proto.DefaultData_Ndarray{Ndarray: toListValue(nested)},
+			},
+		},
+	}
+	if m.Id != "" {
+		sm.Meta = &proto.Meta{Puid: m.Id}
+	}
+	return sm, nil
+}
+
+func fromListValue(l *_struct.ListValue) interface{} {
+	values := make([]interface{}, len(l.GetValues()))
+	for i, v := range l.GetValues() {
+		values[i] = fromValue(v)
+	}
+	return values
+}
+
+func fromValue(v *_struct.Value) interface{} {
+	switch k := v.GetKind().(type) {
+	case *_struct.Value_NumberValue:
+		return k.NumberValue
+	case *_struct.Value_BoolValue:
+		return k.BoolValue
+	case *_struct.Value_StringValue:
+		return k.StringValue
+	case *_struct.Value_ListValue:
+		return fromListValue(k.ListValue)
+	default:
+		return nil
+	}
+}
+
+func toListValue(values []interface{}) *_struct.ListValue {
+	l := &_struct.ListValue{Values: make([]*_struct.Value, len(values))}
+	for i, v := range values {
+		l.Values[i] = toValue(v)
+	}
+	return l
+}
+
+func toValue(v interface{}) *_struct.Value {
+	switch e := v.(type) {
+	case float64:
+		return &_struct.Value{Kind: &_struct.Value_NumberValue{NumberValue: e}}
+	case bool:
+		return &_struct.Value{Kind: &_struct.Value_BoolValue{BoolValue: e}}
+	case string:
+		return &_struct.Value{Kind: &_struct.Value_StringValue{StringValue: e}}
+	case []interface{}:
+		return &_struct.Value{Kind: &_struct.Value_ListValue{ListValue: toListValue(e)}}
+	default:
+		return &_struct.Value{Kind: &_struct.Value_NullValue{}}
+	}
+}
@@ -0,0 +1,212 @@
+package translate
+
+import (
+	"fmt"
+)
+
+// Datatypes use the names of the v2 protocol.
+const (
+	DatatypeBool   = "BOOL"
+	DatatypeInt8   = "INT8"
+	DatatypeInt16  = "INT16"
+	DatatypeInt32  = "INT32"
+	DatatypeInt64  = "INT64"
+	DatatypeUint8  = "UINT8"
+	DatatypeUint16 = "UINT16"
+	DatatypeUint32 = "UINT32"
+	DatatypeUint64 = "UINT64"
+	DatatypeFp32   = "FP32"
+	DatatypeFp64   = "FP64"
+	DatatypeBytes  = "BYTES"
+)
+
+// Tensor is the protocol independent form of a tensor. Values are flattened in row major order
+// with BYTES tensors held in Strings and all other datatypes in Values. Names holds the column
+// names of Seldon data.
+type Tensor struct {
+	Name     string
+	Datatype string
+	Shape    []int64
+	Values   []float64
+	Strings  []string
+	Names    []string
+}
+
+// Message is the protocol independent form of a request or response.
+type Message struct {
+	Id      string
+	Tensors []Tensor
+}
+
+func (t *Tensor) size() int {
+	if t.Datatype == DatatypeBytes {
+		return len(t.Strings)
+	}
+	return len(t.Values)
+}
+
+// checkShape returns an error if the number of elements doesn't match the shape.
+func (t *Tensor) checkShape() error {
+	n := int64(1)
+	for _, d := range t.Shape {
+		n *= d
+	}
+	if n != int64(t.size()) {
+		return fmt.Errorf("Tensor %q has %d elements but shape %v", t.Name, t.size(), t.Shape)
+	}
+	return nil
+}
+
+func defaultName(response bool, i int) string {
+	if response {
+		return fmt.Sprintf("output-%d", i)
+	}
+	return fmt.Sprintf("input-%d", i)
+}
+
+// namedTensors returns the tensors with default names given to unnamed ones.
+func namedTensors(tensors []Tensor, response bool) []Tensor {
+	named := make([]Tensor, len(tensors))
+	for i, t := range tensors {
+		if t.Name == "" {
+			t.Name = defaultName(response, i)
+		}
+		named[i] = t
+	}
+	return named
+}
+
+// fromNested creates a tensor from nested JSON arrays of numbers, booleans or strings.
+func fromNested(name string, v interface{}) (Tensor, error) {
+	t := Tensor{Name: name}
+	for e := v; ; {
+		l, ok := e.([]interface{})
+		if !ok {
+			break
+		}
+		t.Shape = append(t.Shape, int64(len(l)))
+		if len(l) == 0 {
+			break
+		}
+		e = l[0]
+	}
+	if err := t.appendNested(v, 0); err != nil {
+		return t, err
+	}
+	if t.Datatype == "" {
+		t.Datatype = DatatypeFp64
+	}
+	return t, nil
+}
+
+func (t *Tensor) appendNested(v interface{}, depth int) error {
+	if l, ok := v.([]interface{}); ok {
+		if depth >= len(t.Shape) || int64(len(l)) != t.Shape[depth] {
+			return fmt.Errorf("Tensor %q is not rectangular", t.Name)
+		}
+		for _, e := range l {
+			if err := t.appendNested(e, depth+1); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if depth != len(t.Shape) {
+		return fmt.Errorf("Tensor %q is not rectangular", t.Name)
+	}
+	var datatype string
+	switch e := v.(type) {
+	case float64:
+		datatype = DatatypeFp64
+		t.Values = append(t.Values, e)
+	case bool:
+		datatype = DatatypeBool
+		t.Values = append(t.Values, boolToFloat(e))
+	case string:
+		datatype = DatatypeBytes
+		t.Strings = append(t.Strings, e)
+	default:
+		return fmt.Errorf("Tensor %q has unsupported value %v", t.Name, v)
+	}
+	if t.Datatype == "" {
+		t.Datatype = datatype
+	} else if t.Datatype != datatype {
+		return fmt.Errorf("Tensor %q mixes %s and %s values", t.Name, t.Datatype, datatype)
+	}
+	return nil
+}
+
+// flatValues sets the values of a tensor with a known datatype from JSON data which may be flat or nested.
+func (t *Tensor) flatValues(v interface{}) error {
+	if l, ok := v.([]interface{}); ok {
+		for _, e := range l {
+			if err := t.flatValues(e); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	switch e := v.(type) {
+	case float64:
+		t.Values = append(t.Values, e)
+	case bool:
+		t.Values = append(t.Values, boolToFloat(e))
+	case string:
+		t.Strings = append(t.Strings, e)
+	default:
+		return fmt.Errorf("Tensor %q has unsupported value %v", t.Name, v)
+	}
+	return nil
+}
+
+// toNested returns the values of a tensor as nested JSON arrays following its shape.
+func (t *Tensor) toNested() interface{} {
+	if len(t.Shape) == 0 {
+		if t.size() == 0 {
+			return []interface{}{}
+		}
+		return t.value(0)
+	}
+	offset := 0
+	return t.nested(0, &offset)
+}
+
+func (t *Tensor) nested(depth int, offset *int) interface{} {
+	l := make([]interface{}, 0, t.Shape[depth])
+	for i := int64(0); i < t.Shape[depth]; i++ {
+		if depth == len(t.Shape)-1 {
+			l = append(l, t.value(*offset))
+			*offset++
+		} else {
+			l = append(l, t.nested(depth+1, offset))
+		}
+	}
+	return l
+}
+
+// flat returns the values of a tensor as a flat JSON array.
+func (t *Tensor) flat() []interface{} {
+	l := make([]interface{}, 0, t.size())
+	for i := 0; i < t.size(); i++ {
+		l = append(l, t.value(i))
+	}
+	return l
+}
+
+func (t *Tensor) value(i int) interface{} {
+	switch t.Datatype {
+	case DatatypeBytes:
+		return t.Strings[i]
+	case DatatypeBool:
+		return t.Values[i] != 0
+	default:
+		return t.Values[i]
+	}
+}
+
+func boolToFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
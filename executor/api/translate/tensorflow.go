@@ -0,0 +1,284 @@
+package translate
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/seldonio/seldon-core/executor/proto/tensorflow/serving"
+	"github.com/tensorflow/tensorflow/tensorflow/go/core/framework"
+)
+
+var tfDatatypes = map[framework.DataType]string{
+	framework.DataType_DT_BOOL:   DatatypeBool,
+	framework.DataType_DT_INT8:   DatatypeInt8,
+	framework.DataType_DT_INT16:  DatatypeInt16,
+	framework.DataType_DT_INT32:  DatatypeInt32,
+	framework.DataType_DT_INT64:  DatatypeInt64,
+	framework.DataType_DT_UINT8:  DatatypeUint8,
+	framework.DataType_DT_UINT16: DatatypeUint16,
+	framework.DataType_DT_UINT32: DatatypeUint32,
+	framework.DataType_DT_UINT64: DatatypeUint64,
+	framework.DataType_DT_FLOAT:  DatatypeFp32,
+	framework.DataType_DT_DOUBLE: DatatypeFp64,
+	framework.DataType_DT_STRING: DatatypeBytes,
+}
+
+func fromTFTensor(name string, tp *framework.TensorProto) (Tensor, error) {
+	datatype, ok := tfDatatypes[tp.GetDtype()]
+	if !ok {
+		return Tensor{}, fmt.Errorf("Tensor %q has unsupported dtype %s", name, tp.GetDtype())
+	}
+	t := Tensor{Name: name, Datatype: datatype, Shape: []int64{}}
+	for _, d := range tp.GetTensorShape().GetDim() {
+		t.Shape = append(t.Shape, d.GetSize())
+	}
+	if len(tp.GetTensorContent()) > 0 {
+		if err := t.setRaw(tp.GetTensorContent()); err != nil {
+			return t, err
+		}
+	} else {
+		switch datatype {
+		case DatatypeBool:
+			for _, v := range tp.GetBoolVal() {
+				t.Values = append(t.Values, boolToFloat(v))
+			}
+		case DatatypeInt8, DatatypeInt16, DatatypeInt32, DatatypeUint8, DatatypeUint16:
+			for _, v := range tp.GetIntVal() {
+				t.Values = append(t.Values, float64(v))
+			}
+		case DatatypeInt64:
+			for _, v := range tp.GetInt64Val() {
+				t.Values = append(t.Values, float64(v))
+			}
+		case DatatypeUint32:
+			for _, v := range tp.GetUint32Val() {
+				t.Values = append(t.Values, float64(v))
+			}
+		case DatatypeUint64:
+			for _, v := range tp.GetUint64Val() {
+				t.Values = append(t.Values, float64(v))
+			}
+		case DatatypeFp32:
+			for _, v := range tp.GetFloatVal() {
+				t.Values = append(t.Values, float64(v))
+			}
+		case DatatypeFp64:
+			t.Values = append(t.Values, tp.GetDoubleVal()...)
+		case DatatypeBytes:
+			for _, v := range tp.GetStringVal() {
+				t.Strings = append(t.Strings, string(v))
+			}
+		}
+	}
+	// A single value is repeated to fill the shape
+	if t.size() == 1 {
+		t.repeat()
+	}
+	return t, nil
+}
+
+func (t *Tensor) repeat() {
+	n := int64(1)
+	for _, d := range t.Shape {
+		n *= d
+	}
+	for i := int64(1); i < n; i++ {
+		if t.Datatype == DatatypeBytes {
+			t.Strings = append(t.Strings, t.Strings[0])
+		} else {
+			t.Values = append(t.Values, t.Values[0])
+		}
+	}
+}
+
+func toTFTensor(t Tensor) (*framework.TensorProto, error) {
+	if err := t.checkShape(); err != nil {
+		return nil, err
+	}
+	tp := &framework.TensorProto{TensorShape: &framework.TensorShapeProto{}}
+	for _, d := range t.Shape {
+		tp.TensorShape.Dim = append(tp.TensorShape.Dim, &framework.TensorShapeProto_Dim{Size: d})
+	}
+	found := false
+	for dtype, datatype := range tfDatatypes {
+		if datatype == t.Datatype {
+			tp.Dtype, found = dtype, true
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("Tensor %q has unsupported datatype %s", t.Name, t.Datatype)
+	}
+	switch t.Datatype {
+	case DatatypeBool:
+		for _, v := range t.Values {
+			tp.BoolVal = append(tp.BoolVal, v != 0)
+		}
+	case DatatypeInt8, DatatypeInt16, DatatypeInt32, DatatypeUint8, DatatypeUint16:
+		for _, v := range t.Values {
+			tp.IntVal = append(tp.IntVal, int32(v))
+		}
+	case DatatypeInt64:
+		for _, v := range t.Values {
+			tp.Int64Val = append(tp.Int64Val, int64(v))
+		}
+	case DatatypeUint32:
+		for _, v := range t.Values {
+			tp.Uint32Val = append(tp.Uint32Val, uint32(v))
+		}
+	case DatatypeUint64:
+		for _, v := range t.Values {
+			tp.Uint64Val = append(tp.Uint64Val, uint64(v))
+		}
+	case DatatypeFp32:
+		for _, v := range t.Values {
+			tp.FloatVal = append(tp.FloatVal, float32(v))
+		}
+	case DatatypeFp64:
+		tp.DoubleVal = append(tp.DoubleVal, t.Values...)
+	case DatatypeBytes:
+		for _, v := range t.Strings {
+			tp.StringVal = append(tp.StringVal, []byte(v))
+		}
+	}
+	return tp, nil
+}
+
+func fromTFTensors(tensors map[string]*framework.TensorProto) (*Message, error) {
+	names := make([]string, 0, len(tensors))
+	for name := range tensors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	m := &Message{}
+	for _, name := range names {
+		t, err := fromTFTensor(name, tensors[name])
+		if err != nil {
+			return nil, err
+		}
+		m.Tensors = append(m.Tensors, t)
+	}
+	return m, nil
+}
+
+func toTFTensors(m *Message, response bool) (map[string]*framework.TensorProto, error) {
+	tensors := make(map[string]*framework.TensorProto)
+	for _, t := range namedTensors(m.Tensors, response) {
+		tp, err := toTFTensor(t)
+		if err != nil {
+			return nil, err
+		}
+		tensors[t.Name] = tp
+	}
+	return tensors, nil
+}
+
+func toTFRequest(m *Message, modelName string) (*serving.PredictRequest, error) {
+	inputs, err := toTFTensors(m, false)
+	if err != nil {
+		return nil, err
+	}
+	return &serving.PredictRequest{ModelSpec: &serving.ModelSpec{Name: modelName}, Inputs: inputs}, nil
+}
+
+func toTFResponse(m *Message, modelName string) (*serving.PredictResponse, error) {
+	outputs, err := toTFTensors(m, true)
+	if err != nil {
+		return nil, err
+	}
+	return &serving.PredictResponse{ModelSpec: &serving.ModelSpec{Name: modelName}, Outputs: outputs}, nil
+}
+
+// fromTFJson reads the row (instances, predictions) or columnar (inputs, outputs) format of the
+// TensorFlow REST API.
+func fromTFJson(body map[string]interface{}, response bool) (*Message, error) {
+	rowKey, columnKey := "instances", "inputs"
+	if response {
+		rowKey, columnKey = "predictions", "outputs"
+	}
+	if rows, ok := body[rowKey]; ok {
+		return fromTFRows(rows)
+	}
+	columns, ok := body[columnKey]
+	if !ok {
+		return nil, fmt.Errorf("Tensorflow payload has neither %s nor %s", rowKey, columnKey)
+	}
+	if named, ok := columns.(map[string]interface{}); ok {
+		return fromNamedNested(named)
+	}
+	t, err := fromNested("", columns)
+	if err != nil {
+		return nil, err
+	}
+	return &Message{Tensors: []Tensor{t}}, nil
+}
+
+// fromTFRows reads rows which are either values or objects of named values.
+func fromTFRows(v interface{}) (*Message, error) {
+	rows, ok := v.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("Tensorflow rows must be a list")
+	}
+	if len(rows) > 0 {
+		if _, ok := rows[0].(map[string]interface{}); ok {
+			columns := make(map[string]interface{})
+			for _, row := range rows {
+				named, ok := row.(map[string]interface{})
+				if !ok {
+					return nil, fmt.Errorf("Tensorflow rows mix objects and values")
+				}
+				for name, value := range named {
+					column, _ := columns[name].([]interface{})
+					columns[name] = append(column, value)
+				}
+			}
+			return fromNamedNested(columns)
+		}
+	}
+	t, err := fromNested("", rows)
+	if err != nil {
+		return nil, err
+	}
+	return &Message{Tensors: []Tensor{t}}, nil
+}
+
+func fromNamedNested(named map[string]interface{}) (*Message, error) {
+	names := make([]string, 0, len(named))
+	for name := range named {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	m := &Message{}
+	for _, name := range names {
+		t, err := fromNested(name, named[name])
+		if err != nil {
+			return nil, err
+		}
+		m.Tensors = append(m.Tensors, t)
+	}
+	return m, nil
+}
+
+// toTFJson writes a single tensor in the row format and several tensors in the columnar format.
+func toTFJson(m *Message, response bool) (map[string]interface{}, error) {
+	for i := range m.Tensors {
+		if err := m.Tensors[i].checkShape(); err != nil {
+			return nil, err
+		}
+	}
+	if len(m.Tensors) == 1 {
+		key := "instances"
+		if response {
+			key = "predictions"
+		}
+		return map[string]interface{}{key: m.Tensors[0].toNested()}, nil
+	}
+	columns := make(map[string]interface{})
+	for _, t := range namedTensors(m.Tensors, response) {
+		columns[t.Name] = t.toNested()
+	}
+	key := "inputs"
+	if response {
+		key = "outputs"
+	}
+	return map[string]interface{}{key: columns}, nil
+}
@@ -0,0 +1,144 @@
+// Package translate converts payloads between the Seldon, Tensorflow and KFServing v2 protocols so
+// units speaking different protocols can be used in one graph.
+package translate
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/golang/protobuf/jsonpb"
+	proto2 "github.com/golang/protobuf/proto"
+	"github.com/seldonio/seldon-core/executor/api"
+	"github.com/seldonio/seldon-core/executor/api/grpc/kfserving/inference"
+	"github.com/seldonio/seldon-core/executor/api/grpc/seldon/proto"
+	"github.com/seldonio/seldon-core/executor/api/payload"
+	"github.com/seldonio/seldon-core/executor/proto/tensorflow/serving"
+)
+
+const contentTypeJSON = "application/json"
+
+// Translate converts a request, or a response if response is set, from one protocol to another.
+// REST payloads stay JSON and gRPC payloads stay protos. The model name is set on the translated
+// payload where the protocol carries it.
+func Translate(msg payload.SeldonPayload, from string, to string, modelName string, response bool) (payload.SeldonPayload, error) {
+	if from == to {
+		return msg, nil
+	}
+	m, err := Decode(msg, from, response)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := msg.GetPayload().([]byte); ok {
+		data, err := encodeJson(m, to, modelName, response)
+		if err != nil {
+			return nil, err
+		}
+		return &payload.BytesPayload{Msg: data, ContentType: contentTypeJSON}, nil
+	}
+	pb, err := encodeProto(m, to, modelName, response)
+	if err != nil {
+		return nil, err
+	}
+	return &payload.ProtoPayload{Msg: pb}, nil
+}
+
+// Decode reads the tensors of a request, or a response if response is set. The protocol is used
+// for JSON payloads as protos identify their own protocol.
+func Decode(msg payload.SeldonPayload, protocol string, response bool) (*Message, error) {
+	if data, ok := msg.GetPayload().([]byte); ok {
+		return decodeJson(data, protocol, response)
+	}
+	return decodeProto(msg.GetPayload())
+}
+
+func decodeJson(data []byte, protocol string, response bool) (*Message, error) {
+	switch protocol {
+	case api.ProtocolSeldon:
+		var sm proto.SeldonMessage
+		if err := jsonpb.Unmarshal(bytes.NewReader(data), &sm); err != nil {
+			return nil, err
+		}
+		return fromSeldon(&sm)
+	case api.ProtocolTensorflow:
+		var body map[string]interface{}
+		if err := json.Unmarshal(data, &body); err != nil {
+			return nil, err
+		}
+		return fromTFJson(body, response)
+	case api.ProtocolKFServing:
+		var body v2JsonMessage
+		if err := json.Unmarshal(data, &body); err != nil {
+			return nil, err
+		}
+		return fromV2Json(&body, response)
+	default:
+		return nil, fmt.Errorf("Unknown protocol %s", protocol)
+	}
+}
+
+func encodeJson(m *Message, protocol string, modelName string, response bool) ([]byte, error) {
+	switch protocol {
+	case api.ProtocolSeldon:
+		sm, err := toSeldon(m)
+		if err != nil {
+			return nil, err
+		}
+		var buf bytes.Buffer
+		if err := (&jsonpb.Marshaler{}).Marshal(&buf, sm); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	case api.ProtocolTensorflow:
+		body, err := toTFJson(m, response)
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(body)
+	case api.ProtocolKFServing:
+		body, err := toV2Json(m, modelName, response)
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(body)
+	default:
+		return nil, fmt.Errorf("Unknown protocol %s", protocol)
+	}
+}
+
+// decodeProto reads any of the prediction protos. The proto type determines the protocol.
+func decodeProto(msg interface{}) (*Message, error) {
+	switch v := msg.(type) {
+	case *proto.SeldonMessage:
+		return fromSeldon(v)
+	case *serving.PredictRequest:
+		return fromTFTensors(v.GetInputs())
+	case *serving.PredictResponse:
+		return fromTFTensors(v.GetOutputs())
+	case *inference.ModelInferRequest:
+		return fromV2Request(v)
+	case *inference.ModelInferResponse:
+		return fromV2Response(v)
+	default:
+		return nil, fmt.Errorf("Payload type %T can't be translated", v)
+	}
+}
+
+func encodeProto(m *Message, protocol string, modelName string, response bool) (proto2.Message, error) {
+	switch protocol {
+	case api.ProtocolSeldon:
+		return toSeldon(m)
+	case api.ProtocolTensorflow:
+		if response {
+			return toTFResponse(m, modelName)
+		}
+		return toTFRequest(m, modelName)
+	case api.ProtocolKFServing:
+		if response {
+			return toV2Response(m, modelName)
+		}
+		return toV2Request(m, modelName)
+	default:
+		return nil, fmt.Errorf("Unknown protocol %s", protocol)
+	}
+}
@@ -0,0 +1,90 @@
+package translate
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/seldonio/seldon-core/executor/api"
+	"github.com/seldonio/seldon-core/executor/api/grpc/kfserving/inference"
+	"github.com/seldonio/seldon-core/executor/api/grpc/seldon/proto"
+	"github.com/seldonio/seldon-core/executor/api/payload"
+	"github.com/seldonio/seldon-core/executor/proto/tensorflow/serving"
+	"github.com/tensorflow/tensorflow/tensorflow/go/core/framework"
+)
+
+func createJsonPayload(data string) payload.SeldonPayload {
+	return &payload.BytesPayload{Msg: []byte(data), ContentType: contentTypeJSON}
+}
+
+func TestTranslateSeldonJsonToV2(t *testing.T) {
+	g := NewGomegaWithT(t)
+	req, err := Translate(createJsonPayload(`{"meta":{"puid":"1"},"data":{"ndarray":[[1,2],[3,4]]}}`), api.ProtocolSeldon, api.ProtocolKFServing, "model", false)
+	g.Expect(err).To(BeNil())
+	g.Expect(string(req.GetPayload().([]byte))).To(Equal(`{"id":"1","inputs":[{"name":"input-0","shape":[2,2],"datatype":"FP64","data":[1,2,3,4]}]}`))
+
+	res, err := Translate(createJsonPayload(`{"id":"1","outputs":[{"name":"predict","shape":[2],"datatype":"INT64","data":[0,1]}]}`), api.ProtocolKFServing, api.ProtocolSeldon, "model", true)
+	g.Expect(err).To(BeNil())
+	g.Expect(string(res.GetPayload().([]byte))).To(Equal(`{"meta":{"puid":"1"},"data":{"ndarray":[0,1]}}`))
+
+	// A SeldonMessage can only hold one tensor
+	_, err = Translate(createJsonPayload(`{"outputs":[{"name":"a","shape":[1],"datatype":"FP32","data":[1]},{"name":"b","shape":[1],"datatype":"FP32","data":[2]}]}`), api.ProtocolKFServing, api.ProtocolSeldon, "model", true)
+	g.Expect(err).ToNot(BeNil())
+}
+
+func TestTranslateTensorflowJson(t *testing.T) {
+	g := NewGomegaWithT(t)
+	req, err := Translate(createJsonPayload(`{"data":{"tensor":{"shape":[1,2],"values":[1,2]}}}`), api.ProtocolSeldon, api.ProtocolTensorflow, "model", false)
+	g.Expect(err).To(BeNil())
+	g.Expect(string(req.GetPayload().([]byte))).To(Equal(`{"instances":[[1,2]]}`))
+
+	// Named rows become one tensor per name
+	req, err = Translate(createJsonPayload(`{"instances":[{"a":[1,2],"b":"x"},{"a":[3,4],"b":"y"}]}`), api.ProtocolTensorflow, api.ProtocolKFServing, "model", false)
+	g.Expect(err).To(BeNil())
+	g.Expect(string(req.GetPayload().([]byte))).To(Equal(`{"inputs":[{"name":"a","shape":[2,2],"datatype":"FP64","data":[1,2,3,4]},{"name":"b","shape":[2],"datatype":"BYTES","data":["x","y"]}]}`))
+
+	res, err := Translate(createJsonPayload(`{"model_name":"model","outputs":[{"name":"a","shape":[2],"datatype":"BOOL","data":[true,false]},{"name":"b","shape":[1],"datatype":"FP32","data":[[1.5]]}]}`), api.ProtocolKFServing, api.ProtocolTensorflow, "model", true)
+	g.Expect(err).To(BeNil())
+	g.Expect(string(res.GetPayload().([]byte))).To(Equal(`{"outputs":{"a":[true,false],"b":[1.5]}}`))
+
+	_, err = Translate(createJsonPayload(`{"instances":[[1,2],[3]]}`), api.ProtocolTensorflow, api.ProtocolSeldon, "model", false)
+	g.Expect(err).ToNot(BeNil())
+}
+
+func TestTranslateProtos(t *testing.T) {
+	g := NewGomegaWithT(t)
+	sm := &proto.SeldonMessage{
+		DataOneof: &proto.SeldonMessage_Data{
+			Data: &proto.DefaultData{
+				DataOneof: &proto.DefaultData_Tensor{Tensor: &proto.Tensor{Shape: []int32{1, 2}, Values: []float64{1, 2}}},
+			},
+		},
+	}
+	req, err := Translate(&payload.ProtoPayload{Msg: sm}, api.ProtocolSeldon, api.ProtocolTensorflow, "model", false)
+	g.Expect(err).To(BeNil())
+	pr := req.GetPayload().(*serving.PredictRequest)
+	g.Expect(pr.ModelSpec.Name).To(Equal("model"))
+	g.Expect(pr.Inputs["input-0"].DoubleVal).To(Equal([]float64{1, 2}))
+
+	req, err = Translate(req, api.ProtocolTensorflow, api.ProtocolKFServing, "model", false)
+	g.Expect(err).To(BeNil())
+	ir := req.GetPayload().(*inference.ModelInferRequest)
+	g.Expect(ir.ModelName).To(Equal("model"))
+	g.Expect(ir.Inputs[0].Shape).To(Equal([]int64{1, 2}))
+	g.Expect(ir.Inputs[0].Contents.Fp64Contents).To(Equal([]float64{1, 2}))
+
+	// Raw contents are little endian
+	res := &inference.ModelInferResponse{
+		Outputs:           []*inference.ModelInferResponse_InferOutputTensor{{Name: "predict", Datatype: "FP32", Shape: []int64{2}}},
+		RawOutputContents: [][]byte{{0, 0, 128, 63, 0, 0, 0, 64}},
+	}
+	out, err := Translate(&payload.ProtoPayload{Msg: res}, api.ProtocolKFServing, api.ProtocolTensorflow, "model", true)
+	g.Expect(err).To(BeNil())
+	tp := out.GetPayload().(*serving.PredictResponse).Outputs["predict"]
+	g.Expect(tp.Dtype).To(Equal(framework.DataType_DT_FLOAT))
+	g.Expect(tp.FloatVal).To(Equal([]float32{1, 2}))
+
+	out, err = Translate(out, api.ProtocolTensorflow, api.ProtocolSeldon, "model", true)
+	g.Expect(err).To(BeNil())
+	values := out.GetPayload().(*proto.SeldonMessage).GetData().GetNdarray().GetValues()
+	g.Expect(values[1].GetNumberValue()).To(Equal(float64(2)))
+}
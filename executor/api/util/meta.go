@@ -0,0 +1,153 @@
+package util
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+
+	_struct "github.com/golang/protobuf/ptypes/struct"
+	"github.com/seldonio/seldon-core/executor/api/grpc/seldon/proto"
+	"github.com/seldonio/seldon-core/executor/api/payload"
+)
+
+// Top level fields of a SeldonMessage in JSON
+var seldonMessageFields = map[string]bool{
+	"status":   true,
+	"meta":     true,
+	"data":     true,
+	"binData":  true,
+	"strData":  true,
+	"jsonData": true,
+}
+
+// decodeSeldonMessageJson decodes the top level fields of a JSON SeldonMessage and its meta. It
+// returns false if the payload is not a JSON SeldonMessage, e.g. a Tensorflow or KFServing payload.
+func decodeSeldonMessageJson(msg payload.SeldonPayload) (map[string]json.RawMessage, map[string]json.RawMessage, bool) {
+	if !strings.HasPrefix(msg.GetContentType(), "application/json") {
+		return nil, nil, false
+	}
+	data, err := msg.GetBytes()
+	if err != nil {
+		return nil, nil, false
+	}
+	var sm map[string]json.RawMessage
+	if err := json.Unmarshal(data, &sm); err != nil {
+		return nil, nil, false
+	}
+	for field := range sm {
+		if !seldonMessageFields[field] {
+			return nil, nil, false
+		}
+	}
+	meta := map[string]json.RawMessage{}
+	if raw, ok := sm["meta"]; ok {
+		if err := json.Unmarshal(raw, &meta); err != nil || meta == nil {
+			return nil, nil, false
+		}
+	}
+	return sm, meta, true
+}
+
+func encodeSeldonMessageJson(msg payload.SeldonPayload, sm map[string]json.RawMessage, meta map[string]json.RawMessage) (payload.SeldonPayload, error) {
+	metaBytes, err := json.Marshal(meta)
+	if err != nil {
+		return nil, err
+	}
+	sm["meta"] = metaBytes
+	smBytes, err := json.Marshal(sm)
+	if err != nil {
+		return nil, err
+	}
+	return &payload.BytesPayload{Msg: smBytes, ContentType: msg.GetContentType()}, nil
+}
+
+// GetTagsFromSeldonPayload returns the meta tags of a SeldonMessage. The values are *_struct.Value
+// for protobuf payloads and json.RawMessage for JSON payloads.
+func GetTagsFromSeldonPayload(msg payload.SeldonPayload) map[string]interface{} {
+	if msg == nil {
+		return nil
+	}
+	if sm, ok := msg.GetPayload().(*proto.SeldonMessage); ok {
+		if len(sm.GetMeta().GetTags()) == 0 {
+			return nil
+		}
+		tags := make(map[string]interface{}, len(sm.GetMeta().GetTags()))
+		for k, v := range sm.GetMeta().GetTags() {
+			tags[k] = v
+		}
+		return tags
+	}
+	// Avoid decoding payloads which can't have tags
+	if data, ok := msg.GetPayload().([]byte); !ok || !bytes.Contains(data, []byte(`"tags"`)) {
+		return nil
+	}
+	_, meta, ok := decodeSeldonMessageJson(msg)
+	if !ok {
+		return nil
+	}
+	var rawTags map[string]json.RawMessage
+	if err := json.Unmarshal(meta["tags"], &rawTags); err != nil || len(rawTags) == 0 {
+		return nil
+	}
+	tags := make(map[string]interface{}, len(rawTags))
+	for k, v := range rawTags {
+		tags[k] = v
+	}
+	return tags
+}
+
+// SetTagsOnSeldonPredictPayload replaces the meta tags of a SeldonMessage with tags returned by
+// GetTagsFromSeldonPayload. Payloads which are not SeldonMessages are returned unchanged.
+func SetTagsOnSeldonPredictPayload(msg payload.SeldonPayload, tags map[string]interface{}) (payload.SeldonPayload, error) {
+	if sm, ok := msg.GetPayload().(*proto.SeldonMessage); ok {
+		if sm.Meta == nil {
+			sm.Meta = &proto.Meta{}
+		}
+		sm.Meta.Tags = make(map[string]*_struct.Value, len(tags))
+		for k, v := range tags {
+			if value, ok := v.(*_struct.Value); ok {
+				sm.Meta.Tags[k] = value
+			}
+		}
+		return msg, nil
+	}
+	sm, meta, ok := decodeSeldonMessageJson(msg)
+	if !ok {
+		return msg, nil
+	}
+	rawTags := make(map[string]json.RawMessage, len(tags))
+	for k, v := range tags {
+		if value, ok := v.(json.RawMessage); ok {
+			rawTags[k] = value
+		}
+	}
+	tagsBytes, err := json.Marshal(rawTags)
+	if err != nil {
+		return nil, err
+	}
+	meta["tags"] = tagsBytes
+	return encodeSeldonMessageJson(msg, sm, meta)
+}
+
+// InsertRequestPathToSeldonPredictPayload sets the request path, the image of each node the request
+// went through, in the meta of a SeldonMessage. Payloads which are not SeldonMessages are returned
+// unchanged.
+func InsertRequestPathToSeldonPredictPayload(msg payload.SeldonPayload, requestPath map[string]string) (payload.SeldonPayload, error) {
+	if sm, ok := msg.GetPayload().(*proto.SeldonMessage); ok {
+		if sm.Meta == nil {
+			sm.Meta = &proto.Meta{}
+		}
+		sm.Meta.RequestPath = requestPath
+		return msg, nil
+	}
+	sm, meta, ok := decodeSeldonMessageJson(msg)
+	if !ok {
+		return msg, nil
+	}
+	pathBytes, err := json.Marshal(requestPath)
+	if err != nil {
+		return nil, err
+	}
+	meta["requestPath"] = pathBytes
+	return encodeSeldonMessageJson(msg, sm, meta)
+}
@@ -1,11 +1,15 @@
 package util
 
 import (
+	"bytes"
 	"encoding/json"
 	"os"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/golang/protobuf/jsonpb"
+	_struct "github.com/golang/protobuf/ptypes/struct"
 	"github.com/seldonio/seldon-core/executor/api/grpc/seldon/proto"
 	"github.com/seldonio/seldon-core/executor/api/payload"
 )
@@ -97,9 +101,133 @@ func ExtractRouteFromSeldonMessage(msg *proto.SeldonMessage) []int {
 func InsertRouteToSeldonPredictPayload(msg payload.SeldonPayload, routing *map[string]int32) (payload.SeldonPayload, error) {
 
 	if msg.GetContentType() == payload.APPLICATION_TYPE_PROTOBUF {
-		sm := msg.GetPayload().(*proto.SeldonMessage)
+		sm, ok := msg.GetPayload().(*proto.SeldonMessage)
+		if !ok {
+			return msg, nil
+		}
+		if sm.Meta == nil {
+			sm.Meta = &proto.Meta{}
+		}
 		sm.Meta.Routing = *routing
 		return &payload.ProtoPayload{Msg: sm}, nil
+	} else {
+		sm, meta, ok := decodeSeldonMessageJson(msg)
+		if !ok {
+			return msg, nil
+		}
+		routingBytes, err := json.Marshal(*routing)
+		if err != nil {
+			return nil, err
+		}
+		meta["routing"] = routingBytes
+		return encodeSeldonMessageJson(msg, sm, meta)
+	}
+}
+
+// InsertTagToSeldonPredictPayload sets a tag in the meta of a SeldonMessage. The value must be JSON serializable.
+// Payloads which are not SeldonMessages are returned unchanged.
+func InsertTagToSeldonPredictPayload(msg payload.SeldonPayload, key string, value interface{}) (payload.SeldonPayload, error) {
+	valueBytes, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	if msg.GetContentType() == payload.APPLICATION_TYPE_PROTOBUF {
+		sm, ok := msg.GetPayload().(*proto.SeldonMessage)
+		if !ok {
+			return msg, nil
+		}
+		tagValue := _struct.Value{}
+		if err := jsonpb.UnmarshalString(string(valueBytes), &tagValue); err != nil {
+			return nil, err
+		}
+		if sm.Meta == nil {
+			sm.Meta = &proto.Meta{}
+		}
+		if sm.Meta.Tags == nil {
+			sm.Meta.Tags = make(map[string]*_struct.Value)
+		}
+		sm.Meta.Tags[key] = &tagValue
+		return &payload.ProtoPayload{Msg: sm}, nil
+	} else {
+		// Payloads of other protocols have no meta to add the tag to
+		sm, meta, ok := decodeSeldonMessageJson(msg)
+		if !ok {
+			return msg, nil
+		}
+		tags := map[string]json.RawMessage{}
+		if raw, ok := meta["tags"]; ok {
+			if err := json.Unmarshal(raw, &tags); err != nil || tags == nil {
+				tags = map[string]json.RawMessage{}
+			}
+		}
+		tags[key] = valueBytes
+		tagsBytes, err := json.Marshal(tags)
+		if err != nil {
+			return nil, err
+		}
+		meta["tags"] = tagsBytes
+		return encodeSeldonMessageJson(msg, sm, meta)
+	}
+}
+
+// ExtractMetricsFromSeldonPayload returns the custom metrics of a SeldonMessage and the message
+// without them. The metrics of protobuf messages are removed in place.
+func ExtractMetricsFromSeldonPayload(msg payload.SeldonPayload) ([]*proto.Metric, payload.SeldonPayload, error) {
+	if msg == nil {
+		return nil, msg, nil
+	}
+	if sm, ok := msg.GetPayload().(*proto.SeldonMessage); ok {
+		metrics := sm.GetMeta().GetMetrics()
+		if len(metrics) > 0 {
+			sm.Meta.Metrics = nil
+		}
+		return metrics, msg, nil
+	}
+	if !strings.HasPrefix(msg.GetContentType(), "application/json") {
+		return nil, msg, nil
+	}
+	data, err := msg.GetBytes()
+	if err != nil {
+		return nil, msg, err
+	}
+	// Avoid decoding responses which can't have metrics
+	if !bytes.Contains(data, []byte(`"metrics"`)) {
+		return nil, msg, nil
+	}
+	var sm map[string]json.RawMessage
+	if err := json.Unmarshal(data, &sm); err != nil {
+		return nil, msg, nil
+	}
+	var meta map[string]json.RawMessage
+	if err := json.Unmarshal(sm["meta"], &meta); err != nil || meta["metrics"] == nil {
+		return nil, msg, nil
+	}
+	var pm proto.Meta
+	if err := jsonpb.UnmarshalString(`{"metrics":`+string(meta["metrics"])+`}`, &pm); err != nil {
+		return nil, msg, err
+	}
+	delete(meta, "metrics")
+	if sm["meta"], err = json.Marshal(meta); err != nil {
+		return nil, msg, err
+	}
+	if data, err = json.Marshal(sm); err != nil {
+		return nil, msg, err
+	}
+	return pm.Metrics, &payload.BytesPayload{Msg: data, ContentType: msg.GetContentType()}, nil
+}
+
+// InsertMetricsToSeldonPredictPayload adds custom metrics to the meta of a SeldonMessage.
+func InsertMetricsToSeldonPredictPayload(msg payload.SeldonPayload, metrics []*proto.Metric) (payload.SeldonPayload, error) {
+	if msg.GetContentType() == payload.APPLICATION_TYPE_PROTOBUF {
+		sm, ok := msg.GetPayload().(*proto.SeldonMessage)
+		if !ok {
+			return msg, nil
+		}
+		if sm.Meta == nil {
+			sm.Meta = &proto.Meta{}
+		}
+		sm.Meta.Metrics = append(sm.Meta.Metrics, metrics...)
+		return &payload.ProtoPayload{Msg: sm}, nil
 	} else {
 		var smInterface interface{}
 		smBytes, err := msg.GetBytes()
@@ -109,11 +237,26 @@ func InsertRouteToSeldonPredictPayload(msg payload.SeldonPayload, routing *map[s
 		if err := json.Unmarshal(smBytes, &smInterface); err != nil {
 			return nil, err
 		}
-		if smJson, ok := (smInterface).(map[string]interface{}); ok {
-			if metaJson, ok := smJson["meta"].(map[string]interface{}); ok {
-				metaJson["routing"] = *routing
+		smJson, ok := (smInterface).(map[string]interface{})
+		if !ok {
+			return msg, nil
+		}
+		metaJson, ok := smJson["meta"].(map[string]interface{})
+		if !ok {
+			metaJson = make(map[string]interface{})
+			smJson["meta"] = metaJson
+		}
+		metricsJson, _ := metaJson["metrics"].([]interface{})
+		// Include the type of counters, which is the default
+		ma := jsonpb.Marshaler{EmitDefaults: true}
+		for _, m := range metrics {
+			metricJson, err := ma.MarshalToString(m)
+			if err != nil {
+				return nil, err
 			}
+			metricsJson = append(metricsJson, json.RawMessage(metricJson))
 		}
+		metaJson["metrics"] = metricsJson
 		smOutputBytes, err := json.Marshal(smInterface)
 		if err != nil {
 			return nil, err
@@ -141,3 +284,27 @@ func GetEnvAsBool(key string, fallback bool) bool {
 
 	return fallback
 }
+
+// Get an environment variable given by key as an integer or return the fallback.
+func GetEnvAsInt(key string, fallback int) int {
+	if raw, ok := os.LookupEnv(key); ok {
+		val, err := strconv.Atoi(raw)
+		if err == nil {
+			return val
+		}
+	}
+
+	return fallback
+}
+
+// Get an environment variable given by key as a duration such as 10s or return the fallback.
+func GetEnvAsDuration(key string, fallback time.Duration) time.Duration {
+	if raw, ok := os.LookupEnv(key); ok {
+		val, err := time.ParseDuration(raw)
+		if err == nil {
+			return val
+		}
+	}
+
+	return fallback
+}
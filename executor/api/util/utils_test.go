@@ -1,10 +1,13 @@
 package util
 
 import (
+	"encoding/json"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/golang/protobuf/jsonpb"
+	_struct "github.com/golang/protobuf/ptypes/struct"
 	. "github.com/onsi/gomega"
 	"github.com/seldonio/seldon-core/executor/api/grpc/seldon/proto"
 	"github.com/seldonio/seldon-core/executor/api/payload"
@@ -101,6 +104,19 @@ func TestGetEnvAsBool(t *testing.T) {
 	}
 }
 
+func TestGetEnvAsIntAndDuration(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	os.Setenv("TEST_FOO", "345")
+	g.Expect(GetEnvAsInt("TEST_FOO", 1)).To(Equal(345))
+	g.Expect(GetEnvAsDuration("TEST_FOO", time.Second)).To(Equal(time.Second))
+	os.Setenv("TEST_FOO", "10s")
+	g.Expect(GetEnvAsInt("TEST_FOO", 1)).To(Equal(1))
+	g.Expect(GetEnvAsDuration("TEST_FOO", time.Second)).To(Equal(10 * time.Second))
+	os.Unsetenv("TEST_FOO")
+	g.Expect(GetEnvAsInt("TEST_FOO", 1)).To(Equal(1))
+}
+
 func TestInjectRouteSeldonProto(t *testing.T) {
 	g := NewGomegaWithT(t)
 
@@ -120,6 +136,18 @@ func TestInjectRouteSeldonProto(t *testing.T) {
 	g.Expect(routes).To(Equal(testRouting))
 }
 
+func TestInjectRouteOtherProto(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	testRouting := map[string]int32{"test_route": 22}
+	msg := payload.ProtoPayload{Msg: &_struct.Value{}}
+
+	// Messages of other protocols have no meta so are returned unchanged
+	outMsg, err := InsertRouteToSeldonPredictPayload(&msg, &testRouting)
+	g.Expect(err).To(BeNil())
+	g.Expect(outMsg).To(Equal(&msg))
+}
+
 func TestInjectRouteSeldonJson(t *testing.T) {
 	g := NewGomegaWithT(t)
 
@@ -141,3 +169,106 @@ func TestInjectRouteSeldonJson(t *testing.T) {
 
 	g.Expect(routes).To(Equal(testRouting))
 }
+
+func TestInjectRouteSeldonJsonWithoutMeta(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	testRouting := map[string]int32{"test_route": 22}
+
+	msg := &payload.BytesPayload{Msg: []byte(`{"data":{"ndarray":[0]}}`), ContentType: "application/json"}
+	outMsg, err := InsertRouteToSeldonPredictPayload(msg, &testRouting)
+	g.Expect(err).To(BeNil())
+	g.Expect(string(outMsg.GetPayload().([]byte))).To(Equal(`{"data":{"ndarray":[0]},"meta":{"routing":{"test_route":22}}}`))
+
+	// Payloads of other protocols are left unchanged
+	msg = &payload.BytesPayload{Msg: []byte(`{"outputs":[{"name":"output","data":[1]}]}`), ContentType: "application/json"}
+	outMsg, err = InsertRouteToSeldonPredictPayload(msg, &testRouting)
+	g.Expect(err).To(BeNil())
+	g.Expect(string(outMsg.GetPayload().([]byte))).To(Equal(`{"outputs":[{"name":"output","data":[1]}]}`))
+}
+
+func TestInsertTagToSeldonPredictPayload(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	tag := map[string]string{"model": "success"}
+
+	msg := &payload.BytesPayload{Msg: []byte(`{"data":{"ndarray":[1]}}`), ContentType: "application/json"}
+	res, err := InsertTagToSeldonPredictPayload(msg, "status", tag)
+	g.Expect(err).To(BeNil())
+	g.Expect(string(res.GetPayload().([]byte))).To(Equal(`{"data":{"ndarray":[1]},"meta":{"tags":{"status":{"model":"success"}}}}`))
+
+	var sm proto.SeldonMessage
+	jsonpb.UnmarshalString(`{"data":{"ndarray":[1]}}`, &sm)
+	res, err = InsertTagToSeldonPredictPayload(&payload.ProtoPayload{Msg: &sm}, "status", tag)
+	g.Expect(err).To(BeNil())
+	tags := res.GetPayload().(*proto.SeldonMessage).GetMeta().GetTags()
+	g.Expect(tags["status"].GetStructValue().GetFields()["model"].GetStringValue()).To(Equal("success"))
+
+	// Existing tags are kept
+	msg = &payload.BytesPayload{Msg: []byte(`{"data":{"ndarray":[1]},"meta":{"tags":{"a":1}}}`), ContentType: "application/json"}
+	res, err = InsertTagToSeldonPredictPayload(msg, "status", tag)
+	g.Expect(err).To(BeNil())
+	g.Expect(string(res.GetPayload().([]byte))).To(Equal(`{"data":{"ndarray":[1]},"meta":{"tags":{"a":1,"status":{"model":"success"}}}}`))
+
+	// Tensorflow and KFServing payloads are left unchanged
+	for _, body := range []string{`{"predictions":[1]}`, `{"outputs":[{"name":"output","data":[1]}]}`} {
+		msg = &payload.BytesPayload{Msg: []byte(body), ContentType: "application/json"}
+		res, err = InsertTagToSeldonPredictPayload(msg, "status", tag)
+		g.Expect(err).To(BeNil())
+		g.Expect(string(res.GetPayload().([]byte))).To(Equal(body))
+	}
+}
+
+func TestInsertMetrics(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	metrics := []*proto.Metric{{Key: "mycounter", Type: proto.Metric_COUNTER, Value: 1}}
+
+	msg := &payload.BytesPayload{Msg: []byte(`{"data":{"ndarray":[1]},"meta":{"metrics":[{"key":"mygauge","type":"GAUGE","value":2}]}}`), ContentType: "application/json"}
+	res, err := InsertMetricsToSeldonPredictPayload(msg, metrics)
+	g.Expect(err).To(BeNil())
+	g.Expect(string(res.GetPayload().([]byte))).To(Equal(`{"data":{"ndarray":[1]},"meta":{"metrics":[{"key":"mygauge","type":"GAUGE","value":2},{"key":"mycounter","type":"COUNTER","value":1,"tags":{}}]}}`))
+
+	var sm proto.SeldonMessage
+	jsonpb.UnmarshalString(`{"data":{"ndarray":[1]}}`, &sm)
+	res, err = InsertMetricsToSeldonPredictPayload(&payload.ProtoPayload{Msg: &sm}, metrics)
+	g.Expect(err).To(BeNil())
+	g.Expect(res.GetPayload().(*proto.SeldonMessage).GetMeta().GetMetrics()).To(HaveLen(1))
+}
+
+func TestTags(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	msg := &payload.BytesPayload{Msg: []byte(`{"data":{"ndarray":[1]},"meta":{"tags":{"a":1}}}`), ContentType: "application/json"}
+	tags := GetTagsFromSeldonPayload(msg)
+	g.Expect(tags).To(HaveLen(1))
+	tags["b"] = json.RawMessage(`"x"`)
+	res, err := SetTagsOnSeldonPredictPayload(msg, tags)
+	g.Expect(err).To(BeNil())
+	g.Expect(string(res.GetPayload().([]byte))).To(Equal(`{"data":{"ndarray":[1]},"meta":{"tags":{"a":1,"b":"x"}}}`))
+
+	// Payloads of other protocols are not changed
+	tf := &payload.BytesPayload{Msg: []byte(`{"predictions":[1],"meta":{"tags":{"a":1}}}`), ContentType: "application/json"}
+	g.Expect(GetTagsFromSeldonPayload(tf)).To(BeNil())
+	res, err = SetTagsOnSeldonPredictPayload(tf, tags)
+	g.Expect(err).To(BeNil())
+	g.Expect(res).To(Equal(tf))
+
+	var sm proto.SeldonMessage
+	jsonpb.UnmarshalString(`{"data":{"ndarray":[1]},"meta":{"tags":{"a":1}}}`, &sm)
+	pm := &payload.ProtoPayload{Msg: &sm}
+	tags = GetTagsFromSeldonPayload(pm)
+	tags["b"] = &_struct.Value{Kind: &_struct.Value_StringValue{StringValue: "x"}}
+	res, err = SetTagsOnSeldonPredictPayload(pm, tags)
+	g.Expect(err).To(BeNil())
+	g.Expect(res.GetPayload().(*proto.SeldonMessage).GetMeta().GetTags()).To(HaveLen(2))
+}
+
+func TestInsertRequestPath(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	msg := &payload.BytesPayload{Msg: []byte(`{"data":{"ndarray":[1]}}`), ContentType: "application/json"}
+	res, err := InsertRequestPathToSeldonPredictPayload(msg, map[string]string{"model": "seldonio/model:1.0"})
+	g.Expect(err).To(BeNil())
+	g.Expect(string(res.GetPayload().([]byte))).To(Equal(`{"data":{"ndarray":[1]},"meta":{"requestPath":{"model":"seldonio/model:1.0"}}}`))
+}
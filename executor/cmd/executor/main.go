@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"log"
 	"net"
+	"net/http"
 	"net/url"
 	"os"
 	"os/signal"
@@ -15,19 +16,26 @@ import (
 	"time"
 
 	"strconv"
+	"strings"
 
 	"github.com/go-logr/logr"
 	"github.com/seldonio/seldon-core/executor/api"
+	"github.com/seldonio/seldon-core/executor/api/async"
+	"github.com/seldonio/seldon-core/executor/api/cache"
 	seldonclient "github.com/seldonio/seldon-core/executor/api/client"
 	"github.com/seldonio/seldon-core/executor/api/grpc"
+	"github.com/seldonio/seldon-core/executor/api/grpc/clients"
 	"github.com/seldonio/seldon-core/executor/api/grpc/kfserving"
 	kfproto "github.com/seldonio/seldon-core/executor/api/grpc/kfserving/inference"
 	"github.com/seldonio/seldon-core/executor/api/grpc/seldon"
 	"github.com/seldonio/seldon-core/executor/api/grpc/seldon/proto"
 	"github.com/seldonio/seldon-core/executor/api/grpc/tensorflow"
 	"github.com/seldonio/seldon-core/executor/api/kafka"
+	"github.com/seldonio/seldon-core/executor/api/lifecycle"
+	"github.com/seldonio/seldon-core/executor/api/metric"
 	"github.com/seldonio/seldon-core/executor/api/rest"
 	"github.com/seldonio/seldon-core/executor/api/tracing"
+	"github.com/seldonio/seldon-core/executor/api/translate"
 	"github.com/seldonio/seldon-core/executor/api/util"
 	"github.com/seldonio/seldon-core/executor/k8s"
 	loghandler "github.com/seldonio/seldon-core/executor/logger"
@@ -47,6 +55,22 @@ const (
 	certMountPathEnvVar   = "SELDON_CERT_MOUNT_PATH"
 	certFileEnvVar        = "SELDON_CERT_FILE_NAME"
 	certKeyFileNameEnvVar = "SELDON_CERT_KEY_FILE_NAME"
+	logQueueSizeEnvVar    = "SELDON_LOGGER_QUEUE_SIZE"
+	logQueuePolicyEnvVar  = "SELDON_LOGGER_FULL_QUEUE_POLICY"
+	logRetriesEnvVar      = "SELDON_LOGGER_RETRIES"
+	logBackoffEnvVar      = "SELDON_LOGGER_RETRY_BACKOFF"
+	logDeadLettersEnvVar  = "SELDON_LOGGER_DEAD_LETTER_FILE"
+	logReplayEnvVar       = "SELDON_LOGGER_REPLAY_INTERVAL"
+	logFlushEnvVar        = "SELDON_LOGGER_FLUSH_TIMEOUT"
+	metricsLabelsEnvVar   = "SELDON_METRICS_LABELS"
+	asyncWorkersEnvVar    = "SELDON_ASYNC_WORKERS"
+	asyncQueueSizeEnvVar  = "SELDON_ASYNC_QUEUE_SIZE"
+	asyncStoreDirEnvVar   = "SELDON_ASYNC_STORE_DIR"
+	asyncResultTTLEnvVar  = "SELDON_ASYNC_RESULT_TTL"
+	asyncJobTimeoutEnvVar = "SELDON_ASYNC_JOB_TIMEOUT"
+	asyncMaxWaitEnvVar    = "SELDON_ASYNC_MAX_WAIT"
+	asyncRetriesEnvVar    = "SELDON_ASYNC_CALLBACK_RETRIES"
+	asyncCallbacksEnvVar  = "SELDON_ASYNC_CALLBACK_URLS"
 )
 
 var (
@@ -61,17 +85,35 @@ var (
 	httpPort       = flag.Int("http_port", 8080, "Executor http port")
 	grpcPort       = flag.Int("grpc_port", 5000, "Executor grpc port")
 	wait           = flag.Duration("graceful_timeout", time.Second*15, "Graceful shutdown secs")
+	shutdownDelay  = flag.Duration("shutdown_delay", time.Second*5, "Time to report not ready before stopping the servers on shutdown")
 	protocol       = flag.String("protocol", "seldon", "The payload protocol")
 	transport      = flag.String("transport", "rest", "The network transport mechanism rest, grpc")
 	filename       = flag.String("file", "", "Load graph from file")
 	hostname       = flag.String("hostname", "", "The hostname of the running server")
 	logWorkers     = flag.Int("logger_workers", 5, "Number of workers handling payload logging")
+	logQueueSize   = flag.Int("logger_queue_size", util.GetEnvAsInt(logQueueSizeEnvVar, loghandler.DefaultOptions.QueueSize), "Number of payload logs which can be queued")
+	logQueuePolicy = flag.String("logger_full_queue_policy", util.GetEnv(logQueuePolicyEnvVar, string(loghandler.DefaultOptions.FullQueuePolicy)), "What to do with payload logs when the queue is full: block, drop or spill")
+	logRetries     = flag.Int("logger_retries", util.GetEnvAsInt(logRetriesEnvVar, loghandler.DefaultOptions.Retries), "Number of times to retry sending a payload log")
+	logBackoff     = flag.Duration("logger_retry_backoff", util.GetEnvAsDuration(logBackoffEnvVar, loghandler.DefaultOptions.RetryBackoff), "Time to wait before retrying to send a payload log, doubled after each attempt")
+	logDeadLetters = flag.String("logger_dead_letter_file", util.GetEnv(logDeadLettersEnvVar, ""), "File to write payload logs which could not be sent to. They are sent again once the sink recovers")
+	logReplay      = flag.Duration("logger_replay_interval", util.GetEnvAsDuration(logReplayEnvVar, loghandler.DefaultOptions.ReplayInterval), "How often to send payload logs from the dead letter file")
+	logFlush       = flag.Duration("logger_flush_timeout", util.GetEnvAsDuration(logFlushEnvVar, 5*time.Second), "Time to send queued payload logs on shutdown after in-flight requests have finished")
 	prometheusPath = flag.String("prometheus_path", "/metrics", "The prometheus metrics path")
+	metricsLabels  = flag.String("metrics_labels", util.GetEnv(metricsLabelsEnvVar, metric.DefaultNodeLabels), "Comma separated optional labels of the node metrics: predictor_version, model_image, model_version")
+	asyncWorkers   = flag.Int("async_workers", util.GetEnvAsInt(asyncWorkersEnvVar, async.DefaultOptions.Workers), "Number of workers running asynchronous predictions. Asynchronous requests are disabled if 0")
+	asyncQueueSize = flag.Int("async_queue_size", util.GetEnvAsInt(asyncQueueSizeEnvVar, async.DefaultOptions.QueueSize), "Number of asynchronous predictions which can wait for a worker")
+	asyncStoreDir  = flag.String("async_store_dir", util.GetEnv(asyncStoreDirEnvVar, ""), "Directory to keep the results of asynchronous predictions in. Kept in memory if empty")
+	asyncResultTTL = flag.Duration("async_result_ttl", util.GetEnvAsDuration(asyncResultTTLEnvVar, async.DefaultOptions.ResultTTL), "How long the results of asynchronous predictions are kept")
+	asyncTimeout   = flag.Duration("async_job_timeout", util.GetEnvAsDuration(asyncJobTimeoutEnvVar, 0), "Longest time an asynchronous prediction can run for. No limit if 0")
+	asyncMaxWait   = flag.Duration("async_max_wait", util.GetEnvAsDuration(asyncMaxWaitEnvVar, async.DefaultOptions.MaxWait), "Longest time a status request can wait for an asynchronous prediction to finish")
+	asyncRetries   = flag.Int("async_callback_retries", util.GetEnvAsInt(asyncRetriesEnvVar, async.DefaultOptions.CallbackRetries), "Number of times to retry sending the result of an asynchronous prediction to its callback URL")
+	asyncCallbacks = flag.String("async_callback_urls", util.GetEnv(asyncCallbacksEnvVar, ""), "Comma separated URLs the results of asynchronous predictions can be sent to. A callback URL must have the scheme and host of one and start with its path. Callbacks are disabled if empty")
 	kafkaBroker    = flag.String("kafka_broker", "", "The kafka broker as host:port")
 	kafkaTopicIn   = flag.String("kafka_input_topic", "", "The kafka input topic")
 	kafkaTopicOut  = flag.String("kafka_output_topic", "", "The kafka output topic")
 	kafkaFullGraph = flag.Bool("kafka_full_graph", false, "Use kafka for internal graph processing")
 	kafkaWorkers   = flag.Int("kafka_workers", 4, "Number of kafka workers")
+	banditStateDir = flag.String("bandit_state_dir", "", "Directory to persist multi-armed bandit router state. Kept in memory if empty")
 	debug          = flag.Bool(
 		"debug",
 		util.GetEnvAsBool(debugEnvVar, debugDefault),
@@ -92,46 +134,36 @@ func getServerUrl(hostname string, port int) (*url.URL, error) {
 	return url.Parse(fmt.Sprintf("http://%s:%d/", hostname, port))
 }
 
-func runHttpServer(lis net.Listener, logger logr.Logger, predictor *v1.PredictorSpec, client seldonclient.SeldonApiClient, port int,
-	probesOnly bool, serverUrl *url.URL, namespace string, protocol string, deploymentName string, prometheusPath string) {
+func runHttpServer(lis net.Listener, logger logr.Logger, predictor *v1.PredictorSpec, client seldonclient.SeldonApiClient, grpcClient seldonclient.SeldonApiClient, port int,
+	probesOnly bool, serverUrl *url.URL, namespace string, protocol string, deploymentName string, prometheusPath string, annotations map[string]string, jobs *async.Manager) {
 	defer lis.Close()
 
 	// Create REST API
 	seldonRest := rest.NewServerRestApi(predictor, client, probesOnly, serverUrl, namespace, protocol, deploymentName, prometheusPath)
+	// Nodes with gRPC endpoints are checked over gRPC
+	seldonRest.ReadyChecker = predictor2.NewReadyChecker(predictor, protocol, client, grpcClient)
+	seldonRest.Jobs = jobs
+	requestTimeout, err := rest.GetRequestTimeoutFromAnnotations(annotations)
+	if err != nil {
+		log.Fatal("Failed to parse request timeout", err)
+	}
+	seldonRest.RequestTimeout = requestTimeout
 	seldonRest.Initialise()
 	srv := seldonRest.CreateHttpServer(port)
 
-	go func() {
-		if err := srv.Serve(lis); err != nil {
-			logger.Error(err, "Server error")
-		}
-		logger.Info("server started")
-	}()
-
-	c := make(chan os.Signal, 1)
-	// We'll accept graceful shutdowns when quit via SIGINT (Ctrl+C) and SIGTERM
-	// SIGKILL, SIGQUIT will not be caught.
-	signal.Notify(c, syscall.SIGINT)
-	signal.Notify(c, syscall.SIGTERM)
-
-	// Block until we receive our signal.
-	<-c
-
-	// Create a deadline to wait for.
-	ctx, cancel := context.WithTimeout(context.Background(), *wait)
-	defer cancel()
-	// Doesn't block if no connections, but will otherwise wait
-	// until the timeout deadline.
-	srv.Shutdown(ctx)
-	// Optionally, you could run srv.Shutdown in a goroutine and block on
-	// <-ctx.Done() if your application should wait for other services
-	// to finalize based on context cancellation.
-	logger.Info("shutting down")
-	os.Exit(0)
+	lifecycle.OnStop("http", func(ctx context.Context) error {
+		// Doesn't block if no connections, but will otherwise wait
+		// until the timeout deadline.
+		return srv.Shutdown(ctx)
+	})
 
+	logger.Info("server started")
+	if err := srv.Serve(lis); err != nil && err != http.ErrServerClosed {
+		logger.Error(err, "Server error")
+	}
 }
 
-func runGrpcServer(lis net.Listener, logger logr.Logger, predictor *v1.PredictorSpec, client seldonclient.SeldonApiClient, serverUrl *url.URL, namespace string, protocol string, deploymentName string, annotations map[string]string) {
+func runGrpcServer(lis net.Listener, logger logr.Logger, predictor *v1.PredictorSpec, client seldonclient.SeldonApiClient, serverUrl *url.URL, namespace string, protocol string, deploymentName string, annotations map[string]string, jobs *async.Manager) {
 	defer lis.Close()
 	grpcServer, err := grpc.CreateGrpcServer(predictor, deploymentName, annotations, logger)
 	if err != nil {
@@ -140,6 +172,7 @@ func runGrpcServer(lis net.Listener, logger logr.Logger, predictor *v1.Predictor
 	switch protocol {
 	case api.ProtocolSeldon:
 		seldonGrpcServer := seldon.NewGrpcSeldonServer(predictor, client, serverUrl, namespace)
+		seldonGrpcServer.Jobs = jobs
 		proto.RegisterSeldonServer(grpcServer, seldonGrpcServer)
 		// Register reflection service on gRPC server.
 		reflection.Register(grpcServer)
@@ -149,8 +182,23 @@ func runGrpcServer(lis net.Listener, logger logr.Logger, predictor *v1.Predictor
 		serving.RegisterModelServiceServer(grpcServer, tensorflowGrpcServer)
 	case api.ProtocolKFServing:
 		kfservingGrpcServer := kfserving.NewGrpcKFServingServer(predictor, client, serverUrl, namespace)
+		kfservingGrpcServer.Jobs = jobs
 		kfproto.RegisterGRPCInferenceServiceServer(grpcServer, kfservingGrpcServer)
 	}
+	lifecycle.OnStop("grpc", func(ctx context.Context) error {
+		stopped := make(chan struct{})
+		go func() {
+			grpcServer.GracefulStop()
+			close(stopped)
+		}()
+		select {
+		case <-stopped:
+			return nil
+		case <-ctx.Done():
+			grpcServer.Stop()
+			return ctx.Err()
+		}
+	})
 	err = grpcServer.Serve(lis)
 	if err != nil {
 		logger.Error(err, "gRPC server error")
@@ -288,15 +336,40 @@ func main() {
 		logger.Error(err, "Failed to load annotations")
 	}
 
+	if *banditStateDir != "" {
+		banditStore, err := predictor2.NewFileBanditStore(*banditStateDir)
+		if err != nil {
+			log.Fatal("Failed to create bandit state store", err)
+		}
+		predictor2.SetBanditStore(banditStore)
+	}
+
 	//Start Logger Dispacther
-	loghandler.StartDispatcher(*logWorkers, logger, *sdepName, *namespace, *predictorName)
+	err = loghandler.StartDispatcher(*logWorkers, logger, *sdepName, *namespace, *predictorName, loghandler.Options{
+		QueueSize:       *logQueueSize,
+		FullQueuePolicy: loghandler.FullQueuePolicy(*logQueuePolicy),
+		Retries:         *logRetries,
+		RetryBackoff:    *logBackoff,
+		DeadLetterFile:  *logDeadLetters,
+		ReplayInterval:  *logReplay,
+	})
+	if err != nil {
+		log.Fatal("Failed to start payload logger", err)
+	}
 
 	//Init Tracing
 	closer, err := tracing.InitTracing()
 	if err != nil {
-		log.Fatal("Could not initialize jaeger tracer", err.Error())
+		log.Fatal("Could not initialize tracing", err.Error())
 	}
 	defer closer.Close()
+	predictor2.SetNodeImages(predictor)
+
+	nodeLabels, err := metric.ParseNodeLabels(*metricsLabels)
+	if err != nil {
+		log.Fatal("Invalid node metric labels", err)
+	}
+	predictor2.SetNodeMetrics(metric.NewNodeMetrics(predictor, *sdepName, nodeLabels))
 
 	if *serverType == "kafka" {
 		logger.Info("Starting kafka server")
@@ -312,28 +385,91 @@ func main() {
 		}()
 	}
 
-	clientRest, err := rest.NewJSONRestClient(*protocol, *sdepName, predictor, annotations)
+	newRestClient := func(protocol string) (seldonclient.SeldonApiClient, error) {
+		return rest.NewJSONRestClient(protocol, *sdepName, predictor, annotations)
+	}
+	clientRest, err := newRestClient(*protocol)
+	if err != nil {
+		log.Fatalf("Failed to create http client: %v", err)
+	}
+	clientRest, err = translate.NewTranslatingClient(clientRest, *protocol, predictor, newRestClient)
 	if err != nil {
 		log.Fatalf("Failed to create http client: %v", err)
 	}
 
-	var clientGrpc seldonclient.SeldonApiClient
-	switch *protocol {
-	case api.ProtocolSeldon:
-		clientGrpc = seldon.NewSeldonGrpcClient(predictor, *sdepName, annotations)
-	case api.ProtocolTensorflow:
-		clientGrpc = tensorflow.NewTensorflowGrpcClient(predictor, *sdepName, annotations)
-	case api.ProtocolKFServing:
-		clientGrpc = kfserving.NewKFServingGrpcClient(predictor, *sdepName, annotations)
-	default:
-		log.Fatalf("Failed to create grpc client. Unknown protocol %s: %v", *protocol, err)
+	newGrpcClient := func(protocol string) (seldonclient.SeldonApiClient, error) {
+		return clients.NewGrpcClient(protocol, *sdepName, predictor, annotations)
+	}
+	clientGrpc, err := newGrpcClient(*protocol)
+	if err != nil {
+		log.Fatalf("Failed to create grpc client: %v", err)
+	}
+	clientGrpc, err = translate.NewTranslatingClient(clientGrpc, *protocol, predictor, newGrpcClient)
+	if err != nil {
+		log.Fatalf("Failed to create grpc client: %v", err)
+	}
+	clientRest = cache.NewCachingClient(clientRest, predictor, *sdepName, cache.NewMemoryBackendFactory)
+	clientGrpc = cache.NewCachingClient(clientGrpc, predictor, *sdepName, cache.NewMemoryBackendFactory)
+
+	var jobs *async.Manager
+	if *asyncWorkers > 0 {
+		var store async.ResultStore = async.NewMemoryResultStore()
+		if *asyncStoreDir != "" {
+			fileStore, err := async.NewFileResultStore(*asyncStoreDir)
+			if err != nil {
+				log.Fatal("Failed to create asynchronous result store", err)
+			}
+			store = fileStore
+		}
+		opts := async.DefaultOptions
+		opts.Workers = *asyncWorkers
+		opts.QueueSize = *asyncQueueSize
+		opts.ResultTTL = *asyncResultTTL
+		opts.JobTimeout = *asyncTimeout
+		opts.MaxWait = *asyncMaxWait
+		opts.CallbackRetries = *asyncRetries
+		for _, callbackUrl := range strings.Split(*asyncCallbacks, ",") {
+			if callbackUrl = strings.TrimSpace(callbackUrl); callbackUrl != "" {
+				opts.CallbackUrls = append(opts.CallbackUrls, callbackUrl)
+			}
+		}
+		jobs, err = async.NewManager(store, opts, logf.Log.WithName("AsyncManager"))
+		if err != nil {
+			log.Fatal("Failed to start asynchronous predictions", err)
+		}
+		jobs.Start()
+		lifecycle.OnStop("async", jobs.Stop)
 	}
 
 	logger.Info("Running http server ", "port", *httpPort)
-	go runHttpServer(createListener(*httpPort, logger), logger, predictor, clientRest, *httpPort, false, serverUrl, *namespace, *protocol, *sdepName, *prometheusPath)
+	go runHttpServer(createListener(*httpPort, logger), logger, predictor, clientRest, clientGrpc, *httpPort, false, serverUrl, *namespace, *protocol, *sdepName, *prometheusPath, annotations, jobs)
 
 	logger.Info("Running grpc server ", "port", *grpcPort)
-	runGrpcServer(createListener(*grpcPort, logger), logger, predictor, clientGrpc, serverUrl, *namespace, *protocol, *sdepName, annotations)
+	go runGrpcServer(createListener(*grpcPort, logger), logger, predictor, clientGrpc, serverUrl, *namespace, *protocol, *sdepName, annotations, jobs)
+
+	c := make(chan os.Signal, 1)
+	// We'll accept graceful shutdowns when quit via SIGINT (Ctrl+C) and SIGTERM
+	// SIGKILL, SIGQUIT will not be caught.
+	signal.Notify(c, syscall.SIGINT, syscall.SIGTERM)
+
+	// Block until we receive our signal.
+	sig := <-c
+	logger.Info("Received signal", "signal", sig)
+
+	// Become unready, stop the servers and wait for in-flight requests before sending the
+	// remaining payload logs, which has its own timeout as shutdown may have used up its own.
+	ctx, cancel := context.WithTimeout(context.Background(), *shutdownDelay+*wait)
+	defer cancel()
+	lifecycle.Shutdown(ctx, *shutdownDelay)
+	flushCtx, flushCancel := context.WithTimeout(context.Background(), *logFlush)
+	defer flushCancel()
+	if err := loghandler.Flush(flushCtx); err != nil {
+		logger.Error(err, "Failed to flush payload logs")
+	}
+	if err := loghandler.CloseSinks(); err != nil {
+		logger.Error(err, "Failed to close payload log sinks")
+	}
+	logger.Info("shutting down")
 }
 
 func createListener(port int, logger logr.Logger) net.Listener {
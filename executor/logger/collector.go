@@ -1,14 +1,133 @@
 package logger
 
-import "fmt"
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/seldonio/seldon-core/executor/api/metric"
+)
 
 const LoggerWorkerQueueSize = 100
 
+// FullQueuePolicy decides what happens to a log request when the queue is full.
+type FullQueuePolicy string
+
+const (
+	// Wait for space in the queue, delaying the request being served
+	FullQueueBlock FullQueuePolicy = "block"
+	// Drop the log request
+	FullQueueDrop FullQueuePolicy = "drop"
+	// Write the log request to the dead letter file to be sent later
+	FullQueueSpill FullQueuePolicy = "spill"
+)
+
+type Options struct {
+	QueueSize       int
+	FullQueuePolicy FullQueuePolicy
+	// Number of times to retry sending a log request
+	Retries int
+	// Time to wait before the first retry. It is doubled after each attempt.
+	RetryBackoff time.Duration
+	// File requests which can't be sent are written to. Disabled if empty.
+	DeadLetterFile string
+	// How often to try sending the requests in the dead letter file
+	ReplayInterval time.Duration
+}
+
+var DefaultOptions = Options{
+	QueueSize:       LoggerWorkerQueueSize,
+	FullQueuePolicy: FullQueueBlock,
+	Retries:         3,
+	RetryBackoff:    100 * time.Millisecond,
+	ReplayInterval:  30 * time.Second,
+}
+
+func (o Options) Validate() error {
+	switch o.FullQueuePolicy {
+	case FullQueueBlock, FullQueueDrop:
+	case FullQueueSpill:
+		if o.DeadLetterFile == "" {
+			return fmt.Errorf("A dead letter file is needed to spill log requests")
+		}
+	default:
+		return fmt.Errorf("Unknown full queue policy %s", o.FullQueuePolicy)
+	}
+	if o.DeadLetterFile != "" && o.ReplayInterval <= 0 {
+		return fmt.Errorf("Dead letter replay interval must be positive")
+	}
+	if o.QueueSize <= 0 {
+		return fmt.Errorf("Logger queue size must be positive")
+	}
+	return nil
+}
+
+var options = DefaultOptions
+
 // A buffered channel that we can send work requests on.
 var WorkQueue = make(chan LogRequest, LoggerWorkerQueueSize)
 
+// Number of queued requests which have not been sent yet.
+var pendingRequests int64
+
+var loggerMetrics *metric.LoggerMetrics
+
+var deadLetters *deadLetterFile
+
+// QueueLogRequest queues a request to be sent by a worker. If the queue is full the request is
+// handled with the full queue policy.
 func QueueLogRequest(req LogRequest) error {
-	WorkQueue <- req
-	fmt.Println("Work request queued")
+	atomic.AddInt64(&pendingRequests, 1)
+	select {
+	case WorkQueue <- req:
+		loggerMetrics.QueueDepth(len(WorkQueue))
+		return nil
+	default:
+	}
+	switch options.FullQueuePolicy {
+	case FullQueueDrop:
+		requestDone()
+		loggerMetrics.Dropped(metric.LoggerDroppedQueueFull)
+		return fmt.Errorf("Logger queue is full, dropped log request %s", req.Id)
+	case FullQueueSpill:
+		requestDone()
+		return spill(req)
+	default:
+		WorkQueue <- req
+		loggerMetrics.QueueDepth(len(WorkQueue))
+		return nil
+	}
+}
+
+func requestDone() {
+	atomic.AddInt64(&pendingRequests, -1)
+}
+
+// spill writes a request to the dead letter file to be sent later.
+func spill(req LogRequest) error {
+	if deadLetters == nil {
+		loggerMetrics.Dropped(metric.LoggerDroppedQueueFull)
+		return fmt.Errorf("No dead letter file, dropped log request %s", req.Id)
+	}
+	if err := deadLetters.write(req); err != nil {
+		loggerMetrics.Dropped(metric.LoggerDroppedQueueFull)
+		return err
+	}
+	loggerMetrics.DeadLetter(metric.LoggerDeadLetterWritten)
+	return nil
+}
+
+// Flush waits until all queued requests have been sent or the context is done.
+func Flush(ctx context.Context) error {
+	ticker := time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+	for atomic.LoadInt64(&pendingRequests) > 0 {
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%d log requests not sent: %v", atomic.LoadInt64(&pendingRequests), ctx.Err())
+		case <-ticker.C:
+		}
+	}
 	return nil
 }
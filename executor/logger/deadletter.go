@@ -0,0 +1,162 @@
+package logger
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/url"
+	"os"
+	"sync"
+)
+
+// deadLetter is the form of a log request in the dead letter file.
+type deadLetter struct {
+	Url         string         `json:"url"`
+	Data        []byte         `json:"data"`
+	ContentType string         `json:"contentType"`
+	ReqType     LogRequestType `json:"type"`
+	Id          string         `json:"id"`
+	SourceUri   string         `json:"source"`
+	ModelId     string         `json:"modelId"`
+	RequestId   string         `json:"requestId"`
+	Truncated   bool           `json:"truncated,omitempty"`
+}
+
+func toDeadLetter(req LogRequest) deadLetter {
+	dl := deadLetter{
+		ContentType: req.ContentType,
+		ReqType:     req.ReqType,
+		Id:          req.Id,
+		ModelId:     req.ModelId,
+		RequestId:   req.RequestId,
+		Truncated:   req.Truncated,
+	}
+	if req.Url != nil {
+		dl.Url = req.Url.String()
+	}
+	if req.SourceUri != nil {
+		dl.SourceUri = req.SourceUri.String()
+	}
+	if req.Bytes != nil {
+		dl.Data = *req.Bytes
+	}
+	return dl
+}
+
+func (dl deadLetter) toLogRequest() (LogRequest, error) {
+	logUrl, err := url.Parse(dl.Url)
+	if err != nil {
+		return LogRequest{}, err
+	}
+	sourceUri, err := url.Parse(dl.SourceUri)
+	if err != nil {
+		return LogRequest{}, err
+	}
+	data := dl.Data
+	return LogRequest{
+		Url:         logUrl,
+		Bytes:       &data,
+		ContentType: dl.ContentType,
+		ReqType:     dl.ReqType,
+		Id:          dl.Id,
+		SourceUri:   sourceUri,
+		ModelId:     dl.ModelId,
+		RequestId:   dl.RequestId,
+		Truncated:   dl.Truncated,
+	}, nil
+}
+
+// deadLetterFile holds log requests which could not be sent as JSON lines so they survive restarts.
+type deadLetterFile struct {
+	path string
+	mu   sync.Mutex
+}
+
+func newDeadLetterFile(path string) *deadLetterFile {
+	return &deadLetterFile{path: path}
+}
+
+func (d *deadLetterFile) write(reqs ...LogRequest) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	f, err := os.OpenFile(d.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	for _, req := range reqs {
+		if err := enc.Encode(toDeadLetter(req)); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// replay sends the requests in the file in order. Sending stops at the first failure as the sink
+// has not recovered and the remaining requests are kept in the file. The file is moved aside while
+// replaying so requests are sent again after a restart if replaying is interrupted. It returns the
+// number of requests sent.
+func (d *deadLetterFile) replay(send func(LogRequest) error) (int, error) {
+	replayPath := d.path + ".replay"
+	d.mu.Lock()
+	if _, err := os.Stat(replayPath); os.IsNotExist(err) {
+		// Requests written while replaying go to a new file
+		if err := os.Rename(d.path, replayPath); err != nil {
+			d.mu.Unlock()
+			if os.IsNotExist(err) {
+				return 0, nil
+			}
+			return 0, err
+		}
+	}
+	d.mu.Unlock()
+
+	reqs, err := readDeadLetters(replayPath)
+	if err != nil {
+		return 0, err
+	}
+	sent := 0
+	for _, req := range reqs {
+		if err := send(req); err != nil {
+			break
+		}
+		sent++
+	}
+	if sent < len(reqs) {
+		if err := d.write(reqs[sent:]...); err != nil {
+			return sent, err
+		}
+	}
+	return sent, os.Remove(replayPath)
+}
+
+func readDeadLetters(path string) ([]LogRequest, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var reqs []LogRequest
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1<<30)
+	for scanner.Scan() {
+		var dl deadLetter
+		// Skip a partially written last line
+		if err := json.Unmarshal(scanner.Bytes(), &dl); err != nil {
+			continue
+		}
+		if req, err := dl.toLogRequest(); err == nil {
+			reqs = append(reqs, req)
+		}
+	}
+	return reqs, scanner.Err()
+}
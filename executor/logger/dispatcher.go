@@ -1,32 +1,57 @@
 package logger
 
 import (
+	"time"
+
 	"github.com/go-logr/logr"
+	"github.com/seldonio/seldon-core/executor/api/metric"
 )
 
-var WorkerQueue chan chan LogRequest
+func StartDispatcher(nworkers int, log logr.Logger, sdepName string, namespace string, predictorName string, opts Options) error {
+	if err := opts.Validate(); err != nil {
+		return err
+	}
+	options = opts
+	loggerMetrics = metric.NewLoggerMetrics(sdepName, predictorName)
+	if opts.DeadLetterFile != "" {
+		deadLetters = newDeadLetterFile(opts.DeadLetterFile)
+	}
 
-func StartDispatcher(nworkers int, log logr.Logger, sdepName string, namespace string, predictorName string) {
-	// First, initialize the channel we are going to put the workers' work channels into.
-	WorkerQueue = make(chan chan LogRequest, nworkers)
+	// Requests queued before the dispatcher started are kept
+	queue := make(chan LogRequest, opts.QueueSize)
+	loggerMetrics.QueueSize(opts.QueueSize)
+	for len(WorkQueue) > 0 {
+		queue <- <-WorkQueue
+	}
+	WorkQueue = queue
 
 	// Now, create all of our workers.
 	for i := 0; i < nworkers; i++ {
 		log.Info("Starting", "worker", i+1)
-		worker := NewWorker(i+1, WorkerQueue, log, sdepName, namespace, predictorName)
+		worker := NewWorker(i+1, WorkQueue, log, sdepName, namespace, predictorName)
 		worker.Start()
 	}
 
-	go func() {
-		for {
-			select {
-			case work := <-WorkQueue:
-				go func() {
-					worker := <-WorkerQueue
+	if deadLetters != nil {
+		go replayDeadLetters(NewWorker(0, nil, log, sdepName, namespace, predictorName), opts.ReplayInterval)
+	}
+	return nil
+}
 
-					worker <- work
-				}()
-			}
+// replayDeadLetters periodically tries to send the requests in the dead letter file.
+func replayDeadLetters(w Worker, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		sent, err := deadLetters.replay(w.sendCloudEvent)
+		for i := 0; i < sent; i++ {
+			loggerMetrics.DeadLetter(metric.LoggerDeadLetterReplayed)
+		}
+		if err != nil {
+			w.Log.Error(err, "Failed to replay dead letter file")
 		}
-	}()
+		if sent > 0 {
+			w.Log.Info("Replayed dead letters", "sent", sent)
+		}
+	}
 }
@@ -0,0 +1,161 @@
+package logger
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/cloudevents/sdk-go"
+)
+
+const (
+	FileSinkMaxSizeParam  = "max_size_mb"
+	FileSinkMaxFilesParam = "max_files"
+
+	defaultFileSinkMaxSizeMB = 100
+	defaultFileSinkMaxFiles  = 5
+)
+
+// fileSink appends events to a file as JSON lines in the structured mode of CloudEvents. When the
+// file would grow past its maximum size it is rotated to path.1, path.1 to path.2 and so on, keeping
+// at most maxFiles rotated files.
+type fileSink struct {
+	path     string
+	maxSize  int64
+	maxFiles int
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+}
+
+func queryInt(u *url.URL, param string, defaultValue int) (int, error) {
+	s := u.Query().Get(param)
+	if s == "" {
+		return defaultValue, nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil || v < 0 {
+		return 0, fmt.Errorf("Invalid %s %q in logger URL", param, s)
+	}
+	return v, nil
+}
+
+func newFileSink(u *url.URL) (Sink, error) {
+	maxSizeMB, err := queryInt(u, FileSinkMaxSizeParam, defaultFileSinkMaxSizeMB)
+	if err != nil {
+		return nil, err
+	}
+	maxFiles, err := queryInt(u, FileSinkMaxFilesParam, defaultFileSinkMaxFiles)
+	if err != nil {
+		return nil, err
+	}
+	s := &fileSink{path: u.Path, maxSize: int64(maxSizeMB) << 20, maxFiles: maxFiles}
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *fileSink) open() error {
+	f, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	s.file = f
+	s.size = info.Size()
+	return nil
+}
+
+func (s *fileSink) rotatedPath(n int) string {
+	return s.path + "." + strconv.Itoa(n)
+}
+
+func (s *fileSink) rotate() error {
+	if err := s.file.Close(); err != nil {
+		return err
+	}
+	if s.maxFiles == 0 {
+		if err := os.Remove(s.path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return s.open()
+	}
+	for n := s.maxFiles - 1; n > 0; n-- {
+		if err := os.Rename(s.rotatedPath(n), s.rotatedPath(n+1)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	if err := os.Rename(s.path, s.rotatedPath(1)); err != nil {
+		return err
+	}
+	return s.open()
+}
+
+// fileEvent is the structured CloudEvents form of an event. JSON payloads are written as the data
+// so the file can be read with JSON tools, other payloads are base64 encoded.
+func fileEvent(event cloudevents.Event) ([]byte, error) {
+	record := map[string]interface{}{
+		"specversion":     event.SpecVersion(),
+		"id":              event.ID(),
+		"type":            event.Type(),
+		"source":          event.Source(),
+		"time":            event.Time().UTC().Format(time.RFC3339Nano),
+		"datacontenttype": event.DataContentType(),
+	}
+	for name, value := range event.Extensions() {
+		record[name] = value
+	}
+	data, _ := event.Data.([]byte)
+	if json.Valid(data) {
+		record["data"] = json.RawMessage(data)
+	} else {
+		record["data_base64"] = data
+	}
+	return json.Marshal(record)
+}
+
+func (s *fileSink) Send(event cloudevents.Event) error {
+	line, err := fileEvent(event)
+	if err != nil {
+		return fmt.Errorf("while encoding event: %s", err)
+	}
+	line = append(line, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.file == nil {
+		if err := s.open(); err != nil {
+			return err
+		}
+	}
+	if s.size > 0 && s.size+int64(len(line)) > s.maxSize {
+		if err := s.rotate(); err != nil {
+			s.file = nil
+			return fmt.Errorf("while rotating %s: %s", s.path, err)
+		}
+	}
+	n, err := s.file.Write(line)
+	s.size += int64(n)
+	return err
+}
+
+func (s *fileSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	s.file = nil
+	return err
+}
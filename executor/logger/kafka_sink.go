@@ -0,0 +1,88 @@
+package logger
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/cloudevents/sdk-go"
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+)
+
+// How long a payload log may take to be delivered to Kafka.
+var kafkaDeliveryTimeout = 60 * time.Second
+
+// kafkaSink produces events to a topic in the binary mode of the CloudEvents Kafka binding. The
+// attributes are sent as ce_ headers and the payload is the message value. Messages are keyed by
+// the request id so the logs of a request go to the same partition.
+type kafkaSink struct {
+	producer *kafka.Producer
+	topic    string
+}
+
+func kafkaTopic(u *url.URL) string {
+	return strings.Trim(u.Path, "/")
+}
+
+func newKafkaSink(u *url.URL) (Sink, error) {
+	p, err := kafka.NewProducer(&kafka.ConfigMap{
+		"bootstrap.servers":  u.Host,
+		"message.timeout.ms": int(kafkaDeliveryTimeout / time.Millisecond),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("while creating kafka producer: %s", err)
+	}
+	return &kafkaSink{producer: p, topic: kafkaTopic(u)}, nil
+}
+
+func kafkaHeaders(event cloudevents.Event) []kafka.Header {
+	headers := []kafka.Header{
+		{Key: "ce_specversion", Value: []byte(event.SpecVersion())},
+		{Key: "ce_id", Value: []byte(event.ID())},
+		{Key: "ce_type", Value: []byte(event.Type())},
+		{Key: "ce_source", Value: []byte(event.Source())},
+		{Key: "ce_time", Value: []byte(event.Time().UTC().Format(time.RFC3339Nano))},
+		{Key: "content-type", Value: []byte(event.DataContentType())},
+	}
+	for name, value := range event.Extensions() {
+		headers = append(headers, kafka.Header{Key: "ce_" + name, Value: []byte(fmt.Sprint(value))})
+	}
+	return headers
+}
+
+// Send waits for the message to be delivered so failures are retried by the worker.
+func (s *kafkaSink) Send(event cloudevents.Event) error {
+	data, ok := event.Data.([]byte)
+	if !ok {
+		return fmt.Errorf("Event %s has no data", event.ID())
+	}
+	var key []byte
+	if requestId, ok := event.Extensions()[RequestIdAttr]; ok {
+		key = []byte(fmt.Sprint(requestId))
+	}
+	delivery := make(chan kafka.Event, 1)
+	err := s.producer.Produce(&kafka.Message{
+		TopicPartition: kafka.TopicPartition{Topic: &s.topic, Partition: kafka.PartitionAny},
+		Key:            key,
+		Value:          data,
+		Headers:        kafkaHeaders(event),
+	}, delivery)
+	if err != nil {
+		return fmt.Errorf("while producing event: %s", err)
+	}
+	e := <-delivery
+	if m, ok := e.(*kafka.Message); ok && m.TopicPartition.Error != nil {
+		return fmt.Errorf("while delivering event: %s", m.TopicPartition.Error)
+	}
+	return nil
+}
+
+func (s *kafkaSink) Close() error {
+	remaining := s.producer.Flush(int(kafkaDeliveryTimeout / time.Millisecond))
+	s.producer.Close()
+	if remaining > 0 {
+		return fmt.Errorf("%d events not delivered", remaining)
+	}
+	return nil
+}
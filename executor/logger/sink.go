@@ -0,0 +1,127 @@
+package logger
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sync"
+
+	"github.com/cloudevents/sdk-go"
+)
+
+const (
+	SinkSchemeHttp  = "http"
+	SinkSchemeHttps = "https"
+	SinkSchemeKafka = "kafka"
+	SinkSchemeFile  = "file"
+)
+
+// Sink sends payload log events to a destination. Send is called concurrently by the workers.
+type Sink interface {
+	Send(event cloudevents.Event) error
+	Close() error
+}
+
+// Sinks are created on first use and shared by the workers, keyed by sinkKey.
+var sinks sync.Map
+
+// CheckSinkUrl returns an error if there is no sink for the scheme of a logger URL.
+func CheckSinkUrl(u *url.URL) error {
+	switch u.Scheme {
+	case SinkSchemeHttp, SinkSchemeHttps:
+	case SinkSchemeKafka:
+		if u.Host == "" || kafkaTopic(u) == "" {
+			return fmt.Errorf("Kafka logger URL %s must have the form kafka://broker/topic", u.String())
+		}
+	case SinkSchemeFile:
+		if u.Path == "" {
+			return fmt.Errorf("File logger URL %s must have the form file:///path", u.String())
+		}
+	default:
+		return fmt.Errorf("Unsupported logger URL scheme %s", u.Scheme)
+	}
+	return nil
+}
+
+// sinkKey identifies the destination of a logger URL. Options in the query are only read when
+// the sink is created so they don't create a second sink for the same destination.
+func sinkKey(u *url.URL) string {
+	return u.Scheme + "://" + u.Host + u.Path
+}
+
+func getSink(u *url.URL) (Sink, error) {
+	key := sinkKey(u)
+	if sink, ok := sinks.Load(key); ok {
+		return sink.(Sink), nil
+	}
+	sink, err := newSink(u)
+	if err != nil {
+		return nil, err
+	}
+	if existing, loaded := sinks.LoadOrStore(key, sink); loaded {
+		sink.Close()
+		return existing.(Sink), nil
+	}
+	return sink, nil
+}
+
+func newSink(u *url.URL) (Sink, error) {
+	if err := CheckSinkUrl(u); err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case SinkSchemeKafka:
+		return newKafkaSink(u)
+	case SinkSchemeFile:
+		return newFileSink(u)
+	default:
+		return newHttpSink(u)
+	}
+}
+
+// CloseSinks flushes and closes all sinks. It should be called once payload logs have been flushed.
+func CloseSinks() error {
+	var closeErr error
+	sinks.Range(func(key, value interface{}) bool {
+		if err := value.(Sink).Close(); err != nil && closeErr == nil {
+			closeErr = fmt.Errorf("while closing sink %s: %s", key, err)
+		}
+		sinks.Delete(key)
+		return true
+	})
+	return closeErr
+}
+
+// httpSink posts events to an HTTP endpoint in binary mode.
+type httpSink struct {
+	client cloudevents.Client
+}
+
+func newHttpSink(u *url.URL) (Sink, error) {
+	t, err := cloudevents.NewHTTPTransport(
+		cloudevents.WithTarget(u.String()),
+		cloudevents.WithEncoding(cloudevents.HTTPBinaryV1),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("while creating http transport: %s", err)
+	}
+	c, err := cloudevents.NewClient(t,
+		cloudevents.WithTimeNow(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("while creating new cloudevents client: %s", err)
+	}
+	return &httpSink{client: c}, nil
+}
+
+func (s *httpSink) Send(event cloudevents.Event) error {
+	ctx := cloudevents.ContextWithEncoding(context.Background(), cloudevents.Binary)
+	if _, _, err := s.client.Send(ctx, event); err != nil {
+		return fmt.Errorf("while sending event: %s", err)
+	}
+	return nil
+}
+
+func (s *httpSink) Close() error {
+	return nil
+}
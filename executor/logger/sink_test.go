@@ -0,0 +1,126 @@
+package logger
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func readEvents(t *testing.T, path string) []map[string]interface{} {
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var events []map[string]interface{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var event map[string]interface{}
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			t.Fatal(err)
+		}
+		events = append(events, event)
+	}
+	return events
+}
+
+func TestCheckSinkUrl(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	for rawUrl, valid := range map[string]bool{
+		"http://logger.seldon/":              true,
+		"https://logger.seldon/":             true,
+		"kafka://broker:9092/logs":           true,
+		"kafka://broker:9092/":               false,
+		"file:///tmp/logs.jsonl":             true,
+		"file:///tmp/logs.jsonl?max_files=2": true,
+		"ftp://logger.seldon/":               false,
+	} {
+		u, err := url.Parse(rawUrl)
+		g.Expect(err).To(BeNil())
+		if valid {
+			g.Expect(CheckSinkUrl(u)).To(BeNil(), rawUrl)
+		} else {
+			g.Expect(CheckSinkUrl(u)).ToNot(BeNil(), rawUrl)
+		}
+	}
+}
+
+func TestFileSink(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	dir, err := ioutil.TempDir("", "sink")
+	g.Expect(err).To(BeNil())
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "logs.jsonl")
+	defer CloseSinks()
+
+	w := createTestWorker()
+	g.Expect(w.sendCloudEvent(createLogRequest(t, "file://"+path, "1"))).To(BeNil())
+
+	events := readEvents(t, path)
+	g.Expect(events).To(HaveLen(1))
+	g.Expect(events[0]["id"]).To(Equal("1"))
+	g.Expect(events[0]["type"]).To(Equal(CEInferenceRequest))
+	g.Expect(events[0][ModelIdAttr]).To(Equal("model"))
+	g.Expect(events[0][RequestIdAttr]).To(Equal("puid"))
+	g.Expect(events[0][InferenceServiceNameAttr]).To(Equal("dep"))
+	g.Expect(events[0][EndpointAttr]).To(Equal("predictor"))
+	g.Expect(events[0]["data"]).To(Equal(map[string]interface{}{"data": map[string]interface{}{"ndarray": []interface{}{1.0, 2.0}}}))
+}
+
+func TestFileSinkRotation(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	dir, err := ioutil.TempDir("", "sink")
+	g.Expect(err).To(BeNil())
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "logs.jsonl")
+
+	u, _ := url.Parse("file://" + path + "?max_files=2")
+	sink, err := newFileSink(u)
+	g.Expect(err).To(BeNil())
+	defer sink.Close()
+	// Rotate after every event
+	sink.(*fileSink).maxSize = 1
+
+	w := createTestWorker()
+	for _, id := range []string{"1", "2", "3", "4"} {
+		event, err := w.newCloudEvent(createLogRequest(t, u.String(), id))
+		g.Expect(err).To(BeNil())
+		g.Expect(sink.Send(event)).To(BeNil())
+	}
+
+	g.Expect(readEvents(t, path)[0]["id"]).To(Equal("4"))
+	g.Expect(readEvents(t, path+".1")[0]["id"]).To(Equal("3"))
+	g.Expect(readEvents(t, path+".2")[0]["id"]).To(Equal("2"))
+	_, err = os.Stat(path + ".3")
+	g.Expect(os.IsNotExist(err)).To(BeTrue())
+}
+
+func TestKafkaHeaders(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	w := createTestWorker()
+	event, err := w.newCloudEvent(createLogRequest(t, "kafka://broker:9092/logs", "1"))
+	g.Expect(err).To(BeNil())
+
+	headers := map[string]string{}
+	for _, h := range kafkaHeaders(event) {
+		headers[h.Key] = string(h.Value)
+	}
+	g.Expect(headers["ce_id"]).To(Equal("1"))
+	g.Expect(headers["ce_type"]).To(Equal(CEInferenceRequest))
+	g.Expect(headers["ce_specversion"]).To(Equal("1.0"))
+	g.Expect(headers["ce_"+ModelIdAttr]).To(Equal("model"))
+	g.Expect(headers["ce_"+RequestIdAttr]).To(Equal("puid"))
+	g.Expect(headers["ce_"+InferenceServiceNameAttr]).To(Equal("dep"))
+	g.Expect(headers["ce_"+EndpointAttr]).To(Equal("predictor"))
+	g.Expect(headers["content-type"]).To(Equal("application/json"))
+}
@@ -21,4 +21,6 @@ type LogRequest struct {
 	SourceUri   *url.URL
 	ModelId     string
 	RequestId   string
+	// The payload was cut to the maximum size of the logger
+	Truncated bool
 }
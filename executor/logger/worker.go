@@ -1,12 +1,12 @@
 package logger
 
 import (
-	"context"
 	"errors"
 	"fmt"
 	"github.com/cloudevents/sdk-go"
 	"github.com/cloudevents/sdk-go/pkg/cloudevents/transport"
 	"github.com/go-logr/logr"
+	"github.com/seldonio/seldon-core/executor/api/metric"
 	"net/http"
 	"time"
 )
@@ -21,59 +21,48 @@ const (
 	InferenceServiceNameAttr = "inferenceservicename"
 	NamespaceAttr            = "namespace"
 	EndpointAttr             = "endpoint"
+	TruncatedAttr            = "truncated"
 )
 
-// NewWorker creates, and returns a new Worker object. Its only argument
-// is a channel that the worker can add itself to whenever it is done its
-// work.
-func NewWorker(id int, workerQueue chan chan LogRequest, log logr.Logger, sdepName string, namespace string, predictorName string) Worker {
+// Longest time to wait between attempts to send a log request.
+var maxRetryBackoff = 30 * time.Second
+
+// NewWorker creates, and returns a new Worker object which sends the
+// log requests it receives from the work queue.
+func NewWorker(id int, workQueue <-chan LogRequest, log logr.Logger, sdepName string, namespace string, predictorName string) Worker {
 	// Create, and return the worker.
 	return Worker{
-		Log:         log,
-		ID:          id,
-		Work:        make(chan LogRequest),
-		WorkerQueue: workerQueue,
-		QuitChan:    make(chan bool),
+		Log:      log,
+		ID:       id,
+		Work:     workQueue,
+		QuitChan: make(chan bool),
 		Client: http.Client{
 			Timeout: 60 * time.Second,
 		},
-		CeCtx:         cloudevents.ContextWithEncoding(context.Background(), cloudevents.Binary),
 		SdepName:      sdepName,
 		Namespace:     namespace,
 		PredictorName: predictorName,
+		Retries:       options.Retries,
+		RetryBackoff:  options.RetryBackoff,
 	}
 }
 
 type Worker struct {
 	Log           logr.Logger
 	ID            int
-	Work          chan LogRequest
-	WorkerQueue   chan chan LogRequest
+	Work          <-chan LogRequest
 	QuitChan      chan bool
 	Client        http.Client
-	CeCtx         context.Context
 	CeTransport   transport.Transport
 	SdepName      string
 	Namespace     string
 	PredictorName string
+	Retries       int
+	RetryBackoff  time.Duration
 }
 
-func (W *Worker) sendCloudEvent(logReq LogRequest) error {
-
-	t, err := cloudevents.NewHTTPTransport(
-		cloudevents.WithTarget(logReq.Url.String()),
-		cloudevents.WithEncoding(cloudevents.HTTPBinaryV1),
-	)
-
-	if err != nil {
-		return fmt.Errorf("while creating http transport: %s", err)
-	}
-	c, err := cloudevents.NewClient(t,
-		cloudevents.WithTimeNow(),
-	)
-	if err != nil {
-		return fmt.Errorf("while creating new cloudevents client: %s", err)
-	}
+// newCloudEvent creates the event for a log request with the attributes shared by all sinks.
+func (W *Worker) newCloudEvent(logReq LogRequest) (cloudevents.Event, error) {
 	event := cloudevents.NewEvent(cloudevents.VersionV1)
 	event.SetID(logReq.Id)
 	if logReq.ReqType == InferenceRequest {
@@ -83,7 +72,7 @@ func (W *Worker) sendCloudEvent(logReq LogRequest) error {
 	} else if logReq.ReqType == InferenceFeedback {
 		event.SetType(CEFeedback)
 	} else {
-		return fmt.Errorf("Incorrect log request type: %s", errors.New("Incorrect log request type"))
+		return event, fmt.Errorf("Incorrect log request type: %s", errors.New("Incorrect log request type"))
 	}
 
 	event.SetExtension(ModelIdAttr, logReq.ModelId)
@@ -92,19 +81,72 @@ func (W *Worker) sendCloudEvent(logReq LogRequest) error {
 	event.SetExtension(NamespaceAttr, W.Namespace)
 	//use 'endpoint' for the header to align with kfserving - https://github.com/kubeflow/kfserving/pull/699/files#r385360114
 	event.SetExtension(EndpointAttr, W.PredictorName)
+	if logReq.Truncated {
+		event.SetExtension(TruncatedAttr, "true")
+	}
 
 	event.SetSource(logReq.SourceUri.String())
+	event.SetTime(time.Now())
 	event.SetDataContentType(logReq.ContentType)
 	if err := event.SetData(*logReq.Bytes); err != nil {
-		return fmt.Errorf("while setting cloudevents data: %s", err)
+		return event, fmt.Errorf("while setting cloudevents data: %s", err)
 	}
+	return event, nil
+}
 
-	//fmt.Printf("%+v\n", event)
+// sendCloudEvent sends a log request to the sink for the scheme of its URL.
+func (W *Worker) sendCloudEvent(logReq LogRequest) error {
+	event, err := W.newCloudEvent(logReq)
+	if err != nil {
+		return err
+	}
+	sink, err := getSink(logReq.Url)
+	if err != nil {
+		return err
+	}
+	return sink.Send(event)
+}
 
-	if _, _, err := c.Send(W.CeCtx, event); err != nil {
-		return fmt.Errorf("while sending event: %s", err)
+// sendWithRetries sends a log request, retrying with exponential backoff.
+func (w *Worker) sendWithRetries(logReq LogRequest) error {
+	backoff := w.RetryBackoff
+	for attempt := 0; ; attempt++ {
+		err := w.sendCloudEvent(logReq)
+		if err == nil {
+			return nil
+		}
+		loggerMetrics.Failure()
+		if attempt >= w.Retries {
+			return err
+		}
+		w.Log.Info("Retrying log request", "URL", logReq.Url.String(), "attempt", attempt+1, "error", err.Error())
+		time.Sleep(backoff)
+		backoff *= 2
+		if backoff > maxRetryBackoff {
+			backoff = maxRetryBackoff
+		}
 	}
-	return nil
+}
+
+// process sends a log request. Requests which can't be sent are written to the dead letter file
+// if there is one.
+func (w *Worker) process(logReq LogRequest) {
+	err := w.sendWithRetries(logReq)
+	if err == nil {
+		return
+	}
+	if deadLetters == nil {
+		w.Log.Error(err, "Failed to send log", "URL", logReq.Url.String())
+		loggerMetrics.Dropped(metric.LoggerDroppedSendFailed)
+		return
+	}
+	w.Log.Error(err, "Failed to send log, writing to dead letter file", "URL", logReq.Url.String())
+	if err := deadLetters.write(logReq); err != nil {
+		w.Log.Error(err, "Failed to write log to dead letter file")
+		loggerMetrics.Dropped(metric.LoggerDroppedSendFailed)
+		return
+	}
+	loggerMetrics.DeadLetter(metric.LoggerDeadLetterWritten)
 }
 
 // This function "starts" the worker by starting a goroutine, that is
@@ -112,21 +154,23 @@ func (W *Worker) sendCloudEvent(logReq LogRequest) error {
 func (w *Worker) Start() {
 	go func() {
 		for {
-			// Add ourselves into the worker queue.
-			w.WorkerQueue <- w.Work
-
 			select {
-			case work := <-w.Work:
+			case work, ok := <-w.Work:
+				if !ok {
+					return
+				}
+				loggerMetrics.QueueDepth(len(w.Work))
 				// Receive a work request.
-				fmt.Printf("worker%d: Received work request for %s\n", w.ID, work.Url.String())
+				w.Log.V(1).Info("Received work request", "worker", w.ID, "URL", work.Url.String())
 
-				if err := w.sendCloudEvent(work); err != nil {
-					w.Log.Error(err, "Failed to send log", "URL", work.Url.String())
-				}
+				loggerMetrics.InFlight(1)
+				w.process(work)
+				loggerMetrics.InFlight(-1)
+				requestDone()
 
 			case <-w.QuitChan:
 				// We have been asked to stop.
-				fmt.Printf("worker %d stopping\n", w.ID)
+				w.Log.Info("Worker stopping", "worker", w.ID)
 				return
 			}
 		}
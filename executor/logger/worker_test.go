@@ -0,0 +1,158 @@
+package logger
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
+)
+
+func createLogRequest(t *testing.T, sink string, id string) LogRequest {
+	logUrl, err := url.Parse(sink)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sourceUri, _ := url.Parse("http://localhost:8000/")
+	data := []byte(`{"data":{"ndarray":[1,2]}}`)
+	return LogRequest{
+		Url:         logUrl,
+		Bytes:       &data,
+		ContentType: "application/json",
+		ReqType:     InferenceRequest,
+		Id:          id,
+		SourceUri:   sourceUri,
+		ModelId:     "model",
+		RequestId:   "puid",
+	}
+}
+
+// createSink returns a sink which fails the first failures requests and records the ids of events.
+func createSink(failures int32) (*httptest.Server, *int32, chan string) {
+	var calls int32
+	ids := make(chan string, 100)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) <= failures {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		ids <- r.Header.Get(CloudEventsIdHeader)
+		w.WriteHeader(http.StatusOK)
+	}))
+	return server, &calls, ids
+}
+
+func createTestWorker() Worker {
+	w := NewWorker(1, nil, logf.Log.WithName("test"), "dep", "default", "predictor")
+	w.RetryBackoff = time.Millisecond
+	return w
+}
+
+func TestWorkerRetries(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	sink, calls, ids := createSink(2)
+	defer sink.Close()
+
+	w := createTestWorker()
+	w.Retries = 2
+	g.Expect(w.sendWithRetries(createLogRequest(t, sink.URL, "1"))).To(BeNil())
+	g.Expect(atomic.LoadInt32(calls)).To(Equal(int32(3)))
+	g.Expect(<-ids).To(Equal("1"))
+
+	// Without retries the first failure is returned
+	w.Retries = 0
+	atomic.StoreInt32(calls, 0)
+	g.Expect(w.sendWithRetries(createLogRequest(t, sink.URL, "2"))).ToNot(BeNil())
+	g.Expect(atomic.LoadInt32(calls)).To(Equal(int32(1)))
+}
+
+func TestDeadLetterReplay(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	dir, err := ioutil.TempDir("", "deadletters")
+	g.Expect(err).To(BeNil())
+	defer os.RemoveAll(dir)
+
+	sink, calls, ids := createSink(0)
+	defer sink.Close()
+
+	d := newDeadLetterFile(filepath.Join(dir, "deadletters.jsonl"))
+	g.Expect(d.write(createLogRequest(t, sink.URL, "1"), createLogRequest(t, sink.URL, "2"))).To(BeNil())
+	g.Expect(d.write(createLogRequest(t, sink.URL, "3"))).To(BeNil())
+
+	// Replaying stops at the first failure and keeps the remaining requests
+	w := createTestWorker()
+	sent, err := d.replay(func(req LogRequest) error {
+		if req.Id == "2" {
+			return errors.New("sink down")
+		}
+		return w.sendCloudEvent(req)
+	})
+	g.Expect(err).To(BeNil())
+	g.Expect(sent).To(Equal(1))
+	g.Expect(<-ids).To(Equal("1"))
+
+	sent, err = d.replay(w.sendCloudEvent)
+	g.Expect(err).To(BeNil())
+	g.Expect(sent).To(Equal(2))
+	g.Expect(<-ids).To(Equal("2"))
+	g.Expect(<-ids).To(Equal("3"))
+	g.Expect(atomic.LoadInt32(calls)).To(Equal(int32(3)))
+
+	// Nothing is left to replay
+	sent, err = d.replay(w.sendCloudEvent)
+	g.Expect(err).To(BeNil())
+	g.Expect(sent).To(Equal(0))
+}
+
+func TestDeadLetterRoundTrip(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	dir, err := ioutil.TempDir("", "deadletters")
+	g.Expect(err).To(BeNil())
+	defer os.RemoveAll(dir)
+
+	req := createLogRequest(t, "http://sink:8080/logs", "1")
+	d := newDeadLetterFile(filepath.Join(dir, "deadletters.jsonl"))
+	g.Expect(d.write(req)).To(BeNil())
+	reqs, err := readDeadLetters(d.path)
+	g.Expect(err).To(BeNil())
+	g.Expect(reqs).To(Equal([]LogRequest{req}))
+}
+
+func TestQueueFullDrop(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	savedQueue, savedOptions := WorkQueue, options
+	defer func() { WorkQueue, options = savedQueue, savedOptions }()
+	WorkQueue = make(chan LogRequest, 1)
+	options.FullQueuePolicy = FullQueueDrop
+
+	g.Expect(QueueLogRequest(createLogRequest(t, "http://sink", "1"))).To(BeNil())
+	g.Expect(QueueLogRequest(createLogRequest(t, "http://sink", "2"))).ToNot(BeNil())
+	g.Expect(len(WorkQueue)).To(Equal(1))
+	<-WorkQueue
+	requestDone()
+}
+
+func TestOptionsValidate(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	g.Expect(DefaultOptions.Validate()).To(BeNil())
+	opts := DefaultOptions
+	opts.FullQueuePolicy = FullQueueSpill
+	g.Expect(opts.Validate()).ToNot(BeNil())
+	opts.DeadLetterFile = "/tmp/deadletters.jsonl"
+	g.Expect(opts.Validate()).To(BeNil())
+	opts.FullQueuePolicy = "wait"
+	g.Expect(opts.Validate()).ToNot(BeNil())
+}
@@ -0,0 +1,265 @@
+package predictor
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/seldonio/seldon-core/executor/api/grpc/seldon/proto"
+	"github.com/seldonio/seldon-core/executor/api/payload"
+	v1 "github.com/seldonio/seldon-core/operator/apis/machinelearning.seldon.io/v1"
+)
+
+const defaultEpsilon = 0.1
+
+// BanditState holds the number of feedback calls and the total reward for each arm of a router.
+type BanditState struct {
+	Tries   []float64 `json:"tries"`
+	Rewards []float64 `json:"rewards"`
+}
+
+func newBanditState(arms int) *BanditState {
+	return &BanditState{Tries: make([]float64, arms), Rewards: make([]float64, arms)}
+}
+
+func (s *BanditState) copy() *BanditState {
+	c := newBanditState(len(s.Tries))
+	copy(c.Tries, s.Tries)
+	copy(c.Rewards, s.Rewards)
+	return c
+}
+
+// BanditStore keeps the state of multi-armed bandit routers.
+type BanditStore interface {
+	// Get returns the state of a router with the given number of arms.
+	Get(router string, arms int) (*BanditState, error)
+	// Reward records the reward for a route taken by a router.
+	Reward(router string, arms int, arm int, reward float64) error
+}
+
+// MemoryBanditStore keeps router state in memory so it is lost on restart.
+type MemoryBanditStore struct {
+	mu     sync.Mutex
+	states map[string]*BanditState
+}
+
+func NewMemoryBanditStore() *MemoryBanditStore {
+	return &MemoryBanditStore{states: make(map[string]*BanditState)}
+}
+
+// state returns the stored state resetting it if the number of arms has changed. Callers hold the lock.
+func (m *MemoryBanditStore) state(router string, arms int) *BanditState {
+	s, ok := m.states[router]
+	if !ok || len(s.Tries) != arms || len(s.Rewards) != arms {
+		s = newBanditState(arms)
+		m.states[router] = s
+	}
+	return s
+}
+
+func (m *MemoryBanditStore) Get(router string, arms int) (*BanditState, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.state(router, arms).copy(), nil
+}
+
+func (m *MemoryBanditStore) Reward(router string, arms int, arm int, reward float64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s := m.state(router, arms)
+	s.Tries[arm]++
+	s.Rewards[arm] += reward
+	return nil
+}
+
+// FileBanditStore keeps router state in memory and writes it to a JSON file per router
+// so it survives executor restarts.
+type FileBanditStore struct {
+	MemoryBanditStore
+	dir string
+}
+
+func NewFileBanditStore(dir string) (*FileBanditStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &FileBanditStore{MemoryBanditStore: *NewMemoryBanditStore(), dir: dir}, nil
+}
+
+func (f *FileBanditStore) path(router string) string {
+	return filepath.Join(f.dir, router+".json")
+}
+
+// load reads the state of a router from disk the first time it is used. Callers hold the lock.
+func (f *FileBanditStore) load(router string) error {
+	if _, ok := f.states[router]; ok {
+		return nil
+	}
+	data, err := ioutil.ReadFile(f.path(router))
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	s := &BanditState{}
+	if err := json.Unmarshal(data, s); err != nil {
+		return err
+	}
+	f.states[router] = s
+	return nil
+}
+
+func (f *FileBanditStore) Get(router string, arms int) (*BanditState, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.load(router); err != nil {
+		return nil, err
+	}
+	return f.state(router, arms).copy(), nil
+}
+
+func (f *FileBanditStore) Reward(router string, arms int, arm int, reward float64) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.load(router); err != nil {
+		return err
+	}
+	s := f.state(router, arms)
+	s.Tries[arm]++
+	s.Rewards[arm] += reward
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	// Write to a temporary file and rename so a crash never leaves a partial state file
+	tmp := f.path(router) + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, f.path(router))
+}
+
+var banditStore BanditStore = NewMemoryBanditStore()
+
+// SetBanditStore sets the store used by all multi-armed bandit routers.
+func SetBanditStore(store BanditStore) {
+	banditStore = store
+}
+
+func isBanditRouter(node *v1.PredictiveUnit) bool {
+	return node.Implementation != nil && (*node.Implementation == v1.EPSILON_GREEDY || *node.Implementation == v1.THOMPSON_SAMPLING)
+}
+
+func (p *PredictorProcess) banditRouter(node *v1.PredictiveUnit) (int, error) {
+	state, err := banditStore.Get(node.Name, len(node.Children))
+	if err != nil {
+		return 0, err
+	}
+	switch *node.Implementation {
+	case v1.EPSILON_GREEDY:
+		epsilon := defaultEpsilon
+		for _, param := range node.Parameters {
+			if param.Name == "epsilon" {
+				if epsilon, err = strconv.ParseFloat(param.Value, 64); err != nil {
+					return 0, err
+				}
+			}
+		}
+		return epsilonGreedy(state, epsilon), nil
+	default:
+		return thompsonSampling(state), nil
+	}
+}
+
+// epsilonGreedy explores a random arm with probability epsilon and otherwise exploits the arm
+// with the best mean reward so far.
+func epsilonGreedy(state *BanditState, epsilon float64) int {
+	if rand.Float64() < epsilon {
+		return rand.Intn(len(state.Tries))
+	}
+	best := 0
+	bestMean := math.Inf(-1)
+	for i := range state.Tries {
+		mean := 0.0
+		if state.Tries[i] > 0 {
+			mean = state.Rewards[i] / state.Tries[i]
+		}
+		if mean > bestMean {
+			best, bestMean = i, mean
+		}
+	}
+	return best
+}
+
+// thompsonSampling samples the success rate of each arm from a Beta posterior with a uniform prior
+// and picks the highest sample. Rewards are treated as success probabilities in [0,1].
+func thompsonSampling(state *BanditState) int {
+	best := 0
+	bestSample := math.Inf(-1)
+	for i := range state.Tries {
+		successes := math.Min(math.Max(state.Rewards[i], 0), state.Tries[i])
+		sample := sampleBeta(1+successes, 1+state.Tries[i]-successes)
+		if sample > bestSample {
+			best, bestSample = i, sample
+		}
+	}
+	return best
+}
+
+func sampleBeta(alpha float64, beta float64) float64 {
+	x := sampleGamma(alpha)
+	y := sampleGamma(beta)
+	return x / (x + y)
+}
+
+// sampleGamma draws from Gamma(shape, 1) using the Marsaglia and Tsang method.
+func sampleGamma(shape float64) float64 {
+	if shape < 1 {
+		return sampleGamma(shape+1) * math.Pow(rand.Float64(), 1/shape)
+	}
+	d := shape - 1.0/3
+	c := 1 / math.Sqrt(9*d)
+	for {
+		x := rand.NormFloat64()
+		v := 1 + c*x
+		if v <= 0 {
+			continue
+		}
+		v = v * v * v
+		u := rand.Float64()
+		if math.Log(u) < 0.5*x*x+d-d*v+d*math.Log(v) {
+			return d * v
+		}
+	}
+}
+
+// rewardBandit updates the state of a bandit router with the reward of a feedback message.
+func (p *PredictorProcess) rewardBandit(node *v1.PredictiveUnit, route int, msg payload.SeldonPayload) error {
+	if route < 0 || route >= len(node.Children) {
+		return nil
+	}
+	var reward float64
+	switch v := msg.GetPayload().(type) {
+	case *proto.Feedback:
+		reward = float64(v.GetReward())
+	case []byte:
+		var fm proto.Feedback
+		if err := jsonpb.UnmarshalString(string(v), &fm); err != nil {
+			return err
+		}
+		reward = float64(fm.GetReward())
+	default:
+		return fmt.Errorf("Can't read reward from payload type %T", v)
+	}
+	if *node.Implementation == v1.THOMPSON_SAMPLING {
+		reward = math.Min(math.Max(reward, 0), 1)
+	}
+	return banditStore.Reward(node.Name, len(node.Children), route, reward)
+}
@@ -0,0 +1,104 @@
+package predictor
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/golang/protobuf/jsonpb"
+	. "github.com/onsi/gomega"
+	"github.com/seldonio/seldon-core/executor/api/grpc/seldon/proto"
+	"github.com/seldonio/seldon-core/executor/api/payload"
+	"github.com/seldonio/seldon-core/operator/apis/machinelearning.seldon.io/v1"
+)
+
+func createBanditGraph(impl v1.PredictiveUnitImplementation) *v1.PredictiveUnit {
+	model := v1.MODEL
+	graph := &v1.PredictiveUnit{
+		Name:           "bandit",
+		Implementation: &impl,
+		Parameters:     []v1.Parameter{{Name: "epsilon", Value: "0", Type: v1.FLOAT}},
+	}
+	for _, name := range []string{"a", "b"} {
+		graph.Children = append(graph.Children, v1.PredictiveUnit{
+			Name: name,
+			Type: &model,
+			Endpoint: &v1.Endpoint{
+				ServiceHost: "foo",
+				ServicePort: 9000,
+				Type:        v1.REST,
+			},
+		})
+	}
+	return graph
+}
+
+func createBanditFeedbackPayload(g *GomegaWithT, route int, reward float32) payload.SeldonPayload {
+	var fm proto.Feedback
+	data := fmt.Sprintf(`{"request":{"data":{"ndarray":[1.1,2.0]}},"response":{"meta":{"routing":{"bandit":%d}}}}`, route)
+	err := jsonpb.UnmarshalString(data, &fm)
+	g.Expect(err).Should(BeNil())
+	fm.Reward = reward
+	return &payload.ProtoPayload{Msg: &fm}
+}
+
+func TestEpsilonGreedyFeedback(t *testing.T) {
+	g := NewGomegaWithT(t)
+	SetBanditStore(NewMemoryBanditStore())
+	graph := createBanditGraph(v1.EPSILON_GREEDY)
+
+	// With no rewards the first arm is exploited
+	pResp, err := createPredictorProcess(t).Predict(graph, createPredictPayload(g))
+	g.Expect(err).Should(BeNil())
+	g.Expect(pResp.GetPayload().(*proto.SeldonMessage).GetMeta().GetRouting()["bandit"]).To(Equal(int32(0)))
+
+	_, err = createPredictorProcess(t).Feedback(graph, createBanditFeedbackPayload(g, 0, 0))
+	g.Expect(err).Should(BeNil())
+	_, err = createPredictorProcess(t).Feedback(graph, createBanditFeedbackPayload(g, 1, 1))
+	g.Expect(err).Should(BeNil())
+
+	state, err := banditStore.Get("bandit", 2)
+	g.Expect(err).Should(BeNil())
+	g.Expect(state.Tries).To(Equal([]float64{1, 1}))
+	g.Expect(state.Rewards).To(Equal([]float64{0, 1}))
+
+	pResp, err = createPredictorProcess(t).Predict(graph, createPredictPayload(g))
+	g.Expect(err).Should(BeNil())
+	g.Expect(pResp.GetPayload().(*proto.SeldonMessage).GetMeta().GetRouting()["bandit"]).To(Equal(int32(1)))
+}
+
+func TestThompsonSamplingPrefersRewardedArm(t *testing.T) {
+	g := NewGomegaWithT(t)
+	state := &BanditState{Tries: []float64{100, 100}, Rewards: []float64{5, 95}}
+	chosen := 0
+	for i := 0; i < 100; i++ {
+		chosen += thompsonSampling(state)
+	}
+	g.Expect(chosen).To(BeNumerically(">", 95))
+}
+
+func TestFileBanditStore(t *testing.T) {
+	g := NewGomegaWithT(t)
+	dir, err := ioutil.TempDir("", "bandit")
+	g.Expect(err).Should(BeNil())
+	defer os.RemoveAll(dir)
+
+	store, err := NewFileBanditStore(dir)
+	g.Expect(err).Should(BeNil())
+	g.Expect(store.Reward("bandit", 2, 1, 0.5)).Should(BeNil())
+	g.Expect(store.Reward("bandit", 2, 1, 1)).Should(BeNil())
+
+	// A new store reads the state written by the previous one
+	store, err = NewFileBanditStore(dir)
+	g.Expect(err).Should(BeNil())
+	state, err := store.Get("bandit", 2)
+	g.Expect(err).Should(BeNil())
+	g.Expect(state.Tries).To(Equal([]float64{0, 2}))
+	g.Expect(state.Rewards).To(Equal([]float64{0, 1.5}))
+
+	// Changing the number of arms resets the state
+	state, err = store.Get("bandit", 3)
+	g.Expect(err).Should(BeNil())
+	g.Expect(state.Tries).To(Equal([]float64{0, 0, 0}))
+}
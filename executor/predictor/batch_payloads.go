@@ -0,0 +1,503 @@
+package predictor
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/golang/protobuf/jsonpb"
+	protobuf "github.com/golang/protobuf/proto"
+	_struct "github.com/golang/protobuf/ptypes/struct"
+	"github.com/seldonio/seldon-core/executor/api/grpc/kfserving/inference"
+	"github.com/seldonio/seldon-core/executor/api/grpc/seldon/proto"
+	"github.com/seldonio/seldon-core/executor/api/payload"
+)
+
+// batchSize returns the number of items along the first axis of a prediction that can be batched.
+func batchSize(msg payload.SeldonPayload) (int, bool) {
+	switch v := msg.GetPayload().(type) {
+	case *proto.SeldonMessage:
+		return seldonMessageBatchSize(v)
+	case *inference.ModelInferRequest:
+		if len(v.Inputs) == 0 || len(v.RawInputContents) > 0 || len(v.Inputs[0].Shape) == 0 {
+			return 0, false
+		}
+		return int(v.Inputs[0].Shape[0]), true
+	case []byte:
+		if fields, ok := decodeJsonObject(v); ok {
+			if _, ok := fields["inputs"]; ok {
+				inputs, err := batchJsonTensors(fields, "inputs")
+				if err != nil || len(inputs) == 0 || len(inputs[0].Shape) == 0 {
+					return 0, false
+				}
+				return inputs[0].Shape[0], true
+			}
+		}
+		sm, err := toSeldonMessage(msg)
+		if err != nil {
+			return 0, false
+		}
+		return seldonMessageBatchSize(sm)
+	}
+	return 0, false
+}
+
+// mergeBatch concatenates predictions of the same type along the first axis.
+func mergeBatch(msgs []payload.SeldonPayload) (payload.SeldonPayload, error) {
+	switch msgs[0].GetPayload().(type) {
+	case *proto.SeldonMessage:
+		sms, err := toSeldonMessages(msgs)
+		if err != nil {
+			return nil, err
+		}
+		sm, err := mergeSeldonMessages(sms)
+		if err != nil {
+			return nil, err
+		}
+		return &payload.ProtoPayload{Msg: sm}, nil
+	case *inference.ModelInferRequest:
+		reqs := make([]*inference.ModelInferRequest, len(msgs))
+		for i, msg := range msgs {
+			var ok bool
+			if reqs[i], ok = msg.GetPayload().(*inference.ModelInferRequest); !ok {
+				return nil, fmt.Errorf("Can't batch mixed payload types")
+			}
+		}
+		req, err := mergeInferRequests(reqs)
+		if err != nil {
+			return nil, err
+		}
+		return &payload.ProtoPayload{Msg: req}, nil
+	case []byte:
+		var res []byte
+		var err error
+		if isV2JsonRequest(msgs[0]) {
+			res, err = mergeV2Json(msgs)
+		} else {
+			var sms []*proto.SeldonMessage
+			if sms, err = toSeldonMessages(msgs); err == nil {
+				var sm *proto.SeldonMessage
+				if sm, err = mergeSeldonMessages(sms); err == nil {
+					res, err = seldonMessageJson(sm)
+				}
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+		return &payload.BytesPayload{Msg: res, ContentType: msgs[0].GetContentType()}, nil
+	default:
+		return nil, fmt.Errorf("Can't batch payload type %T", msgs[0].GetPayload())
+	}
+}
+
+// splitBatch splits the response for a merged prediction into one response per caller.
+func splitBatch(res payload.SeldonPayload, sizes []int) ([]payload.SeldonPayload, error) {
+	parts := make([]payload.SeldonPayload, len(sizes))
+	switch v := res.GetPayload().(type) {
+	case *proto.SeldonMessage:
+		sms, err := splitSeldonMessage(v, sizes)
+		if err != nil {
+			return nil, err
+		}
+		for i, sm := range sms {
+			parts[i] = &payload.ProtoPayload{Msg: sm}
+		}
+	case *inference.ModelInferResponse:
+		resps, err := splitInferResponse(v, sizes)
+		if err != nil {
+			return nil, err
+		}
+		for i, resp := range resps {
+			parts[i] = &payload.ProtoPayload{Msg: resp}
+		}
+	case []byte:
+		var data [][]byte
+		var err error
+		if fields, ok := decodeJsonObject(v); ok && fields["outputs"] != nil {
+			data, err = splitV2Json(fields, sizes)
+		} else {
+			var sm *proto.SeldonMessage
+			if sm, err = toSeldonMessage(res); err == nil {
+				var sms []*proto.SeldonMessage
+				if sms, err = splitSeldonMessage(sm, sizes); err == nil {
+					data = make([][]byte, len(sms))
+					for i := range sms {
+						if data[i], err = seldonMessageJson(sms[i]); err != nil {
+							break
+						}
+					}
+				}
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+		for i := range data {
+			parts[i] = &payload.BytesPayload{Msg: data[i], ContentType: res.GetContentType()}
+		}
+	default:
+		return nil, fmt.Errorf("Can't split response payload type %T", v)
+	}
+	return parts, nil
+}
+
+func total(sizes []int) int {
+	n := 0
+	for _, s := range sizes {
+		n += s
+	}
+	return n
+}
+
+// --- SeldonMessage
+
+func seldonMessageBatchSize(sm *proto.SeldonMessage) (int, bool) {
+	switch v := sm.GetData().GetDataOneof().(type) {
+	case *proto.DefaultData_Ndarray:
+		return len(v.Ndarray.GetValues()), true
+	case *proto.DefaultData_Tensor:
+		if len(v.Tensor.GetShape()) == 0 {
+			return 0, false
+		}
+		return int(v.Tensor.GetShape()[0]), true
+	}
+	return 0, false
+}
+
+func toSeldonMessages(msgs []payload.SeldonPayload) ([]*proto.SeldonMessage, error) {
+	sms := make([]*proto.SeldonMessage, len(msgs))
+	for i, msg := range msgs {
+		var err error
+		if sms[i], err = toSeldonMessage(msg); err != nil {
+			return nil, err
+		}
+	}
+	return sms, nil
+}
+
+func seldonMessageJson(sm *proto.SeldonMessage) ([]byte, error) {
+	buf := bytes.Buffer{}
+	ma := jsonpb.Marshaler{}
+	if err := ma.Marshal(&buf, sm); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func mergeSeldonMessages(sms []*proto.SeldonMessage) (*proto.SeldonMessage, error) {
+	data := &proto.DefaultData{Names: sms[0].GetData().GetNames()}
+	switch first := sms[0].GetData().GetDataOneof().(type) {
+	case *proto.DefaultData_Ndarray:
+		values := &_struct.ListValue{}
+		for _, sm := range sms {
+			ndarray := sm.GetData().GetNdarray()
+			if ndarray == nil {
+				return nil, fmt.Errorf("Can't batch ndarray with other data types")
+			}
+			values.Values = append(values.Values, ndarray.Values...)
+		}
+		data.DataOneof = &proto.DefaultData_Ndarray{Ndarray: values}
+	case *proto.DefaultData_Tensor:
+		shape := append([]int32{}, first.Tensor.GetShape()...)
+		shape[0] = 0
+		var values []float64
+		for _, sm := range sms {
+			tensor := sm.GetData().GetTensor()
+			if tensor == nil || len(tensor.Shape) != len(shape) {
+				return nil, fmt.Errorf("Can't batch tensors with different shapes")
+			}
+			for i := 1; i < len(shape); i++ {
+				if tensor.Shape[i] != shape[i] {
+					return nil, fmt.Errorf("Can't batch tensors with different shapes")
+				}
+			}
+			shape[0] += tensor.Shape[0]
+			values = append(values, tensor.Values...)
+		}
+		data.DataOneof = &proto.DefaultData_Tensor{Tensor: &proto.Tensor{Shape: shape, Values: values}}
+	default:
+		return nil, fmt.Errorf("Only ndarray and tensor data can be batched")
+	}
+	return &proto.SeldonMessage{Meta: sms[0].GetMeta(), DataOneof: &proto.SeldonMessage_Data{Data: data}}, nil
+}
+
+func splitSeldonMessage(sm *proto.SeldonMessage, sizes []int) ([]*proto.SeldonMessage, error) {
+	n, ok := seldonMessageBatchSize(sm)
+	if !ok || n != total(sizes) {
+		return nil, fmt.Errorf("Batched response has %d items for %d requested", n, total(sizes))
+	}
+	sms := make([]*proto.SeldonMessage, len(sizes))
+	offset := 0
+	for i, size := range sizes {
+		data := &proto.DefaultData{Names: sm.GetData().GetNames()}
+		switch v := sm.GetData().GetDataOneof().(type) {
+		case *proto.DefaultData_Ndarray:
+			data.DataOneof = &proto.DefaultData_Ndarray{Ndarray: &_struct.ListValue{Values: v.Ndarray.Values[offset : offset+size]}}
+		case *proto.DefaultData_Tensor:
+			rowSize := len(v.Tensor.Values) / n
+			shape := append([]int32{}, v.Tensor.Shape...)
+			shape[0] = int32(size)
+			data.DataOneof = &proto.DefaultData_Tensor{Tensor: &proto.Tensor{Shape: shape, Values: v.Tensor.Values[offset*rowSize : (offset+size)*rowSize]}}
+		}
+		sms[i] = &proto.SeldonMessage{Status: sm.GetStatus(), DataOneof: &proto.SeldonMessage_Data{Data: data}}
+		if sm.GetMeta() != nil {
+			sms[i].Meta = protobuf.Clone(sm.GetMeta()).(*proto.Meta)
+		}
+		offset += size
+	}
+	return sms, nil
+}
+
+// --- KFServing V2 gRPC
+
+func rowSize(shape []int64) int {
+	size := 1
+	for _, d := range shape[1:] {
+		size *= int(d)
+	}
+	return size
+}
+
+func appendContents(to *inference.InferTensorContents, from *inference.InferTensorContents) {
+	to.BoolContents = append(to.BoolContents, from.GetBoolContents()...)
+	to.IntContents = append(to.IntContents, from.GetIntContents()...)
+	to.Int64Contents = append(to.Int64Contents, from.GetInt64Contents()...)
+	to.UintContents = append(to.UintContents, from.GetUintContents()...)
+	to.Uint64Contents = append(to.Uint64Contents, from.GetUint64Contents()...)
+	to.Fp32Contents = append(to.Fp32Contents, from.GetFp32Contents()...)
+	to.Fp64Contents = append(to.Fp64Contents, from.GetFp64Contents()...)
+	to.ByteContents = append(to.ByteContents, from.GetByteContents()...)
+}
+
+func sliceContents(c *inference.InferTensorContents, from int, to int) *inference.InferTensorContents {
+	s := &inference.InferTensorContents{}
+	if len(c.GetBoolContents()) >= to {
+		s.BoolContents = c.BoolContents[from:to]
+	}
+	if len(c.GetIntContents()) >= to {
+		s.IntContents = c.IntContents[from:to]
+	}
+	if len(c.GetInt64Contents()) >= to {
+		s.Int64Contents = c.Int64Contents[from:to]
+	}
+	if len(c.GetUintContents()) >= to {
+		s.UintContents = c.UintContents[from:to]
+	}
+	if len(c.GetUint64Contents()) >= to {
+		s.Uint64Contents = c.Uint64Contents[from:to]
+	}
+	if len(c.GetFp32Contents()) >= to {
+		s.Fp32Contents = c.Fp32Contents[from:to]
+	}
+	if len(c.GetFp64Contents()) >= to {
+		s.Fp64Contents = c.Fp64Contents[from:to]
+	}
+	if len(c.GetByteContents()) >= to {
+		s.ByteContents = c.ByteContents[from:to]
+	}
+	return s
+}
+
+func mergeInferRequests(reqs []*inference.ModelInferRequest) (*inference.ModelInferRequest, error) {
+	first := reqs[0]
+	merged := &inference.ModelInferRequest{
+		ModelName:    first.ModelName,
+		ModelVersion: first.ModelVersion,
+		Id:           first.Id,
+		Parameters:   first.Parameters,
+		Outputs:      first.Outputs,
+		Inputs:       make([]*inference.ModelInferRequest_InferInputTensor, len(first.Inputs)),
+	}
+	for i, input := range first.Inputs {
+		shape := append([]int64{}, input.Shape...)
+		shape[0] = 0
+		mergedInput := &inference.ModelInferRequest_InferInputTensor{
+			Name:       input.Name,
+			Datatype:   input.Datatype,
+			Parameters: input.Parameters,
+			Contents:   &inference.InferTensorContents{},
+		}
+		for _, req := range reqs {
+			if len(req.Inputs) != len(first.Inputs) || len(req.RawInputContents) > 0 {
+				return nil, fmt.Errorf("Can't batch requests with different inputs")
+			}
+			other := req.Inputs[i]
+			if other.Name != input.Name || other.Datatype != input.Datatype || len(other.Shape) != len(shape) || rowSize(other.Shape) != rowSize(shape) {
+				return nil, fmt.Errorf("Can't batch input %s with different shapes or datatypes", input.Name)
+			}
+			shape[0] += other.Shape[0]
+			appendContents(mergedInput.Contents, other.GetContents())
+		}
+		mergedInput.Shape = shape
+		merged.Inputs[i] = mergedInput
+	}
+	return merged, nil
+}
+
+func splitInferResponse(resp *inference.ModelInferResponse, sizes []int) ([]*inference.ModelInferResponse, error) {
+	n := total(sizes)
+	resps := make([]*inference.ModelInferResponse, len(sizes))
+	for i := range resps {
+		resps[i] = &inference.ModelInferResponse{
+			ModelName:    resp.ModelName,
+			ModelVersion: resp.ModelVersion,
+			Id:           resp.Id,
+			Parameters:   resp.Parameters,
+		}
+	}
+	for _, output := range resp.Outputs {
+		if len(output.Shape) == 0 || int(output.Shape[0]) != n {
+			return nil, fmt.Errorf("Batched output %s does not have %d items", output.Name, n)
+		}
+		rs := rowSize(output.Shape)
+		offset := 0
+		for i, size := range sizes {
+			shape := append([]int64{}, output.Shape...)
+			shape[0] = int64(size)
+			resps[i].Outputs = append(resps[i].Outputs, &inference.ModelInferResponse_InferOutputTensor{
+				Name:       output.Name,
+				Datatype:   output.Datatype,
+				Shape:      shape,
+				Parameters: output.Parameters,
+				Contents:   sliceContents(output.GetContents(), offset*rs, (offset+size)*rs),
+			})
+			offset += size
+		}
+	}
+	return resps, nil
+}
+
+// --- KFServing V2 JSON
+
+type batchJsonTensor struct {
+	Name       string                 `json:"name"`
+	Shape      []int                  `json:"shape"`
+	Datatype   string                 `json:"datatype"`
+	Parameters map[string]interface{} `json:"parameters,omitempty"`
+	Data       []interface{}          `json:"data"`
+}
+
+func decodeJsonObject(data []byte) (map[string]json.RawMessage, bool) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, false
+	}
+	return fields, true
+}
+
+func isV2JsonRequest(msg payload.SeldonPayload) bool {
+	fields, ok := decodeJsonObject(msg.GetPayload().([]byte))
+	return ok && fields["inputs"] != nil
+}
+
+// batchJsonTensors decodes the inputs or outputs of a request or response flattening nested data.
+func batchJsonTensors(fields map[string]json.RawMessage, key string) ([]*batchJsonTensor, error) {
+	var tensors []*batchJsonTensor
+	if err := json.Unmarshal(fields[key], &tensors); err != nil {
+		return nil, err
+	}
+	for _, t := range tensors {
+		t.Data = flattenJsonData(t.Data)
+	}
+	return tensors, nil
+}
+
+func flattenJsonData(data []interface{}) []interface{} {
+	var flat []interface{}
+	for _, v := range data {
+		if inner, ok := v.([]interface{}); ok {
+			flat = append(flat, flattenJsonData(inner)...)
+		} else {
+			flat = append(flat, v)
+		}
+	}
+	return flat
+}
+
+func jsonRowSize(shape []int) int {
+	size := 1
+	for _, d := range shape[1:] {
+		size *= d
+	}
+	return size
+}
+
+func mergeV2Json(msgs []payload.SeldonPayload) ([]byte, error) {
+	fields, _ := decodeJsonObject(msgs[0].GetPayload().([]byte))
+	merged, err := batchJsonTensors(fields, "inputs")
+	if err != nil {
+		return nil, err
+	}
+	for _, msg := range msgs[1:] {
+		other, ok := decodeJsonObject(msg.GetPayload().([]byte))
+		if !ok {
+			return nil, fmt.Errorf("Can't batch invalid JSON request")
+		}
+		inputs, err := batchJsonTensors(other, "inputs")
+		if err != nil {
+			return nil, err
+		}
+		if len(inputs) != len(merged) {
+			return nil, fmt.Errorf("Can't batch requests with different inputs")
+		}
+		for i, input := range inputs {
+			m := merged[i]
+			if input.Name != m.Name || input.Datatype != m.Datatype || len(input.Shape) != len(m.Shape) || len(m.Shape) == 0 || jsonRowSize(input.Shape) != jsonRowSize(m.Shape) {
+				return nil, fmt.Errorf("Can't batch input %s with different shapes or datatypes", m.Name)
+			}
+			m.Shape[0] += input.Shape[0]
+			m.Data = append(m.Data, input.Data...)
+		}
+	}
+	inputs, err := json.Marshal(merged)
+	if err != nil {
+		return nil, err
+	}
+	fields["inputs"] = inputs
+	return json.Marshal(fields)
+}
+
+func splitV2Json(fields map[string]json.RawMessage, sizes []int) ([][]byte, error) {
+	outputs, err := batchJsonTensors(fields, "outputs")
+	if err != nil {
+		return nil, err
+	}
+	n := total(sizes)
+	parts := make([][]*batchJsonTensor, len(sizes))
+	for _, output := range outputs {
+		if len(output.Shape) == 0 || output.Shape[0] != n || len(output.Data) != n*jsonRowSize(output.Shape) {
+			return nil, fmt.Errorf("Batched output %s does not have %d items", output.Name, n)
+		}
+		rs := jsonRowSize(output.Shape)
+		offset := 0
+		for i, size := range sizes {
+			shape := append([]int{}, output.Shape...)
+			shape[0] = size
+			parts[i] = append(parts[i], &batchJsonTensor{
+				Name:       output.Name,
+				Shape:      shape,
+				Datatype:   output.Datatype,
+				Parameters: output.Parameters,
+				Data:       output.Data[offset*rs : (offset+size)*rs],
+			})
+			offset += size
+		}
+	}
+	data := make([][]byte, len(sizes))
+	for i, part := range parts {
+		partOutputs, err := json.Marshal(part)
+		if err != nil {
+			return nil, err
+		}
+		partFields := make(map[string]json.RawMessage, len(fields))
+		for k, v := range fields {
+			partFields[k] = v
+		}
+		partFields["outputs"] = partOutputs
+		if data[i], err = json.Marshal(partFields); err != nil {
+			return nil, err
+		}
+	}
+	return data, nil
+}
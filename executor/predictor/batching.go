@@ -0,0 +1,228 @@
+package predictor
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	guuid "github.com/google/uuid"
+	"github.com/seldonio/seldon-core/executor/api/client"
+	"github.com/seldonio/seldon-core/executor/api/payload"
+	"github.com/seldonio/seldon-core/executor/api/tracing"
+	v1 "github.com/seldonio/seldon-core/operator/apis/machinelearning.seldon.io/v1"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type batchResult struct {
+	msg payload.SeldonPayload
+	err error
+}
+
+type batchRequest struct {
+	p    *PredictorProcess
+	msg  payload.SeldonPayload
+	size int
+	done chan batchResult
+}
+
+// batcher collects concurrent predictions for a model until the batch is full or the oldest
+// prediction has waited for the maximum latency.
+type batcher struct {
+	mu         sync.Mutex
+	node       *v1.PredictiveUnit
+	maxSize    int
+	maxLatency time.Duration
+	pending    []*batchRequest
+	size       int
+	generation int
+	timer      *time.Timer
+}
+
+// Batchers are shared by all requests so predictions from concurrent requests can be merged.
+var batchers sync.Map
+
+func getBatcher(node *v1.PredictiveUnit) *batcher {
+	if node.Batching == nil || node.Batching.MaxBatchSize <= 1 || node.Batching.MaxLatencyMs <= 0 {
+		return nil
+	}
+	key := fmt.Sprintf("%s/%s:%d", node.Name, node.Endpoint.ServiceHost, node.Endpoint.ServicePort)
+	b, _ := batchers.LoadOrStore(key, &batcher{
+		node:       node.DeepCopy(),
+		maxSize:    int(node.Batching.MaxBatchSize),
+		maxLatency: time.Duration(node.Batching.MaxLatencyMs) * time.Millisecond,
+	})
+	return b.(*batcher)
+}
+
+// add queues a prediction. The pending batch is sent first if the prediction would make it larger
+// than the maximum size, so only a single prediction larger than the maximum is sent on its own.
+func (b *batcher) add(req *batchRequest) {
+	b.mu.Lock()
+	if len(b.pending) > 0 && b.size+req.size > b.maxSize {
+		go b.run(b.take())
+	}
+	b.pending = append(b.pending, req)
+	b.size += req.size
+	if b.size >= b.maxSize {
+		batch := b.take()
+		b.mu.Unlock()
+		go b.run(batch)
+		return
+	}
+	if len(b.pending) == 1 {
+		generation := b.generation
+		b.timer = time.AfterFunc(b.maxLatency, func() { b.flush(generation) })
+	}
+	b.mu.Unlock()
+}
+
+// take removes the pending batch. Callers hold the lock.
+func (b *batcher) take() []*batchRequest {
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	batch := b.pending
+	b.pending = nil
+	b.size = 0
+	b.generation++
+	return batch
+}
+
+// flush runs the pending batch if it is still the one the timer was started for.
+func (b *batcher) flush(generation int) {
+	b.mu.Lock()
+	if generation != b.generation || len(b.pending) == 0 {
+		b.mu.Unlock()
+		return
+	}
+	batch := b.take()
+	b.mu.Unlock()
+	b.run(batch)
+}
+
+// run makes a single call for the batch and returns each caller its part of the response.
+// Predictions that can't be merged are sent individually.
+func (b *batcher) run(batch []*batchRequest) {
+	if len(batch) == 1 {
+		b.runSingle(batch[0])
+		return
+	}
+	msgs := make([]payload.SeldonPayload, len(batch))
+	sizes := make([]int, len(batch))
+	for i, req := range batch {
+		msgs[i], sizes[i] = req.msg, req.size
+	}
+	merged, err := mergeBatch(msgs)
+	if err != nil {
+		batch[0].p.Log.V(1).Info("Sending predictions individually as they can't be batched", "node", b.node.Name, "error", err.Error())
+		for _, req := range batch {
+			go b.runSingle(req)
+		}
+		return
+	}
+
+	bp, span := b.batchProcess(batch)
+	res, err := bp.predictModel(b.node, merged)
+	tracing.EndSpan(span, err)
+	// Each caller gets the custom metrics returned for the batch as it gets the meta of the response
+	if metrics := bp.customMetrics.snapshot(); len(metrics) > 0 {
+		for _, req := range batch {
+			if req.p.customMetrics != nil {
+				req.p.customMetrics.add(metrics)
+			}
+		}
+	}
+	if err != nil {
+		for _, req := range batch {
+			req.done <- batchResult{msg: res, err: err}
+		}
+		return
+	}
+	parts, err := splitBatch(res, sizes)
+	for i, req := range batch {
+		if err != nil {
+			req.done <- batchResult{err: err}
+		} else {
+			req.done <- batchResult{msg: parts[i]}
+		}
+	}
+}
+
+// batchProcess returns the predictor process to make the call for a batch. The call is made on
+// behalf of all callers so it has its own puid, only the headers all callers share, and a span
+// linked to the span of each caller. It is not cancelled with any one caller.
+func (b *batcher) batchProcess(batch []*batchRequest) (*PredictorProcess, trace.Span) {
+	puid := guuid.New().String()
+	links := make([]trace.Link, len(batch))
+	for i, req := range batch {
+		links[i] = trace.Link{SpanContext: trace.SpanContextFromContext(req.p.Ctx)}
+	}
+	ctx := context.WithValue(context.Background(), payload.SeldonPUIDHeader, puid)
+	ctx, span := tracing.Tracer().Start(ctx, b.node.Name+" batch", trace.WithLinks(links...),
+		trace.WithAttributes(tracing.NodeNameKey.String(b.node.Name), tracing.BatchSizeKey.Int(len(batch))))
+
+	meta := sharedHeaders(batch)
+	meta[payload.SeldonPUIDHeader] = []string{puid}
+	first := batch[0].p
+	return &PredictorProcess{
+		Ctx:           ctx,
+		Client:        first.Client,
+		Log:           first.Log,
+		ServerUrl:     first.ServerUrl,
+		Namespace:     first.Namespace,
+		Meta:          payload.NewFromMap(meta),
+		Routing:       make(map[string]int32),
+		customMetrics: &customMetricsRecorder{},
+		nested:        true,
+	}, span
+}
+
+// sharedHeaders returns the headers with the same values for all callers other than the puid.
+func sharedHeaders(batch []*batchRequest) map[string][]string {
+	shared := make(map[string][]string)
+	for k, vv := range batch[0].p.Meta.Meta {
+		if strings.EqualFold(k, payload.SeldonPUIDHeader) {
+			continue
+		}
+		same := true
+		for _, req := range batch[1:] {
+			if !reflect.DeepEqual(req.p.Meta.Meta[k], vv) {
+				same = false
+				break
+			}
+		}
+		if same {
+			shared[k] = vv
+		}
+	}
+	return shared
+}
+
+func (b *batcher) runSingle(req *batchRequest) {
+	res, err := req.p.predictModel(b.node, req.msg)
+	req.done <- batchResult{msg: res, err: err}
+}
+
+// predictBatched sends a prediction through the batcher of the node if it has one.
+func (p *PredictorProcess) predictBatched(node *v1.PredictiveUnit, msg payload.SeldonPayload) (payload.SeldonPayload, error) {
+	b := getBatcher(node)
+	if b == nil {
+		return p.predictModel(node, msg)
+	}
+	size, ok := batchSize(msg)
+	if !ok || size >= b.maxSize {
+		return p.predictModel(node, msg)
+	}
+	req := &batchRequest{p: p, msg: msg, size: size, done: make(chan batchResult, 1)}
+	b.add(req)
+	select {
+	case res := <-req.done:
+		return res.msg, res.err
+	case <-p.Ctx.Done():
+		return nil, p.wrapTimeout(node, client.SeldonPredictPath, p.Ctx, p.Ctx.Err())
+	}
+}
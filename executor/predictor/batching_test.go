@@ -0,0 +1,262 @@
+package predictor
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/golang/protobuf/jsonpb"
+	protobuf "github.com/golang/protobuf/proto"
+	. "github.com/onsi/gomega"
+	"github.com/seldonio/seldon-core/executor/api/grpc/kfserving/inference"
+	"github.com/seldonio/seldon-core/executor/api/grpc/seldon/proto"
+	"github.com/seldonio/seldon-core/executor/api/payload"
+	"github.com/seldonio/seldon-core/executor/api/test"
+	"github.com/seldonio/seldon-core/operator/apis/machinelearning.seldon.io/v1"
+	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
+)
+
+func createBatchingGraph(name string, maxBatchSize int32, maxLatencyMs int32) *v1.PredictiveUnit {
+	model := v1.MODEL
+	return &v1.PredictiveUnit{
+		Name: name,
+		Type: &model,
+		Endpoint: &v1.Endpoint{
+			ServiceHost: "foo",
+			ServicePort: 9000,
+			Type:        v1.REST,
+		},
+		Batching: &v1.BatchingPolicy{
+			MaxBatchSize: maxBatchSize,
+			MaxLatencyMs: maxLatencyMs,
+		},
+	}
+}
+
+func createNdarrayPayload(g *GomegaWithT, data string) payload.SeldonPayload {
+	var sm proto.SeldonMessage
+	g.Expect(jsonpb.UnmarshalString(data, &sm)).To(BeNil())
+	return &payload.ProtoPayload{Msg: &sm}
+}
+
+func TestBatchingMergesConcurrentPredictions(t *testing.T) {
+	g := NewGomegaWithT(t)
+	graph := createBatchingGraph("batched", 3, 10000)
+
+	var calls int32
+	var wg sync.WaitGroup
+	results := make([]*proto.SeldonMessage, 3)
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			msg := createNdarrayPayload(g, fmt.Sprintf(`{"data":{"ndarray":[[%d,%d]]}}`, i, i))
+			res, err := createPredictorProcessWithUnreliableClient(t, 0, 0, &calls).Predict(graph, msg)
+			g.Expect(err).Should(BeNil())
+			results[i] = res.GetPayload().(*proto.SeldonMessage)
+		}(i)
+	}
+	wg.Wait()
+
+	g.Expect(calls).To(Equal(int32(1)))
+	for i, res := range results {
+		values := res.GetData().GetNdarray().GetValues()
+		g.Expect(len(values)).To(Equal(1))
+		g.Expect(values[0].GetListValue().Values[0].GetNumberValue()).To(Equal(float64(i)))
+	}
+}
+
+// batchMetaTestClient records the headers models are called with and returns a custom metric.
+type batchMetaTestClient struct {
+	test.SeldonMessageTestClient
+	mu   *sync.Mutex
+	meta *[]map[string][]string
+}
+
+func (s batchMetaTestClient) Predict(ctx context.Context, modelName string, host string, port int32, msg payload.SeldonPayload, meta map[string][]string) (payload.SeldonPayload, error) {
+	s.mu.Lock()
+	*s.meta = append(*s.meta, meta)
+	s.mu.Unlock()
+	sm := protobuf.Clone(msg.GetPayload().(*proto.SeldonMessage)).(*proto.SeldonMessage)
+	sm.Meta = &proto.Meta{Metrics: []*proto.Metric{{Key: "batch_gauge", Type: proto.Metric_GAUGE, Value: 1}}}
+	return &payload.ProtoPayload{Msg: sm}, nil
+}
+
+func TestBatchingIsNeutralToCallers(t *testing.T) {
+	g := NewGomegaWithT(t)
+	graph := createBatchingGraph("neutral", 2, 10000)
+
+	var mu sync.Mutex
+	var calls []map[string][]string
+	c := batchMetaTestClient{mu: &mu, meta: &calls}
+	var wg sync.WaitGroup
+	results := make([]*proto.SeldonMessage, 2)
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			puid := fmt.Sprintf("caller-%d", i)
+			ctx := context.WithValue(context.Background(), payload.SeldonPUIDHeader, puid)
+			meta := map[string][]string{payload.SeldonPUIDHeader: {puid}, "Shared": {"yes"}, "Caller": {puid}}
+			pp := NewPredictorProcess(ctx, c, logf.Log.WithName("test"), nil, "default", meta)
+			res, err := pp.Predict(graph, createNdarrayPayload(g, fmt.Sprintf(`{"data":{"ndarray":[[%d]]}}`, i)))
+			g.Expect(err).Should(BeNil())
+			results[i] = res.GetPayload().(*proto.SeldonMessage)
+		}(i)
+	}
+	wg.Wait()
+
+	// The batch has its own puid and only the headers shared by all callers
+	g.Expect(calls).To(HaveLen(1))
+	g.Expect(calls[0][payload.SeldonPUIDHeader]).ToNot(ContainElement(HavePrefix("caller-")))
+	g.Expect(calls[0]).To(HaveKeyWithValue("Shared", []string{"yes"}))
+	g.Expect(calls[0]).ToNot(HaveKey("Caller"))
+	// Every caller gets the custom metrics of the batch
+	for _, res := range results {
+		g.Expect(res.GetMeta().GetMetrics()).To(HaveLen(1))
+		g.Expect(res.GetMeta().GetMetrics()[0].GetKey()).To(Equal("batch_gauge"))
+	}
+}
+
+// batchSizeTestClient records the number of rows models are called with.
+type batchSizeTestClient struct {
+	test.SeldonMessageTestClient
+	mu    *sync.Mutex
+	sizes *[]int
+}
+
+func (s batchSizeTestClient) Predict(ctx context.Context, modelName string, host string, port int32, msg payload.SeldonPayload, meta map[string][]string) (payload.SeldonPayload, error) {
+	s.mu.Lock()
+	*s.sizes = append(*s.sizes, len(msg.GetPayload().(*proto.SeldonMessage).GetData().GetNdarray().GetValues()))
+	s.mu.Unlock()
+	return msg, nil
+}
+
+func TestBatchingKeepsBatchesWithinMaxSize(t *testing.T) {
+	g := NewGomegaWithT(t)
+	graph := createBatchingGraph("mixed", 4, 50)
+
+	var mu sync.Mutex
+	var sizes []int
+	c := batchSizeTestClient{mu: &mu, sizes: &sizes}
+	rows := []int{3, 2, 2, 1, 3, 4}
+	var wg sync.WaitGroup
+	for i, n := range rows {
+		wg.Add(1)
+		go func(i int, n int) {
+			defer wg.Done()
+			values := make([]string, n)
+			for r := range values {
+				values[r] = fmt.Sprintf("[%d]", i)
+			}
+			ctx := context.WithValue(context.Background(), payload.SeldonPUIDHeader, fmt.Sprintf("caller-%d", i))
+			pp := NewPredictorProcess(ctx, c, logf.Log.WithName("test"), nil, "default", nil)
+			res, err := pp.Predict(graph, createNdarrayPayload(g, fmt.Sprintf(`{"data":{"ndarray":[%s]}}`, strings.Join(values, ","))))
+			g.Expect(err).Should(BeNil())
+			g.Expect(res.GetPayload().(*proto.SeldonMessage).GetData().GetNdarray().GetValues()).To(HaveLen(n))
+		}(i, n)
+	}
+	wg.Wait()
+
+	total := 0
+	for _, size := range sizes {
+		g.Expect(size).To(BeNumerically("<=", 4))
+		total += size
+	}
+	g.Expect(total).To(Equal(15))
+}
+
+func TestBatchingFlushesAfterMaxLatency(t *testing.T) {
+	g := NewGomegaWithT(t)
+	graph := createBatchingGraph("partial", 10, 10)
+
+	var calls int32
+	res, err := createPredictorProcessWithUnreliableClient(t, 0, 0, &calls).Predict(graph, createNdarrayPayload(g, `{"data":{"tensor":{"shape":[2,2],"values":[1,2,3,4]}}}`))
+	g.Expect(err).Should(BeNil())
+	g.Expect(calls).To(Equal(int32(1)))
+	g.Expect(res.GetPayload().(*proto.SeldonMessage).GetData().GetTensor().Values).To(Equal([]float64{1, 2, 3, 4}))
+}
+
+func TestMergeAndSplitSeldonTensor(t *testing.T) {
+	g := NewGomegaWithT(t)
+	msgs := []payload.SeldonPayload{
+		createNdarrayPayload(g, `{"data":{"tensor":{"shape":[1,2],"values":[1,2]}}}`),
+		createNdarrayPayload(g, `{"data":{"tensor":{"shape":[2,2],"values":[3,4,5,6]}}}`),
+	}
+	merged, err := mergeBatch(msgs)
+	g.Expect(err).Should(BeNil())
+	tensor := merged.GetPayload().(*proto.SeldonMessage).GetData().GetTensor()
+	g.Expect(tensor.Shape).To(Equal([]int32{3, 2}))
+	g.Expect(tensor.Values).To(Equal([]float64{1, 2, 3, 4, 5, 6}))
+
+	parts, err := splitBatch(merged, []int{1, 2})
+	g.Expect(err).Should(BeNil())
+	g.Expect(parts[1].GetPayload().(*proto.SeldonMessage).GetData().GetTensor().Values).To(Equal([]float64{3, 4, 5, 6}))
+
+	// Tensors with different trailing dimensions can't be merged
+	_, err = mergeBatch(append(msgs, createNdarrayPayload(g, `{"data":{"tensor":{"shape":[1,3],"values":[1,2,3]}}}`)))
+	g.Expect(err).ShouldNot(BeNil())
+}
+
+func TestMergeAndSplitV2Json(t *testing.T) {
+	g := NewGomegaWithT(t)
+	msgs := createSeldonJsonPayloads(
+		`{"inputs":[{"name":"input","shape":[1,2],"datatype":"FP32","data":[1,2]}]}`,
+		`{"inputs":[{"name":"input","shape":[2,2],"datatype":"FP32","data":[[3,4],[5,6]]}]}`,
+	)
+	size, ok := batchSize(msgs[1])
+	g.Expect(ok).To(BeTrue())
+	g.Expect(size).To(Equal(2))
+
+	merged, err := mergeBatch(msgs)
+	g.Expect(err).Should(BeNil())
+	g.Expect(string(merged.GetPayload().([]byte))).To(Equal(`{"inputs":[{"name":"input","shape":[3,2],"datatype":"FP32","data":[1,2,3,4,5,6]}]}`))
+
+	res := &payload.BytesPayload{Msg: []byte(`{"model_name":"m","outputs":[{"name":"predict","shape":[3],"datatype":"INT64","data":[0,1,1]}]}`), ContentType: "application/json"}
+	parts, err := splitBatch(res, []int{1, 2})
+	g.Expect(err).Should(BeNil())
+	g.Expect(string(parts[0].GetPayload().([]byte))).To(Equal(`{"model_name":"m","outputs":[{"name":"predict","shape":[1],"datatype":"INT64","data":[0]}]}`))
+	g.Expect(string(parts[1].GetPayload().([]byte))).To(Equal(`{"model_name":"m","outputs":[{"name":"predict","shape":[2],"datatype":"INT64","data":[1,1]}]}`))
+}
+
+func TestMergeAndSplitV2Grpc(t *testing.T) {
+	g := NewGomegaWithT(t)
+	var msgs []payload.SeldonPayload
+	for _, values := range [][]float32{{1, 2}, {3, 4, 5, 6}} {
+		msgs = append(msgs, &payload.ProtoPayload{Msg: &inference.ModelInferRequest{
+			ModelName: "model",
+			Inputs: []*inference.ModelInferRequest_InferInputTensor{
+				{
+					Name:     "input",
+					Datatype: "FP32",
+					Shape:    []int64{int64(len(values) / 2), 2},
+					Contents: &inference.InferTensorContents{Fp32Contents: values},
+				},
+			},
+		}})
+	}
+	merged, err := mergeBatch(msgs)
+	g.Expect(err).Should(BeNil())
+	input := merged.GetPayload().(*inference.ModelInferRequest).Inputs[0]
+	g.Expect(input.Shape).To(Equal([]int64{3, 2}))
+	g.Expect(input.Contents.Fp32Contents).To(Equal([]float32{1, 2, 3, 4, 5, 6}))
+
+	res := &payload.ProtoPayload{Msg: &inference.ModelInferResponse{
+		ModelName: "model",
+		Outputs: []*inference.ModelInferResponse_InferOutputTensor{
+			{
+				Name:     "predict",
+				Datatype: "INT64",
+				Shape:    []int64{3},
+				Contents: &inference.InferTensorContents{Int64Contents: []int64{7, 8, 9}},
+			},
+		},
+	}}
+	parts, err := splitBatch(res, []int{1, 2})
+	g.Expect(err).Should(BeNil())
+	output := parts[1].GetPayload().(*inference.ModelInferResponse).Outputs[0]
+	g.Expect(output.Shape).To(Equal([]int64{2}))
+	g.Expect(output.Contents.Int64Contents).To(Equal([]int64{8, 9}))
+}
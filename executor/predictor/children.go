@@ -0,0 +1,161 @@
+package predictor
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/seldonio/seldon-core/executor/api/payload"
+	"github.com/seldonio/seldon-core/executor/api/util"
+	v1 "github.com/seldonio/seldon-core/operator/apis/machinelearning.seldon.io/v1"
+)
+
+const (
+	ChildStatusTag       = "childStatus"
+	ChildStatusSuccess   = "success"
+	ChildStatusFailure   = "failure"
+	ChildStatusCancelled = "cancelled"
+)
+
+// ChildStatus is the outcome of calling a child of a node with a children policy.
+type ChildStatus struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// childStatusRecorder collects child statuses from nodes that may be walked concurrently.
+type childStatusRecorder struct {
+	mu    sync.Mutex
+	nodes map[string]map[string]ChildStatus
+}
+
+func (r *childStatusRecorder) record(nodeName string, status map[string]ChildStatus) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.nodes[nodeName] = status
+}
+
+func (r *childStatusRecorder) snapshot() map[string]map[string]ChildStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.nodes) == 0 {
+		return nil
+	}
+	nodes := make(map[string]map[string]ChildStatus, len(r.nodes))
+	for k, v := range r.nodes {
+		nodes[k] = v
+	}
+	return nodes
+}
+
+type childResult struct {
+	index   int
+	msg     payload.SeldonPayload
+	err     error
+	routing map[string]int32
+}
+
+// predictAllChildren calls all children of a node in parallel and returns the outputs to aggregate,
+// and the indexes of the children they came from, according to the children policy of the node.
+// Without a policy all children must succeed.
+func (p *PredictorProcess) predictAllChildren(node *v1.PredictiveUnit, msg payload.SeldonPayload) ([]payload.SeldonPayload, []int, payload.SeldonPayload, error) {
+	mode := v1.ChildrenAll
+	if node.ChildrenPolicy != nil {
+		mode = node.ChildrenPolicy.Mode
+	}
+
+	// Children still running once the first one succeeds are cancelled
+	ctx, cancel := context.WithCancel(p.Ctx)
+	defer cancel()
+	cp := *p
+	cp.Ctx = ctx
+
+	cmsgs := make([]payload.SeldonPayload, len(node.Children))
+	errs := make([]error, len(node.Children))
+	results := make(chan childResult, len(node.Children))
+	for i, nodeChild := range node.Children {
+		go func(i int, nodeChild v1.PredictiveUnit, msg payload.SeldonPayload) {
+			// Each child records its routes in its own map as maps can't be written concurrently
+			ccp := cp
+			ccp.Routing = make(map[string]int32)
+			res, err := ccp.Predict(&nodeChild, msg)
+			results <- childResult{index: i, msg: res, err: err, routing: ccp.Routing}
+		}(i, nodeChild, msg)
+	}
+	first := -1
+	status := make(map[string]ChildStatus, len(node.Children))
+	for range node.Children {
+		r := <-results
+		cmsgs[r.index], errs[r.index] = r.msg, r.err
+		for k, v := range r.routing {
+			p.Routing[k] = v
+		}
+		childName := node.Children[r.index].Name
+		switch {
+		case r.err == nil:
+			status[childName] = ChildStatus{Status: ChildStatusSuccess}
+		case first >= 0:
+			status[childName] = ChildStatus{Status: ChildStatusCancelled}
+		default:
+			status[childName] = ChildStatus{Status: ChildStatusFailure, Error: r.err.Error()}
+		}
+		if mode == v1.ChildrenFirstSuccess && r.err == nil && first < 0 {
+			first = r.index
+			cancel()
+		}
+	}
+
+	if node.ChildrenPolicy != nil && p.childStatus != nil {
+		p.childStatus.record(node.Name, status)
+	}
+
+	var succeeded []payload.SeldonPayload
+	var indexes []int
+	firstErr := -1
+	for i, err := range errs {
+		if err == nil {
+			succeeded = append(succeeded, cmsgs[i])
+			indexes = append(indexes, i)
+		} else if firstErr < 0 {
+			firstErr = i
+		}
+	}
+	if firstErr < 0 && mode != v1.ChildrenFirstSuccess {
+		return cmsgs, indexes, nil, nil
+	}
+
+	switch mode {
+	case v1.ChildrenFirstSuccess:
+		if first >= 0 {
+			return []payload.SeldonPayload{cmsgs[first]}, []int{first}, nil, nil
+		}
+	case v1.ChildrenQuorum:
+		if len(succeeded) >= int(node.ChildrenPolicy.Quorum) {
+			return succeeded, indexes, nil, nil
+		}
+		return nil, nil, cmsgs[firstErr], fmt.Errorf("Only %d of %d children of %s succeeded but a quorum of %d is required: %v",
+			len(succeeded), len(node.Children), node.Name, node.ChildrenPolicy.Quorum, errs[firstErr])
+	case v1.ChildrenIgnoreFailures:
+		if len(succeeded) > 0 {
+			return succeeded, indexes, nil, nil
+		}
+	}
+	return nil, nil, cmsgs[firstErr], errs[firstErr]
+}
+
+// insertChildStatus adds the status of children of nodes with a children policy to the response meta.
+func (p *PredictorProcess) insertChildStatus(msg payload.SeldonPayload) payload.SeldonPayload {
+	if p.childStatus == nil {
+		return msg
+	}
+	nodes := p.childStatus.snapshot()
+	if nodes == nil {
+		return msg
+	}
+	res, err := util.InsertTagToSeldonPredictPayload(msg, ChildStatusTag, nodes)
+	if err != nil {
+		p.Log.Error(err, "Failed to add child status to response")
+		return msg
+	}
+	return res
+}
@@ -0,0 +1,162 @@
+package predictor
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/jsonpb"
+	proto2 "github.com/golang/protobuf/proto"
+	"github.com/seldonio/seldon-core/executor/api/grpc/kfserving/inference"
+	"github.com/seldonio/seldon-core/executor/api/grpc/seldon/proto"
+	"github.com/seldonio/seldon-core/executor/api/metric"
+	"github.com/seldonio/seldon-core/executor/api/payload"
+	"github.com/seldonio/seldon-core/executor/proto/tensorflow/serving"
+	v1 "github.com/seldonio/seldon-core/operator/apis/machinelearning.seldon.io/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const defaultCircuitResetTimeout = 30 * time.Second
+
+// CircuitOpenError is returned when a call to a predictive unit is rejected by its open circuit breaker.
+type CircuitOpenError struct {
+	NodeName string
+}
+
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("Circuit breaker open for predictive unit %s", e.NodeName)
+}
+
+// GRPCStatus allows gRPC servers to return the error with an Unavailable code.
+func (e *CircuitOpenError) GRPCStatus() *status.Status {
+	return status.New(codes.Unavailable, e.Error())
+}
+
+type circuitState int
+
+const (
+	circuitClosed circuitState = iota
+	circuitOpen
+	circuitHalfOpen
+)
+
+// circuitBreaker tracks consecutive failures of calls to a single predictive unit endpoint.
+type circuitBreaker struct {
+	mu           sync.Mutex
+	state        circuitState
+	failures     int32
+	openedAt     time.Time
+	maxFailures  int32
+	resetTimeout time.Duration
+}
+
+// allow reports whether a call may be made. Once the reset timeout has passed a single
+// trial call is let through and its outcome decides whether the breaker closes again.
+func (cb *circuitBreaker) allow(now time.Time) bool {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	switch cb.state {
+	case circuitOpen:
+		if now.Sub(cb.openedAt) >= cb.resetTimeout {
+			cb.state = circuitHalfOpen
+			return true
+		}
+		return false
+	case circuitHalfOpen:
+		return false
+	default:
+		return true
+	}
+}
+
+// record updates the breaker with the outcome of a call. Only server errors, timeouts and
+// unavailable endpoints are failures: a client error still shows the unit is up, and calls
+// cancelled by the caller, such as the losers of a firstSuccess ensemble, say nothing about it.
+func (cb *circuitBreaker) record(err error, now time.Time) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	switch errorClass(err) {
+	case "", metric.ErrorClassClient:
+		cb.state = circuitClosed
+		cb.failures = 0
+		return
+	case metric.ErrorClassServer, metric.ErrorClassTimeout, metric.ErrorClassUnavailable:
+	default:
+		// Let another trial call through if this one didn't decide the state
+		if cb.state == circuitHalfOpen {
+			cb.state = circuitOpen
+		}
+		return
+	}
+	cb.failures++
+	if cb.state == circuitHalfOpen || cb.failures >= cb.maxFailures {
+		cb.state = circuitOpen
+		cb.openedAt = now
+	}
+}
+
+// Breakers live for the life of the executor so failures are counted across requests.
+var circuitBreakers sync.Map
+
+func getCircuitBreaker(node *v1.PredictiveUnit) *circuitBreaker {
+	if node.CircuitBreaker == nil || node.CircuitBreaker.MaxFailures <= 0 {
+		return nil
+	}
+	key := fmt.Sprintf("%s/%s:%d", node.Name, node.Endpoint.ServiceHost, node.Endpoint.ServicePort)
+	resetTimeout := defaultCircuitResetTimeout
+	if node.CircuitBreaker.ResetTimeoutMs > 0 {
+		resetTimeout = time.Duration(node.CircuitBreaker.ResetTimeoutMs) * time.Millisecond
+	}
+	cb, _ := circuitBreakers.LoadOrStore(key, &circuitBreaker{
+		maxFailures:  node.CircuitBreaker.MaxFailures,
+		resetTimeout: resetTimeout,
+	})
+	return cb.(*circuitBreaker)
+}
+
+// fallback returns the response for a node whose circuit breaker is open. With no fallback
+// configured the open circuit error is returned so the request fails fast. Static responses
+// for gRPC must be the JSON form of the response message of the protocol.
+func (p *PredictorProcess) fallback(node *v1.PredictiveUnit, msg payload.SeldonPayload, err error) (payload.SeldonPayload, error) {
+	cb := node.CircuitBreaker
+	if cb.FallbackChild != "" {
+		for i := range node.Children {
+			if node.Children[i].Name == cb.FallbackChild {
+				p.Log.Info("Calling fallback child", "node", node.Name, "child", cb.FallbackChild)
+				return p.Predict(&node.Children[i], msg)
+			}
+		}
+		return nil, fmt.Errorf("Fallback child %s not found for predictive unit %s", cb.FallbackChild, node.Name)
+	}
+	if cb.FallbackResponse != "" {
+		p.Log.Info("Returning fallback response", "node", node.Name)
+		if p.Client.IsGrpc() {
+			res, err := fallbackProto(msg)
+			if err != nil {
+				return nil, err
+			}
+			if err := jsonpb.UnmarshalString(cb.FallbackResponse, res); err != nil {
+				return nil, err
+			}
+			return &payload.ProtoPayload{Msg: res}, nil
+		}
+		return &payload.BytesPayload{Msg: []byte(cb.FallbackResponse), ContentType: "application/json"}, nil
+	}
+	return nil, err
+}
+
+// fallbackProto returns an empty response message of the protocol of the request for a static
+// fallback response to be decoded into, so gRPC servers get the type they expect.
+func fallbackProto(msg payload.SeldonPayload) (proto2.Message, error) {
+	switch msg.GetPayload().(type) {
+	case *proto.SeldonMessage:
+		return &proto.SeldonMessage{}, nil
+	case *serving.PredictRequest, *serving.PredictResponse:
+		return &serving.PredictResponse{}, nil
+	case *inference.ModelInferRequest, *inference.ModelInferResponse:
+		return &inference.ModelInferResponse{}, nil
+	default:
+		return nil, fmt.Errorf("Circuit breaker fallbackResponse is not supported for %T messages", msg.GetPayload())
+	}
+}
@@ -0,0 +1,580 @@
+package predictor
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/golang/protobuf/jsonpb"
+	_struct "github.com/golang/protobuf/ptypes/struct"
+	"github.com/seldonio/seldon-core/executor/api/grpc/kfserving/inference"
+	"github.com/seldonio/seldon-core/executor/api/grpc/seldon/proto"
+	"github.com/seldonio/seldon-core/executor/api/payload"
+	v1 "github.com/seldonio/seldon-core/operator/apis/machinelearning.seldon.io/v1"
+)
+
+const combinerWeightsParameter = "weights"
+
+// combinerTensor is a dense row major tensor extracted from a child output.
+type combinerTensor struct {
+	shape  []int
+	values []float64
+}
+
+// combineFunc combines same shaped tensors from each child. Weights has one entry per tensor.
+type combineFunc func(tensors []*combinerTensor, weights []float64) *combinerTensor
+
+var combineFuncs = map[v1.PredictiveUnitImplementation]combineFunc{
+	v1.AVERAGE_COMBINER:       averageCombine,
+	v1.MAJORITY_VOTE_COMBINER: majorityVoteCombine,
+	v1.MAX_COMBINER:           maxCombine,
+}
+
+func isBuiltinCombiner(node *v1.PredictiveUnit) bool {
+	if node.Implementation == nil {
+		return false
+	}
+	_, ok := combineFuncs[*node.Implementation]
+	return ok
+}
+
+// averageCombine returns the element wise weighted mean.
+func averageCombine(tensors []*combinerTensor, weights []float64) *combinerTensor {
+	out := &combinerTensor{shape: tensors[0].shape, values: make([]float64, len(tensors[0].values))}
+	total := 0.0
+	for i, t := range tensors {
+		total += weights[i]
+		for j, v := range t.values {
+			out.values[j] += weights[i] * v
+		}
+	}
+	for j := range out.values {
+		out.values[j] /= total
+	}
+	return out
+}
+
+// majorityVoteCombine lets each child vote for the class with its highest score in each row and
+// returns the weighted fraction of votes per class. Outputs with a single value per row are
+// treated as labels and the most voted label is returned.
+func majorityVoteCombine(tensors []*combinerTensor, weights []float64) *combinerTensor {
+	out := &combinerTensor{shape: tensors[0].shape, values: make([]float64, len(tensors[0].values))}
+	classes := tensors[0].classes()
+	if classes > 1 {
+		total := 0.0
+		for _, w := range weights {
+			total += w
+		}
+		for r := 0; r < len(out.values)/classes; r++ {
+			for i, t := range tensors {
+				out.values[r*classes+argmax(t.values[r*classes:(r+1)*classes])] += weights[i] / total
+			}
+		}
+		return out
+	}
+	for j := range out.values {
+		votes := make(map[float64]float64)
+		best := math.Inf(-1)
+		for i, t := range tensors {
+			label := t.values[j]
+			votes[label] += weights[i]
+			if votes[label] > best {
+				best = votes[label]
+				out.values[j] = label
+			}
+		}
+	}
+	return out
+}
+
+// maxCombine returns for each row the output of the child with the highest score in that row.
+// Outputs with a single value per row are combined with an element wise maximum.
+func maxCombine(tensors []*combinerTensor, weights []float64) *combinerTensor {
+	out := &combinerTensor{shape: tensors[0].shape, values: make([]float64, len(tensors[0].values))}
+	classes := tensors[0].classes()
+	for r := 0; r < len(out.values)/classes; r++ {
+		var best []float64
+		for _, t := range tensors {
+			row := t.values[r*classes : (r+1)*classes]
+			if best == nil || row[argmax(row)] > best[argmax(best)] {
+				best = row
+			}
+		}
+		copy(out.values[r*classes:(r+1)*classes], best)
+	}
+	return out
+}
+
+func argmax(values []float64) int {
+	best := 0
+	for i, v := range values {
+		if v > values[best] {
+			best = i
+		}
+	}
+	return best
+}
+
+// classes is the size of the last dimension for tensors of rank 2 or more.
+func (t *combinerTensor) classes() int {
+	if len(t.shape) < 2 || t.shape[len(t.shape)-1] < 1 {
+		return 1
+	}
+	return t.shape[len(t.shape)-1]
+}
+
+func (t *combinerTensor) isIntegral() bool {
+	for _, v := range t.values {
+		if v != math.Trunc(v) {
+			return false
+		}
+	}
+	return true
+}
+
+// checkTensor ensures a tensor is consistent with its shape and with the first tensor to combine.
+func checkTensor(t *combinerTensor, first *combinerTensor) error {
+	size := 1
+	for _, d := range t.shape {
+		size *= d
+	}
+	if size != len(t.values) {
+		return fmt.Errorf("Shape %v does not match %d values", t.shape, len(t.values))
+	}
+	if first == nil {
+		return nil
+	}
+	mismatch := len(t.shape) != len(first.shape)
+	for i := 0; !mismatch && i < len(t.shape); i++ {
+		mismatch = t.shape[i] != first.shape[i]
+	}
+	if mismatch {
+		return fmt.Errorf("Can't combine outputs with shapes %v and %v", first.shape, t.shape)
+	}
+	return nil
+}
+
+// getCombinerWeights returns the weight of each child output. Indexes are the positions of the
+// children that produced the outputs as some may be missing under a children policy.
+func getCombinerWeights(node *v1.PredictiveUnit, indexes []int) ([]float64, error) {
+	weights := make([]float64, len(indexes))
+	for i := range weights {
+		weights[i] = 1
+	}
+	for _, param := range node.Parameters {
+		if param.Name != combinerWeightsParameter {
+			continue
+		}
+		parts := strings.Split(param.Value, ",")
+		if len(parts) != len(node.Children) {
+			return nil, fmt.Errorf("Combiner %s has %d weights for %d children", node.Name, len(parts), len(node.Children))
+		}
+		for i, idx := range indexes {
+			w, err := strconv.ParseFloat(strings.TrimSpace(parts[idx]), 64)
+			if err != nil {
+				return nil, err
+			}
+			weights[i] = w
+		}
+	}
+	total := 0.0
+	for _, w := range weights {
+		total += w
+	}
+	if total <= 0 {
+		return nil, fmt.Errorf("Combiner %s weights must sum to a positive value", node.Name)
+	}
+	return weights, nil
+}
+
+// combine merges the outputs of children with a built in combiner.
+func (p *PredictorProcess) combine(node *v1.PredictiveUnit, msgs []payload.SeldonPayload, indexes []int) (payload.SeldonPayload, error) {
+	if len(msgs) == 1 {
+		return msgs[0], nil
+	}
+	weights, err := getCombinerWeights(node, indexes)
+	if err != nil {
+		return nil, err
+	}
+	f := combineFuncs[*node.Implementation]
+	switch msgs[0].GetPayload().(type) {
+	case *proto.SeldonMessage:
+		sms := make([]*proto.SeldonMessage, len(msgs))
+		for i, msg := range msgs {
+			if sms[i], err = toSeldonMessage(msg); err != nil {
+				return nil, err
+			}
+		}
+		sm, err := combineSeldonMessages(sms, weights, f)
+		if err != nil {
+			return nil, err
+		}
+		return &payload.ProtoPayload{Msg: sm}, nil
+	case *inference.ModelInferResponse:
+		responses := make([]*inference.ModelInferResponse, len(msgs))
+		for i, msg := range msgs {
+			var ok bool
+			if responses[i], ok = msg.GetPayload().(*inference.ModelInferResponse); !ok {
+				return nil, fmt.Errorf("Combiner %s received mixed payload types", node.Name)
+			}
+		}
+		resp, err := combineInferResponses(responses, weights, f)
+		if err != nil {
+			return nil, err
+		}
+		return &payload.ProtoPayload{Msg: resp}, nil
+	case []byte:
+		var res []byte
+		if isV2Json(msgs[0]) {
+			res, err = combineV2Json(msgs, weights, f)
+		} else {
+			res, err = combineSeldonJson(msgs, weights, f)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return &payload.BytesPayload{Msg: res, ContentType: msgs[0].GetContentType()}, nil
+	default:
+		return nil, fmt.Errorf("Combiner %s does not support payload type %T", node.Name, msgs[0].GetPayload())
+	}
+}
+
+func toSeldonMessage(msg payload.SeldonPayload) (*proto.SeldonMessage, error) {
+	switch v := msg.GetPayload().(type) {
+	case *proto.SeldonMessage:
+		return v, nil
+	case []byte:
+		sm := &proto.SeldonMessage{}
+		unmarshaler := jsonpb.Unmarshaler{AllowUnknownFields: true}
+		if err := unmarshaler.Unmarshal(bytes.NewReader(v), sm); err != nil {
+			return nil, err
+		}
+		return sm, nil
+	default:
+		return nil, fmt.Errorf("Can't combine payload type %T", v)
+	}
+}
+
+// --- SeldonMessage
+
+func combineSeldonJson(msgs []payload.SeldonPayload, weights []float64, f combineFunc) ([]byte, error) {
+	sms := make([]*proto.SeldonMessage, len(msgs))
+	var err error
+	for i, msg := range msgs {
+		if sms[i], err = toSeldonMessage(msg); err != nil {
+			return nil, err
+		}
+	}
+	sm, err := combineSeldonMessages(sms, weights, f)
+	if err != nil {
+		return nil, err
+	}
+	ma := jsonpb.Marshaler{}
+	s, err := ma.MarshalToString(sm)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(s), nil
+}
+
+func combineSeldonMessages(sms []*proto.SeldonMessage, weights []float64, f combineFunc) (*proto.SeldonMessage, error) {
+	tensors := make([]*combinerTensor, len(sms))
+	for i, sm := range sms {
+		t, err := seldonMessageTensor(sm)
+		if err != nil {
+			return nil, err
+		}
+		if err := checkTensor(t, tensors[0]); err != nil {
+			return nil, err
+		}
+		tensors[i] = t
+	}
+	out := f(tensors, weights)
+
+	// Keep names and meta of the first output
+	data := &proto.DefaultData{Names: sms[0].GetData().GetNames()}
+	switch sms[0].GetData().GetDataOneof().(type) {
+	case *proto.DefaultData_Tensor:
+		data.DataOneof = &proto.DefaultData_Tensor{Tensor: &proto.Tensor{Shape: sms[0].GetData().GetTensor().GetShape(), Values: out.values}}
+	default:
+		values, _ := toListValue(out.shape, out.values)
+		data.DataOneof = &proto.DefaultData_Ndarray{Ndarray: values}
+	}
+	return &proto.SeldonMessage{Status: sms[0].GetStatus(), Meta: sms[0].GetMeta(), DataOneof: &proto.SeldonMessage_Data{Data: data}}, nil
+}
+
+func seldonMessageTensor(sm *proto.SeldonMessage) (*combinerTensor, error) {
+	switch v := sm.GetData().GetDataOneof().(type) {
+	case *proto.DefaultData_Tensor:
+		shape := make([]int, len(v.Tensor.GetShape()))
+		for i, d := range v.Tensor.GetShape() {
+			shape[i] = int(d)
+		}
+		return &combinerTensor{shape: shape, values: v.Tensor.GetValues()}, nil
+	case *proto.DefaultData_Ndarray:
+		t := &combinerTensor{}
+		if err := flattenListValue(v.Ndarray, 0, t); err != nil {
+			return nil, err
+		}
+		return t, nil
+	default:
+		return nil, fmt.Errorf("Built in combiners only support ndarray and tensor data")
+	}
+}
+
+// flattenListValue appends the numbers of a rectangular nested list to the tensor recording its shape.
+func flattenListValue(lv *_struct.ListValue, depth int, t *combinerTensor) error {
+	values := lv.GetValues()
+	if len(t.shape) == depth {
+		t.shape = append(t.shape, len(values))
+	} else if t.shape[depth] != len(values) {
+		return fmt.Errorf("Ndarray is not rectangular")
+	}
+	for _, value := range values {
+		switch k := value.GetKind().(type) {
+		case *_struct.Value_NumberValue:
+			if len(t.shape) != depth+1 {
+				return fmt.Errorf("Ndarray is not rectangular")
+			}
+			t.values = append(t.values, k.NumberValue)
+		case *_struct.Value_ListValue:
+			if err := flattenListValue(k.ListValue, depth+1, t); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("Built in combiners only support numeric ndarrays")
+		}
+	}
+	return nil
+}
+
+func toListValue(shape []int, values []float64) (*_struct.ListValue, []float64) {
+	lv := &_struct.ListValue{Values: make([]*_struct.Value, shape[0])}
+	for i := range lv.Values {
+		if len(shape) == 1 {
+			lv.Values[i] = &_struct.Value{Kind: &_struct.Value_NumberValue{NumberValue: values[0]}}
+			values = values[1:]
+		} else {
+			var inner *_struct.ListValue
+			inner, values = toListValue(shape[1:], values)
+			lv.Values[i] = &_struct.Value{Kind: &_struct.Value_ListValue{ListValue: inner}}
+		}
+	}
+	return lv, values
+}
+
+// --- KFServing V2
+
+func combinedDatatype(datatype string, t *combinerTensor) string {
+	if strings.HasPrefix(datatype, "FP") || t.isIntegral() {
+		return datatype
+	}
+	return "FP64"
+}
+
+func combineInferResponses(responses []*inference.ModelInferResponse, weights []float64, f combineFunc) (*inference.ModelInferResponse, error) {
+	first := responses[0]
+	out := &inference.ModelInferResponse{
+		ModelName:    first.ModelName,
+		ModelVersion: first.ModelVersion,
+		Id:           first.Id,
+		Parameters:   first.Parameters,
+		Outputs:      make([]*inference.ModelInferResponse_InferOutputTensor, len(first.Outputs)),
+	}
+	for o, output := range first.Outputs {
+		tensors := make([]*combinerTensor, len(responses))
+		for i, resp := range responses {
+			if len(resp.Outputs) != len(first.Outputs) {
+				return nil, fmt.Errorf("Can't combine responses with %d and %d outputs", len(first.Outputs), len(resp.Outputs))
+			}
+			t, err := inferOutputTensor(resp.Outputs[o])
+			if err != nil {
+				return nil, err
+			}
+			if err := checkTensor(t, tensors[0]); err != nil {
+				return nil, err
+			}
+			tensors[i] = t
+		}
+		combined := f(tensors, weights)
+		datatype := combinedDatatype(output.Datatype, combined)
+		contents, err := inferTensorContents(datatype, combined.values)
+		if err != nil {
+			return nil, err
+		}
+		out.Outputs[o] = &inference.ModelInferResponse_InferOutputTensor{
+			Name:       output.Name,
+			Datatype:   datatype,
+			Shape:      output.Shape,
+			Parameters: output.Parameters,
+			Contents:   contents,
+		}
+	}
+	return out, nil
+}
+
+func inferOutputTensor(output *inference.ModelInferResponse_InferOutputTensor) (*combinerTensor, error) {
+	t := &combinerTensor{shape: make([]int, len(output.Shape))}
+	for i, d := range output.Shape {
+		t.shape[i] = int(d)
+	}
+	c := output.GetContents()
+	switch output.Datatype {
+	case "BOOL":
+		for _, v := range c.GetBoolContents() {
+			if v {
+				t.values = append(t.values, 1)
+			} else {
+				t.values = append(t.values, 0)
+			}
+		}
+	case "INT8", "INT16", "INT32":
+		for _, v := range c.GetIntContents() {
+			t.values = append(t.values, float64(v))
+		}
+	case "INT64":
+		for _, v := range c.GetInt64Contents() {
+			t.values = append(t.values, float64(v))
+		}
+	case "UINT8", "UINT16", "UINT32":
+		for _, v := range c.GetUintContents() {
+			t.values = append(t.values, float64(v))
+		}
+	case "UINT64":
+		for _, v := range c.GetUint64Contents() {
+			t.values = append(t.values, float64(v))
+		}
+	case "FP32":
+		for _, v := range c.GetFp32Contents() {
+			t.values = append(t.values, float64(v))
+		}
+	case "FP64":
+		t.values = append(t.values, c.GetFp64Contents()...)
+	default:
+		return nil, fmt.Errorf("Built in combiners do not support datatype %s", output.Datatype)
+	}
+	return t, nil
+}
+
+func inferTensorContents(datatype string, values []float64) (*inference.InferTensorContents, error) {
+	c := &inference.InferTensorContents{}
+	for _, v := range values {
+		switch datatype {
+		case "BOOL":
+			c.BoolContents = append(c.BoolContents, v != 0)
+		case "INT8", "INT16", "INT32":
+			c.IntContents = append(c.IntContents, int32(v))
+		case "INT64":
+			c.Int64Contents = append(c.Int64Contents, int64(v))
+		case "UINT8", "UINT16", "UINT32":
+			c.UintContents = append(c.UintContents, uint32(v))
+		case "UINT64":
+			c.Uint64Contents = append(c.Uint64Contents, uint64(v))
+		case "FP32":
+			c.Fp32Contents = append(c.Fp32Contents, float32(v))
+		case "FP64":
+			c.Fp64Contents = append(c.Fp64Contents, v)
+		default:
+			return nil, fmt.Errorf("Built in combiners do not support datatype %s", datatype)
+		}
+	}
+	return c, nil
+}
+
+func isV2Json(msg payload.SeldonPayload) bool {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(msg.GetPayload().([]byte), &fields); err != nil {
+		return false
+	}
+	_, ok := fields["outputs"]
+	return ok
+}
+
+func combineV2Json(msgs []payload.SeldonPayload, weights []float64, f combineFunc) ([]byte, error) {
+	responses := make([]map[string]interface{}, len(msgs))
+	outputs := make([][]interface{}, len(msgs))
+	for i, msg := range msgs {
+		if err := json.Unmarshal(msg.GetPayload().([]byte), &responses[i]); err != nil {
+			return nil, err
+		}
+		var ok bool
+		if outputs[i], ok = responses[i]["outputs"].([]interface{}); !ok || len(outputs[i]) != len(outputs[0]) {
+			return nil, fmt.Errorf("Can't combine responses with different outputs")
+		}
+	}
+	for o := range outputs[0] {
+		tensors := make([]*combinerTensor, len(msgs))
+		for i := range msgs {
+			output, ok := outputs[i][o].(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("Invalid output in response")
+			}
+			t, err := v2JsonTensor(output)
+			if err != nil {
+				return nil, err
+			}
+			if err := checkTensor(t, tensors[0]); err != nil {
+				return nil, err
+			}
+			tensors[i] = t
+		}
+		combined := f(tensors, weights)
+		output := outputs[0][o].(map[string]interface{})
+		datatype, _ := output["datatype"].(string)
+		// Averages of booleans which are not all true or all false are returned as FP64
+		datatype = combinedDatatype(datatype, combined)
+		output["datatype"] = datatype
+		if datatype == "BOOL" {
+			data := make([]bool, len(combined.values))
+			for i, v := range combined.values {
+				data[i] = v != 0
+			}
+			output["data"] = data
+		} else {
+			output["data"] = combined.values
+		}
+	}
+	return json.Marshal(responses[0])
+}
+
+func v2JsonTensor(output map[string]interface{}) (*combinerTensor, error) {
+	t := &combinerTensor{}
+	shape, _ := output["shape"].([]interface{})
+	for _, d := range shape {
+		n, ok := d.(float64)
+		if !ok {
+			return nil, fmt.Errorf("Invalid shape in output")
+		}
+		t.shape = append(t.shape, int(n))
+	}
+	if err := flattenJson(output["data"], t); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// flattenJson collects numbers from a flat or nested data array.
+func flattenJson(data interface{}, t *combinerTensor) error {
+	switch v := data.(type) {
+	case float64:
+		t.values = append(t.values, v)
+	case bool:
+		if v {
+			t.values = append(t.values, 1)
+		} else {
+			t.values = append(t.values, 0)
+		}
+	case []interface{}:
+		for _, e := range v {
+			if err := flattenJson(e, t); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("Built in combiners only support numeric data")
+	}
+	return nil
+}
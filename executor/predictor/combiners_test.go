@@ -0,0 +1,167 @@
+package predictor
+
+import (
+	"testing"
+
+	"github.com/golang/protobuf/jsonpb"
+	. "github.com/onsi/gomega"
+	"github.com/seldonio/seldon-core/executor/api/grpc/kfserving/inference"
+	"github.com/seldonio/seldon-core/executor/api/grpc/seldon/proto"
+	"github.com/seldonio/seldon-core/executor/api/payload"
+	"github.com/seldonio/seldon-core/operator/apis/machinelearning.seldon.io/v1"
+)
+
+func createCombinerNode(impl v1.PredictiveUnitImplementation, weights string) *v1.PredictiveUnit {
+	node := &v1.PredictiveUnit{
+		Name:           "combiner",
+		Implementation: &impl,
+		Children:       []v1.PredictiveUnit{{Name: "a"}, {Name: "b"}, {Name: "c"}},
+	}
+	if weights != "" {
+		node.Parameters = []v1.Parameter{{Name: "weights", Value: weights, Type: v1.STRING}}
+	}
+	return node
+}
+
+func createSeldonJsonPayloads(msgs ...string) []payload.SeldonPayload {
+	payloads := make([]payload.SeldonPayload, len(msgs))
+	for i, msg := range msgs {
+		payloads[i] = &payload.BytesPayload{Msg: []byte(msg), ContentType: "application/json"}
+	}
+	return payloads
+}
+
+func TestAverageCombinerSeldonJson(t *testing.T) {
+	g := NewGomegaWithT(t)
+	pp := createPredictorProcess(t)
+
+	msgs := createSeldonJsonPayloads(
+		`{"data":{"names":["a","b"],"ndarray":[[0.25,0.75]]}}`,
+		`{"data":{"names":["a","b"],"ndarray":[[0.5,0.5]]}}`,
+		`{"data":{"names":["a","b"],"ndarray":[[0.75,0.25]]}}`,
+	)
+	res, err := pp.combine(createCombinerNode(v1.AVERAGE_COMBINER, ""), msgs, []int{0, 1, 2})
+	g.Expect(err).To(BeNil())
+	g.Expect(string(res.GetPayload().([]byte))).To(Equal(`{"data":{"names":["a","b"],"ndarray":[[0.5,0.5]]}}`))
+
+	res, err = pp.combine(createCombinerNode(v1.AVERAGE_COMBINER, "1,1,2"), msgs, []int{0, 1, 2})
+	g.Expect(err).To(BeNil())
+	g.Expect(string(res.GetPayload().([]byte))).To(Equal(`{"data":{"names":["a","b"],"ndarray":[[0.5625,0.4375]]}}`))
+
+	// Weights follow the children that succeeded
+	res, err = pp.combine(createCombinerNode(v1.AVERAGE_COMBINER, "1,1,2"), msgs[1:], []int{1, 2})
+	g.Expect(err).To(BeNil())
+	smRes := &proto.SeldonMessage{}
+	g.Expect(jsonpb.UnmarshalString(string(res.GetPayload().([]byte)), smRes)).To(BeNil())
+	g.Expect(smRes.GetData().GetNdarray().Values[0].GetListValue().Values[0].GetNumberValue()).To(BeNumerically("~", 2.0/3))
+}
+
+func TestMajorityVoteCombinerSeldonTensor(t *testing.T) {
+	g := NewGomegaWithT(t)
+	pp := createPredictorProcess(t)
+
+	var msgs []payload.SeldonPayload
+	for _, data := range []string{
+		`{"data":{"tensor":{"shape":[2,2],"values":[0.9,0.1,0.2,0.8]}}}`,
+		`{"data":{"tensor":{"shape":[2,2],"values":[0.6,0.4,0.7,0.3]}}}`,
+		`{"data":{"tensor":{"shape":[2,2],"values":[0.3,0.7,0.1,0.9]}}}`,
+	} {
+		var sm proto.SeldonMessage
+		g.Expect(jsonpb.UnmarshalString(data, &sm)).To(BeNil())
+		msgs = append(msgs, &payload.ProtoPayload{Msg: &sm})
+	}
+	res, err := pp.combine(createCombinerNode(v1.MAJORITY_VOTE_COMBINER, ""), msgs, []int{0, 1, 2})
+	g.Expect(err).To(BeNil())
+	tensor := res.GetPayload().(*proto.SeldonMessage).GetData().GetTensor()
+	g.Expect(tensor.Shape).To(Equal([]int32{2, 2}))
+	g.Expect(tensor.Values).To(Equal([]float64{2.0 / 3, 1.0 / 3, 1.0 / 3, 2.0 / 3}))
+}
+
+func TestMajorityVoteCombinerLabels(t *testing.T) {
+	g := NewGomegaWithT(t)
+	pp := createPredictorProcess(t)
+
+	msgs := createSeldonJsonPayloads(
+		`{"data":{"ndarray":[1,2]}}`,
+		`{"data":{"ndarray":[3,2]}}`,
+		`{"data":{"ndarray":[3,1]}}`,
+	)
+	res, err := pp.combine(createCombinerNode(v1.MAJORITY_VOTE_COMBINER, ""), msgs, []int{0, 1, 2})
+	g.Expect(err).To(BeNil())
+	g.Expect(string(res.GetPayload().([]byte))).To(Equal(`{"data":{"ndarray":[3,2]}}`))
+}
+
+func TestMaxCombinerV2Json(t *testing.T) {
+	g := NewGomegaWithT(t)
+	pp := createPredictorProcess(t)
+
+	msgs := createSeldonJsonPayloads(
+		`{"model_name":"a","outputs":[{"name":"predict","shape":[2,2],"datatype":"FP32","data":[0.9,0.1,0.4,0.6]}]}`,
+		`{"model_name":"b","outputs":[{"name":"predict","shape":[2,2],"datatype":"FP32","data":[0.5,0.5,0.2,0.8]}]}`,
+		`{"model_name":"c","outputs":[{"name":"predict","shape":[2,2],"datatype":"FP32","data":[[0.7,0.3],[0.3,0.7]]}]}`,
+	)
+	res, err := pp.combine(createCombinerNode(v1.MAX_COMBINER, ""), msgs, []int{0, 1, 2})
+	g.Expect(err).To(BeNil())
+	g.Expect(string(res.GetPayload().([]byte))).To(Equal(`{"model_name":"a","outputs":[{"data":[0.9,0.1,0.2,0.8],"datatype":"FP32","name":"predict","shape":[2,2]}]}`))
+}
+
+func TestAverageCombinerV2JsonBool(t *testing.T) {
+	g := NewGomegaWithT(t)
+	pp := createPredictorProcess(t)
+
+	msgs := createSeldonJsonPayloads(
+		`{"outputs":[{"name":"predict","shape":[2],"datatype":"BOOL","data":[true,true]}]}`,
+		`{"outputs":[{"name":"predict","shape":[2],"datatype":"BOOL","data":[true,false]}]}`,
+	)
+	res, err := pp.combine(createCombinerNode(v1.AVERAGE_COMBINER, ""), msgs, []int{0, 1})
+	g.Expect(err).To(BeNil())
+	g.Expect(string(res.GetPayload().([]byte))).To(Equal(`{"outputs":[{"data":[1,0.5],"datatype":"FP64","name":"predict","shape":[2]}]}`))
+
+	msgs = createSeldonJsonPayloads(
+		`{"outputs":[{"name":"predict","shape":[2],"datatype":"BOOL","data":[true,false]}]}`,
+		`{"outputs":[{"name":"predict","shape":[2],"datatype":"BOOL","data":[true,false]}]}`,
+	)
+	res, err = pp.combine(createCombinerNode(v1.AVERAGE_COMBINER, ""), msgs, []int{0, 1})
+	g.Expect(err).To(BeNil())
+	g.Expect(string(res.GetPayload().([]byte))).To(Equal(`{"outputs":[{"data":[true,false],"datatype":"BOOL","name":"predict","shape":[2]}]}`))
+}
+
+func TestAverageCombinerV2Grpc(t *testing.T) {
+	g := NewGomegaWithT(t)
+	pp := createPredictorProcess(t)
+
+	var msgs []payload.SeldonPayload
+	for _, values := range [][]int64{{1, 2}, {2, 3}, {3, 5}} {
+		msgs = append(msgs, &payload.ProtoPayload{Msg: &inference.ModelInferResponse{
+			ModelName: "model",
+			Outputs: []*inference.ModelInferResponse_InferOutputTensor{
+				{
+					Name:     "predict",
+					Datatype: "INT64",
+					Shape:    []int64{2},
+					Contents: &inference.InferTensorContents{Int64Contents: values},
+				},
+			},
+		}})
+	}
+	res, err := pp.combine(createCombinerNode(v1.AVERAGE_COMBINER, ""), msgs, []int{0, 1, 2})
+	g.Expect(err).To(BeNil())
+	output := res.GetPayload().(*inference.ModelInferResponse).Outputs[0]
+	g.Expect(output.Datatype).To(Equal("FP64"))
+	g.Expect(output.Contents.Fp64Contents).To(Equal([]float64{2, 10.0 / 3}))
+
+	_, err = pp.combine(createCombinerNode(v1.AVERAGE_COMBINER, ""), append(msgs, createPredictPayload(g)), []int{0, 1, 2, 2})
+	g.Expect(err).ToNot(BeNil())
+}
+
+func TestAverageCombinerShapeMismatch(t *testing.T) {
+	g := NewGomegaWithT(t)
+	pp := createPredictorProcess(t)
+
+	msgs := createSeldonJsonPayloads(
+		`{"data":{"ndarray":[[1,2]]}}`,
+		`{"data":{"ndarray":[[1,2,3]]}}`,
+	)
+	_, err := pp.combine(createCombinerNode(v1.AVERAGE_COMBINER, ""), msgs, []int{0, 1})
+	g.Expect(err).ToNot(BeNil())
+}
@@ -1,24 +1,217 @@
 package predictor
 
 import (
-	"github.com/seldonio/seldon-core/operator/apis/machinelearning.seldon.io/v1"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
 	"math/rand"
 	"strconv"
+	"strings"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+	"github.com/seldonio/seldon-core/executor/api/payload"
+	"github.com/seldonio/seldon-core/operator/apis/machinelearning.seldon.io/v1"
 )
 
+// abTestRouter picks a child at random. Weights are given as a comma separated list with one entry
+// per child, or for two children as ratioA, and default to an even split.
 func (p *PredictorProcess) abTestRouter(node *v1.PredictiveUnit) (int, error) {
-	ratioA := 0.5
-	var err error
-	if len(node.Parameters) == 1 && node.Parameters[0].Name == "ratioA" {
-		ratioA, err = strconv.ParseFloat(node.Parameters[0].Value, 32)
+	weights := make([]float64, len(node.Children))
+	for i := range weights {
+		weights[i] = 1 / float64(len(weights))
+	}
+	for _, param := range node.Parameters {
+		switch param.Name {
+		case "ratioA":
+			if len(weights) != 2 {
+				return 0, fmt.Errorf("Router %s can only use ratioA with two children", node.Name)
+			}
+			ratioA, err := strconv.ParseFloat(param.Value, 64)
+			if err != nil {
+				return 0, err
+			}
+			weights[0], weights[1] = ratioA, 1-ratioA
+		case "weights":
+			parts := strings.Split(param.Value, ",")
+			if len(parts) != len(weights) {
+				return 0, fmt.Errorf("Router %s has %d weights for %d children", node.Name, len(parts), len(weights))
+			}
+			for i, part := range parts {
+				w, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+				if err != nil {
+					return 0, err
+				}
+				weights[i] = w
+			}
+		}
+	}
+
+	total := 0.0
+	for _, w := range weights {
+		total += w
+	}
+	r := rand.Float64() * total
+	for i, w := range weights {
+		if r < w {
+			return i, nil
+		}
+		r -= w
+	}
+	return len(weights) - 1, nil
+}
+
+const (
+	routerHeaderParameter   = "header"
+	routerJsonPathParameter = "jsonPath"
+	routerMappingParameter  = "mapping"
+	routerDefaultParameter  = "default"
+)
+
+func getParameter(node *v1.PredictiveUnit, name string) string {
+	for _, param := range node.Parameters {
+		if param.Name == name {
+			return param.Value
+		}
+	}
+	return ""
+}
+
+func getChildIndex(node *v1.PredictiveUnit, name string) (int, error) {
+	for i, child := range node.Children {
+		if child.Name == name {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("Router %s has no child %s", node.Name, name)
+}
+
+// routingKey extracts the value a router routes on from a request header or a JSON path in the payload.
+func (p *PredictorProcess) routingKey(node *v1.PredictiveUnit, msg payload.SeldonPayload) (string, bool, error) {
+	if header := getParameter(node, routerHeaderParameter); header != "" {
+		for k, vv := range p.Meta.Meta {
+			if strings.EqualFold(k, header) && len(vv) > 0 {
+				return vv[0], true, nil
+			}
+		}
+		return "", false, nil
+	}
+	path := getParameter(node, routerJsonPathParameter)
+	if path == "" {
+		return "", false, fmt.Errorf("Router %s needs a %s or %s parameter", node.Name, routerHeaderParameter, routerJsonPathParameter)
+	}
+	var data []byte
+	switch v := msg.GetPayload().(type) {
+	case []byte:
+		data = v
+	case proto.Message:
+		ma := jsonpb.Marshaler{}
+		s, err := ma.MarshalToString(v)
 		if err != nil {
-			return 0, err
+			return "", false, err
+		}
+		data = []byte(s)
+	default:
+		return "", false, fmt.Errorf("Router %s can't read payload type %T", node.Name, v)
+	}
+	var doc interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return "", false, err
+	}
+	value, ok := lookupJsonPath(doc, path)
+	if !ok {
+		return "", false, nil
+	}
+	switch v := value.(type) {
+	case string:
+		return v, true, nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), true, nil
+	case bool:
+		return strconv.FormatBool(v), true, nil
+	default:
+		b, err := json.Marshal(v)
+		return string(b), true, err
+	}
+}
+
+// lookupJsonPath resolves a path such as $.meta.tags.user or jsonData.users[0].id in a decoded JSON document.
+func lookupJsonPath(doc interface{}, path string) (interface{}, bool) {
+	path = strings.TrimPrefix(strings.TrimPrefix(path, "$"), ".")
+	for _, segment := range strings.Split(path, ".") {
+		key := segment
+		var indexes []string
+		if i := strings.Index(segment, "["); i >= 0 {
+			key = segment[:i]
+			indexes = strings.Split(strings.TrimSuffix(segment[i+1:], "]"), "][")
+		}
+		if key != "" {
+			obj, ok := doc.(map[string]interface{})
+			if !ok {
+				return nil, false
+			}
+			if doc, ok = obj[key]; !ok {
+				return nil, false
+			}
+		}
+		for _, index := range indexes {
+			arr, ok := doc.([]interface{})
+			n, err := strconv.Atoi(index)
+			if !ok || err != nil || n < 0 || n >= len(arr) {
+				return nil, false
+			}
+			doc = arr[n]
 		}
 	}
+	return doc, true
+}
 
-	if rand.Float64() < ratioA {
-		return 0, nil
-	} else {
-		return 1, nil
+// defaultRoute returns the default child of a router when no route matches.
+func defaultRoute(node *v1.PredictiveUnit, reason string) (int, error) {
+	if def := getParameter(node, routerDefaultParameter); def != "" {
+		return getChildIndex(node, def)
+	}
+	return 0, fmt.Errorf("Router %s %s and has no default child", node.Name, reason)
+}
+
+// headerRouter picks the child mapped to the routing key. The mapping parameter is a list of value:child entries.
+func (p *PredictorProcess) headerRouter(node *v1.PredictiveUnit, msg payload.SeldonPayload) (int, error) {
+	key, ok, err := p.routingKey(node, msg)
+	if err != nil {
+		return 0, err
+	}
+	if !ok {
+		return defaultRoute(node, "found no routing key")
+	}
+	for _, entry := range strings.Split(getParameter(node, routerMappingParameter), ",") {
+		parts := strings.SplitN(entry, ":", 2)
+		if len(parts) == 2 && strings.TrimSpace(parts[0]) == key {
+			return getChildIndex(node, strings.TrimSpace(parts[1]))
+		}
+	}
+	return defaultRoute(node, "has no mapping for "+key)
+}
+
+// consistentHashRouter assigns each routing key to a child with rendezvous hashing so the same key
+// always goes to the same child and only keys of a removed child move when children change.
+func (p *PredictorProcess) consistentHashRouter(node *v1.PredictiveUnit, msg payload.SeldonPayload) (int, error) {
+	key, ok, err := p.routingKey(node, msg)
+	if err != nil {
+		return 0, err
+	}
+	if !ok {
+		return defaultRoute(node, "found no routing key")
+	}
+	best := 0
+	var bestHash uint64
+	for i, child := range node.Children {
+		h := fnv.New64a()
+		h.Write([]byte(key))
+		h.Write([]byte{0})
+		h.Write([]byte(child.Name))
+		if sum := h.Sum64(); i == 0 || sum > bestHash {
+			best, bestHash = i, sum
+		}
 	}
+	return best, nil
 }
@@ -0,0 +1,76 @@
+package predictor
+
+import (
+	"os"
+	"sync"
+
+	"github.com/seldonio/seldon-core/executor/api/grpc/seldon/proto"
+	"github.com/seldonio/seldon-core/executor/api/payload"
+	"github.com/seldonio/seldon-core/executor/api/util"
+	v1 "github.com/seldonio/seldon-core/operator/apis/machinelearning.seldon.io/v1"
+)
+
+const (
+	// What to do with the custom metrics of the nodes in the response: merge or strip.
+	ENV_CUSTOM_METRICS_RESPONSE = "SELDON_CUSTOM_METRICS_RESPONSE"
+	CustomMetricsMerge          = "merge"
+	CustomMetricsStrip          = "strip"
+)
+
+var envCustomMetricsResponse = os.Getenv(ENV_CUSTOM_METRICS_RESPONSE)
+
+// customMetricsRecorder collects the custom metrics of nodes that may be walked concurrently.
+type customMetricsRecorder struct {
+	mu      sync.Mutex
+	metrics []*proto.Metric
+}
+
+func (r *customMetricsRecorder) add(metrics []*proto.Metric) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.metrics = append(r.metrics, metrics...)
+}
+
+func (r *customMetricsRecorder) snapshot() []*proto.Metric {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]*proto.Metric{}, r.metrics...)
+}
+
+// collectMetrics exports the custom metrics in the meta of a node response and removes them from the
+// response, so they are counted once even if the response is passed on to other nodes.
+func (p *PredictorProcess) collectMetrics(node *v1.PredictiveUnit, msg payload.SeldonPayload) payload.SeldonPayload {
+	metrics, res, err := util.ExtractMetricsFromSeldonPayload(msg)
+	if err != nil {
+		p.Log.Error(err, "Failed to read custom metrics", "node", node.Name)
+		return msg
+	}
+	if len(metrics) == 0 {
+		return msg
+	}
+	if err := nodeMetrics.RecordCustom(node.Name, metrics); err != nil {
+		p.Log.Error(err, "Failed to export custom metrics", "node", node.Name)
+	}
+	if p.customMetrics != nil {
+		p.customMetrics.add(metrics)
+	}
+	return res
+}
+
+// insertMetrics adds the custom metrics of all nodes to the response of the graph unless they are
+// configured to be stripped.
+func (p *PredictorProcess) insertMetrics(msg payload.SeldonPayload) payload.SeldonPayload {
+	if envCustomMetricsResponse == CustomMetricsStrip || p.customMetrics == nil {
+		return msg
+	}
+	metrics := p.customMetrics.snapshot()
+	if len(metrics) == 0 {
+		return msg
+	}
+	res, err := util.InsertMetricsToSeldonPredictPayload(msg, metrics)
+	if err != nil {
+		p.Log.Error(err, "Failed to add custom metrics to response")
+		return msg
+	}
+	return res
+}
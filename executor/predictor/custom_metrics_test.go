@@ -0,0 +1,92 @@
+package predictor
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/golang/protobuf/jsonpb"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/seldonio/seldon-core/executor/api/grpc/seldon/proto"
+	"github.com/seldonio/seldon-core/executor/api/metric"
+	"github.com/seldonio/seldon-core/executor/api/payload"
+	"github.com/seldonio/seldon-core/operator/apis/machinelearning.seldon.io/v1"
+)
+
+const testMetricsMessage = `{"meta":{"metrics":[{"key":"custom_requests_total","type":"COUNTER","value":2,"tags":{"user":"a"}}]},"data":{"ndarray":[1.1,2.0]}}`
+
+func createCustomMetricsGraph() *v1.PredictiveUnit {
+	model := v1.MODEL
+	return &v1.PredictiveUnit{
+		Name:     "custom-a",
+		Type:     &model,
+		Endpoint: &v1.Endpoint{ServiceHost: "foo", ServicePort: 9000, Type: v1.REST},
+		Children: []v1.PredictiveUnit{
+			{
+				Name:     "custom-b",
+				Type:     &model,
+				Endpoint: &v1.Endpoint{ServiceHost: "foo2", ServicePort: 9001, Type: v1.REST},
+			},
+		},
+	}
+}
+
+// gatherCustomCounter returns the value of a custom counter for a node.
+func gatherCustomCounter(g *GomegaWithT, name string, node string) float64 {
+	families, err := prometheus.DefaultGatherer.Gather()
+	g.Expect(err).To(BeNil())
+	total := 0.0
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+		for _, m := range family.GetMetric() {
+			for _, label := range m.GetLabel() {
+				if label.GetName() == metric.ModelNameMetric && label.GetValue() == node {
+					total += m.GetCounter().GetValue()
+				}
+			}
+		}
+	}
+	return total
+}
+
+func TestCustomMetricsJson(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	graph := createCustomMetricsGraph()
+	SetNodeMetrics(metric.NewNodeMetrics(&v1.PredictorSpec{Name: "p", Graph: *graph}, "dep", nil))
+	defer SetNodeMetrics(nil)
+
+	// The test client returns the request, so the metrics are returned by the first node and
+	// removed before the request is sent to the second
+	msg := &payload.BytesPayload{Msg: []byte(testMetricsMessage), ContentType: contentTypeJSON}
+	pResp, err := createPredictorProcess(t).Predict(graph, msg)
+	g.Expect(err).Should(BeNil())
+
+	var res struct {
+		Meta struct {
+			Metrics []map[string]interface{} `json:"metrics"`
+		} `json:"meta"`
+	}
+	g.Expect(json.Unmarshal(pResp.GetPayload().([]byte), &res)).To(BeNil())
+	g.Expect(res.Meta.Metrics).To(HaveLen(1))
+	g.Expect(res.Meta.Metrics[0]["key"]).To(Equal("custom_requests_total"))
+	g.Expect(res.Meta.Metrics[0]["tags"]).To(Equal(map[string]interface{}{"user": "a"}))
+
+	g.Expect(gatherCustomCounter(g, "custom_requests_total", "custom-a")).To(Equal(2.0))
+	g.Expect(gatherCustomCounter(g, "custom_requests_total", "custom-b")).To(Equal(0.0))
+}
+
+func TestCustomMetricsProtoStripped(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	envCustomMetricsResponse = CustomMetricsStrip
+	defer func() { envCustomMetricsResponse = "" }()
+
+	var sm proto.SeldonMessage
+	g.Expect(jsonpb.UnmarshalString(testMetricsMessage, &sm)).To(BeNil())
+	pResp, err := createPredictorProcess(t).Predict(createCustomMetricsGraph(), &payload.ProtoPayload{Msg: &sm})
+	g.Expect(err).Should(BeNil())
+	g.Expect(pResp.GetPayload().(*proto.SeldonMessage).GetMeta().GetMetrics()).To(BeEmpty())
+}
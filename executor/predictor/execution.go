@@ -0,0 +1,136 @@
+package predictor
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/seldonio/seldon-core/executor/api/metric"
+	"github.com/seldonio/seldon-core/executor/api/payload"
+	v1 "github.com/seldonio/seldon-core/operator/apis/machinelearning.seldon.io/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// NodeTimeoutError is returned when a call to a predictive unit does not finish before its deadline.
+type NodeTimeoutError struct {
+	NodeName string
+	Method   string
+	Timeout  time.Duration
+}
+
+func (e *NodeTimeoutError) Error() string {
+	if e.Timeout > 0 {
+		return fmt.Sprintf("Timeout calling %s on predictive unit %s after %s", e.Method, e.NodeName, e.Timeout)
+	}
+	return fmt.Sprintf("Request deadline exceeded calling %s on predictive unit %s", e.Method, e.NodeName)
+}
+
+// GRPCStatus allows gRPC servers to return the error with a DeadlineExceeded code.
+func (e *NodeTimeoutError) GRPCStatus() *status.Status {
+	return status.New(codes.DeadlineExceeded, e.Error())
+}
+
+func getTimeout(node *v1.PredictiveUnit) time.Duration {
+	if node.Execution != nil && node.Execution.TimeoutMs > 0 {
+		return time.Duration(node.Execution.TimeoutMs) * time.Millisecond
+	}
+	return 0
+}
+
+func getRetries(node *v1.PredictiveUnit) int {
+	if node.Execution != nil && node.Execution.Retries > 0 {
+		return int(node.Execution.Retries)
+	}
+	return 0
+}
+
+func getBackoff(node *v1.PredictiveUnit, attempt int) time.Duration {
+	if node.Execution != nil && node.Execution.BackoffMs > 0 {
+		return time.Duration(node.Execution.BackoffMs) * time.Millisecond << uint(attempt)
+	}
+	return 0
+}
+
+// RequestTimeout returns the deadline for a REST or Kafka request, which unlike gRPC requests do not
+// carry one. The value of the Seldon-Request-Timeout header is in milliseconds and overrides the
+// default. No deadline is set if the timeout is zero.
+func RequestTimeout(header string, defaultTimeout time.Duration) (time.Duration, error) {
+	if header == "" {
+		return defaultTimeout, nil
+	}
+	ms, err := strconv.ParseInt(header, 10, 64)
+	if err != nil || ms <= 0 {
+		return 0, invalidRequest("%s must be a positive number of milliseconds but was %q", payload.SeldonRequestTimeoutHeader, header)
+	}
+	return time.Duration(ms) * time.Millisecond, nil
+}
+
+// nodeContext derives the context for a single call to a node. The deadline is the earlier of
+// the node timeout and the deadline of the request so it shrinks as the graph is walked.
+func (p *PredictorProcess) nodeContext(node *v1.PredictiveUnit) (context.Context, context.CancelFunc) {
+	if timeout := getTimeout(node); timeout > 0 {
+		return context.WithTimeout(p.Ctx, timeout)
+	}
+	return context.WithCancel(p.Ctx)
+}
+
+// execute runs a client call for a node applying its execution policy. Failed calls other than
+// client errors are retried with exponential backoff while the request deadline allows. Calls are rejected while the
+// circuit breaker of the node is open.
+func (p *PredictorProcess) execute(node *v1.PredictiveUnit, method string, retry bool, call func(ctx context.Context) error) error {
+	nodeMetrics.Started(node.Name, method)
+	err := p.executeWithCircuitBreaker(node, method, retry, call)
+	nodeMetrics.Finished(node.Name, method, errorClass(err))
+	return err
+}
+
+func (p *PredictorProcess) executeWithCircuitBreaker(node *v1.PredictiveUnit, method string, retry bool, call func(ctx context.Context) error) error {
+	cb := getCircuitBreaker(node)
+	if cb != nil && !cb.allow(time.Now()) {
+		return &CircuitOpenError{NodeName: node.Name}
+	}
+	err := p.executeWithRetries(node, method, retry, call)
+	if cb != nil {
+		cb.record(err, time.Now())
+	}
+	return err
+}
+
+func (p *PredictorProcess) executeWithRetries(node *v1.PredictiveUnit, method string, retry bool, call func(ctx context.Context) error) error {
+	attempts := 1
+	if retry {
+		attempts += getRetries(node)
+	}
+	var err error
+	for attempt := 0; attempt < attempts; attempt++ {
+		if attempt > 0 {
+			p.Log.Info("Retrying call", "node", node.Name, "method", method, "attempt", attempt, "error", err.Error())
+			select {
+			case <-time.After(getBackoff(node, attempt-1)):
+			case <-p.Ctx.Done():
+				return p.wrapTimeout(node, method, p.Ctx, err)
+			}
+		}
+		ctx, cancel := p.nodeContext(node)
+		err = call(ctx)
+		err = p.wrapTimeout(node, method, ctx, err)
+		cancel()
+		// Requests rejected by the node would be rejected again
+		if err == nil || p.Ctx.Err() != nil || errorClass(err) == metric.ErrorClassClient {
+			return err
+		}
+	}
+	return err
+}
+
+func (p *PredictorProcess) wrapTimeout(node *v1.PredictiveUnit, method string, ctx context.Context, err error) error {
+	if err != nil && ctx.Err() == context.DeadlineExceeded {
+		if p.Ctx.Err() == context.DeadlineExceeded {
+			return &NodeTimeoutError{NodeName: node.Name, Method: method}
+		}
+		return &NodeTimeoutError{NodeName: node.Name, Method: method, Timeout: getTimeout(node)}
+	}
+	return err
+}
@@ -0,0 +1,74 @@
+package predictor
+
+import (
+	"sync"
+
+	"github.com/seldonio/seldon-core/executor/api/payload"
+	"github.com/seldonio/seldon-core/executor/api/util"
+	v1 "github.com/seldonio/seldon-core/operator/apis/machinelearning.seldon.io/v1"
+)
+
+// requestPathRecorder collects the image of each node a request goes through, from nodes that may
+// be walked concurrently.
+type requestPathRecorder struct {
+	mu    sync.Mutex
+	nodes map[string]string
+}
+
+func (r *requestPathRecorder) record(node *v1.PredictiveUnit) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.nodes[node.Name] = nodeImage(node.Name)
+}
+
+func (r *requestPathRecorder) snapshot() map[string]string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	nodes := make(map[string]string, len(r.nodes))
+	for k, v := range r.nodes {
+		nodes[k] = v
+	}
+	return nodes
+}
+
+// mergeTags adds the meta tags of the messages a node was called with to its response, so tags are
+// carried through the graph. The tags returned by the node take precedence, followed by the tags of
+// later inputs, e.g. later children of a combiner.
+func (p *PredictorProcess) mergeTags(node *v1.PredictiveUnit, res payload.SeldonPayload, inputs ...payload.SeldonPayload) payload.SeldonPayload {
+	tags := make(map[string]interface{})
+	for _, input := range inputs {
+		for k, v := range util.GetTagsFromSeldonPayload(input) {
+			tags[k] = v
+		}
+	}
+	if len(tags) == 0 || res == nil {
+		return res
+	}
+	resTags := util.GetTagsFromSeldonPayload(res)
+	for k, v := range resTags {
+		tags[k] = v
+	}
+	if len(tags) == len(resTags) {
+		// The node returned all the tags
+		return res
+	}
+	merged, err := util.SetTagsOnSeldonPredictPayload(res, tags)
+	if err != nil {
+		p.Log.Error(err, "Failed to merge tags", "node", node.Name)
+		return res
+	}
+	return merged
+}
+
+// insertRequestPath adds the image of each node the request went through to the response of the graph.
+func (p *PredictorProcess) insertRequestPath(msg payload.SeldonPayload) payload.SeldonPayload {
+	if p.requestPath == nil {
+		return msg
+	}
+	res, err := util.InsertRequestPathToSeldonPredictPayload(msg, p.requestPath.snapshot())
+	if err != nil {
+		p.Log.Error(err, "Failed to add request path to response")
+		return msg
+	}
+	return res
+}
@@ -0,0 +1,106 @@
+package predictor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"testing"
+
+	"github.com/golang/protobuf/jsonpb"
+	. "github.com/onsi/gomega"
+	"github.com/seldonio/seldon-core/executor/api/grpc/seldon/proto"
+	"github.com/seldonio/seldon-core/executor/api/payload"
+	"github.com/seldonio/seldon-core/executor/api/test"
+	"github.com/seldonio/seldon-core/operator/apis/machinelearning.seldon.io/v1"
+	corev1 "k8s.io/api/core/v1"
+	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
+)
+
+// taggingTestClient returns responses with only the tags set by the model, like models which don't
+// return the tags of the request.
+type taggingTestClient struct {
+	test.SeldonMessageTestClient
+}
+
+func (s taggingTestClient) Predict(ctx context.Context, modelName string, host string, port int32, msg payload.SeldonPayload, meta map[string][]string) (payload.SeldonPayload, error) {
+	res := fmt.Sprintf(`{"data":{"ndarray":[1]},"meta":{"tags":{"%s":true,"shared":"%s"}}}`, modelName, modelName)
+	return &payload.BytesPayload{Msg: []byte(res), ContentType: contentTypeJSON}, nil
+}
+
+func createTaggingPredictorProcess() *PredictorProcess {
+	url, _ := url.Parse(testSourceUrl)
+	ctx := context.WithValue(context.TODO(), payload.SeldonPUIDHeader, testSeldonPuid)
+	pp := NewPredictorProcess(ctx, &taggingTestClient{}, logf.Log.WithName("SeldonMessageRestClient"), url, "default", map[string][]string{})
+	return &pp
+}
+
+func TestMergeTags(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	model := v1.MODEL
+	graph := &v1.PredictiveUnit{
+		Name:     "tags-a",
+		Type:     &model,
+		Endpoint: &v1.Endpoint{ServiceHost: "foo", ServicePort: 9000, Type: v1.REST},
+		Children: []v1.PredictiveUnit{
+			{
+				Name:     "tags-b",
+				Type:     &model,
+				Endpoint: &v1.Endpoint{ServiceHost: "foo2", ServicePort: 9001, Type: v1.REST},
+			},
+		},
+	}
+	SetNodeImages(&v1.PredictorSpec{
+		Graph: *graph,
+		ComponentSpecs: []*v1.SeldonPodSpec{{Spec: corev1.PodSpec{Containers: []corev1.Container{
+			{Name: "tags-a", Image: "seldonio/tags-a:1.0"},
+			{Name: "tags-b", Image: "seldonio/tags-b:1.0"},
+		}}}},
+	})
+
+	msg := &payload.BytesPayload{Msg: []byte(`{"data":{"ndarray":[1]},"meta":{"tags":{"request":"1","shared":"request"}}}`), ContentType: contentTypeJSON}
+	pResp, err := createTaggingPredictorProcess().Predict(graph, msg)
+	g.Expect(err).Should(BeNil())
+
+	var res struct {
+		Meta struct {
+			Tags        map[string]interface{} `json:"tags"`
+			RequestPath map[string]string      `json:"requestPath"`
+		} `json:"meta"`
+	}
+	g.Expect(json.Unmarshal(pResp.GetPayload().([]byte), &res)).To(BeNil())
+	g.Expect(res.Meta.Tags).To(Equal(map[string]interface{}{
+		"request": "1",
+		"tags-a":  true,
+		"tags-b":  true,
+		"shared":  "tags-b",
+	}))
+	g.Expect(res.Meta.RequestPath).To(Equal(map[string]string{
+		"tags-a": "seldonio/tags-a:1.0",
+		"tags-b": "seldonio/tags-b:1.0",
+	}))
+}
+
+func TestRequestPathProto(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	model := v1.MODEL
+	graph := &v1.PredictiveUnit{
+		Name:     "path-a",
+		Type:     &model,
+		Endpoint: &v1.Endpoint{ServiceHost: "foo", ServicePort: 9000, Type: v1.REST},
+	}
+	SetNodeImages(&v1.PredictorSpec{
+		Graph:          *graph,
+		ComponentSpecs: []*v1.SeldonPodSpec{{Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "path-a", Image: "seldonio/path-a:1.0"}}}}},
+	})
+
+	var sm proto.SeldonMessage
+	g.Expect(jsonpb.UnmarshalString(`{"data":{"ndarray":[1]},"meta":{"tags":{"request":"1"}}}`, &sm)).To(BeNil())
+	pResp, err := createPredictorProcess(t).Predict(graph, &payload.ProtoPayload{Msg: &sm})
+	g.Expect(err).Should(BeNil())
+	meta := pResp.GetPayload().(*proto.SeldonMessage).GetMeta()
+	g.Expect(meta.GetRequestPath()).To(Equal(map[string]string{"path-a": "seldonio/path-a:1.0"}))
+	g.Expect(meta.GetTags()["request"].GetStringValue()).To(Equal("1"))
+}
@@ -0,0 +1,159 @@
+package predictor
+
+import (
+	"context"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/seldonio/seldon-core/executor/api/client"
+	v1 "github.com/seldonio/seldon-core/operator/apis/machinelearning.seldon.io/v1"
+	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
+)
+
+const ENV_METADATA_REFRESH_INTERVAL = "SELDON_METADATA_REFRESH_INTERVAL"
+
+const defaultMetadataRefreshInterval = time.Minute
+
+// How often readiness of the graph is checked to refresh metadata when it changes.
+var metadataReadinessInterval = 5 * time.Second
+
+// How long fetching the metadata of the graph may take.
+var metadataFetchTimeout = 10 * time.Second
+
+func metadataRefreshInterval(log logr.Logger) time.Duration {
+	value := os.Getenv(ENV_METADATA_REFRESH_INTERVAL)
+	if value == "" {
+		return defaultMetadataRefreshInterval
+	}
+	interval, err := time.ParseDuration(value)
+	if err != nil {
+		log.Error(err, "Invalid metadata refresh interval, using default", "value", value)
+		return defaultMetadataRefreshInterval
+	}
+	return interval
+}
+
+// GraphMetadataCache holds the metadata of the graph of a predictor so it is not fetched from every
+// node on each request. Once fetched the metadata is refreshed in the background at an interval and
+// whenever the readiness of the graph changes. A non-positive interval disables caching.
+type GraphMetadataCache struct {
+	Log logr.Logger
+	// Checks the readiness of the graph with the health endpoints of its nodes
+	ReadyChecker *ReadyChecker
+
+	client            client.SeldonApiClient
+	predictor         *v1.PredictorSpec
+	serverUrl         *url.URL
+	namespace         string
+	interval          time.Duration
+	readinessInterval time.Duration
+	fetchTimeout      time.Duration
+
+	mu       sync.Mutex
+	metadata *GraphMetadata
+	fetched  time.Time
+	start    sync.Once
+	done     chan struct{}
+	stop     sync.Once
+}
+
+func NewGraphMetadataCache(client client.SeldonApiClient, predictor *v1.PredictorSpec, serverUrl *url.URL, namespace string, readyChecker *ReadyChecker) *GraphMetadataCache {
+	log := logf.Log.WithName("GraphMetadataCache")
+	return &GraphMetadataCache{
+		Log:               log,
+		ReadyChecker:      readyChecker,
+		client:            client,
+		predictor:         predictor,
+		serverUrl:         serverUrl,
+		namespace:         namespace,
+		interval:          metadataRefreshInterval(log),
+		readinessInterval: metadataReadinessInterval,
+		fetchTimeout:      metadataFetchTimeout,
+		done:              make(chan struct{}),
+	}
+}
+
+// GraphMetadata returns the metadata of the graph and its age. Metadata which is missing or older
+// than the refresh interval is fetched with the predictor process of the request. Stale metadata is
+// returned if it can't be fetched again.
+func (c *GraphMetadataCache) GraphMetadata(p *PredictorProcess) (*GraphMetadata, time.Duration, error) {
+	if c.interval <= 0 {
+		gm, err := p.GraphMetadata(c.predictor)
+		return gm, 0, err
+	}
+	c.mu.Lock()
+	stale := c.metadata == nil || time.Since(c.fetched) >= c.interval
+	c.mu.Unlock()
+	if stale {
+		if err := c.fetch(p); err != nil {
+			c.mu.Lock()
+			cached := c.metadata != nil
+			c.mu.Unlock()
+			if !cached {
+				return nil, 0, err
+			}
+			c.Log.Error(err, "Failed to refresh graph metadata, returning cached metadata")
+		}
+	}
+	c.start.Do(func() {
+		go c.refresh()
+	})
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.metadata, time.Since(c.fetched), nil
+}
+
+// fetch gets the metadata of the graph without holding the lock, so a slow node doesn't block other
+// requests, and then replaces the cached metadata unless a fetch started later has already done so.
+func (c *GraphMetadataCache) fetch(p *PredictorProcess) error {
+	started := time.Now()
+	ctx, cancel := context.WithTimeout(p.Ctx, c.fetchTimeout)
+	defer cancel()
+	fp := *p
+	fp.Ctx = ctx
+	gm, err := fp.GraphMetadata(c.predictor)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if started.After(c.fetched) {
+		c.metadata = gm
+		c.fetched = started
+	}
+	return nil
+}
+
+func (c *GraphMetadataCache) refresh() {
+	ticker := time.NewTicker(c.readinessInterval)
+	defer ticker.Stop()
+	ready := c.ReadyChecker.Ready(context.Background()) == nil
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+			nowReady := c.ReadyChecker.Ready(context.Background()) == nil
+			c.mu.Lock()
+			stale := time.Since(c.fetched) >= c.interval
+			c.mu.Unlock()
+			if nowReady != ready || stale {
+				p := NewPredictorProcess(context.Background(), c.client, c.Log, c.serverUrl, c.namespace, nil)
+				if err := c.fetch(&p); err != nil {
+					c.Log.Error(err, "Failed to refresh graph metadata")
+				}
+			}
+			ready = nowReady
+		}
+	}
+}
+
+// Stop ends the background refresh of the metadata.
+func (c *GraphMetadataCache) Stop() {
+	c.stop.Do(func() {
+		close(c.done)
+	})
+}
@@ -0,0 +1,187 @@
+package predictor
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	"github.com/seldonio/seldon-core/executor/api"
+	"github.com/seldonio/seldon-core/executor/api/client"
+	"github.com/seldonio/seldon-core/executor/api/payload"
+	"github.com/seldonio/seldon-core/executor/api/test"
+	v1 "github.com/seldonio/seldon-core/operator/apis/machinelearning.seldon.io/v1"
+)
+
+func createMetadataCacheSpec() *v1.PredictorSpec {
+	model := v1.MODEL
+	return &v1.PredictorSpec{
+		Name: "predictor-name",
+		Graph: v1.PredictiveUnit{
+			Name:     "model-1",
+			Type:     &model,
+			Endpoint: &v1.Endpoint{ServiceHost: "foo", ServicePort: 9000, Type: v1.REST},
+			Children: []v1.PredictiveUnit{
+				{
+					Name:     "model-2",
+					Type:     &model,
+					Endpoint: &v1.Endpoint{ServiceHost: "foo", ServicePort: 9001, Type: v1.REST},
+				},
+			},
+		},
+	}
+}
+
+func createMetadataCacheMap(platform string) map[string]payload.ModelMetadata {
+	return map[string]payload.ModelMetadata{
+		"model-1": {Name: "model-1", Platform: platform},
+		"model-2": {Name: "model-2", Platform: platform},
+	}
+}
+
+func TestGraphMetadataCache(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	spec := createMetadataCacheSpec()
+	pp := createPredictorProcessWithMetadata(t, nil, createMetadataCacheMap("v1"))
+	cache := NewGraphMetadataCache(pp.Client, spec, pp.ServerUrl, pp.Namespace, NewReadyChecker(spec, api.ProtocolSeldon, pp.Client, nil))
+	defer cache.Stop()
+
+	gm, age, err := cache.GraphMetadata(pp)
+	g.Expect(err).To(BeNil())
+	g.Expect(age < time.Second).To(BeTrue())
+	g.Expect(gm.Models["model-2"].Platform).To(Equal("v1"))
+
+	// Cached metadata is returned until it is older than the refresh interval
+	updated := createPredictorProcessWithMetadata(t, nil, createMetadataCacheMap("v2"))
+	gm, _, err = cache.GraphMetadata(updated)
+	g.Expect(err).To(BeNil())
+	g.Expect(gm.Models["model-2"].Platform).To(Equal("v1"))
+
+	cache.mu.Lock()
+	cache.fetched = cache.fetched.Add(-2 * cache.interval)
+	cache.mu.Unlock()
+	gm, age, err = cache.GraphMetadata(updated)
+	g.Expect(err).To(BeNil())
+	g.Expect(age < time.Second).To(BeTrue())
+	g.Expect(gm.Models["model-2"].Platform).To(Equal("v2"))
+}
+
+func TestGraphMetadataCacheStale(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	spec := createMetadataCacheSpec()
+	pp := createPredictorProcessWithMetadata(t, nil, createMetadataCacheMap("v1"))
+	cache := NewGraphMetadataCache(pp.Client, spec, pp.ServerUrl, pp.Namespace, NewReadyChecker(spec, api.ProtocolSeldon, pp.Client, nil))
+	defer cache.Stop()
+
+	// Nothing is cached when metadata can't be fetched
+	failing := createPredictorProcessWithMetadata(t, nil, map[string]payload.ModelMetadata{})
+	_, _, err := cache.GraphMetadata(failing)
+	g.Expect(err).ToNot(BeNil())
+
+	_, _, err = cache.GraphMetadata(pp)
+	g.Expect(err).To(BeNil())
+
+	// Stale metadata is returned if it can't be refreshed
+	cache.mu.Lock()
+	cache.fetched = cache.fetched.Add(-2 * cache.interval)
+	cache.mu.Unlock()
+	gm, age, err := cache.GraphMetadata(failing)
+	g.Expect(err).To(BeNil())
+	g.Expect(age > cache.interval).To(BeTrue())
+	g.Expect(gm.Models["model-1"].Platform).To(Equal("v1"))
+}
+
+func TestGraphMetadataCacheReadinessChange(t *testing.T) {
+	g := NewGomegaWithT(t)
+	spec := createMetadataCacheSpec()
+	pp := createPredictorProcessWithMetadata(t, nil, createMetadataCacheMap("v1"))
+	client := &statusTestClient{
+		SeldonMessageTestClient: test.SeldonMessageTestClient{ModelMetadataMap: createMetadataCacheMap("v1")},
+		errs:                    map[string]error{"model-2": errors.New("not ready")},
+	}
+	rc := NewReadyChecker(spec, api.ProtocolSeldon, client, nil)
+	rc.ttl = 0
+	cache := NewGraphMetadataCache(client, spec, pp.ServerUrl, pp.Namespace, rc)
+	cache.readinessInterval = 10 * time.Millisecond
+	defer cache.Stop()
+	_, _, err := cache.GraphMetadata(pp)
+	g.Expect(err).To(BeNil())
+	cache.mu.Lock()
+	fetched := cache.fetched
+	cache.mu.Unlock()
+
+	// The health check of the model passing refreshes the metadata
+	g.Eventually(func() int {
+		client.mu.Lock()
+		defer client.mu.Unlock()
+		return client.calls
+	}).Should(BeNumerically(">=", 2))
+	client.mu.Lock()
+	client.errs = nil
+	client.mu.Unlock()
+	g.Eventually(func() time.Time {
+		cache.mu.Lock()
+		defer cache.mu.Unlock()
+		return cache.fetched
+	}, 5*time.Second).ShouldNot(Equal(fetched))
+}
+
+// blockingMetadataClient doesn't return model metadata until its context is done.
+type blockingMetadataClient struct {
+	client.SeldonApiClient
+}
+
+func (c *blockingMetadataClient) ModelMetadata(ctx context.Context, modelName string, host string, port int32, msg payload.SeldonPayload, meta map[string][]string) (payload.ModelMetadata, error) {
+	<-ctx.Done()
+	return payload.ModelMetadata{}, ctx.Err()
+}
+
+func TestGraphMetadataCacheSlowFetch(t *testing.T) {
+	g := NewGomegaWithT(t)
+	spec := createMetadataCacheSpec()
+	pp := createPredictorProcessWithMetadata(t, nil, createMetadataCacheMap("v1"))
+	cache := NewGraphMetadataCache(pp.Client, spec, pp.ServerUrl, pp.Namespace, NewReadyChecker(spec, api.ProtocolSeldon, pp.Client, nil))
+	cache.fetchTimeout = 200 * time.Millisecond
+	defer cache.Stop()
+	_, _, err := cache.GraphMetadata(pp)
+	g.Expect(err).To(BeNil())
+
+	cache.mu.Lock()
+	cache.fetched = cache.fetched.Add(-2 * cache.interval)
+	cache.mu.Unlock()
+	slow := *pp
+	slow.Client = &blockingMetadataClient{SeldonApiClient: pp.Client}
+	done := make(chan *GraphMetadata)
+	go func() {
+		gm, _, _ := cache.GraphMetadata(&slow)
+		done <- gm
+	}()
+
+	// Other requests are not blocked by a slow fetch
+	updated := createPredictorProcessWithMetadata(t, nil, createMetadataCacheMap("v2"))
+	gm, _, err := cache.GraphMetadata(updated)
+	g.Expect(err).To(BeNil())
+	g.Expect(gm.Models["model-1"].Platform).To(Equal("v2"))
+
+	// The slow fetch times out and its request gets the cached metadata
+	g.Eventually(done, 5*time.Second).Should(Receive(&gm))
+	g.Expect(gm.Models["model-1"].Platform).To(Equal("v2"))
+}
+
+func TestGraphMetadataCacheDisabled(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	spec := createMetadataCacheSpec()
+	pp := createPredictorProcessWithMetadata(t, nil, createMetadataCacheMap("v1"))
+	cache := NewGraphMetadataCache(pp.Client, spec, pp.ServerUrl, pp.Namespace, NewReadyChecker(spec, api.ProtocolSeldon, pp.Client, nil))
+	cache.interval = 0
+
+	_, _, err := cache.GraphMetadata(pp)
+	g.Expect(err).To(BeNil())
+	gm, _, err := cache.GraphMetadata(createPredictorProcessWithMetadata(t, nil, createMetadataCacheMap("v2")))
+	g.Expect(err).To(BeNil())
+	g.Expect(gm.Models["model-1"].Platform).To(Equal("v2"))
+}
@@ -0,0 +1,107 @@
+package predictor
+
+import (
+	"context"
+	"net"
+	"net/url"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/seldonio/seldon-core/executor/api/metric"
+	"github.com/seldonio/seldon-core/executor/api/payload"
+	v1 "github.com/seldonio/seldon-core/operator/apis/machinelearning.seldon.io/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var nodeMetrics *metric.NodeMetrics
+
+// SetNodeMetrics sets the metrics recorded for the calls to each node.
+func SetNodeMetrics(m *metric.NodeMetrics) {
+	nodeMetrics = m
+}
+
+// httpStatusError is implemented by errors of REST calls which returned an error status.
+type httpStatusError interface {
+	HTTPStatus() int
+}
+
+// errorClass returns the class of error of a failed call to a node, or an empty string if the call
+// succeeded.
+func errorClass(err error) string {
+	if err == nil {
+		return ""
+	}
+	switch e := err.(type) {
+	case *NodeTimeoutError:
+		return metric.ErrorClassTimeout
+	case *CircuitOpenError:
+		return metric.ErrorClassCircuitOpen
+	case httpStatusError:
+		if e.HTTPStatus() >= 400 && e.HTTPStatus() < 500 {
+			return metric.ErrorClassClient
+		}
+		return metric.ErrorClassServer
+	case *url.Error:
+		if e.Err == context.Canceled {
+			return metric.ErrorClassCancelled
+		}
+		if e.Timeout() {
+			return metric.ErrorClassTimeout
+		}
+		return metric.ErrorClassUnavailable
+	case net.Error:
+		if e.Timeout() {
+			return metric.ErrorClassTimeout
+		}
+		return metric.ErrorClassUnavailable
+	}
+	if err == context.Canceled {
+		return metric.ErrorClassCancelled
+	}
+	if st, ok := status.FromError(err); ok {
+		switch st.Code() {
+		case codes.DeadlineExceeded:
+			return metric.ErrorClassTimeout
+		case codes.Canceled:
+			return metric.ErrorClassCancelled
+		case codes.Unavailable:
+			return metric.ErrorClassUnavailable
+		case codes.InvalidArgument, codes.NotFound, codes.AlreadyExists, codes.PermissionDenied, codes.FailedPrecondition,
+			codes.OutOfRange, codes.Unimplemented, codes.Unauthenticated:
+			return metric.ErrorClassClient
+		default:
+			return metric.ErrorClassServer
+		}
+	}
+	return metric.ErrorClassOther
+}
+
+// routeChild returns the name of the child chosen by a router for metrics.
+func routeChild(node *v1.PredictiveUnit, route int) string {
+	switch {
+	case route == -1:
+		return metric.RouteAllChildren
+	case route >= 0 && route < len(node.Children):
+		return node.Children[route].Name
+	default:
+		return metric.RouteNoChildren
+	}
+}
+
+// payloadSize returns the size of a payload in bytes, without encoding protobuf messages.
+func payloadSize(msg payload.SeldonPayload) int {
+	if msg == nil {
+		return 0
+	}
+	switch pl := msg.GetPayload().(type) {
+	case proto.Message:
+		return proto.Size(pl)
+	case []byte:
+		return len(pl)
+	}
+	b, err := msg.GetBytes()
+	if err != nil {
+		return 0
+	}
+	return len(b)
+}
@@ -0,0 +1,90 @@
+package predictor
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/url"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/seldonio/seldon-core/executor/api/client"
+	"github.com/seldonio/seldon-core/executor/api/metric"
+	"github.com/seldonio/seldon-core/operator/apis/machinelearning.seldon.io/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type testStatusError int
+
+func (e testStatusError) Error() string {
+	return "status error"
+}
+
+func (e testStatusError) HTTPStatus() int {
+	return int(e)
+}
+
+func TestErrorClass(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	refused := &url.Error{Op: "Post", URL: "http://model", Err: &net.OpError{Op: "dial", Err: errors.New("connection refused")}}
+	cancelled := &url.Error{Op: "Post", URL: "http://model", Err: context.Canceled}
+	for err, class := range map[error]string{
+		&NodeTimeoutError{NodeName: "model"}: metric.ErrorClassTimeout,
+		&CircuitOpenError{NodeName: "model"}: metric.ErrorClassCircuitOpen,
+		testStatusError(400):                 metric.ErrorClassClient,
+		testStatusError(503):                 metric.ErrorClassServer,
+		refused:                              metric.ErrorClassUnavailable,
+		cancelled:                            metric.ErrorClassCancelled,
+		status.Error(codes.Unavailable, "unavailable"): metric.ErrorClassUnavailable,
+		status.Error(codes.InvalidArgument, "invalid"): metric.ErrorClassClient,
+		status.Error(codes.Internal, "internal"):       metric.ErrorClassServer,
+		errors.New("failed"):                           metric.ErrorClassOther,
+	} {
+		g.Expect(errorClass(err)).To(Equal(class), err.Error())
+	}
+	g.Expect(errorClass(nil)).To(Equal(""))
+}
+
+func TestNodeMetrics(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	model := v1.MODEL
+	router := v1.ROUTER
+	graph := &v1.PredictiveUnit{
+		Name:     "metrics-router",
+		Type:     &router,
+		Endpoint: &v1.Endpoint{ServiceHost: "foo", ServicePort: 9000, Type: v1.REST},
+		Children: []v1.PredictiveUnit{
+			{
+				Name:     "metrics-a",
+				Type:     &model,
+				Endpoint: &v1.Endpoint{ServiceHost: "foo2", ServicePort: 9001, Type: v1.REST},
+			},
+			{
+				Name:     "metrics-b",
+				Type:     &model,
+				Endpoint: &v1.Endpoint{ServiceHost: "foo3", ServicePort: 9002, Type: v1.REST},
+			},
+		},
+	}
+	m := metric.NewNodeMetrics(&v1.PredictorSpec{Name: "p", Graph: *graph}, "dep", nil)
+	// The collectors are shared with other tests
+	m.RequestBytesHistogram.Reset()
+	m.ResponseBytesHistogram.Reset()
+	SetNodeMetrics(m)
+	defer SetNodeMetrics(nil)
+
+	_, err := createPredictorProcessWithRoute(t, 1).Predict(graph, createPredictPayload(g))
+	g.Expect(err).Should(BeNil())
+
+	g.Expect(testutil.ToFloat64(m.RoutingCounter.WithLabelValues("dep", "p", "metrics-router", "metrics-b"))).To(Equal(1.0))
+	g.Expect(testutil.ToFloat64(m.RequestsCounter.WithLabelValues("dep", "p", "metrics-router", client.SeldonRoutePath))).To(Equal(1.0))
+	g.Expect(testutil.ToFloat64(m.RequestsCounter.WithLabelValues("dep", "p", "metrics-b", client.SeldonPredictPath))).To(Equal(1.0))
+	g.Expect(testutil.ToFloat64(m.RequestsCounter.WithLabelValues("dep", "p", "metrics-a", client.SeldonPredictPath))).To(Equal(0.0))
+	g.Expect(testutil.ToFloat64(m.InFlightGauge.WithLabelValues("dep", "p", "metrics-b", client.SeldonPredictPath))).To(Equal(0.0))
+	g.Expect(testutil.CollectAndCount(m.RequestBytesHistogram)).To(Equal(2))
+	g.Expect(testutil.CollectAndCount(m.ResponseBytesHistogram)).To(Equal(2))
+}
@@ -0,0 +1,229 @@
+package predictor
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"strings"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+	"github.com/seldonio/seldon-core/executor/api/payload"
+	"github.com/seldonio/seldon-core/operator/apis/machinelearning.seldon.io/v1"
+)
+
+const (
+	// The value redacted fields are replaced with.
+	redactedValue          = "[REDACTED]"
+	contentTypeJSON        = "application/json"
+	contentTypeOctetStream = "application/octet-stream"
+)
+
+// sampled decides if the payloads of a request are logged. The decision is made from the request id
+// so the request and response payloads of every node are logged for the same requests.
+func sampled(logger *v1.Logger, puid string) bool {
+	if logger.SamplePercent == nil || *logger.SamplePercent >= 100 {
+		return true
+	}
+	if *logger.SamplePercent <= 0 {
+		return false
+	}
+	h := fnv.New32a()
+	h.Write([]byte(puid))
+	return h.Sum32()%100 < uint32(*logger.SamplePercent)
+}
+
+// payloadJson returns the JSON form of a payload. Protobuf payloads are converted to JSON so they can
+// be redacted.
+func payloadJson(msg payload.SeldonPayload) ([]byte, error) {
+	if pb, ok := msg.GetPayload().(proto.Message); ok {
+		var buf bytes.Buffer
+		if err := (&jsonpb.Marshaler{}).Marshal(&buf, pb); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+	if !strings.HasPrefix(msg.GetContentType(), contentTypeJSON) {
+		return nil, fmt.Errorf("Can't redact payload with content type %s", msg.GetContentType())
+	}
+	return msg.GetBytes()
+}
+
+// truncatedPayload is logged in place of a JSON payload larger than the logger's limit so the
+// logged body is still valid JSON.
+type truncatedPayload struct {
+	Truncated bool   `json:"truncated"`
+	Size      int    `json:"size"`
+	Payload   string `json:"payload"`
+}
+
+// truncatePayload cuts a payload to maxBytes. Cutting a document leaves it invalid so JSON payloads
+// are replaced with their size and the start of the payload as a string, and other payloads are
+// logged as bytes.
+func truncatePayload(data []byte, contentType string, maxBytes int) ([]byte, string, error) {
+	if !strings.HasPrefix(contentType, contentTypeJSON) {
+		return data[:maxBytes], contentTypeOctetStream, nil
+	}
+	stub, err := json.Marshal(truncatedPayload{Truncated: true, Size: len(data), Payload: string(data[:maxBytes])})
+	if err != nil {
+		return nil, "", err
+	}
+	return stub, contentTypeJSON, nil
+}
+
+// redactPayload replaces the fields of a JSON payload given by JSON paths, such as
+// $.jsonData.users[*].email, or tensor names with a placeholder.
+func redactPayload(data []byte, fields []string) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	// Keep numbers as they were sent
+	dec.UseNumber()
+	var doc interface{}
+	if err := dec.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("Can't redact payload which is not JSON: %v", err)
+	}
+	for _, field := range fields {
+		if strings.HasPrefix(field, "$") {
+			doc = redactPath(doc, parseRedactPath(field))
+		} else {
+			redactTensor(doc, field)
+		}
+	}
+	return json.Marshal(doc)
+}
+
+// parseRedactPath splits a JSON path into keys and [n] or [*] array indexes. A * key matches every key of an object.
+func parseRedactPath(path string) []string {
+	path = strings.TrimPrefix(strings.TrimPrefix(path, "$"), ".")
+	var tokens []string
+	for _, segment := range strings.Split(path, ".") {
+		key := segment
+		var indexes []string
+		if i := strings.Index(segment, "["); i >= 0 {
+			key = segment[:i]
+			indexes = strings.Split(strings.TrimSuffix(segment[i+1:], "]"), "][")
+		}
+		if key != "" {
+			tokens = append(tokens, key)
+		}
+		for _, index := range indexes {
+			tokens = append(tokens, "["+index+"]")
+		}
+	}
+	return tokens
+}
+
+func redactPath(doc interface{}, tokens []string) interface{} {
+	if len(tokens) == 0 {
+		return redactedValue
+	}
+	token, rest := tokens[0], tokens[1:]
+	switch v := doc.(type) {
+	case map[string]interface{}:
+		if token == "*" {
+			for key, child := range v {
+				v[key] = redactPath(child, rest)
+			}
+		} else if child, ok := v[token]; ok {
+			v[token] = redactPath(child, rest)
+		}
+	case []interface{}:
+		if token == "[*]" {
+			for i, child := range v {
+				v[i] = redactPath(child, rest)
+			}
+		} else if strings.HasPrefix(token, "[") {
+			if n, err := strconv.Atoi(token[1 : len(token)-1]); err == nil && n >= 0 && n < len(v) {
+				v[n] = redactPath(v[n], rest)
+			}
+		}
+	}
+	return doc
+}
+
+// The raw contents of KFServing gRPC tensors, which are kept at the index of their tensor, in their
+// JSON and protobuf field names.
+var rawContentsKeys = map[string][]string{
+	"inputs":  {"rawInputContents", "raw_input_contents"},
+	"outputs": {"rawOutputContents", "raw_output_contents"},
+}
+
+// redactTensor redacts a named tensor in the payload formats of the supported protocols: the named
+// inputs and outputs of KFServing, the inputs, outputs, instances and predictions of Tensorflow and
+// the columns and jsonData keys of Seldon messages.
+func redactTensor(doc interface{}, name string) {
+	obj, ok := doc.(map[string]interface{})
+	if !ok {
+		return
+	}
+	for _, key := range []string{"inputs", "outputs"} {
+		switch tensors := obj[key].(type) {
+		case []interface{}:
+			for i, t := range tensors {
+				if tensor, ok := t.(map[string]interface{}); ok && tensor["name"] == name {
+					for _, dataKey := range []string{"data", "contents"} {
+						if _, ok := tensor[dataKey]; ok {
+							tensor[dataKey] = redactedValue
+						}
+					}
+					for _, rawKey := range rawContentsKeys[key] {
+						if raw, ok := obj[rawKey].([]interface{}); ok && i < len(raw) {
+							raw[i] = redactedValue
+						}
+					}
+				}
+			}
+		case map[string]interface{}:
+			if _, ok := tensors[name]; ok {
+				tensors[name] = redactedValue
+			}
+		}
+	}
+	for _, key := range []string{"instances", "predictions"} {
+		if rows, ok := obj[key].([]interface{}); ok {
+			for _, r := range rows {
+				if row, ok := r.(map[string]interface{}); ok {
+					if _, ok := row[name]; ok {
+						row[name] = redactedValue
+					}
+				}
+			}
+		}
+	}
+	if jsonData, ok := obj["jsonData"].(map[string]interface{}); ok {
+		if _, ok := jsonData[name]; ok {
+			jsonData[name] = redactedValue
+		}
+	}
+	if data, ok := obj["data"].(map[string]interface{}); ok {
+		redactColumn(data, name)
+	}
+}
+
+// redactColumn redacts the column of a Seldon ndarray or tensor with the given name.
+func redactColumn(data map[string]interface{}, name string) {
+	names, _ := data["names"].([]interface{})
+	col := -1
+	for i, n := range names {
+		if n == name {
+			col = i
+		}
+	}
+	if col < 0 {
+		return
+	}
+	if rows, ok := data["ndarray"].([]interface{}); ok {
+		for _, r := range rows {
+			if row, ok := r.([]interface{}); ok && col < len(row) {
+				row[col] = redactedValue
+			}
+		}
+	}
+	if tensor, ok := data["tensor"].(map[string]interface{}); ok {
+		values, _ := tensor["values"].([]interface{})
+		for i := col; i < len(values); i += len(names) {
+			values[i] = redactedValue
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package predictor
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/seldonio/seldon-core/executor/api/grpc/kfserving/inference"
+	"github.com/seldonio/seldon-core/executor/api/payload"
+	"github.com/seldonio/seldon-core/operator/apis/machinelearning.seldon.io/v1"
+)
+
+func TestSampled(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	g.Expect(sampled(&v1.Logger{}, "puid")).To(BeTrue())
+
+	samplePercent := int32(0)
+	logger := &v1.Logger{SamplePercent: &samplePercent}
+	g.Expect(sampled(logger, "puid")).To(BeFalse())
+
+	samplePercent = 10
+	count := 0
+	for i := 0; i < 10000; i++ {
+		puid := fmt.Sprintf("puid-%d", i)
+		if sampled(logger, puid) {
+			count++
+		}
+		// The same request is always sampled the same way
+		g.Expect(sampled(logger, puid)).To(Equal(sampled(logger, puid)))
+	}
+	g.Expect(count).To(BeNumerically("~", 1000, 200))
+}
+
+func TestRedactJsonPath(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	data := []byte(`{"jsonData":{"users":[{"email":"a@b.c","age":30},{"email":"d@e.f","age":12345678901234567}]}}`)
+	redacted, err := redactPayload(data, []string{"$.jsonData.users[*].email", "$.jsonData.missing"})
+	g.Expect(err).To(BeNil())
+	g.Expect(string(redacted)).To(Equal(`{"jsonData":{"users":[{"age":30,"email":"[REDACTED]"},{"age":12345678901234567,"email":"[REDACTED]"}]}}`))
+
+	redacted, err = redactPayload(data, []string{"$.jsonData.users[1]"})
+	g.Expect(err).To(BeNil())
+	g.Expect(string(redacted)).To(Equal(`{"jsonData":{"users":[{"age":30,"email":"a@b.c"},"[REDACTED]"]}}`))
+
+	_, err = redactPayload([]byte("not json"), []string{"$.a"})
+	g.Expect(err).ToNot(BeNil())
+}
+
+func TestRedactTensor(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	// Seldon columns
+	redacted, err := redactPayload([]byte(`{"data":{"names":["ssn","age"],"ndarray":[["123",30],["456",40]]}}`), []string{"ssn"})
+	g.Expect(err).To(BeNil())
+	g.Expect(string(redacted)).To(Equal(`{"data":{"names":["ssn","age"],"ndarray":[["[REDACTED]",30],["[REDACTED]",40]]}}`))
+
+	redacted, err = redactPayload([]byte(`{"data":{"names":["a","b"],"tensor":{"shape":[2,2],"values":[1,2,3,4]}}}`), []string{"b"})
+	g.Expect(err).To(BeNil())
+	g.Expect(string(redacted)).To(Equal(`{"data":{"names":["a","b"],"tensor":{"shape":[2,2],"values":[1,"[REDACTED]",3,"[REDACTED]"]}}}`))
+
+	// KFServing named tensors
+	redacted, err = redactPayload([]byte(`{"inputs":[{"name":"ssn","shape":[1],"datatype":"BYTES","data":["123"]},{"name":"age","shape":[1],"datatype":"INT32","data":[30]}]}`), []string{"ssn"})
+	g.Expect(err).To(BeNil())
+	g.Expect(string(redacted)).To(Equal(`{"inputs":[{"data":"[REDACTED]","datatype":"BYTES","name":"ssn","shape":[1]},{"data":[30],"datatype":"INT32","name":"age","shape":[1]}]}`))
+
+	// Tensorflow instances
+	redacted, err = redactPayload([]byte(`{"instances":[{"ssn":"123","age":30}]}`), []string{"ssn"})
+	g.Expect(err).To(BeNil())
+	g.Expect(string(redacted)).To(Equal(`{"instances":[{"age":30,"ssn":"[REDACTED]"}]}`))
+}
+
+func TestRedactRawContents(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	// The raw contents of a named gRPC tensor are redacted as well as its contents
+	msg := &payload.ProtoPayload{Msg: &inference.ModelInferRequest{
+		ModelName: "model",
+		Inputs: []*inference.ModelInferRequest_InferInputTensor{
+			{Name: "age", Datatype: "INT32", Shape: []int64{1}},
+			{Name: "ssn", Datatype: "BYTES", Shape: []int64{1}},
+		},
+		RawInputContents: [][]byte{[]byte("30"), []byte("123-45-6789")},
+	}}
+	data, err := payloadJson(msg)
+	g.Expect(err).To(BeNil())
+	redacted, err := redactPayload(data, []string{"ssn"})
+	g.Expect(err).To(BeNil())
+	var doc struct {
+		RawInputContents []string `json:"rawInputContents"`
+	}
+	g.Expect(json.Unmarshal(redacted, &doc)).To(BeNil())
+	g.Expect(doc.RawInputContents).To(Equal([]string{base64.StdEncoding.EncodeToString([]byte("30")), redactedValue}))
+
+	redacted, err = redactPayload([]byte(`{"outputs":[{"name":"ssn"}],"raw_output_contents":["MTIz"]}`), []string{"ssn"})
+	g.Expect(err).To(BeNil())
+	g.Expect(string(redacted)).To(Equal(`{"outputs":[{"name":"ssn"}],"raw_output_contents":["[REDACTED]"]}`))
+}
+
+func TestTruncatePayload(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	// Truncated JSON payloads are still JSON
+	data, contentType, err := truncatePayload([]byte(`{"data":{"ndarray":[1,2,3]}}`), contentTypeJSON, 10)
+	g.Expect(err).To(BeNil())
+	g.Expect(contentType).To(Equal(contentTypeJSON))
+	g.Expect(string(data)).To(MatchJSON(`{"truncated":true,"size":28,"payload":"{\"data\":{\""}`))
+
+	data, contentType, err = truncatePayload([]byte{1, 2, 3, 4}, "application/x-protobuf", 2)
+	g.Expect(err).To(BeNil())
+	g.Expect(contentType).To(Equal(contentTypeOctetStream))
+	g.Expect(data).To(Equal([]byte{1, 2}))
+}
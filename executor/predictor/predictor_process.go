@@ -12,6 +12,7 @@ import (
 	"github.com/seldonio/seldon-core/executor/api/client"
 	"github.com/seldonio/seldon-core/executor/api/grpc/seldon/proto"
 	"github.com/seldonio/seldon-core/executor/api/payload"
+	"github.com/seldonio/seldon-core/executor/api/tracing"
 	"github.com/seldonio/seldon-core/executor/api/util"
 
 	payloadLogger "github.com/seldonio/seldon-core/executor/logger"
@@ -37,6 +38,12 @@ type PredictorProcess struct {
 	Namespace string
 	Meta      *payload.MetaData
 	Routing   map[string]int32
+
+	childStatus   *childStatusRecorder
+	customMetrics *customMetricsRecorder
+	requestPath   *requestPathRecorder
+	// Set for the nodes below the node the request was sent to
+	nested bool
 }
 
 func NewPredictorProcess(context context.Context, client client.SeldonApiClient, log logr.Logger, serverUrl *url.URL, namespace string, meta map[string][]string) PredictorProcess {
@@ -48,6 +55,10 @@ func NewPredictorProcess(context context.Context, client client.SeldonApiClient,
 		Namespace: namespace,
 		Meta:      payload.NewFromMap(meta),
 		Routing:   make(map[string]int32),
+
+		childStatus:   &childStatusRecorder{nodes: make(map[string]map[string]ChildStatus)},
+		customMetrics: &customMetricsRecorder{},
+		requestPath:   &requestPathRecorder{nodes: make(map[string]string)},
 	}
 }
 
@@ -85,25 +96,54 @@ func (p *PredictorProcess) transformInput(node *v1.PredictiveUnit, msg payload.S
 		callTransformInput = true
 	}
 	if callModel {
-		msg, err := p.Client.Chain(p.Ctx, node.Name, msg)
-		if err != nil {
-			return nil, err
-		}
-		p.Routing[node.Name] = -1
-		return p.Client.Predict(p.Ctx, node.Name, node.Endpoint.ServiceHost, p.getPort(node), msg, p.Meta.Meta)
+		return p.traceStep(node, StepPredict, func(p *PredictorProcess) (payload.SeldonPayload, error) {
+			msg, err := p.Client.Chain(p.Ctx, node.Name, msg)
+			if err != nil {
+				return nil, err
+			}
+			p.Routing[node.Name] = -1
+			res, err := p.predictBatched(node, msg)
+			if err == nil {
+				res = p.mergeTags(node, res, msg)
+			}
+			return res, err
+		})
 	} else if callTransformInput {
-		msg, err := p.Client.Chain(p.Ctx, node.Name, msg)
-		if err != nil {
-			return nil, err
-		}
-		p.Routing[node.Name] = -1
-		return p.Client.TransformInput(p.Ctx, node.Name, node.Endpoint.ServiceHost, p.getPort(node), msg, p.Meta.Meta)
+		return p.traceStep(node, StepTransformInput, func(p *PredictorProcess) (payload.SeldonPayload, error) {
+			msg, err := p.Client.Chain(p.Ctx, node.Name, msg)
+			if err != nil {
+				return nil, err
+			}
+			p.Routing[node.Name] = -1
+			var res payload.SeldonPayload
+			err = p.execute(node, client.SeldonTransformInputPath, true, func(ctx context.Context) (err error) {
+				res, err = p.Client.TransformInput(ctx, node.Name, node.Endpoint.ServiceHost, p.getPort(node), msg, p.Meta.Meta)
+				return err
+			})
+			if err == nil {
+				res = p.collectMetrics(node, res)
+				res = p.mergeTags(node, res, msg)
+			}
+			return res, err
+		})
 	} else {
 		return msg, nil
 	}
 
 }
 
+func (p *PredictorProcess) predictModel(node *v1.PredictiveUnit, msg payload.SeldonPayload) (payload.SeldonPayload, error) {
+	var res payload.SeldonPayload
+	err := p.execute(node, client.SeldonPredictPath, true, func(ctx context.Context) (err error) {
+		res, err = p.Client.Predict(ctx, node.Name, node.Endpoint.ServiceHost, p.getPort(node), msg, p.Meta.Meta)
+		return err
+	})
+	if err == nil {
+		res = p.collectMetrics(node, res)
+	}
+	return res, err
+}
+
 func (p *PredictorProcess) transformOutput(node *v1.PredictiveUnit, msg payload.SeldonPayload) (payload.SeldonPayload, error) {
 	callClient := false
 	if (*node).Type != nil {
@@ -117,11 +157,22 @@ func (p *PredictorProcess) transformOutput(node *v1.PredictiveUnit, msg payload.
 	}
 
 	if callClient {
-		msg, err := p.Client.Chain(p.Ctx, node.Name, msg)
-		if err != nil {
-			return nil, err
-		}
-		return p.Client.TransformOutput(p.Ctx, node.Name, node.Endpoint.ServiceHost, p.getPort(node), msg, p.Meta.Meta)
+		return p.traceStep(node, StepTransformOutput, func(p *PredictorProcess) (payload.SeldonPayload, error) {
+			msg, err := p.Client.Chain(p.Ctx, node.Name, msg)
+			if err != nil {
+				return nil, err
+			}
+			var res payload.SeldonPayload
+			err = p.execute(node, client.SeldonTransformOutputPath, true, func(ctx context.Context) (err error) {
+				res, err = p.Client.TransformOutput(ctx, node.Name, node.Endpoint.ServiceHost, p.getPort(node), msg, p.Meta.Meta)
+				return err
+			})
+			if err == nil {
+				res = p.collectMetrics(node, res)
+				res = p.mergeTags(node, res, msg)
+			}
+			return res, err
+		})
 	} else {
 		return msg, nil
 	}
@@ -141,7 +192,13 @@ func (p *PredictorProcess) feedback(node *v1.PredictiveUnit, msg payload.SeldonP
 	}
 
 	if callClient {
-		return p.Client.Feedback(p.Ctx, node.Name, node.Endpoint.ServiceHost, p.getPort(node), msg, p.Meta.Meta)
+		// Feedback is not retried as it may not be idempotent
+		var res payload.SeldonPayload
+		err := p.execute(node, client.SeldonFeedbackPath, false, func(ctx context.Context) (err error) {
+			res, err = p.Client.Feedback(ctx, node.Name, node.Endpoint.ServiceHost, p.getPort(node), msg, p.Meta.Meta)
+			return err
+		})
+		return res, err
 	} else {
 		return msg, nil
 	}
@@ -156,7 +213,37 @@ func (p *PredictorProcess) routeFeedback(node *v1.PredictiveUnit, msg payload.Se
 	}
 }
 
+// isRouter returns true if a node decides which of its children are called.
+func isRouter(node *v1.PredictiveUnit) bool {
+	if (node.Type != nil && *node.Type == v1.ROUTER) || hasMethod(v1.ROUTE, node.Methods) || isBanditRouter(node) {
+		return true
+	}
+	if node.Implementation != nil {
+		switch *node.Implementation {
+		case v1.RANDOM_ABTEST, v1.HEADER_ROUTER, v1.CONSISTENT_HASH_ROUTER:
+			return true
+		}
+	}
+	return false
+}
+
+// route returns the index of the child to send a request to, -1 for all children or -2 to return
+// the request. Routers are traced in their own span.
 func (p *PredictorProcess) route(node *v1.PredictiveUnit, msg payload.SeldonPayload) (int, error) {
+	if !isRouter(node) {
+		return -1, nil
+	}
+	sp, span := p.startStepSpan(node, StepRoute)
+	route, err := sp.routeNode(node, msg)
+	if err == nil {
+		p.traceRoute(span, node, route)
+		nodeMetrics.Routed(node.Name, routeChild(node, route))
+	}
+	tracing.EndSpan(span, err)
+	return route, err
+}
+
+func (p *PredictorProcess) routeNode(node *v1.PredictiveUnit, msg payload.SeldonPayload) (int, error) {
 	callClient := false
 	if (*node).Type != nil {
 		switch *node.Type {
@@ -168,15 +255,26 @@ func (p *PredictorProcess) route(node *v1.PredictiveUnit, msg payload.SeldonPayl
 		callClient = true
 	}
 	if callClient {
-		return p.Client.Route(p.Ctx, node.Name, node.Endpoint.ServiceHost, p.getPort(node), msg, p.Meta.Meta)
+		var route int
+		err := p.execute(node, client.SeldonRoutePath, true, func(ctx context.Context) (err error) {
+			route, err = p.Client.Route(ctx, node.Name, node.Endpoint.ServiceHost, p.getPort(node), msg, p.Meta.Meta)
+			return err
+		})
+		return route, err
 	} else if node.Implementation != nil && *node.Implementation == v1.RANDOM_ABTEST {
 		return p.abTestRouter(node)
+	} else if isBanditRouter(node) {
+		return p.banditRouter(node)
+	} else if node.Implementation != nil && *node.Implementation == v1.HEADER_ROUTER {
+		return p.headerRouter(node, msg)
+	} else if node.Implementation != nil && *node.Implementation == v1.CONSISTENT_HASH_ROUTER {
+		return p.consistentHashRouter(node, msg)
 	} else {
 		return -1, nil
 	}
 }
 
-func (p *PredictorProcess) aggregate(node *v1.PredictiveUnit, msg []payload.SeldonPayload) (payload.SeldonPayload, error) {
+func (p *PredictorProcess) aggregate(node *v1.PredictiveUnit, msg []payload.SeldonPayload, indexes []int) (payload.SeldonPayload, error) {
 	callClient := false
 	if (*node).Type != nil {
 		switch *node.Type {
@@ -190,7 +288,26 @@ func (p *PredictorProcess) aggregate(node *v1.PredictiveUnit, msg []payload.Seld
 
 	if callClient {
 		p.Routing[node.Name] = -1
-		return p.Client.Combine(p.Ctx, node.Name, node.Endpoint.ServiceHost, p.getPort(node), msg, p.Meta.Meta)
+		return p.traceStep(node, StepAggregate, func(p *PredictorProcess) (payload.SeldonPayload, error) {
+			var res payload.SeldonPayload
+			err := p.execute(node, client.SeldonCombinePath, true, func(ctx context.Context) (err error) {
+				res, err = p.Client.Combine(ctx, node.Name, node.Endpoint.ServiceHost, p.getPort(node), msg, p.Meta.Meta)
+				return err
+			})
+			if err == nil {
+				res = p.collectMetrics(node, res)
+				res = p.mergeTags(node, res, msg...)
+			}
+			return res, err
+		})
+	} else if isBuiltinCombiner(node) {
+		return p.traceStep(node, StepAggregate, func(p *PredictorProcess) (payload.SeldonPayload, error) {
+			res, err := p.combine(node, msg, indexes)
+			if err == nil {
+				res = p.mergeTags(node, res, msg...)
+			}
+			return res, err
+		})
 	} else {
 		return msg[0], nil
 	}
@@ -203,38 +320,33 @@ func (p *PredictorProcess) predictChildren(node *v1.PredictiveUnit, msg payload.
 		if err != nil {
 			return nil, err
 		}
+		if route == -2 {
+			//Abort and return request
+			p.Routing[node.Name] = -2
+			return msg, nil
+		}
+		cp, span := p.startStepSpan(node, StepChildren)
 		var cmsgs []payload.SeldonPayload
+		var indexes []int
 		if route == -1 {
-			cmsgs = make([]payload.SeldonPayload, len(node.Children))
-			var errs = make([]error, len(node.Children))
-			wg := sync.WaitGroup{}
-			for i, nodeChild := range node.Children {
-				wg.Add(1)
-				go func(i int, nodeChild v1.PredictiveUnit, msg payload.SeldonPayload) {
-					cmsgs[i], errs[i] = p.Predict(&nodeChild, msg)
-					wg.Done()
-				}(i, nodeChild, msg)
-			}
-			wg.Wait()
+			var errMsg payload.SeldonPayload
+			cmsgs, indexes, errMsg, err = cp.predictAllChildren(node, msg)
 			p.Routing[node.Name] = -1
-			for i, err := range errs {
-				if err != nil {
-					return cmsgs[i], err
-				}
+			tracing.EndSpan(span, err)
+			if err != nil {
+				return errMsg, err
 			}
-		} else if route == -2 {
-			//Abort and return request
-			p.Routing[node.Name] = -2
-			return msg, nil
 		} else {
 			cmsgs = make([]payload.SeldonPayload, 1)
-			cmsgs[0], err = p.Predict(&node.Children[route], msg)
+			cmsgs[0], err = cp.Predict(&node.Children[route], msg)
+			indexes = []int{route}
 			p.Routing[node.Name] = int32(route)
+			tracing.EndSpan(span, err)
 			if err != nil {
 				return cmsgs[0], err
 			}
 		}
-		return p.aggregate(node, cmsgs)
+		return p.aggregate(node, cmsgs, indexes)
 	} else {
 		p.Routing[node.Name] = -2
 		return msg, nil
@@ -248,6 +360,11 @@ func (p *PredictorProcess) feedbackChildren(node *v1.PredictiveUnit, msg payload
 		if err != nil {
 			return nil, err
 		}
+		if isBanditRouter(node) {
+			if err := p.rewardBandit(node, route, msg); err != nil {
+				return nil, err
+			}
+		}
 		var cmsgs []payload.SeldonPayload
 		if route == -1 {
 			cmsgs = make([]payload.SeldonPayload, len(node.Children))
@@ -273,38 +390,70 @@ func (p *PredictorProcess) feedbackChildren(node *v1.PredictiveUnit, msg payload
 				return cmsgs[0], err
 			}
 		}
-		return p.aggregate(node, cmsgs)
+		// Feedback responses are not combined by built in combiners
+		if isBuiltinCombiner(node) {
+			return cmsgs[0], nil
+		}
+		return p.aggregate(node, cmsgs, nil)
 	} else {
 		return msg, nil
 	}
 }
 
 func (p *PredictorProcess) getLogUrl(logger *v1.Logger) (*url.URL, error) {
+	rawUrl := envRequestLoggerDefaultEndpoint
 	if logger.Url != nil {
-		return url.Parse(*logger.Url)
-	} else {
-		return url.Parse(envRequestLoggerDefaultEndpoint)
+		rawUrl = *logger.Url
 	}
+	logUrl, err := url.Parse(rawUrl)
+	if err != nil {
+		return nil, err
+	}
+	return logUrl, payloadLogger.CheckSinkUrl(logUrl)
 }
 
 func (p *PredictorProcess) logPayload(nodeName string, logger *v1.Logger, reqType payloadLogger.LogRequestType, msg payload.SeldonPayload, puid string) error {
-	data, err := msg.GetBytes()
+	if !sampled(logger, puid) {
+		return nil
+	}
+	logUrl, err := p.getLogUrl(logger)
 	if err != nil {
 		return err
 	}
-	logUrl, err := p.getLogUrl(logger)
+	data, err := msg.GetBytes()
 	if err != nil {
 		return err
 	}
+	contentType := msg.GetContentType()
+	if len(logger.Redact) > 0 {
+		// Payloads which can't be redacted are not logged rather than failing the request
+		data, err = payloadJson(msg)
+		if err == nil {
+			data, err = redactPayload(data, logger.Redact)
+		}
+		if err != nil {
+			p.Log.Error(err, "Not logging payload which could not be redacted", "node", nodeName, "puid", puid)
+			return nil
+		}
+		contentType = contentTypeJSON
+	}
+	truncated := false
+	if logger.MaxPayloadBytes > 0 && len(data) > int(logger.MaxPayloadBytes) {
+		if data, contentType, err = truncatePayload(data, contentType, int(logger.MaxPayloadBytes)); err != nil {
+			return err
+		}
+		truncated = true
+	}
 	payloadLogger.QueueLogRequest(payloadLogger.LogRequest{
 		Url:         logUrl,
 		Bytes:       &data,
-		ContentType: msg.GetContentType(),
+		ContentType: contentType,
 		ReqType:     reqType,
 		Id:          guuid.New().String(),
 		SourceUri:   p.ServerUrl,
 		ModelId:     nodeName,
 		RequestId:   puid,
+		Truncated:   truncated,
 	})
 	return nil
 }
@@ -317,11 +466,46 @@ func (p *PredictorProcess) getPUIDHeader() (string, error) {
 	return "", fmt.Errorf(NilPUIDError)
 }
 
+func (p *PredictorProcess) predictNode(node *v1.PredictiveUnit, msg payload.SeldonPayload) (payload.SeldonPayload, error) {
+	tmsg, err := p.transformInput(node, msg)
+	if err != nil {
+		return tmsg, err
+	}
+	cmsg, err := p.predictChildren(node, tmsg)
+	if err != nil {
+		return tmsg, err
+	}
+	return p.transformOutput(node, cmsg)
+}
+
+// Predict sends a request through the graph from node. Each node is traced in its own span.
 func (p *PredictorProcess) Predict(node *v1.PredictiveUnit, msg payload.SeldonPayload) (payload.SeldonPayload, error) {
+	np, span := p.startNodeSpan(node)
+	np.nested = true
+	nodeMetrics.RequestBytes(node.Name, payloadSize(msg))
+	response, err := np.predict(node, msg)
+	if err == nil {
+		nodeMetrics.ResponseBytes(node.Name, payloadSize(response))
+		// The custom metrics, request path and child statuses of all nodes are added once to the
+		// response of the graph
+		if !p.nested {
+			response = p.insertMetrics(response)
+			response = p.insertRequestPath(response)
+			response = p.insertChildStatus(response)
+		}
+	}
+	tracing.EndSpan(span, err)
+	return response, err
+}
+
+func (p *PredictorProcess) predict(node *v1.PredictiveUnit, msg payload.SeldonPayload) (payload.SeldonPayload, error) {
 	puid, err := p.getPUIDHeader()
 	if err != nil {
 		return nil, err
 	}
+	if p.requestPath != nil {
+		p.requestPath.record(node)
+	}
 	//Log Request
 	if node.Logger != nil && (node.Logger.Mode == v1.LogRequest || node.Logger.Mode == v1.LogAll) {
 		err := p.logPayload(node.Name, node.Logger, payloadLogger.InferenceRequest, msg, puid)
@@ -329,15 +513,10 @@ func (p *PredictorProcess) Predict(node *v1.PredictiveUnit, msg payload.SeldonPa
 			return nil, err
 		}
 	}
-	tmsg, err := p.transformInput(node, msg)
-	if err != nil {
-		return tmsg, err
-	}
-	cmsg, err := p.predictChildren(node, tmsg)
-	if err != nil {
-		return tmsg, err
+	response, err := p.predictNode(node, msg)
+	if cerr, ok := err.(*CircuitOpenError); ok && cerr.NodeName == node.Name {
+		response, err = p.fallback(node, msg, err)
 	}
-	response, err := p.transformOutput(node, cmsg)
 	// Log Response
 	if err == nil && node.Logger != nil && (node.Logger.Mode == v1.LogResponse || node.Logger.Mode == v1.LogAll) {
 		err := p.logPayload(node.Name, node.Logger, payloadLogger.InferenceResponse, response, puid)
@@ -345,7 +524,8 @@ func (p *PredictorProcess) Predict(node *v1.PredictiveUnit, msg payload.SeldonPa
 			return nil, err
 		}
 	}
-	if envEnableRoutingInjection {
+	// Bandit routers need the route in the response so it can be sent back with feedback
+	if envEnableRoutingInjection || (err == nil && isBanditRouter(node)) {
 		if routeResponse, err := util.InsertRouteToSeldonPredictPayload(response, &p.Routing); err == nil {
 			return routeResponse, err
 		}
@@ -388,6 +568,13 @@ func (p *PredictorProcess) GraphMetadata(spec *v1.PredictorSpec) (*GraphMetadata
 }
 
 func (p *PredictorProcess) Feedback(node *v1.PredictiveUnit, msg payload.SeldonPayload) (payload.SeldonPayload, error) {
+	np, span := p.startNodeSpan(node)
+	response, err := np.sendFeedback(node, msg)
+	tracing.EndSpan(span, err)
+	return response, err
+}
+
+func (p *PredictorProcess) sendFeedback(node *v1.PredictiveUnit, msg payload.SeldonPayload) (payload.SeldonPayload, error) {
 
 	if node.Logger != nil && (node.Logger.Mode == v1.LogResponse || node.Logger.Mode == v1.LogAll) {
 		puid, puiderr := p.getPUIDHeader()
@@ -417,10 +604,21 @@ func (p *PredictorProcess) ModelMetadataMap(node *v1.PredictiveUnit) (map[string
 	var output = map[string]payload.ModelMetadata{
 		node.Name: resPayload,
 	}
-	for _, child := range node.Children {
-		childMeta, err := p.ModelMetadataMap(&child)
-		if err != nil {
-			return nil, err
+	// Fetch the metadata of children in parallel
+	childMetas := make([]map[string]payload.ModelMetadata, len(node.Children))
+	errs := make([]error, len(node.Children))
+	var wg sync.WaitGroup
+	for i := range node.Children {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			childMetas[i], errs[i] = p.ModelMetadataMap(&node.Children[i])
+		}(i)
+	}
+	wg.Wait()
+	for i, childMeta := range childMetas {
+		if errs[i] != nil {
+			return nil, errs[i]
 		}
 		for k, v := range childMeta {
 			output[k] = v
@@ -4,10 +4,13 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/golang/protobuf/jsonpb"
 	. "github.com/onsi/gomega"
@@ -16,7 +19,10 @@ import (
 	"github.com/seldonio/seldon-core/executor/api/payload"
 	"github.com/seldonio/seldon-core/executor/api/test"
 	"github.com/seldonio/seldon-core/executor/logger"
+	"github.com/seldonio/seldon-core/executor/proto/tensorflow/serving"
 	"github.com/seldonio/seldon-core/operator/apis/machinelearning.seldon.io/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
 )
 
@@ -416,7 +422,7 @@ func TestModelWithLogRequests(t *testing.T) {
 
 	logf.SetLogger(logf.ZapLogger(false))
 	log := logf.Log.WithName("entrypoint")
-	logger.StartDispatcher(1, log, "", "", "")
+	logger.StartDispatcher(1, log, "", "", "", logger.DefaultOptions)
 
 	model := v1.MODEL
 	graph := &v1.PredictiveUnit{
@@ -464,7 +470,7 @@ func TestModelWithLogRequestsAtDefaultedUrl(t *testing.T) {
 
 	logf.SetLogger(logf.ZapLogger(false))
 	log := logf.Log.WithName("entrypoint")
-	logger.StartDispatcher(1, log, "", "", "")
+	logger.StartDispatcher(1, log, "", "", "", logger.DefaultOptions)
 
 	model := v1.MODEL
 	graph := &v1.PredictiveUnit{
@@ -488,6 +494,48 @@ func TestModelWithLogRequestsAtDefaultedUrl(t *testing.T) {
 	g.Eventually(func() bool { return logged }).Should(Equal(true))
 }
 
+func TestModelWithRedactedLogRequests(t *testing.T) {
+	t.Logf("Started")
+	g := NewGomegaWithT(t)
+	modelName := "foo"
+	logged := make(chan string, 1)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		g.Expect(r.Header.Get(contentTypeHeaderName)).To(Equal(contentTypeJSON))
+		g.Expect(r.Header.Get("Ce-Truncated")).To(Equal("true"))
+		body, err := ioutil.ReadAll(r.Body)
+		g.Expect(err).To(BeNil())
+		w.Write([]byte(""))
+		logged <- string(body)
+	})
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	logf.SetLogger(logf.ZapLogger(false))
+	log := logf.Log.WithName("entrypoint")
+	logger.StartDispatcher(1, log, "", "", "", logger.DefaultOptions)
+
+	model := v1.MODEL
+	graph := &v1.PredictiveUnit{
+		Name: modelName,
+		Type: &model,
+		Endpoint: &v1.Endpoint{
+			ServiceHost: "foo",
+			ServicePort: 9000,
+			Type:        v1.REST,
+		},
+		Logger: &v1.Logger{
+			Url:             &server.URL,
+			Mode:            v1.LogRequest,
+			MaxPayloadBytes: 32,
+			Redact:          []string{"$.data.ndarray[0]"},
+		},
+	}
+
+	_, err := createPredictorProcess(t).Predict(graph, createPredictPayload(g))
+	g.Expect(err).Should(BeNil())
+	g.Eventually(logged).Should(Receive(MatchJSON(`{"truncated":true,"size":37,"payload":"{\"data\":{\"ndarray\":[\"[REDACTED]\""}`)))
+}
+
 func TestModelWithLogResponses(t *testing.T) {
 	t.Logf("Started")
 	g := NewGomegaWithT(t)
@@ -507,7 +555,7 @@ func TestModelWithLogResponses(t *testing.T) {
 
 	logf.SetLogger(logf.ZapLogger(false))
 	log := logf.Log.WithName("entrypoint")
-	logger.StartDispatcher(1, log, "", "", "")
+	logger.StartDispatcher(1, log, "", "", "", logger.DefaultOptions)
 
 	model := v1.MODEL
 	graph := &v1.PredictiveUnit{
@@ -544,3 +592,408 @@ func TestPredictNilPUIDError(t *testing.T) {
 	g.Expect(err).NotTo(BeNil())
 	g.Expect(err.Error()).Should(Equal(NilPUIDError))
 }
+
+// Test client which is slow or fails for a given number of predict calls
+type unreliableTestClient struct {
+	test.SeldonMessageTestClient
+	delay    time.Duration
+	failures int32
+	calls    *int32
+	// Returned by the failing calls, Unavailable if not set
+	err error
+}
+
+func (s unreliableTestClient) Predict(ctx context.Context, modelName string, host string, port int32, msg payload.SeldonPayload, meta map[string][]string) (payload.SeldonPayload, error) {
+	call := atomic.AddInt32(s.calls, 1)
+	if call <= s.failures {
+		if s.err != nil {
+			return nil, s.err
+		}
+		return nil, status.Error(codes.Unavailable, "unavailable")
+	}
+	select {
+	case <-time.After(s.delay):
+		return msg, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func createPredictorProcessWithUnreliableClient(t *testing.T, delay time.Duration, failures int32, calls *int32) *PredictorProcess {
+	url, _ := url.Parse(testSourceUrl)
+	ctx := context.WithValue(context.TODO(), payload.SeldonPUIDHeader, testSeldonPuid)
+	pp := NewPredictorProcess(ctx, unreliableTestClient{delay: delay, failures: failures, calls: calls}, logf.Log.WithName("SeldonMessageRestClient"), url, "default", map[string][]string{})
+	return &pp
+}
+
+func TestModelTimeout(t *testing.T) {
+	g := NewGomegaWithT(t)
+	model := v1.MODEL
+	graph := &v1.PredictiveUnit{
+		Name: "slow",
+		Type: &model,
+		Endpoint: &v1.Endpoint{
+			ServiceHost: "foo",
+			ServicePort: 9000,
+			Type:        v1.REST,
+		},
+		Execution: &v1.ExecutionPolicy{
+			TimeoutMs: 10,
+		},
+	}
+
+	var calls int32
+	_, err := createPredictorProcessWithUnreliableClient(t, time.Second, 0, &calls).Predict(graph, createPredictPayload(g))
+	g.Expect(err).ShouldNot(BeNil())
+	terr, ok := err.(*NodeTimeoutError)
+	g.Expect(ok).To(BeTrue())
+	g.Expect(terr.NodeName).To(Equal("slow"))
+	g.Expect(terr.Timeout).To(Equal(10 * time.Millisecond))
+}
+
+func TestRequestTimeout(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	timeout, err := RequestTimeout("", time.Second)
+	g.Expect(err).To(BeNil())
+	g.Expect(timeout).To(Equal(time.Second))
+
+	timeout, err = RequestTimeout("250", time.Second)
+	g.Expect(err).To(BeNil())
+	g.Expect(timeout).To(Equal(250 * time.Millisecond))
+
+	for _, header := range []string{"1s", "0", "-5"} {
+		_, err = RequestTimeout(header, time.Second)
+		_, ok := err.(*RequestValidationError)
+		g.Expect(ok).To(BeTrue(), header)
+	}
+}
+
+func TestModelRetries(t *testing.T) {
+	g := NewGomegaWithT(t)
+	model := v1.MODEL
+	graph := &v1.PredictiveUnit{
+		Name: "flaky",
+		Type: &model,
+		Endpoint: &v1.Endpoint{
+			ServiceHost: "foo",
+			ServicePort: 9000,
+			Type:        v1.REST,
+		},
+		Execution: &v1.ExecutionPolicy{
+			Retries:   2,
+			BackoffMs: 1,
+		},
+	}
+
+	var calls int32
+	pResp, err := createPredictorProcessWithUnreliableClient(t, 0, 2, &calls).Predict(graph, createPredictPayload(g))
+	g.Expect(err).Should(BeNil())
+	g.Expect(calls).To(Equal(int32(3)))
+	smRes := pResp.GetPayload().(*proto.SeldonMessage)
+	g.Expect(smRes.GetData().GetNdarray().Values[0].GetNumberValue()).Should(Equal(1.1))
+
+	calls = 0
+	_, err = createPredictorProcessWithUnreliableClient(t, 0, 3, &calls).Predict(graph, createPredictPayload(g))
+	g.Expect(err).ShouldNot(BeNil())
+	g.Expect(calls).To(Equal(int32(3)))
+
+	// Requests the model rejects are not retried
+	calls = 0
+	pp := createPredictorProcessWithUnreliableClient(t, 0, 3, &calls)
+	pp.Client = unreliableTestClient{failures: 3, calls: &calls, err: status.Error(codes.InvalidArgument, "invalid")}
+	_, err = pp.Predict(graph, createPredictPayload(g))
+	g.Expect(status.Code(err)).To(Equal(codes.InvalidArgument))
+	g.Expect(calls).To(Equal(int32(1)))
+}
+
+func TestCircuitBreakerOpens(t *testing.T) {
+	g := NewGomegaWithT(t)
+	model := v1.MODEL
+	graph := &v1.PredictiveUnit{
+		Name: "broken",
+		Type: &model,
+		Endpoint: &v1.Endpoint{
+			ServiceHost: "foo",
+			ServicePort: 9000,
+			Type:        v1.REST,
+		},
+		CircuitBreaker: &v1.CircuitBreaker{
+			MaxFailures:    2,
+			ResetTimeoutMs: 50,
+		},
+	}
+
+	var calls int32
+	for i := 0; i < 3; i++ {
+		_, err := createPredictorProcessWithUnreliableClient(t, 0, 2, &calls).Predict(graph, createPredictPayload(g))
+		g.Expect(err).ShouldNot(BeNil())
+	}
+	g.Expect(calls).To(Equal(int32(2)))
+	_, err := createPredictorProcessWithUnreliableClient(t, 0, 2, &calls).Predict(graph, createPredictPayload(g))
+	cerr, ok := err.(*CircuitOpenError)
+	g.Expect(ok).To(BeTrue())
+	g.Expect(cerr.NodeName).To(Equal("broken"))
+
+	// After the reset timeout a trial call is allowed and closes the breaker
+	time.Sleep(60 * time.Millisecond)
+	_, err = createPredictorProcessWithUnreliableClient(t, 0, 2, &calls).Predict(graph, createPredictPayload(g))
+	g.Expect(err).Should(BeNil())
+	g.Expect(calls).To(Equal(int32(3)))
+}
+
+func TestCircuitBreakerFailures(t *testing.T) {
+	g := NewGomegaWithT(t)
+	now := time.Now()
+	cb := &circuitBreaker{maxFailures: 1, resetTimeout: time.Minute}
+
+	// Cancelled calls and client errors don't open the breaker
+	cb.record(context.Canceled, now)
+	cb.record(&url.Error{Op: "Post", URL: testSourceUrl, Err: context.Canceled}, now)
+	cb.record(status.Error(codes.InvalidArgument, "bad request"), now)
+	g.Expect(cb.allow(now)).To(BeTrue())
+
+	cb.record(status.Error(codes.Internal, "server error"), now)
+	g.Expect(cb.allow(now)).To(BeFalse())
+
+	// A cancelled trial call lets another trial through
+	later := now.Add(time.Minute)
+	g.Expect(cb.allow(later)).To(BeTrue())
+	cb.record(context.Canceled, later)
+	g.Expect(cb.allow(later)).To(BeTrue())
+	cb.record(nil, later)
+	g.Expect(cb.state).To(Equal(circuitClosed))
+}
+
+func TestCircuitBreakerFallbackResponse(t *testing.T) {
+	g := NewGomegaWithT(t)
+	model := v1.MODEL
+	graph := &v1.PredictiveUnit{
+		Name: "brokenWithFallback",
+		Type: &model,
+		Endpoint: &v1.Endpoint{
+			ServiceHost: "foo",
+			ServicePort: 9000,
+			Type:        v1.REST,
+		},
+		CircuitBreaker: &v1.CircuitBreaker{
+			MaxFailures:      1,
+			FallbackResponse: `{"data":{"ndarray":[0]}}`,
+		},
+	}
+
+	var calls int32
+	_, err := createPredictorProcessWithUnreliableClient(t, 0, 10, &calls).Predict(graph, createPredictPayload(g))
+	g.Expect(err).ShouldNot(BeNil())
+	pResp, err := createPredictorProcessWithUnreliableClient(t, 0, 10, &calls).Predict(graph, createPredictPayload(g))
+	g.Expect(err).Should(BeNil())
+	g.Expect(calls).To(Equal(int32(1)))
+	smRes := pResp.GetPayload().(*proto.SeldonMessage)
+	g.Expect(smRes.GetData().GetNdarray().Values[0].GetNumberValue()).Should(Equal(0.0))
+}
+
+func TestCircuitBreakerFallbackResponseTensorflow(t *testing.T) {
+	g := NewGomegaWithT(t)
+	model := v1.MODEL
+	graph := &v1.PredictiveUnit{
+		Name: "brokenTensorflowWithFallback",
+		Type: &model,
+		Endpoint: &v1.Endpoint{
+			ServiceHost: "foo",
+			ServicePort: 9000,
+			Type:        v1.GRPC,
+		},
+		CircuitBreaker: &v1.CircuitBreaker{
+			MaxFailures:      1,
+			FallbackResponse: `{"outputs":{"y":{"dtype":"DT_FLOAT","floatVal":[0.5]}}}`,
+		},
+	}
+	msg := &payload.ProtoPayload{Msg: &serving.PredictRequest{ModelSpec: &serving.ModelSpec{Name: "brokenTensorflowWithFallback"}}}
+
+	var calls int32
+	_, err := createPredictorProcessWithUnreliableClient(t, 0, 10, &calls).Predict(graph, msg)
+	g.Expect(err).ShouldNot(BeNil())
+	pResp, err := createPredictorProcessWithUnreliableClient(t, 0, 10, &calls).Predict(graph, msg)
+	g.Expect(err).Should(BeNil())
+	// The gRPC server of the protocol expects its own response type
+	tfRes, ok := pResp.GetPayload().(*serving.PredictResponse)
+	g.Expect(ok).To(BeTrue())
+	g.Expect(tfRes.GetOutputs()["y"].GetFloatVal()).To(Equal([]float32{0.5}))
+}
+
+type failingModelsTestClient struct {
+	test.SeldonMessageTestClient
+	failing map[string]bool
+}
+
+func (s failingModelsTestClient) Predict(ctx context.Context, modelName string, host string, port int32, msg payload.SeldonPayload, meta map[string][]string) (payload.SeldonPayload, error) {
+	if s.failing[modelName] {
+		return nil, errors.New("unavailable")
+	}
+	return msg, nil
+}
+
+func createEnsembleGraph(policy *v1.ChildrenPolicy) *v1.PredictiveUnit {
+	model := v1.MODEL
+	combiner := v1.COMBINER
+	graph := &v1.PredictiveUnit{
+		Name:           "combiner",
+		Type:           &combiner,
+		ChildrenPolicy: policy,
+		Endpoint: &v1.Endpoint{
+			ServiceHost: "foo",
+			ServicePort: 9000,
+			Type:        v1.REST,
+		},
+	}
+	for _, name := range []string{"a", "b", "c"} {
+		graph.Children = append(graph.Children, v1.PredictiveUnit{
+			Name: name,
+			Type: &model,
+			Endpoint: &v1.Endpoint{
+				ServiceHost: "foo",
+				ServicePort: 9000,
+				Type:        v1.REST,
+			},
+		})
+	}
+	return graph
+}
+
+func TestChildrenPolicy(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	tests := []struct {
+		policy  *v1.ChildrenPolicy
+		failing map[string]bool
+		success bool
+	}{
+		{policy: nil, failing: map[string]bool{"b": true}, success: false},
+		{policy: &v1.ChildrenPolicy{Mode: v1.ChildrenQuorum, Quorum: 2}, failing: map[string]bool{"b": true}, success: true},
+		{policy: &v1.ChildrenPolicy{Mode: v1.ChildrenQuorum, Quorum: 2}, failing: map[string]bool{"a": true, "b": true}, success: false},
+		{policy: &v1.ChildrenPolicy{Mode: v1.ChildrenIgnoreFailures}, failing: map[string]bool{"a": true, "b": true}, success: true},
+		{policy: &v1.ChildrenPolicy{Mode: v1.ChildrenIgnoreFailures}, failing: map[string]bool{"a": true, "b": true, "c": true}, success: false},
+		{policy: &v1.ChildrenPolicy{Mode: v1.ChildrenFirstSuccess}, failing: map[string]bool{"a": true, "c": true}, success: true},
+	}
+
+	for _, tt := range tests {
+		url, _ := url.Parse(testSourceUrl)
+		ctx := context.WithValue(context.TODO(), payload.SeldonPUIDHeader, testSeldonPuid)
+		pp := NewPredictorProcess(ctx, failingModelsTestClient{failing: tt.failing}, logf.Log.WithName("SeldonMessageRestClient"), url, "default", map[string][]string{})
+		pResp, err := pp.Predict(createEnsembleGraph(tt.policy), createPredictPayload(g))
+		if !tt.success {
+			g.Expect(err).ShouldNot(BeNil())
+			continue
+		}
+		g.Expect(err).Should(BeNil())
+		smRes := pResp.GetPayload().(*proto.SeldonMessage)
+		g.Expect(smRes.GetData().GetNdarray().Values[0].GetNumberValue()).Should(Equal(1.1))
+		status := smRes.GetMeta().GetTags()[ChildStatusTag].GetStructValue().GetFields()["combiner"].GetStructValue().GetFields()
+		g.Expect(status).To(HaveLen(3))
+		if tt.policy.Mode != v1.ChildrenFirstSuccess {
+			g.Expect(status["b"].GetStructValue().GetFields()["status"].GetStringValue()).To(Equal(ChildStatusFailure))
+		}
+		// Routes recorded by the children are merged into the request's routing
+		for _, child := range []string{"a", "b", "c"} {
+			g.Expect(pp.Routing).To(HaveKey(child))
+		}
+	}
+}
+
+func createKeyRouterGraph(impl v1.PredictiveUnitImplementation, params map[string]string) *v1.PredictiveUnit {
+	model := v1.MODEL
+	graph := &v1.PredictiveUnit{
+		Name:           "router",
+		Implementation: &impl,
+	}
+	for name, value := range params {
+		graph.Parameters = append(graph.Parameters, v1.Parameter{Name: name, Value: value, Type: v1.STRING})
+	}
+	for _, name := range []string{"a", "b", "c"} {
+		graph.Children = append(graph.Children, v1.PredictiveUnit{
+			Name: name,
+			Type: &model,
+			Endpoint: &v1.Endpoint{
+				ServiceHost: "foo",
+				ServicePort: 9000,
+				Type:        v1.REST,
+			},
+		})
+	}
+	return graph
+}
+
+func TestHeaderRouter(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	graph := createKeyRouterGraph(v1.HEADER_ROUTER, map[string]string{"header": "Key", "mapping": "bar:a,foo:c"})
+	route, err := createPredictorProcess(t).route(graph, createPredictPayload(g))
+	g.Expect(err).Should(BeNil())
+	g.Expect(route).To(Equal(2))
+
+	graph = createKeyRouterGraph(v1.HEADER_ROUTER, map[string]string{"jsonPath": "$.data.ndarray[1]", "mapping": "2:b"})
+	route, err = createPredictorProcess(t).route(graph, createPredictPayload(g))
+	g.Expect(err).Should(BeNil())
+	g.Expect(route).To(Equal(1))
+
+	graph = createKeyRouterGraph(v1.HEADER_ROUTER, map[string]string{"header": "X-Missing", "mapping": "foo:c"})
+	_, err = createPredictorProcess(t).route(graph, createPredictPayload(g))
+	g.Expect(err).ShouldNot(BeNil())
+
+	graph = createKeyRouterGraph(v1.HEADER_ROUTER, map[string]string{"header": "X-Missing", "mapping": "foo:c", "default": "b"})
+	route, err = createPredictorProcess(t).route(graph, createPredictPayload(g))
+	g.Expect(err).Should(BeNil())
+	g.Expect(route).To(Equal(1))
+}
+
+func TestConsistentHashRouter(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	graph := createKeyRouterGraph(v1.CONSISTENT_HASH_ROUTER, map[string]string{"jsonPath": "meta.tags.user"})
+	routes := make(map[string]int)
+	counts := make(map[int]int)
+	for i := 0; i < 300; i++ {
+		user := fmt.Sprintf("user%d", i)
+		msg := &payload.BytesPayload{Msg: []byte(`{"meta":{"tags":{"user":"` + user + `"}},"data":{"ndarray":[1]}}`), ContentType: "application/json"}
+		route, err := createPredictorProcess(t).route(graph, msg)
+		g.Expect(err).Should(BeNil())
+		// The same user is always sent to the same child
+		again, _ := createPredictorProcess(t).route(graph, msg)
+		g.Expect(again).To(Equal(route))
+		routes[user] = route
+		counts[route]++
+	}
+	g.Expect(counts).To(HaveLen(3))
+
+	// Removing a child only moves the users that were assigned to it
+	graph.Children = graph.Children[:2]
+	for user, route := range routes {
+		if route == 2 {
+			continue
+		}
+		msg := &payload.BytesPayload{Msg: []byte(`{"meta":{"tags":{"user":"` + user + `"}},"data":{"ndarray":[1]}}`), ContentType: "application/json"}
+		newRoute, err := createPredictorProcess(t).route(graph, msg)
+		g.Expect(err).Should(BeNil())
+		g.Expect(newRoute).To(Equal(route))
+	}
+}
+
+func TestWeightedABTest(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	graph := createKeyRouterGraph(v1.RANDOM_ABTEST, map[string]string{"weights": "0.2,0,0.8"})
+	counts := make([]int, 3)
+	for i := 0; i < 1000; i++ {
+		pp := createPredictorProcess(t)
+		_, err := pp.Predict(graph, createPredictPayload(g))
+		g.Expect(err).Should(BeNil())
+		counts[pp.Routing["router"]]++
+	}
+	g.Expect(counts[1]).To(Equal(0))
+	g.Expect(counts[0]).To(BeNumerically("~", 200, 100))
+	g.Expect(counts[2]).To(BeNumerically("~", 800, 100))
+
+	graph = createKeyRouterGraph(v1.RANDOM_ABTEST, map[string]string{"weights": "0.5,0.5"})
+	_, err := createPredictorProcess(t).route(graph, createPredictPayload(g))
+	g.Expect(err).ShouldNot(BeNil())
+}
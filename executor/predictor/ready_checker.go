@@ -1,11 +1,31 @@
 package predictor
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
-	"github.com/seldonio/seldon-core/operator/apis/machinelearning.seldon.io/v1"
 	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/seldonio/seldon-core/executor/api"
+	"github.com/seldonio/seldon-core/executor/api/client"
+	"github.com/seldonio/seldon-core/executor/api/grpc/kfserving/inference"
+	"github.com/seldonio/seldon-core/executor/api/payload"
+	"github.com/seldonio/seldon-core/executor/proto/tensorflow/serving"
+	"github.com/seldonio/seldon-core/operator/apis/machinelearning.seldon.io/v1"
+	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
 )
 
+// How long the result of a readiness check is reused for.
+var readyCacheTTL = time.Second
+
+// How long the health check of a single node may take.
+var readyCheckTimeout = 2 * time.Second
+
+// Ready checks a TCP connection can be made to every node of the graph.
 func Ready(node *v1.PredictiveUnit) error {
 	for _, child := range node.Children {
 		err := Ready(&child)
@@ -14,14 +34,184 @@ func Ready(node *v1.PredictiveUnit) error {
 		}
 	}
 	if node.Endpoint != nil && node.Endpoint.ServiceHost != "" && node.Endpoint.ServicePort > 0 {
-		c, err := net.Dial("tcp", fmt.Sprintf("%s:%d", node.Endpoint.ServiceHost, node.Endpoint.ServicePort))
+		return dial(node.Endpoint.ServiceHost, node.Endpoint.ServicePort)
+	} else {
+		return nil
+	}
+}
+
+func dial(host string, port int32) error {
+	c, err := net.Dial("tcp", net.JoinHostPort(host, strconv.Itoa(int(port))))
+	if err != nil {
+		return err
+	}
+	return c.Close()
+}
+
+// NodeNotReadyError is returned when a node of the graph fails its health check.
+type NodeNotReadyError struct {
+	Node string
+	Err  error
+}
+
+func (e *NodeNotReadyError) Error() string {
+	return fmt.Sprintf("Node %s is not ready: %v", e.Node, e.Err)
+}
+
+// ReadyChecker checks the nodes of a graph are ready with the health endpoint of their protocol.
+// Seldon nodes are checked with /health/status, Tensorflow nodes with the model status and KFServing
+// nodes with the model ready endpoint, over gRPC for gRPC endpoints. Nodes whose transport has no
+// client are checked by opening a TCP connection.
+type ReadyChecker struct {
+	Log        logr.Logger
+	predictor  *v1.PredictorSpec
+	protocol   string
+	restClient client.SeldonApiClient
+	grpcClient client.SeldonApiClient
+	ttl        time.Duration
+
+	mu      sync.Mutex
+	err     error
+	checked time.Time
+}
+
+func NewReadyChecker(predictor *v1.PredictorSpec, protocol string, restClient client.SeldonApiClient, grpcClient client.SeldonApiClient) *ReadyChecker {
+	return &ReadyChecker{
+		Log:        logf.Log.WithName("ReadyChecker"),
+		predictor:  predictor,
+		protocol:   protocol,
+		restClient: restClient,
+		grpcClient: grpcClient,
+		ttl:        readyCacheTTL,
+	}
+}
+
+// Ready checks all nodes of the graph in parallel and returns the error of the first node in the
+// graph which is not ready. The result is cached for a short time so frequent probes don't add load
+// to the models.
+func (rc *ReadyChecker) Ready(ctx context.Context) error {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if !rc.checked.IsZero() && time.Since(rc.checked) < rc.ttl {
+		return rc.err
+	}
+	rc.err = rc.check(ctx)
+	rc.checked = time.Now()
+	return rc.err
+}
+
+func (rc *ReadyChecker) check(ctx context.Context) error {
+	nodes := v1.GetPredictiveUnitList(&rc.predictor.Graph)
+	errs := make([]error, len(nodes))
+	var wg sync.WaitGroup
+	for i, node := range nodes {
+		wg.Add(1)
+		go func(i int, node *v1.PredictiveUnit) {
+			defer wg.Done()
+			if err := rc.nodeReady(ctx, node); err != nil {
+				errs[i] = &NodeNotReadyError{Node: node.Name, Err: err}
+			}
+		}(i, node)
+	}
+	wg.Wait()
+	for _, err := range errs {
 		if err != nil {
 			return err
-		} else {
-			err = c.Close()
-			return nil
 		}
-	} else {
+	}
+	return nil
+}
+
+func (rc *ReadyChecker) nodeReady(ctx context.Context, node *v1.PredictiveUnit) error {
+	endpoint := node.Endpoint
+	if endpoint == nil || endpoint.ServiceHost == "" || endpoint.ServicePort <= 0 {
 		return nil
 	}
+	ctx, cancel := context.WithTimeout(ctx, readyCheckTimeout)
+	defer cancel()
+
+	protocol := rc.protocol
+	if node.Protocol != nil && *node.Protocol != "" {
+		protocol = string(*node.Protocol)
+	}
+	httpPort, grpcPort := portOrDefault(endpoint.HttpPort, endpoint.ServicePort), portOrDefault(endpoint.GrpcPort, endpoint.ServicePort)
+
+	// Seldon gRPC models have no health endpoint so their REST endpoint is used
+	if endpoint.Type == v1.GRPC && protocol != api.ProtocolSeldon {
+		if rc.grpcClient == nil {
+			return dial(endpoint.ServiceHost, endpoint.ServicePort)
+		}
+		return grpcReady(ctx, rc.grpcClient, protocol, node.Name, endpoint.ServiceHost, grpcPort)
+	}
+	if rc.restClient == nil || (endpoint.Type == v1.GRPC && endpoint.HttpPort <= 0) {
+		return dial(endpoint.ServiceHost, endpoint.ServicePort)
+	}
+	return restReady(ctx, rc.restClient, protocol, node.Name, endpoint.ServiceHost, httpPort)
+}
+
+func portOrDefault(port int32, defaultPort int32) int32 {
+	if port > 0 {
+		return port
+	}
+	return defaultPort
+}
+
+// restReady calls the health endpoint of a REST node. The REST client returns an error for any
+// status other than 200.
+func restReady(ctx context.Context, c client.SeldonApiClient, protocol string, name string, host string, port int32) error {
+	res, err := c.Status(ctx, name, host, port, nil, nil)
+	if err != nil {
+		return err
+	}
+	if protocol != api.ProtocolTensorflow {
+		return nil
+	}
+	// Tensorflow returns the state of each version of the model
+	var modelStatus struct {
+		ModelVersionStatus []struct {
+			State string `json:"state"`
+		} `json:"model_version_status"`
+	}
+	if b, ok := res.GetPayload().([]byte); ok {
+		if err := json.Unmarshal(b, &modelStatus); err != nil {
+			return err
+		}
+	}
+	for _, s := range modelStatus.ModelVersionStatus {
+		if s.State == serving.ModelVersionStatus_AVAILABLE.String() {
+			return nil
+		}
+	}
+	return fmt.Errorf("No version of model %s is available", name)
+}
+
+func grpcReady(ctx context.Context, c client.SeldonApiClient, protocol string, name string, host string, port int32) error {
+	switch protocol {
+	case api.ProtocolTensorflow:
+		req := &serving.GetModelStatusRequest{ModelSpec: &serving.ModelSpec{Name: name}}
+		res, err := c.Status(ctx, name, host, port, &payload.ProtoPayload{Msg: req}, nil)
+		if err != nil {
+			return err
+		}
+		if modelStatus, ok := res.GetPayload().(*serving.GetModelStatusResponse); ok {
+			for _, s := range modelStatus.GetModelVersionStatus() {
+				if s.GetState() == serving.ModelVersionStatus_AVAILABLE {
+					return nil
+				}
+			}
+		}
+		return fmt.Errorf("No version of model %s is available", name)
+	case api.ProtocolKFServing:
+		req := &inference.ModelReadyRequest{Name: name}
+		res, err := c.Status(ctx, name, host, port, &payload.ProtoPayload{Msg: req}, nil)
+		if err != nil {
+			return err
+		}
+		if modelReady, ok := res.GetPayload().(*inference.ModelReadyResponse); ok && modelReady.GetReady() {
+			return nil
+		}
+		return fmt.Errorf("Model %s is not ready", name)
+	default:
+		return fmt.Errorf("Unknown protocol %s", protocol)
+	}
 }
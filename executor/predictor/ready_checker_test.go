@@ -0,0 +1,156 @@
+package predictor
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/seldonio/seldon-core/executor/api"
+	"github.com/seldonio/seldon-core/executor/api/grpc/kfserving/inference"
+	"github.com/seldonio/seldon-core/executor/api/payload"
+	"github.com/seldonio/seldon-core/executor/api/test"
+	v1 "github.com/seldonio/seldon-core/operator/apis/machinelearning.seldon.io/v1"
+)
+
+// statusTestClient returns a status response or error for each model.
+type statusTestClient struct {
+	test.SeldonMessageTestClient
+	mu        sync.Mutex
+	responses map[string]payload.SeldonPayload
+	errs      map[string]error
+	calls     int
+}
+
+func (s *statusTestClient) Status(ctx context.Context, modelName string, host string, port int32, msg payload.SeldonPayload, meta map[string][]string) (payload.SeldonPayload, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls++
+	if err := s.errs[modelName]; err != nil {
+		return nil, err
+	}
+	if res, ok := s.responses[modelName]; ok {
+		return res, nil
+	}
+	return &payload.BytesPayload{Msg: []byte(test.TestClientStatusResponse)}, nil
+}
+
+func createReadySpec(endpointType v1.EndpointType) *v1.PredictorSpec {
+	model := v1.MODEL
+	return &v1.PredictorSpec{
+		Name: "p",
+		Graph: v1.PredictiveUnit{
+			Name:     "model-1",
+			Type:     &model,
+			Endpoint: &v1.Endpoint{ServiceHost: "foo", ServicePort: 9000, HttpPort: 9000, GrpcPort: 9500, Type: endpointType},
+			Children: []v1.PredictiveUnit{
+				{
+					Name:     "model-2",
+					Type:     &model,
+					Endpoint: &v1.Endpoint{ServiceHost: "foo", ServicePort: 9001, HttpPort: 9001, GrpcPort: 9501, Type: endpointType},
+				},
+			},
+		},
+	}
+}
+
+func TestReadyCheckerSeldon(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	client := &statusTestClient{}
+	rc := NewReadyChecker(createReadySpec(v1.REST), api.ProtocolSeldon, client, nil)
+	g.Expect(rc.Ready(context.TODO())).To(BeNil())
+	g.Expect(client.calls).To(Equal(2))
+
+	client = &statusTestClient{errs: map[string]error{"model-2": errors.New("503")}}
+	rc = NewReadyChecker(createReadySpec(v1.REST), api.ProtocolSeldon, client, nil)
+	err := rc.Ready(context.TODO())
+	g.Expect(err).To(BeAssignableToTypeOf(&NodeNotReadyError{}))
+	g.Expect(err.(*NodeNotReadyError).Node).To(Equal("model-2"))
+	g.Expect(err.Error()).To(Equal("Node model-2 is not ready: 503"))
+}
+
+func TestReadyCheckerTensorflowRest(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	client := &statusTestClient{responses: map[string]payload.SeldonPayload{
+		"model-1": &payload.BytesPayload{Msg: []byte(`{"model_version_status":[{"version":"1","state":"AVAILABLE"}]}`)},
+		"model-2": &payload.BytesPayload{Msg: []byte(`{"model_version_status":[{"version":"1","state":"LOADING"}]}`)},
+	}}
+	rc := NewReadyChecker(createReadySpec(v1.REST), api.ProtocolTensorflow, client, nil)
+	err := rc.Ready(context.TODO())
+	g.Expect(err).ToNot(BeNil())
+	g.Expect(err.(*NodeNotReadyError).Node).To(Equal("model-2"))
+}
+
+func TestReadyCheckerKFServingGrpc(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	client := &statusTestClient{responses: map[string]payload.SeldonPayload{
+		"model-1": &payload.ProtoPayload{Msg: &inference.ModelReadyResponse{Ready: true}},
+		"model-2": &payload.ProtoPayload{Msg: &inference.ModelReadyResponse{Ready: true}},
+	}}
+	rc := NewReadyChecker(createReadySpec(v1.GRPC), api.ProtocolKFServing, nil, client)
+	g.Expect(rc.Ready(context.TODO())).To(BeNil())
+
+	client.responses["model-1"] = &payload.ProtoPayload{Msg: &inference.ModelReadyResponse{Ready: false}}
+	rc = NewReadyChecker(createReadySpec(v1.GRPC), api.ProtocolKFServing, nil, client)
+	err := rc.Ready(context.TODO())
+	g.Expect(err).ToNot(BeNil())
+	g.Expect(err.(*NodeNotReadyError).Node).To(Equal("model-1"))
+}
+
+func TestReadyCheckerUnitProtocol(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	spec := createReadySpec(v1.REST)
+	tensorflow := v1.ProtocolTensorflow
+	spec.Graph.Children[0].Protocol = &tensorflow
+	// The Seldon status response has no available Tensorflow model versions
+	client := &statusTestClient{}
+	rc := NewReadyChecker(spec, api.ProtocolSeldon, client, nil)
+	err := rc.Ready(context.TODO())
+	g.Expect(err).ToNot(BeNil())
+	g.Expect(err.(*NodeNotReadyError).Node).To(Equal("model-2"))
+}
+
+func TestReadyCheckerCached(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	client := &statusTestClient{}
+	rc := NewReadyChecker(createReadySpec(v1.REST), api.ProtocolSeldon, client, nil)
+	g.Expect(rc.Ready(context.TODO())).To(BeNil())
+	client.errs = map[string]error{"model-1": errors.New("503")}
+	g.Expect(rc.Ready(context.TODO())).To(BeNil())
+	g.Expect(client.calls).To(Equal(2))
+
+	rc.checked = rc.checked.Add(-2 * rc.ttl)
+	g.Expect(rc.Ready(context.TODO())).ToNot(BeNil())
+}
+
+func TestReadyCheckerDial(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	g.Expect(err).To(BeNil())
+	port := int32(lis.Addr().(*net.TCPAddr).Port)
+
+	// Nodes are checked with a TCP connection without a client for their transport
+	model := v1.MODEL
+	spec := &v1.PredictorSpec{
+		Name: "p",
+		Graph: v1.PredictiveUnit{
+			Name:     "model-1",
+			Type:     &model,
+			Endpoint: &v1.Endpoint{ServiceHost: "127.0.0.1", ServicePort: port, GrpcPort: port, Type: v1.GRPC},
+		},
+	}
+	rc := NewReadyChecker(spec, api.ProtocolKFServing, &statusTestClient{}, nil)
+	g.Expect(rc.Ready(context.TODO())).To(BeNil())
+
+	lis.Close()
+	rc = NewReadyChecker(spec, api.ProtocolKFServing, &statusTestClient{}, nil)
+	g.Expect(rc.Ready(context.TODO())).ToNot(BeNil())
+}
@@ -0,0 +1,79 @@
+package predictor
+
+import (
+	"sync"
+
+	"github.com/seldonio/seldon-core/executor/api/payload"
+	"github.com/seldonio/seldon-core/executor/api/tracing"
+	"github.com/seldonio/seldon-core/operator/apis/machinelearning.seldon.io/v1"
+	"go.opentelemetry.io/otel/label"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Steps of a node which are traced in their own span.
+const (
+	StepTransformInput  = "transform-input"
+	StepPredict         = "predict"
+	StepRoute           = "route"
+	StepChildren        = "children"
+	StepAggregate       = "aggregate"
+	StepTransformOutput = "transform-output"
+)
+
+// Container image of each node, keyed by node name.
+var nodeImages sync.Map
+
+// SetNodeImages records the container image of each node of the predictor.
+func SetNodeImages(spec *v1.PredictorSpec) {
+	for _, node := range v1.GetPredictiveUnitList(&spec.Graph) {
+		if c := v1.GetContainerForPredictiveUnit(spec, node.Name); c != nil {
+			nodeImages.Store(node.Name, c.Image)
+		}
+	}
+}
+
+func nodeImage(name string) string {
+	if image, ok := nodeImages.Load(name); ok {
+		return image.(string)
+	}
+	return ""
+}
+
+// startSpan starts a span and returns a copy of the predictor process whose context carries it, so
+// calls made with the copy are children of the span.
+func (p *PredictorProcess) startSpan(name string, attrs ...label.KeyValue) (*PredictorProcess, trace.Span) {
+	ctx, span := tracing.Tracer().Start(p.Ctx, name, trace.WithAttributes(attrs...))
+	sp := *p
+	sp.Ctx = ctx
+	return &sp, span
+}
+
+func (p *PredictorProcess) startNodeSpan(node *v1.PredictiveUnit) (*PredictorProcess, trace.Span) {
+	attrs := []label.KeyValue{tracing.NodeNameKey.String(node.Name)}
+	if image := nodeImage(node.Name); image != "" {
+		attrs = append(attrs, tracing.NodeImageKey.String(image))
+	}
+	return p.startSpan(node.Name, attrs...)
+}
+
+func (p *PredictorProcess) startStepSpan(node *v1.PredictiveUnit, step string) (*PredictorProcess, trace.Span) {
+	return p.startSpan(node.Name+" "+step, tracing.NodeNameKey.String(node.Name), tracing.NodeStepKey.String(step))
+}
+
+// traceStep runs a step of a node in its own span.
+func (p *PredictorProcess) traceStep(node *v1.PredictiveUnit, step string, fn func(sp *PredictorProcess) (payload.SeldonPayload, error)) (payload.SeldonPayload, error) {
+	sp, span := p.startStepSpan(node, step)
+	res, err := fn(sp)
+	tracing.EndSpan(span, err)
+	return res, err
+}
+
+// traceRoute adds the route chosen by a router to its span and the span of the node.
+func (p *PredictorProcess) traceRoute(span trace.Span, node *v1.PredictiveUnit, route int) {
+	attrs := []label.KeyValue{tracing.RouteKey.Int(route)}
+	if route >= 0 && route < len(node.Children) {
+		attrs = append(attrs, tracing.RouteChildKey.String(node.Children[route].Name))
+	}
+	span.SetAttributes(attrs...)
+	trace.SpanFromContext(p.Ctx).SetAttributes(attrs...)
+}
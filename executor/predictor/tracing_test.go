@@ -0,0 +1,84 @@
+package predictor
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/seldonio/seldon-core/executor/api/tracing"
+	"github.com/seldonio/seldon-core/operator/apis/machinelearning.seldon.io/v1"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/label"
+	"go.opentelemetry.io/otel/sdk/export/trace/tracetest"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestNodeSpans(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	otel.SetTracerProvider(provider)
+	defer otel.SetTracerProvider(trace.NewNoopTracerProvider())
+
+	model := v1.MODEL
+	router := v1.ROUTER
+	graph := &v1.PredictiveUnit{
+		Name:     "router",
+		Type:     &router,
+		Endpoint: &v1.Endpoint{ServiceHost: "foo", ServicePort: 9000, Type: v1.REST},
+		Children: []v1.PredictiveUnit{
+			{
+				Name:     "model-a",
+				Type:     &model,
+				Endpoint: &v1.Endpoint{ServiceHost: "foo2", ServicePort: 9001, Type: v1.REST},
+			},
+			{
+				Name:     "model-b",
+				Type:     &model,
+				Endpoint: &v1.Endpoint{ServiceHost: "foo3", ServicePort: 9002, Type: v1.REST},
+			},
+		},
+	}
+	SetNodeImages(&v1.PredictorSpec{
+		Graph: *graph,
+		ComponentSpecs: []*v1.SeldonPodSpec{{Spec: corev1.PodSpec{Containers: []corev1.Container{
+			{Name: "router", Image: "seldonio/router:1.0"},
+			{Name: "model-b", Image: "seldonio/model-b:1.0"},
+		}}}},
+	})
+
+	_, err := createPredictorProcessWithRoute(t, 1).Predict(graph, createPredictPayload(g))
+	g.Expect(err).Should(BeNil())
+
+	spans := map[string]map[label.Key]label.Value{}
+	parents := map[string]string{}
+	ids := map[trace.SpanID]string{}
+	for _, span := range exporter.GetSpans() {
+		attrs := map[label.Key]label.Value{}
+		for _, attr := range span.Attributes {
+			attrs[attr.Key] = attr.Value
+		}
+		spans[span.Name] = attrs
+		ids[span.SpanContext.SpanID] = span.Name
+	}
+	for _, span := range exporter.GetSpans() {
+		parents[span.Name] = ids[span.ParentSpanID]
+	}
+
+	g.Expect(spans).To(HaveLen(5))
+	g.Expect(spans["router"][tracing.NodeImageKey].AsString()).To(Equal("seldonio/router:1.0"))
+	g.Expect(spans["router"][tracing.RouteKey].AsInt64()).To(Equal(int64(1)))
+	g.Expect(spans["router"][tracing.RouteChildKey].AsString()).To(Equal("model-b"))
+	g.Expect(spans["router route"][tracing.NodeStepKey].AsString()).To(Equal(StepRoute))
+	g.Expect(spans["router children"][tracing.NodeStepKey].AsString()).To(Equal(StepChildren))
+	g.Expect(spans["model-b"][tracing.NodeImageKey].AsString()).To(Equal("seldonio/model-b:1.0"))
+	g.Expect(spans["model-b predict"][tracing.NodeNameKey].AsString()).To(Equal("model-b"))
+
+	g.Expect(parents["router"]).To(Equal(""))
+	g.Expect(parents["router route"]).To(Equal("router"))
+	g.Expect(parents["router children"]).To(Equal("router"))
+	g.Expect(parents["model-b"]).To(Equal("router children"))
+	g.Expect(parents["model-b predict"]).To(Equal("model-b"))
+}
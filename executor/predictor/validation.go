@@ -0,0 +1,260 @@
+package predictor
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/seldonio/seldon-core/executor/api"
+	"github.com/seldonio/seldon-core/executor/api/payload"
+	"github.com/seldonio/seldon-core/executor/api/translate"
+	v1 "github.com/seldonio/seldon-core/operator/apis/machinelearning.seldon.io/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const ENV_ENABLE_REQUEST_VALIDATION = "SELDON_ENABLE_REQUEST_VALIDATION"
+
+var envEnableRequestValidation = len(os.Getenv(ENV_ENABLE_REQUEST_VALIDATION)) != 0
+
+// How long to wait before fetching metadata again after it could not be fetched.
+var validationRetryInterval = time.Minute
+
+// RequestValidationError is returned when a request does not match the metadata of the graph inputs.
+type RequestValidationError struct {
+	Reason string
+}
+
+func (e *RequestValidationError) Error() string {
+	return "Invalid request: " + e.Reason
+}
+
+// GRPCStatus allows gRPC servers to return the error with an InvalidArgument code.
+func (e *RequestValidationError) GRPCStatus() *status.Status {
+	return status.New(codes.InvalidArgument, e.Error())
+}
+
+func invalidRequest(format string, args ...interface{}) error {
+	return &RequestValidationError{Reason: fmt.Sprintf(format, args...)}
+}
+
+// metadataInput is an input in either the v2 form (name, datatype, shape) or the Seldon form
+// (messagetype and a schema of column names and shape).
+type metadataInput struct {
+	Name        string        `json:"name"`
+	DataType    string        `json:"datatype"`
+	Shape       []interface{} `json:"shape"`
+	MessageType string        `json:"messagetype"`
+	Schema      *struct {
+		Names []string      `json:"names"`
+		Shape []interface{} `json:"shape"`
+	} `json:"schema"`
+}
+
+type expectedInput struct {
+	name     string
+	datatype string
+	shape    []int64
+	// Seldon schemas describe a single row so the batch dimension is not included
+	rowShape bool
+	columns  []string
+}
+
+func parseShape(dims []interface{}) ([]int64, error) {
+	shape := make([]int64, 0, len(dims))
+	for _, d := range dims {
+		switch n := d.(type) {
+		case float64:
+			shape = append(shape, int64(n))
+		case string:
+			i, err := strconv.ParseInt(n, 10, 64)
+			if err != nil {
+				return nil, err
+			}
+			shape = append(shape, i)
+		default:
+			return nil, fmt.Errorf("Invalid dimension %v", d)
+		}
+	}
+	return shape, nil
+}
+
+// parseInputs reads the inputs of model metadata. Inputs that can't be understood are not validated.
+func parseInputs(inputs interface{}) ([]expectedInput, error) {
+	data, err := json.Marshal(inputs)
+	if err != nil {
+		return nil, err
+	}
+	var parsed []metadataInput
+	if err := json.Unmarshal(data, &parsed); err != nil {
+		return nil, err
+	}
+	expected := make([]expectedInput, 0, len(parsed))
+	for _, in := range parsed {
+		switch in.MessageType {
+		case "", "tensor", "ndarray", "tftensor":
+		default:
+			// Other Seldon message types are not tensors
+			return nil, nil
+		}
+		e := expectedInput{name: in.Name, datatype: in.DataType}
+		dims := in.Shape
+		if in.Schema != nil {
+			e.columns = in.Schema.Names
+			e.rowShape = true
+			dims = in.Schema.Shape
+		}
+		if e.shape, err = parseShape(dims); err != nil {
+			return nil, err
+		}
+		expected = append(expected, e)
+	}
+	return expected, nil
+}
+
+type graphInputs struct {
+	inputs  []expectedInput
+	err     error
+	fetched time.Time
+}
+
+// Inputs of the graph keyed by the node requests are sent to.
+var validationInputs sync.Map
+
+// expectedInputs returns the inputs of the graph from its metadata. Failures to fetch metadata are
+// remembered so models without metadata are not asked on every request.
+func (p *PredictorProcess) expectedInputs(node *v1.PredictiveUnit) ([]expectedInput, error) {
+	if node.Endpoint == nil {
+		return nil, nil
+	}
+	key := fmt.Sprintf("%s/%s:%d", node.Name, node.Endpoint.ServiceHost, node.Endpoint.ServicePort)
+	if v, ok := validationInputs.Load(key); ok {
+		gi := v.(*graphInputs)
+		if gi.err == nil || time.Since(gi.fetched) < validationRetryInterval {
+			return gi.inputs, gi.err
+		}
+	}
+	gi := &graphInputs{fetched: time.Now()}
+	metadataMap, err := p.ModelMetadataMap(node)
+	if err == nil {
+		gm := &GraphMetadata{Models: metadataMap}
+		if input, _ := gm.getEdgeNodes(node); input != nil && input.Inputs != nil {
+			gi.inputs, err = parseInputs(input.Inputs)
+		}
+	}
+	gi.err = err
+	validationInputs.Store(key, gi)
+	return gi.inputs, gi.err
+}
+
+// ValidateRequest checks a request sent to a node against the inputs in the metadata of the graph
+// when request validation is enabled. Requests are not validated if there is no usable metadata.
+func (p *PredictorProcess) ValidateRequest(node *v1.PredictiveUnit, protocol string, msg payload.SeldonPayload) error {
+	if !envEnableRequestValidation {
+		return nil
+	}
+	expected, err := p.expectedInputs(node)
+	if err != nil {
+		p.Log.V(1).Info("Not validating request as metadata is not available", "node", node.Name, "error", err.Error())
+		return nil
+	}
+	if len(expected) == 0 {
+		return nil
+	}
+	m, err := translate.Decode(msg, protocol, false)
+	if err != nil {
+		return invalidRequest("%v", err)
+	}
+	return validateTensors(expected, m.Tensors, protocol == api.ProtocolKFServing)
+}
+
+// validateTensors matches tensors to inputs by name or, for unnamed tensors, by position. Numeric
+// datatypes are only compared exactly if the protocol declares them.
+func validateTensors(expected []expectedInput, tensors []translate.Tensor, exactDatatypes bool) error {
+	named := true
+	for _, t := range tensors {
+		if t.Name == "" {
+			named = false
+		}
+	}
+	byName := make(map[string]*translate.Tensor)
+	if named {
+		for i := range tensors {
+			byName[tensors[i].Name] = &tensors[i]
+		}
+	} else if len(tensors) != len(expected) {
+		return invalidRequest("expected %d input tensors but got %d", len(expected), len(tensors))
+	}
+	for i, e := range expected {
+		var t *translate.Tensor
+		if named && e.name != "" {
+			if t = byName[e.name]; t == nil {
+				return invalidRequest("missing input tensor %q", e.name)
+			}
+			delete(byName, e.name)
+		} else if i < len(tensors) {
+			t = &tensors[i]
+			delete(byName, t.Name)
+		} else {
+			return invalidRequest("expected %d input tensors but got %d", len(expected), len(tensors))
+		}
+		if err := validateTensor(e, t, exactDatatypes); err != nil {
+			return err
+		}
+	}
+	for name := range byName {
+		return invalidRequest("unexpected input tensor %q", name)
+	}
+	return nil
+}
+
+func validateTensor(e expectedInput, t *translate.Tensor, exactDatatypes bool) error {
+	name := e.name
+	if name == "" {
+		name = t.Name
+	}
+	if e.datatype != "" && !compatibleDatatypes(e.datatype, t.Datatype, exactDatatypes) {
+		return invalidRequest("input tensor %q has datatype %s but %s was expected", name, t.Datatype, e.datatype)
+	}
+	shape := t.Shape
+	if e.rowShape && len(shape) > 0 {
+		shape = shape[1:]
+	}
+	if len(e.shape) > 0 {
+		if len(shape) != len(e.shape) {
+			return invalidRequest("input tensor %q has shape %v but %v was expected", name, shape, e.shape)
+		}
+		for i, d := range e.shape {
+			if d >= 0 && shape[i] != d {
+				return invalidRequest("input tensor %q has shape %v but %v was expected", name, shape, e.shape)
+			}
+		}
+	}
+	if len(e.columns) > 0 {
+		if len(t.Names) > 0 {
+			if len(t.Names) != len(e.columns) {
+				return invalidRequest("input has columns %v but %v were expected", t.Names, e.columns)
+			}
+			for i, c := range e.columns {
+				if t.Names[i] != c {
+					return invalidRequest("input has columns %v but %v were expected", t.Names, e.columns)
+				}
+			}
+		} else if len(t.Shape) > 0 && t.Shape[len(t.Shape)-1] != int64(len(e.columns)) {
+			return invalidRequest("input has %d columns but %d were expected", t.Shape[len(t.Shape)-1], len(e.columns))
+		}
+	}
+	return nil
+}
+
+func compatibleDatatypes(expected string, actual string, exact bool) bool {
+	if expected == actual {
+		return true
+	}
+	// Numbers in JSON payloads don't carry a precision
+	return !exact && expected != translate.DatatypeBytes && actual != translate.DatatypeBytes &&
+		expected != translate.DatatypeBool && actual != translate.DatatypeBool
+}
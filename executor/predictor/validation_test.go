@@ -0,0 +1,90 @@
+package predictor
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/seldonio/seldon-core/executor/api"
+	"github.com/seldonio/seldon-core/executor/api/payload"
+	v1 "github.com/seldonio/seldon-core/operator/apis/machinelearning.seldon.io/v1"
+)
+
+func createValidationGraph(name string) *v1.PredictiveUnit {
+	model := v1.MODEL
+	return &v1.PredictiveUnit{
+		Name:     name,
+		Type:     &model,
+		Endpoint: &v1.Endpoint{ServiceHost: "foo", ServicePort: 9000, Type: v1.REST},
+	}
+}
+
+func TestValidateRequestV2(t *testing.T) {
+	g := NewGomegaWithT(t)
+	envEnableRequestValidation = true
+	defer func() { envEnableRequestValidation = false }()
+
+	graph := createValidationGraph("validate-v2")
+	pp := createPredictorProcessWithMetadata(t, nil, map[string]payload.ModelMetadata{
+		"validate-v2": {
+			Name:   "validate-v2",
+			Inputs: []interface{}{map[string]interface{}{"name": "input", "datatype": "FP32", "shape": []interface{}{-1.0, 2.0}}},
+		},
+	})
+	validate := func(data string) error {
+		return pp.ValidateRequest(graph, api.ProtocolKFServing, &payload.BytesPayload{Msg: []byte(data)})
+	}
+
+	g.Expect(validate(`{"inputs":[{"name":"input","datatype":"FP32","shape":[3,2],"data":[1,2,3,4,5,6]}]}`)).To(BeNil())
+
+	err := validate(`{"inputs":[{"name":"other","datatype":"FP32","shape":[1,2],"data":[1,2]}]}`)
+	g.Expect(err).To(BeAssignableToTypeOf(&RequestValidationError{}))
+	g.Expect(err.Error()).To(ContainSubstring(`missing input tensor "input"`))
+
+	err = validate(`{"inputs":[{"name":"input","datatype":"FP64","shape":[1,2],"data":[1,2]}]}`)
+	g.Expect(err.Error()).To(ContainSubstring("datatype FP64 but FP32 was expected"))
+
+	err = validate(`{"inputs":[{"name":"input","datatype":"FP32","shape":[1,3],"data":[1,2,3]}]}`)
+	g.Expect(err.Error()).To(ContainSubstring("shape [1 3] but [-1 2] was expected"))
+}
+
+func TestValidateRequestSeldonSchema(t *testing.T) {
+	g := NewGomegaWithT(t)
+	envEnableRequestValidation = true
+	defer func() { envEnableRequestValidation = false }()
+
+	graph := createValidationGraph("validate-seldon")
+	pp := createPredictorProcessWithMetadata(t, nil, map[string]payload.ModelMetadata{
+		"validate-seldon": {
+			Name: "validate-seldon",
+			Inputs: []interface{}{map[string]interface{}{
+				"messagetype": "ndarray",
+				"schema":      map[string]interface{}{"names": []interface{}{"a", "b"}, "shape": []interface{}{2.0}},
+			}},
+		},
+	})
+	validate := func(data string) error {
+		return pp.ValidateRequest(graph, api.ProtocolSeldon, &payload.BytesPayload{Msg: []byte(data)})
+	}
+
+	g.Expect(validate(`{"data":{"names":["a","b"],"ndarray":[[1,2],[3,4]]}}`)).To(BeNil())
+	g.Expect(validate(`{"data":{"ndarray":[[1,2]]}}`)).To(BeNil())
+
+	err := validate(`{"data":{"names":["b","a"],"ndarray":[[1,2]]}}`)
+	g.Expect(err.Error()).To(ContainSubstring("columns [b a] but [a b] were expected"))
+
+	err = validate(`{"data":{"ndarray":[[1,2,3]]}}`)
+	g.Expect(err).ToNot(BeNil())
+
+	err = validate(`{"data":{"ndarray":[["x","y"]]}}`)
+	g.Expect(err).To(BeNil())
+}
+
+func TestValidateRequestWithoutMetadata(t *testing.T) {
+	g := NewGomegaWithT(t)
+	envEnableRequestValidation = true
+	defer func() { envEnableRequestValidation = false }()
+
+	pp := createPredictorProcessWithMetadata(t, nil, nil)
+	err := pp.ValidateRequest(createValidationGraph("validate-none"), api.ProtocolSeldon, &payload.BytesPayload{Msg: []byte(`{"strData":"x"}`)})
+	g.Expect(err).To(BeNil())
+}
@@ -117,7 +117,7 @@ func init() {
 }
 
 func IsPrepack(pu *PredictiveUnit) bool {
-	isPrepack := len(*pu.Implementation) > 0 && *pu.Implementation != SIMPLE_MODEL && *pu.Implementation != SIMPLE_ROUTER && *pu.Implementation != RANDOM_ABTEST && *pu.Implementation != AVERAGE_COMBINER && *pu.Implementation != UNKNOWN_IMPLEMENTATION
+	isPrepack := len(*pu.Implementation) > 0 && *pu.Implementation != SIMPLE_MODEL && *pu.Implementation != SIMPLE_ROUTER && *pu.Implementation != RANDOM_ABTEST && *pu.Implementation != AVERAGE_COMBINER && *pu.Implementation != MAJORITY_VOTE_COMBINER && *pu.Implementation != MAX_COMBINER && *pu.Implementation != EPSILON_GREEDY && *pu.Implementation != THOMPSON_SAMPLING && *pu.Implementation != HEADER_ROUTER && *pu.Implementation != CONSISTENT_HASH_ROUTER && *pu.Implementation != UNKNOWN_IMPLEMENTATION
 	return isPrepack
 }
 
@@ -181,6 +181,14 @@ func GetEnginePredictiveUnit(pu *PredictiveUnit) *PredictiveUnit {
 	}
 }
 
+// GetProtocol returns the protocol of a predictive unit which defaults to the deployment protocol.
+func (r *SeldonDeploymentSpec) GetProtocol(pu *PredictiveUnit) Protocol {
+	if pu.Protocol != nil && *pu.Protocol != "" {
+		return *pu.Protocol
+	}
+	return r.Protocol
+}
+
 func GetPredictiveUnitList(p *PredictiveUnit) (list []*PredictiveUnit) {
 	list = append(list, p)
 
@@ -354,6 +362,12 @@ const (
 	SIMPLE_ROUTER          PredictiveUnitImplementation = "SIMPLE_ROUTER"
 	RANDOM_ABTEST          PredictiveUnitImplementation = "RANDOM_ABTEST"
 	AVERAGE_COMBINER       PredictiveUnitImplementation = "AVERAGE_COMBINER"
+	MAJORITY_VOTE_COMBINER PredictiveUnitImplementation = "MAJORITY_VOTE_COMBINER"
+	MAX_COMBINER           PredictiveUnitImplementation = "MAX_COMBINER"
+	EPSILON_GREEDY         PredictiveUnitImplementation = "EPSILON_GREEDY"
+	THOMPSON_SAMPLING      PredictiveUnitImplementation = "THOMPSON_SAMPLING"
+	HEADER_ROUTER          PredictiveUnitImplementation = "HEADER_ROUTER"
+	CONSISTENT_HASH_ROUTER PredictiveUnitImplementation = "CONSISTENT_HASH_ROUTER"
 )
 
 type PredictiveUnitMethod string
@@ -410,6 +424,18 @@ type PredictiveUnit struct {
 	EnvSecretRefName   string                        `json:"envSecretRefName,omitempty" protobuf:"bytes,10,opt,name=envSecretRefName"`
 	// Request/response  payload logging. v2alpha1 feature that is added to v1 for backwards compatibility while v1 is the storage version.
 	Logger *Logger `json:"logger,omitempty"`
+	// Timeouts and retries applied by the executor when calling this unit
+	Execution *ExecutionPolicy `json:"execution,omitempty"`
+	// Executor side circuit breaker for calls to this unit
+	CircuitBreaker *CircuitBreaker `json:"circuitBreaker,omitempty"`
+	// How failures of children called in parallel are handled
+	ChildrenPolicy *ChildrenPolicy `json:"childrenPolicy,omitempty"`
+	// Executor side batching of concurrent predictions sent to this model
+	Batching *BatchingPolicy `json:"batching,omitempty"`
+	// Executor side cache of responses from this model
+	Cache *ResponseCachePolicy `json:"cache,omitempty"`
+	// Protocol spoken by this unit if different to the deployment protocol. The executor translates payloads between them.
+	Protocol *Protocol `json:"protocol,omitempty"`
 }
 
 type LoggerMode string
@@ -428,6 +454,86 @@ type Logger struct {
 	Url *string `json:"url,omitempty"`
 	// What payloads to log
 	Mode LoggerMode `json:"mode,omitempty"`
+	// Percentage of requests whose payloads are logged, 100 if not set. Requests are sampled by their
+	// id so all payloads of a sampled request are logged. Nothing is logged if 0.
+	// +optional
+	SamplePercent *int32 `json:"samplePercent,omitempty"`
+	// Payloads larger than this number of bytes are truncated
+	// +optional
+	MaxPayloadBytes int32 `json:"maxPayloadBytes,omitempty"`
+	// Fields to redact from payloads before they are logged. Either JSON paths such as
+	// $.jsonData.user.email or tensor names
+	// +optional
+	Redact []string `json:"redact,omitempty"`
+}
+
+// ExecutionPolicy controls how the executor calls a predictive unit
+// +experimental
+type ExecutionPolicy struct {
+	// Timeout in milliseconds for a single call to the unit
+	// +optional
+	TimeoutMs int32 `json:"timeoutMs,omitempty"`
+	// Number of times a failed call is retried. Calls failing with a client error are not retried
+	// +optional
+	Retries int32 `json:"retries,omitempty"`
+	// Delay in milliseconds before the first retry, doubled for each further retry
+	// +optional
+	BackoffMs int32 `json:"backoffMs,omitempty"`
+}
+
+// CircuitBreaker stops the executor calling a failing predictive unit for a while
+// +experimental
+type CircuitBreaker struct {
+	// Number of consecutive failed calls after which the breaker opens
+	MaxFailures int32 `json:"maxFailures"`
+	// Time in milliseconds the breaker stays open before a trial call is allowed
+	// +optional
+	ResetTimeoutMs int32 `json:"resetTimeoutMs,omitempty"`
+	// Name of a child unit to call instead of this unit while the breaker is open
+	// +optional
+	FallbackChild string `json:"fallbackChild,omitempty"`
+	// Static JSON response to return while the breaker is open
+	// +optional
+	FallbackResponse string `json:"fallbackResponse,omitempty"`
+}
+
+type ChildrenPolicyMode string
+
+const (
+	ChildrenAll            ChildrenPolicyMode = "all"
+	ChildrenQuorum         ChildrenPolicyMode = "quorum"
+	ChildrenFirstSuccess   ChildrenPolicyMode = "firstSuccess"
+	ChildrenIgnoreFailures ChildrenPolicyMode = "ignoreFailures"
+)
+
+// ChildrenPolicy decides which outputs of children called in parallel are passed on
+// +experimental
+type ChildrenPolicy struct {
+	// Whether all children, a quorum, the first successful child or any successful children are required
+	Mode ChildrenPolicyMode `json:"mode"`
+	// Number of children that must succeed in quorum mode
+	// +optional
+	Quorum int32 `json:"quorum,omitempty"`
+}
+
+// BatchingPolicy merges concurrent predictions for a model into a single call
+// +experimental
+type BatchingPolicy struct {
+	// Maximum number of items, along the first axis, sent in one call
+	MaxBatchSize int32 `json:"maxBatchSize"`
+	// Maximum time in milliseconds a prediction waits for a batch to fill
+	MaxLatencyMs int32 `json:"maxLatencyMs"`
+}
+
+// ResponseCachePolicy caches model responses keyed by a hash of the request payload
+// +experimental
+type ResponseCachePolicy struct {
+	// Time in seconds a response is cached for
+	TtlSeconds int32 `json:"ttlSeconds"`
+	// Maximum number of cached responses, the least recently used are evicted first
+	MaxEntries int32 `json:"maxEntries,omitempty"`
+	// Request headers which are part of the cache key
+	Headers []string `json:"headers,omitempty"`
 }
 
 type DeploymentStatus struct {
@@ -17,6 +17,7 @@ limitations under the License.
 package v1
 
 import (
+	"encoding/json"
 	"github.com/seldonio/seldon-core/operator/constants"
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
@@ -25,12 +26,14 @@ import (
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/util/validation/field"
 	"log"
+	"math"
 	"os"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
 	"strconv"
+	"strings"
 )
 
 var (
@@ -297,12 +300,12 @@ func (r *SeldonDeploymentSpec) DefaultSeldonDeployment(mldepName string, namespa
 				grpcPortNum := portMapGrpc[con.Name]
 
 				r.setContainerPredictiveUnitDefaults(0, httpPortNum, grpcPortNum, &nextMetricsPortNum, mldepName, namespace, &p, pu, con)
-				//Only set image default for non tensorflow graphs
-				if r.Protocol != ProtocolTensorflow {
+				//Only set image default for non tensorflow units
+				if r.GetProtocol(pu) != ProtocolTensorflow {
 					serverConfig := GetPrepackServerConfig(string(*pu.Implementation))
 					if serverConfig != nil {
 						if con.Image == "" {
-							con.Image = serverConfig.PrepackImageName(r.Protocol, pu)
+							con.Image = serverConfig.PrepackImageName(r.GetProtocol(pu), pu)
 						}
 					}
 				}
@@ -352,7 +355,7 @@ func (r *SeldonDeploymentSpec) checkPredictiveUnits(pu *PredictiveUnit, p *Predi
 		c := GetContainerForPredictiveUnit(p, pu.Name)
 
 		//Current non tensorflow serving prepack servers can not handle tensorflow protocol
-		if r.Protocol == ProtocolTensorflow && (*pu.Implementation == PrepackSklearnName || *pu.Implementation == PrepackXgboostName || *pu.Implementation == PrepackMlflowName) {
+		if r.GetProtocol(pu) == ProtocolTensorflow && (*pu.Implementation == PrepackSklearnName || *pu.Implementation == PrepackXgboostName || *pu.Implementation == PrepackMlflowName) {
 			allErrs = append(allErrs, field.Invalid(fldPath, pu.Name, "Prepackaged server does not handle tendorflow protocol "+string(*pu.Implementation)))
 		}
 
@@ -375,6 +378,171 @@ func (r *SeldonDeploymentSpec) checkPredictiveUnits(pu *PredictiveUnit, p *Predi
 		if pu.Logger.Mode == "" {
 			allErrs = append(allErrs, field.Invalid(fldPath, pu.Logger.Mode, "No logger mode specified"))
 		}
+		if pu.Logger.SamplePercent != nil && (*pu.Logger.SamplePercent < 0 || *pu.Logger.SamplePercent > 100) {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("logger", "samplePercent"), *pu.Logger.SamplePercent, "Logger samplePercent must be between 0 and 100"))
+		}
+		if pu.Logger.MaxPayloadBytes < 0 {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("logger", "maxPayloadBytes"), pu.Logger.MaxPayloadBytes, "Logger maxPayloadBytes must not be negative"))
+		}
+		for _, redact := range pu.Logger.Redact {
+			if strings.TrimSpace(redact) == "" || redact == "$" {
+				allErrs = append(allErrs, field.Invalid(fldPath.Child("logger", "redact"), redact, "Logger redact entries must be a JSON path or a tensor name"))
+			}
+		}
+	}
+
+	if pu.Execution != nil {
+		if pu.Execution.TimeoutMs < 0 || pu.Execution.Retries < 0 || pu.Execution.BackoffMs < 0 {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("execution"), pu.Name, "Execution timeoutMs, retries and backoffMs must not be negative"))
+		}
+	}
+
+	if pu.Batching != nil {
+		if pu.Batching.MaxBatchSize < 1 || pu.Batching.MaxLatencyMs < 1 {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("batching"), pu.Name, "Batching maxBatchSize and maxLatencyMs must be positive"))
+		}
+		if pu.Type != nil && *pu.Type != MODEL {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("batching"), pu.Name, "Batching can only be used with models"))
+		}
+	}
+
+	if pu.Protocol != nil && !(*pu.Protocol == ProtocolSeldon || *pu.Protocol == ProtocolTensorflow || *pu.Protocol == ProtocolKfserving) {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("protocol"), pu.Name, "Invalid protocol "+string(*pu.Protocol)))
+	}
+
+	if pu.Cache != nil {
+		if pu.Cache.TtlSeconds < 1 || pu.Cache.MaxEntries < 0 {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("cache"), pu.Name, "Cache ttlSeconds must be positive and maxEntries must not be negative"))
+		}
+		if pu.Type != nil && *pu.Type != MODEL {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("cache"), pu.Name, "Cache can only be used with models"))
+		}
+	}
+
+	if pu.CircuitBreaker != nil {
+		cb := pu.CircuitBreaker
+		if cb.MaxFailures < 0 || cb.ResetTimeoutMs < 0 {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("circuitBreaker"), pu.Name, "Circuit breaker maxFailures and resetTimeoutMs must not be negative"))
+		}
+		if cb.FallbackChild != "" && cb.FallbackResponse != "" {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("circuitBreaker"), pu.Name, "Circuit breaker can not have both a fallbackChild and a fallbackResponse"))
+		}
+		if cb.FallbackChild != "" {
+			found := false
+			for _, child := range pu.Children {
+				if child.Name == cb.FallbackChild {
+					found = true
+					break
+				}
+			}
+			if !found {
+				allErrs = append(allErrs, field.Invalid(fldPath.Child("circuitBreaker", "fallbackChild"), cb.FallbackChild, "Circuit breaker fallbackChild must be a child of the predictive unit"))
+			}
+		}
+		if cb.FallbackResponse != "" && !json.Valid([]byte(cb.FallbackResponse)) {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("circuitBreaker", "fallbackResponse"), pu.Name, "Circuit breaker fallbackResponse must be valid JSON"))
+		}
+	}
+
+	if pu.Implementation != nil && (*pu.Implementation == AVERAGE_COMBINER || *pu.Implementation == MAJORITY_VOTE_COMBINER) {
+		for _, param := range pu.Parameters {
+			if param.Name == "weights" {
+				weights := strings.Split(param.Value, ",")
+				if len(weights) != len(pu.Children) {
+					allErrs = append(allErrs, field.Invalid(fldPath.Child("parameters"), param.Value, "Combiner weights must have one entry per child"))
+				}
+				for _, weight := range weights {
+					if w, err := strconv.ParseFloat(strings.TrimSpace(weight), 64); err != nil || w < 0 {
+						allErrs = append(allErrs, field.Invalid(fldPath.Child("parameters"), param.Value, "Combiner weights must be non-negative numbers"))
+						break
+					}
+				}
+			}
+		}
+	}
+
+	if pu.Implementation != nil && *pu.Implementation == RANDOM_ABTEST {
+		for _, param := range pu.Parameters {
+			switch param.Name {
+			case "ratioA":
+				if ratio, err := strconv.ParseFloat(param.Value, 64); err != nil || ratio < 0 || ratio > 1 || len(pu.Children) != 2 {
+					allErrs = append(allErrs, field.Invalid(fldPath.Child("parameters"), param.Value, "ratioA must be a number between 0 and 1 for a router with two children"))
+				}
+			case "weights":
+				weights := strings.Split(param.Value, ",")
+				if len(weights) != len(pu.Children) {
+					allErrs = append(allErrs, field.Invalid(fldPath.Child("parameters"), param.Value, "Router weights must have one entry per child"))
+					break
+				}
+				total := 0.0
+				for _, weight := range weights {
+					w, err := strconv.ParseFloat(strings.TrimSpace(weight), 64)
+					if err != nil || w < 0 {
+						total = -1
+						break
+					}
+					total += w
+				}
+				if math.Abs(total-1) > 1e-6 {
+					allErrs = append(allErrs, field.Invalid(fldPath.Child("parameters"), param.Value, "Router weights must be non-negative numbers that sum to 1"))
+				}
+			}
+		}
+	}
+
+	if pu.Implementation != nil && (*pu.Implementation == EPSILON_GREEDY || *pu.Implementation == THOMPSON_SAMPLING) {
+		if len(pu.Children) < 2 {
+			allErrs = append(allErrs, field.Invalid(fldPath, pu.Name, "Multi-armed bandit routers need at least two children"))
+		}
+		// The route is returned in the meta of the response so it can be sent back with feedback
+		if protocol := r.GetProtocol(pu); protocol != "" && protocol != ProtocolSeldon {
+			allErrs = append(allErrs, field.Invalid(fldPath, pu.Name, "Multi-armed bandit routers can only be used with the seldon protocol"))
+		}
+		for _, param := range pu.Parameters {
+			if param.Name == "epsilon" {
+				if epsilon, err := strconv.ParseFloat(param.Value, 64); err != nil || epsilon < 0 || epsilon > 1 {
+					allErrs = append(allErrs, field.Invalid(fldPath.Child("parameters"), param.Value, "Epsilon must be a number between 0 and 1"))
+				}
+			}
+		}
+	}
+
+	if pu.Implementation != nil && (*pu.Implementation == HEADER_ROUTER || *pu.Implementation == CONSISTENT_HASH_ROUTER) {
+		params := make(map[string]string)
+		for _, param := range pu.Parameters {
+			params[param.Name] = param.Value
+		}
+		if (params["header"] == "") == (params["jsonPath"] == "") {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("parameters"), pu.Name, "Router needs exactly one of a header or jsonPath parameter"))
+		}
+		children := make(map[string]bool)
+		for _, child := range pu.Children {
+			children[child.Name] = true
+		}
+		if def, ok := params["default"]; ok && !children[def] {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("parameters"), def, "Router default must be the name of a child"))
+		}
+		if *pu.Implementation == HEADER_ROUTER {
+			for _, entry := range strings.Split(params["mapping"], ",") {
+				parts := strings.SplitN(entry, ":", 2)
+				if len(parts) != 2 || !children[strings.TrimSpace(parts[1])] {
+					allErrs = append(allErrs, field.Invalid(fldPath.Child("parameters"), params["mapping"], "Router mapping must be a list of value:child entries"))
+					break
+				}
+			}
+		}
+	}
+
+	if pu.ChildrenPolicy != nil {
+		switch pu.ChildrenPolicy.Mode {
+		case ChildrenAll, ChildrenFirstSuccess, ChildrenIgnoreFailures:
+		case ChildrenQuorum:
+			if pu.ChildrenPolicy.Quorum < 1 || int(pu.ChildrenPolicy.Quorum) > len(pu.Children) {
+				allErrs = append(allErrs, field.Invalid(fldPath.Child("childrenPolicy", "quorum"), pu.ChildrenPolicy.Quorum, "Children policy quorum must be between 1 and the number of children"))
+			}
+		default:
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("childrenPolicy", "mode"), pu.ChildrenPolicy.Mode, "Unknown children policy mode"))
+		}
 	}
 
 	for i := 0; i < len(pu.Children); i++ {
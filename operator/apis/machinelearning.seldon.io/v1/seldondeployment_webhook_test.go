@@ -1329,3 +1329,540 @@ func TestValidateTwoShadows(t *testing.T) {
 	err = spec.ValidateSeldonDeployment()
 	g.Expect(err).ToNot(BeNil())
 }
+
+func TestValidateNegativeExecutionPolicy(t *testing.T) {
+	g := NewGomegaWithT(t)
+	spec := &SeldonDeploymentSpec{
+		Predictors: []PredictorSpec{
+			{
+				Name: "p1",
+				ComponentSpecs: []*SeldonPodSpec{
+					{
+						Spec: v1.PodSpec{
+							Containers: []v1.Container{
+								{
+									Image: "seldonio/mock_classifier:1.0",
+									Name:  "classifier",
+								},
+							},
+						},
+					},
+				},
+				Graph: PredictiveUnit{
+					Name: "classifier",
+					Execution: &ExecutionPolicy{
+						TimeoutMs: 100,
+						Retries:   -1,
+					},
+				},
+			},
+		},
+	}
+
+	spec.DefaultSeldonDeployment("mydep", "default")
+	err := spec.ValidateSeldonDeployment()
+	g.Expect(err).ToNot(BeNil())
+	serr := err.(*errors.StatusError)
+	g.Expect(serr.Status().Details.Causes[0].Field).To(Equal("spec.predictors[0].graph.execution"))
+}
+
+func TestValidateCircuitBreakerFallbackChild(t *testing.T) {
+	g := NewGomegaWithT(t)
+	spec := &SeldonDeploymentSpec{
+		Predictors: []PredictorSpec{
+			{
+				Name: "p1",
+				ComponentSpecs: []*SeldonPodSpec{
+					{
+						Spec: v1.PodSpec{
+							Containers: []v1.Container{
+								{
+									Image: "seldonio/mock_classifier:1.0",
+									Name:  "classifier",
+								},
+							},
+						},
+					},
+				},
+				Graph: PredictiveUnit{
+					Name: "classifier",
+					CircuitBreaker: &CircuitBreaker{
+						MaxFailures:   3,
+						FallbackChild: "missing",
+					},
+				},
+			},
+		},
+	}
+
+	spec.DefaultSeldonDeployment("mydep", "default")
+	err := spec.ValidateSeldonDeployment()
+	g.Expect(err).ToNot(BeNil())
+	serr := err.(*errors.StatusError)
+	g.Expect(serr.Status().Details.Causes[0].Field).To(Equal("spec.predictors[0].graph.circuitBreaker.fallbackChild"))
+}
+
+func TestValidateChildrenPolicyQuorum(t *testing.T) {
+	g := NewGomegaWithT(t)
+	model := MODEL
+	spec := &SeldonDeploymentSpec{
+		Predictors: []PredictorSpec{
+			{
+				Name: "p1",
+				ComponentSpecs: []*SeldonPodSpec{
+					{
+						Spec: v1.PodSpec{
+							Containers: []v1.Container{
+								{
+									Image: "seldonio/mock_classifier:1.0",
+									Name:  "classifier",
+								},
+								{
+									Image: "seldonio/mock_classifier:1.0",
+									Name:  "model",
+								},
+							},
+						},
+					},
+				},
+				Graph: PredictiveUnit{
+					Name: "classifier",
+					Type: &model,
+					ChildrenPolicy: &ChildrenPolicy{
+						Mode:   ChildrenQuorum,
+						Quorum: 2,
+					},
+					Children: []PredictiveUnit{
+						{
+							Name: "model",
+							Type: &model,
+						},
+					},
+				},
+			},
+		},
+	}
+
+	spec.DefaultSeldonDeployment("mydep", "default")
+	err := spec.ValidateSeldonDeployment()
+	g.Expect(err).ToNot(BeNil())
+	serr := err.(*errors.StatusError)
+	g.Expect(serr.Status().Details.Causes[0].Field).To(Equal("spec.predictors[0].graph.childrenPolicy.quorum"))
+}
+
+func TestValidateCombinerWeights(t *testing.T) {
+	g := NewGomegaWithT(t)
+	impl := AVERAGE_COMBINER
+	spec := &SeldonDeploymentSpec{
+		Predictors: []PredictorSpec{
+			{
+				Name: "p1",
+				ComponentSpecs: []*SeldonPodSpec{
+					{
+						Spec: v1.PodSpec{
+							Containers: []v1.Container{
+								{
+									Image: "seldonio/mock_classifier:1.0",
+									Name:  "classifier1",
+								},
+								{
+									Image: "seldonio/mock_classifier:1.0",
+									Name:  "classifier2",
+								},
+							},
+						},
+					},
+				},
+				Graph: PredictiveUnit{
+					Name:           "combiner",
+					Implementation: &impl,
+					Parameters: []Parameter{
+						{
+							Name:  "weights",
+							Value: "0.2,0.3,0.5",
+							Type:  STRING,
+						},
+					},
+					Children: []PredictiveUnit{
+						{
+							Name: "classifier1",
+						},
+						{
+							Name: "classifier2",
+						},
+					},
+				},
+			},
+		},
+	}
+
+	spec.DefaultSeldonDeployment("mydep", "default")
+	err := spec.ValidateSeldonDeployment()
+	g.Expect(err).ToNot(BeNil())
+	serr := err.(*errors.StatusError)
+	g.Expect(serr.Status().Details.Causes[0].Field).To(Equal("spec.predictors[0].graph.parameters"))
+
+	spec.Predictors[0].Graph.Parameters[0].Value = "0.4,0.6"
+	err = spec.ValidateSeldonDeployment()
+	g.Expect(err).To(BeNil())
+}
+
+func TestValidateEpsilonGreedy(t *testing.T) {
+	g := NewGomegaWithT(t)
+	impl := EPSILON_GREEDY
+	spec := &SeldonDeploymentSpec{
+		Predictors: []PredictorSpec{
+			{
+				Name: "p1",
+				ComponentSpecs: []*SeldonPodSpec{
+					{
+						Spec: v1.PodSpec{
+							Containers: []v1.Container{
+								{
+									Image: "seldonio/mock_classifier:1.0",
+									Name:  "classifier1",
+								},
+								{
+									Image: "seldonio/mock_classifier:1.0",
+									Name:  "classifier2",
+								},
+							},
+						},
+					},
+				},
+				Graph: PredictiveUnit{
+					Name:           "router",
+					Implementation: &impl,
+					Parameters: []Parameter{
+						{
+							Name:  "epsilon",
+							Value: "1.5",
+							Type:  FLOAT,
+						},
+					},
+					Children: []PredictiveUnit{
+						{
+							Name: "classifier1",
+						},
+						{
+							Name: "classifier2",
+						},
+					},
+				},
+			},
+		},
+	}
+
+	spec.DefaultSeldonDeployment("mydep", "default")
+	err := spec.ValidateSeldonDeployment()
+	g.Expect(err).ToNot(BeNil())
+	serr := err.(*errors.StatusError)
+	g.Expect(serr.Status().Details.Causes[0].Field).To(Equal("spec.predictors[0].graph.parameters"))
+
+	spec.Predictors[0].Graph.Parameters[0].Value = "0.2"
+	err = spec.ValidateSeldonDeployment()
+	g.Expect(err).To(BeNil())
+
+	spec.Protocol = ProtocolKfserving
+	err = spec.ValidateSeldonDeployment()
+	g.Expect(err).ToNot(BeNil())
+	serr = err.(*errors.StatusError)
+	g.Expect(serr.Status().Details.Causes[0].Field).To(Equal("spec.predictors[0].graph"))
+}
+
+func TestValidateHeaderRouter(t *testing.T) {
+	g := NewGomegaWithT(t)
+	impl := HEADER_ROUTER
+	spec := &SeldonDeploymentSpec{
+		Predictors: []PredictorSpec{
+			{
+				Name: "p1",
+				ComponentSpecs: []*SeldonPodSpec{
+					{
+						Spec: v1.PodSpec{
+							Containers: []v1.Container{
+								{
+									Image: "seldonio/mock_classifier:1.0",
+									Name:  "classifier1",
+								},
+								{
+									Image: "seldonio/mock_classifier:1.0",
+									Name:  "classifier2",
+								},
+							},
+						},
+					},
+				},
+				Graph: PredictiveUnit{
+					Name:           "router",
+					Implementation: &impl,
+					Parameters: []Parameter{
+						{
+							Name:  "header",
+							Value: "X-Variant",
+							Type:  STRING,
+						},
+						{
+							Name:  "mapping",
+							Value: "a:classifier1,b:classifier3",
+							Type:  STRING,
+						},
+					},
+					Children: []PredictiveUnit{
+						{
+							Name: "classifier1",
+						},
+						{
+							Name: "classifier2",
+						},
+					},
+				},
+			},
+		},
+	}
+
+	spec.DefaultSeldonDeployment("mydep", "default")
+	err := spec.ValidateSeldonDeployment()
+	g.Expect(err).ToNot(BeNil())
+	serr := err.(*errors.StatusError)
+	g.Expect(serr.Status().Details.Causes[0].Field).To(Equal("spec.predictors[0].graph.parameters"))
+
+	spec.Predictors[0].Graph.Parameters[1].Value = "a:classifier1,b:classifier2"
+	err = spec.ValidateSeldonDeployment()
+	g.Expect(err).To(BeNil())
+}
+
+func TestValidateABTestWeights(t *testing.T) {
+	g := NewGomegaWithT(t)
+	impl := RANDOM_ABTEST
+	spec := &SeldonDeploymentSpec{
+		Predictors: []PredictorSpec{
+			{
+				Name: "p1",
+				ComponentSpecs: []*SeldonPodSpec{
+					{
+						Spec: v1.PodSpec{
+							Containers: []v1.Container{
+								{
+									Image: "seldonio/mock_classifier:1.0",
+									Name:  "classifier1",
+								},
+								{
+									Image: "seldonio/mock_classifier:1.0",
+									Name:  "classifier2",
+								},
+								{
+									Image: "seldonio/mock_classifier:1.0",
+									Name:  "classifier3",
+								},
+							},
+						},
+					},
+				},
+				Graph: PredictiveUnit{
+					Name:           "router",
+					Implementation: &impl,
+					Parameters: []Parameter{
+						{
+							Name:  "weights",
+							Value: "0.5,0.3,0.3",
+							Type:  STRING,
+						},
+					},
+					Children: []PredictiveUnit{
+						{
+							Name: "classifier1",
+						},
+						{
+							Name: "classifier2",
+						},
+						{
+							Name: "classifier3",
+						},
+					},
+				},
+			},
+		},
+	}
+
+	spec.DefaultSeldonDeployment("mydep", "default")
+	err := spec.ValidateSeldonDeployment()
+	g.Expect(err).ToNot(BeNil())
+	serr := err.(*errors.StatusError)
+	g.Expect(serr.Status().Details.Causes[0].Field).To(Equal("spec.predictors[0].graph.parameters"))
+
+	spec.Predictors[0].Graph.Parameters[0].Value = "0.5,0.3"
+	err = spec.ValidateSeldonDeployment()
+	g.Expect(err).ToNot(BeNil())
+
+	spec.Predictors[0].Graph.Parameters[0].Value = "0.5,0.3,0.2"
+	err = spec.ValidateSeldonDeployment()
+	g.Expect(err).To(BeNil())
+}
+
+func TestValidateBatching(t *testing.T) {
+	g := NewGomegaWithT(t)
+	spec := &SeldonDeploymentSpec{
+		Predictors: []PredictorSpec{
+			{
+				Name: "p1",
+				ComponentSpecs: []*SeldonPodSpec{
+					{
+						Spec: v1.PodSpec{
+							Containers: []v1.Container{
+								{
+									Image: "seldonio/mock_classifier:1.0",
+									Name:  "classifier",
+								},
+							},
+						},
+					},
+				},
+				Graph: PredictiveUnit{
+					Name: "classifier",
+					Batching: &BatchingPolicy{
+						MaxBatchSize: 32,
+					},
+				},
+			},
+		},
+	}
+
+	spec.DefaultSeldonDeployment("mydep", "default")
+	err := spec.ValidateSeldonDeployment()
+	g.Expect(err).ToNot(BeNil())
+	serr := err.(*errors.StatusError)
+	g.Expect(serr.Status().Details.Causes[0].Field).To(Equal("spec.predictors[0].graph.batching"))
+
+	spec.Predictors[0].Graph.Batching.MaxLatencyMs = 5
+	err = spec.ValidateSeldonDeployment()
+	g.Expect(err).To(BeNil())
+}
+
+func TestValidateResponseCache(t *testing.T) {
+	g := NewGomegaWithT(t)
+	spec := &SeldonDeploymentSpec{
+		Predictors: []PredictorSpec{
+			{
+				Name: "p1",
+				ComponentSpecs: []*SeldonPodSpec{
+					{
+						Spec: v1.PodSpec{
+							Containers: []v1.Container{
+								{
+									Image: "seldonio/mock_classifier:1.0",
+									Name:  "classifier",
+								},
+							},
+						},
+					},
+				},
+				Graph: PredictiveUnit{
+					Name: "classifier",
+					Cache: &ResponseCachePolicy{
+						MaxEntries: 100,
+					},
+				},
+			},
+		},
+	}
+
+	spec.DefaultSeldonDeployment("mydep", "default")
+	err := spec.ValidateSeldonDeployment()
+	g.Expect(err).ToNot(BeNil())
+	serr := err.(*errors.StatusError)
+	g.Expect(serr.Status().Details.Causes[0].Field).To(Equal("spec.predictors[0].graph.cache"))
+
+	spec.Predictors[0].Graph.Cache.TtlSeconds = 10
+	err = spec.ValidateSeldonDeployment()
+	g.Expect(err).To(BeNil())
+}
+
+func TestValidateUnitProtocol(t *testing.T) {
+	g := NewGomegaWithT(t)
+	protocol := Protocol("unknown")
+	spec := &SeldonDeploymentSpec{
+		Protocol: ProtocolSeldon,
+		Predictors: []PredictorSpec{
+			{
+				Name: "p1",
+				ComponentSpecs: []*SeldonPodSpec{
+					{
+						Spec: v1.PodSpec{
+							Containers: []v1.Container{
+								{
+									Image: "seldonio/mock_classifier:1.0",
+									Name:  "classifier",
+								},
+							},
+						},
+					},
+				},
+				Graph: PredictiveUnit{
+					Name:     "classifier",
+					Protocol: &protocol,
+				},
+			},
+		},
+	}
+
+	spec.DefaultSeldonDeployment("mydep", "default")
+	err := spec.ValidateSeldonDeployment()
+	g.Expect(err).ToNot(BeNil())
+	serr := err.(*errors.StatusError)
+	g.Expect(serr.Status().Details.Causes[0].Field).To(Equal("spec.predictors[0].graph.protocol"))
+
+	protocol = ProtocolKfserving
+	err = spec.ValidateSeldonDeployment()
+	g.Expect(err).To(BeNil())
+	g.Expect(spec.GetProtocol(&spec.Predictors[0].Graph)).To(Equal(ProtocolKfserving))
+}
+
+func TestValidateLogger(t *testing.T) {
+	g := NewGomegaWithT(t)
+	samplePercent := int32(150)
+	spec := &SeldonDeploymentSpec{
+		Predictors: []PredictorSpec{
+			{
+				Name: "p1",
+				ComponentSpecs: []*SeldonPodSpec{
+					{
+						Spec: v1.PodSpec{
+							Containers: []v1.Container{
+								{
+									Image: "seldonio/mock_classifier:1.0",
+									Name:  "classifier",
+								},
+							},
+						},
+					},
+				},
+				Graph: PredictiveUnit{
+					Name: "classifier",
+					Logger: &Logger{
+						Mode:          LogAll,
+						SamplePercent: &samplePercent,
+					},
+				},
+			},
+		},
+	}
+
+	spec.DefaultSeldonDeployment("mydep", "default")
+	err := spec.ValidateSeldonDeployment()
+	g.Expect(err).ToNot(BeNil())
+	serr := err.(*errors.StatusError)
+	g.Expect(serr.Status().Details.Causes[0].Field).To(Equal("spec.predictors[0].graph.logger.samplePercent"))
+
+	samplePercent = 0
+	spec.Predictors[0].Graph.Logger.MaxPayloadBytes = 1024
+	spec.Predictors[0].Graph.Logger.Redact = []string{"$.jsonData.email", "ssn"}
+	err = spec.ValidateSeldonDeployment()
+	g.Expect(err).To(BeNil())
+
+	samplePercent = 10
+	spec.Predictors[0].Graph.Logger.MaxPayloadBytes = 1024
+	spec.Predictors[0].Graph.Logger.Redact = []string{"$.jsonData.email", "ssn"}
+	err = spec.ValidateSeldonDeployment()
+	g.Expect(err).To(BeNil())
+}
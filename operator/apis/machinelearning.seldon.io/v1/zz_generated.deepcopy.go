@@ -28,6 +28,51 @@ import (
 	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BatchingPolicy) DeepCopyInto(out *BatchingPolicy) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BatchingPolicy.
+func (in *BatchingPolicy) DeepCopy() *BatchingPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(BatchingPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ChildrenPolicy) DeepCopyInto(out *ChildrenPolicy) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ChildrenPolicy.
+func (in *ChildrenPolicy) DeepCopy() *ChildrenPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(ChildrenPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CircuitBreaker) DeepCopyInto(out *CircuitBreaker) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CircuitBreaker.
+func (in *CircuitBreaker) DeepCopy() *CircuitBreaker {
+	if in == nil {
+		return nil
+	}
+	out := new(CircuitBreaker)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *DeploymentStatus) DeepCopyInto(out *DeploymentStatus) {
 	*out = *in
@@ -58,6 +103,21 @@ func (in *Endpoint) DeepCopy() *Endpoint {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ExecutionPolicy) DeepCopyInto(out *ExecutionPolicy) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ExecutionPolicy.
+func (in *ExecutionPolicy) DeepCopy() *ExecutionPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(ExecutionPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Explainer) DeepCopyInto(out *Explainer) {
 	*out = *in
@@ -94,6 +154,16 @@ func (in *Logger) DeepCopyInto(out *Logger) {
 		*out = new(string)
 		**out = **in
 	}
+	if in.SamplePercent != nil {
+		in, out := &in.SamplePercent, &out.SamplePercent
+		*out = new(int32)
+		**out = **in
+	}
+	if in.Redact != nil {
+		in, out := &in.Redact, &out.Redact
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Logger.
@@ -165,6 +235,36 @@ func (in *PredictiveUnit) DeepCopyInto(out *PredictiveUnit) {
 		*out = new(Logger)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.Execution != nil {
+		in, out := &in.Execution, &out.Execution
+		*out = new(ExecutionPolicy)
+		**out = **in
+	}
+	if in.CircuitBreaker != nil {
+		in, out := &in.CircuitBreaker, &out.CircuitBreaker
+		*out = new(CircuitBreaker)
+		**out = **in
+	}
+	if in.ChildrenPolicy != nil {
+		in, out := &in.ChildrenPolicy, &out.ChildrenPolicy
+		*out = new(ChildrenPolicy)
+		**out = **in
+	}
+	if in.Batching != nil {
+		in, out := &in.Batching, &out.Batching
+		*out = new(BatchingPolicy)
+		**out = **in
+	}
+	if in.Cache != nil {
+		in, out := &in.Cache, &out.Cache
+		*out = new(ResponseCachePolicy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Protocol != nil {
+		in, out := &in.Protocol, &out.Protocol
+		*out = new(Protocol)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PredictiveUnit.
@@ -302,6 +402,26 @@ func (in *PredictorSpec) DeepCopy() *PredictorSpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ResponseCachePolicy) DeepCopyInto(out *ResponseCachePolicy) {
+	*out = *in
+	if in.Headers != nil {
+		in, out := &in.Headers, &out.Headers
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResponseCachePolicy.
+func (in *ResponseCachePolicy) DeepCopy() *ResponseCachePolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(ResponseCachePolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *SSL) DeepCopyInto(out *SSL) {
 	*out = *in
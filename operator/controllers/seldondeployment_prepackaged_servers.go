@@ -101,22 +101,23 @@ func (pi *PrePackedInitialiser) addTFServerContainer(mlDepSpec *machinelearningv
 
 	c := utils.GetContainerForDeployment(deploy, pu.Name)
 
+	protocol := mlDepSpec.GetProtocol(pu)
 	var tfServingContainer *v1.Container
-	if mlDepSpec.Protocol == machinelearningv1.ProtocolTensorflow {
+	if protocol == machinelearningv1.ProtocolTensorflow {
 		tfServingContainer = c
 	} else {
-		c.Image = serverConfig.PrepackImageName(mlDepSpec.Protocol, pu)
+		c.Image = serverConfig.PrepackImageName(protocol, pu)
 		SetUriParamsForTFServingProxyContainer(pu, c)
 		tfServingContainer = utils.GetContainerForDeployment(deploy, constants.TFServingContainerName)
 	}
 
 	existing := tfServingContainer != nil
 	if !existing {
-		tfServingContainer = createTensorflowServingContainer(mlDepSpec, pu, mlDepSpec.Protocol == machinelearningv1.ProtocolTensorflow)
+		tfServingContainer = createTensorflowServingContainer(mlDepSpec, pu, protocol == machinelearningv1.ProtocolTensorflow)
 		deploy.Spec.Template.Spec.Containers = append(deploy.Spec.Template.Spec.Containers, *tfServingContainer)
 	} else {
 		// Update any missing fields
-		protoType := createTensorflowServingContainer(mlDepSpec, pu, mlDepSpec.Protocol == machinelearningv1.ProtocolTensorflow)
+		protoType := createTensorflowServingContainer(mlDepSpec, pu, protocol == machinelearningv1.ProtocolTensorflow)
 		if tfServingContainer.Image == "" {
 			tfServingContainer.Image = protoType.Image
 		}
@@ -198,7 +199,7 @@ func (pi *PrePackedInitialiser) addTritonServer(mlDepSpec *machinelearningv1.Sel
 			},
 		},
 	}
-	cServer.Image = serverConfig.PrepackImageName(mlDepSpec.Protocol, pu)
+	cServer.Image = serverConfig.PrepackImageName(mlDepSpec.GetProtocol(pu), pu)
 
 	if existing {
 		// Overwrite core items if not existing or required
@@ -422,7 +423,7 @@ func (pi *PrePackedInitialiser) createStandaloneModelServers(mlDep *machinelearn
 				}
 			default:
 				// If protocol is KFServing, try to add container with MLServer
-				if mlDep.Spec.Protocol == machinelearningv1.ProtocolKfserving {
+				if mlDep.Spec.GetProtocol(pu) == machinelearningv1.ProtocolKfserving {
 					err := pi.addMLServerDefault(pu, deploy)
 					if err != nil {
 						return err